@@ -0,0 +1,193 @@
+package airquality
+
+import (
+	"math"
+
+	"weather-service/models"
+)
+
+// Molecular weights used to convert µg/m³ to ppb at 25°C and 1 atm
+const (
+	molarVolume = 24.45
+	weightO3    = 48.00
+	weightNO2   = 46.01
+	weightSO2   = 64.07
+	weightCO    = 28.01
+)
+
+// breakpoint maps a concentration range to an AQI range
+type breakpoint struct {
+	cLow, cHigh float64
+	iLow, iHigh int
+}
+
+// US EPA breakpoints; PM in µg/m³, gases in ppb except CO in ppm
+var (
+	pm25Breakpoints = []breakpoint{
+		{0.0, 9.0, 0, 50}, {9.1, 35.4, 51, 100}, {35.5, 55.4, 101, 150},
+		{55.5, 125.4, 151, 200}, {125.5, 225.4, 201, 300}, {225.5, 325.4, 301, 500},
+	}
+	pm10Breakpoints = []breakpoint{
+		{0, 54, 0, 50}, {55, 154, 51, 100}, {155, 254, 101, 150},
+		{255, 354, 151, 200}, {355, 424, 201, 300}, {425, 604, 301, 500},
+	}
+	o3Breakpoints8h = []breakpoint{
+		{0, 54, 0, 50}, {55, 70, 51, 100}, {71, 85, 101, 150},
+		{86, 105, 151, 200}, {106, 200, 201, 300},
+	}
+	o3Breakpoints1h = []breakpoint{
+		{125, 164, 101, 150}, {165, 204, 151, 200}, {205, 404, 201, 300}, {405, 604, 301, 500},
+	}
+	no2Breakpoints = []breakpoint{
+		{0, 53, 0, 50}, {54, 100, 51, 100}, {101, 360, 101, 150},
+		{361, 649, 151, 200}, {650, 1249, 201, 300}, {1250, 2049, 301, 500},
+	}
+	so2Breakpoints = []breakpoint{
+		{0, 35, 0, 50}, {36, 75, 51, 100}, {76, 185, 101, 150},
+		{186, 304, 151, 200}, {305, 604, 201, 300}, {605, 1004, 301, 500},
+	}
+	coBreakpoints = []breakpoint{
+		{0.0, 4.4, 0, 50}, {4.5, 9.4, 51, 100}, {9.5, 12.4, 101, 150},
+		{12.5, 15.4, 151, 200}, {15.5, 30.4, 201, 300}, {30.5, 50.4, 301, 500},
+	}
+)
+
+// usCategory describes a US AQI band with its health guidance
+type usCategory struct {
+	max      int
+	name     string
+	guidance string
+}
+
+var usCategories = []usCategory{
+	{50, "Good", "Air quality is satisfactory, and air pollution poses little or no risk."},
+	{100, "Moderate", "Air quality is acceptable. Unusually sensitive people should consider reducing prolonged or heavy exertion outdoors."},
+	{150, "Unhealthy for Sensitive Groups", "People with heart or lung disease, older adults, children and teenagers should reduce prolonged or heavy exertion outdoors."},
+	{200, "Unhealthy", "Everyone may begin to experience health effects; sensitive groups should avoid prolonged or heavy exertion outdoors."},
+	{300, "Very Unhealthy", "Health alert: everyone should avoid prolonged or heavy exertion; sensitive groups should remain indoors."},
+	{500, "Hazardous", "Health warning of emergency conditions: everyone should avoid all outdoor physical activity."},
+}
+
+// euLevels are the upper bounds (µg/m³) of the European Air Quality Index levels 1-5; above the last is level 6
+var euLevels = map[string][]float64{
+	"pm25": {10, 20, 25, 50, 75},
+	"pm10": {20, 40, 50, 100, 150},
+	"no2":  {40, 90, 120, 230, 340},
+	"o3":   {50, 100, 130, 240, 380},
+	"so2":  {100, 200, 350, 500, 750},
+}
+
+var euCategories = []string{"Good", "Fair", "Moderate", "Poor", "Very poor", "Extremely poor"}
+
+// Annotate computes the US and EU indices, categories and health guidance from the pollutant concentrations.
+// Provider values are instantaneous, so they stand in for the averaging periods the indices are defined on.
+func Annotate(aq *models.AirQuality) {
+	usAQI, dominant := USAQI(aq)
+	aq.USAQI = usAQI
+	aq.DominantPollutant = dominant
+	aq.USCategory, aq.HealthGuidance = USCategory(usAQI)
+
+	aq.EUAQI = EUAQI(aq)
+	aq.EUCategory = euCategories[aq.EUAQI-1]
+}
+
+// USAQI returns the US EPA AQI and the pollutant that determines it
+func USAQI(aq *models.AirQuality) (int, string) {
+	candidates := []struct {
+		name  string
+		value float64
+		table []breakpoint
+		step  float64
+	}{
+		{"pm25", aq.PM25, pm25Breakpoints, 0.1},
+		{"pm10", aq.PM10, pm10Breakpoints, 1},
+		{"no2", aq.NO2 * molarVolume / weightNO2, no2Breakpoints, 1},
+		{"so2", aq.SO2 * molarVolume / weightSO2, so2Breakpoints, 1},
+		{"co", aq.CO * molarVolume / weightCO / 1000, coBreakpoints, 0.1},
+	}
+
+	best, dominant := ozoneSubIndex(aq.O3*molarVolume/weightO3), "o3"
+	for _, c := range candidates {
+		index := subIndex(c.value, c.table, c.step)
+		if index > best {
+			best, dominant = index, c.name
+		}
+	}
+	if best == 0 {
+		dominant = ""
+	}
+	return best, dominant
+}
+
+// ozoneSubIndex returns the larger of the 8-hour and 1-hour ozone sub-indices for a concentration in ppb,
+// each where its table applies: the 8-hour table up to 200 ppb and the 1-hour table from 125 ppb. Above
+// 200 ppb the 8-hour index stays at the top of its table, so the index never falls as ozone rises.
+func ozoneSubIndex(ppb float64) int {
+	c := math.Floor(ppb)
+
+	index8h := o3Breakpoints8h[len(o3Breakpoints8h)-1].iHigh
+	if c <= o3Breakpoints8h[len(o3Breakpoints8h)-1].cHigh {
+		index8h = subIndex(ppb, o3Breakpoints8h, 1)
+	}
+
+	index1h := 0
+	if c >= o3Breakpoints1h[0].cLow {
+		index1h = subIndex(ppb, o3Breakpoints1h, 1)
+	}
+	return max(index8h, index1h)
+}
+
+// subIndex interpolates the AQI for a concentration, truncating it to the table's precision first
+func subIndex(concentration float64, table []breakpoint, step float64) int {
+	if concentration <= 0 {
+		return 0
+	}
+	// The epsilon keeps values such as 9.1/0.1 = 90.99999999999999 from truncating into the band below
+	c := math.Floor(concentration/step+1e-9) * step
+
+	for _, bp := range table {
+		if c >= bp.cLow && c <= bp.cHigh+step/2 {
+			ratio := float64(bp.iHigh-bp.iLow) / (bp.cHigh - bp.cLow)
+			return int(math.Round(ratio*(c-bp.cLow) + float64(bp.iLow)))
+		}
+	}
+
+	return 500
+}
+
+// USCategory returns the US EPA category name and health guidance for an AQI value
+func USCategory(aqi int) (string, string) {
+	for _, c := range usCategories {
+		if aqi <= c.max {
+			return c.name, c.guidance
+		}
+	}
+	last := usCategories[len(usCategories)-1]
+	return last.name, last.guidance
+}
+
+// EUAQI returns the European Air Quality Index level (1-6), the worst level among the pollutants
+func EUAQI(aq *models.AirQuality) int {
+	values := map[string]float64{
+		"pm25": aq.PM25,
+		"pm10": aq.PM10,
+		"no2":  aq.NO2,
+		"o3":   aq.O3,
+		"so2":  aq.SO2,
+	}
+
+	worst := 1
+	for pollutant, bounds := range euLevels {
+		level := len(bounds) + 1
+		for i, bound := range bounds {
+			if values[pollutant] <= bound {
+				level = i + 1
+				break
+			}
+		}
+		if level > worst {
+			worst = level
+		}
+	}
+	return worst
+}
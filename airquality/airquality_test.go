@@ -0,0 +1,90 @@
+package airquality
+
+import (
+	"testing"
+
+	"weather-service/models"
+)
+
+func TestOzoneSubIndex(t *testing.T) {
+	tests := []struct {
+		ppb  float64
+		want int
+	}{
+		{0, 0},
+		{54, 50},
+		{70, 100},
+		{124, 220},
+		{125, 221}, // both tables apply; the 8-hour index is larger
+		{200, 300}, // top of the 8-hour table
+		{201, 300}, // only the 1-hour table applies, but the index doesn't fall
+		{404, 300}, // 1-hour "Very Unhealthy" upper bound
+		{405, 301}, // 1-hour "Hazardous"
+		{604, 500},
+		{1000, 500},
+	}
+	for _, tt := range tests {
+		if got := ozoneSubIndex(tt.ppb); got != tt.want {
+			t.Errorf("ozoneSubIndex(%v) = %d, want %d", tt.ppb, got, tt.want)
+		}
+	}
+}
+
+func TestOzoneSubIndexIsMonotonic(t *testing.T) {
+	previous := 0
+	for ppb := 0.0; ppb <= 700; ppb++ {
+		index := ozoneSubIndex(ppb)
+		if index < previous {
+			t.Fatalf("ozoneSubIndex(%v) = %d, below %d at %v ppb", ppb, index, previous, ppb-1)
+		}
+		previous = index
+	}
+}
+
+func TestSubIndexBreakpoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		value float64
+		table []breakpoint
+		step  float64
+		want  int
+	}{
+		{"pm25 zero", 0, pm25Breakpoints, 0.1, 0},
+		{"pm25 top of good", 9.0, pm25Breakpoints, 0.1, 50},
+		{"pm25 bottom of moderate", 9.1, pm25Breakpoints, 0.1, 51},
+		{"pm25 truncated to a tenth", 9.09, pm25Breakpoints, 0.1, 50},
+		{"pm25 unhealthy", 55.5, pm25Breakpoints, 0.1, 151},
+		{"pm25 beyond table", 400, pm25Breakpoints, 0.1, 500},
+		{"pm10 moderate", 154, pm10Breakpoints, 1, 100},
+		{"co ppm", 9.4, coBreakpoints, 0.1, 100},
+		{"no2 ppb", 100, no2Breakpoints, 1, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subIndex(tt.value, tt.table, tt.step); got != tt.want {
+				t.Errorf("subIndex(%v) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	aq := models.AirQuality{PM25: 40, PM10: 20, NO2: 10, O3: 30}
+	Annotate(&aq)
+
+	if aq.USAQI != 112 || aq.DominantPollutant != "pm25" {
+		t.Errorf("US AQI = %d (%s), want 112 (pm25)", aq.USAQI, aq.DominantPollutant)
+	}
+	if aq.USCategory != "Unhealthy for Sensitive Groups" || aq.HealthGuidance == "" {
+		t.Errorf("US category = %q, guidance %q", aq.USCategory, aq.HealthGuidance)
+	}
+	if aq.EUAQI != 4 || aq.EUCategory != "Poor" {
+		t.Errorf("EU AQI = %d (%s), want 4 (Poor)", aq.EUAQI, aq.EUCategory)
+	}
+
+	clean := models.AirQuality{}
+	Annotate(&clean)
+	if clean.USAQI != 0 || clean.DominantPollutant != "" || clean.USCategory != "Good" || clean.EUAQI != 1 {
+		t.Errorf("clean air annotated as %+v", clean)
+	}
+}
@@ -0,0 +1,286 @@
+package alerts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+const feed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <content type="text/xml">
+      <alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+        <identifier>flood-1</identifier>
+        <sender>met@example.org</sender>
+        <sent>2024-03-10T06:00:00+00:00</sent>
+        <status>Actual</status>
+        <msgType>Alert</msgType>
+        <info>
+          <language>de-DE</language>
+          <event>Hochwasserwarnung</event>
+          <severity>Severe</severity>
+        </info>
+        <info>
+          <language>en-GB</language>
+          <event> Flood Warning </event>
+          <urgency>Expected</urgency>
+          <severity>Severe</severity>
+          <certainty>Likely</certainty>
+          <onset>2024-03-10T08:00:00+00:00</onset>
+          <expires>2024-03-11T08:00:00+00:00</expires>
+          <headline>Flooding expected</headline>
+          <description> River levels are rising. </description>
+          <instruction>Move to higher ground.</instruction>
+          <area><areaDesc>Reading</areaDesc></area>
+          <area><areaDesc> Oxford </areaDesc></area>
+        </info>
+      </alert>
+    </content>
+  </entry>
+  <entry>
+    <content type="text/xml">
+      <alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+        <identifier>exercise-1</identifier>
+        <sent>2024-03-10T06:00:00+00:00</sent>
+        <status>Exercise</status>
+        <msgType>Alert</msgType>
+        <info><event>Drill</event></info>
+      </alert>
+    </content>
+  </entry>
+  <entry>
+    <content type="text/xml">
+      <alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+        <identifier>cancel-1</identifier>
+        <sent>2024-03-10T06:00:00+00:00</sent>
+        <status>Actual</status>
+        <msgType>Cancel</msgType>
+        <info><event>Wind Warning</event></info>
+      </alert>
+    </content>
+  </entry>
+  <entry>
+    <content type="text/xml">
+      <alert xmlns="urn:oasis:names:tc:emergency:cap:1.2">
+        <identifier>wind-1</identifier>
+        <sent>2024-03-10T07:00:00+00:00</sent>
+        <status>Actual</status>
+        <msgType>Update</msgType>
+        <info>
+          <event>Wind Warning</event>
+          <severity>Moderate</severity>
+          <expires>not a time</expires>
+        </info>
+      </alert>
+    </content>
+  </entry>
+</feed>`
+
+func TestParseCAP(t *testing.T) {
+	alerts, err := ParseCAP([]byte(feed), "met")
+	if err != nil {
+		t.Fatalf("ParseCAP: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want the flood and wind alerts: %+v", len(alerts), alerts)
+	}
+
+	flood := alerts[0]
+	if flood.ID != "flood-1" || flood.Event != "Flood Warning" || flood.Headline != "Flooding expected" {
+		t.Errorf("flood alert = %+v, want the English info block", flood)
+	}
+	if flood.Severity != "Severe" || flood.Urgency != "Expected" || flood.Certainty != "Likely" {
+		t.Errorf("flood severity, urgency, certainty = %q, %q, %q", flood.Severity, flood.Urgency, flood.Certainty)
+	}
+	if flood.Area != "Reading; Oxford" {
+		t.Errorf("flood area = %q, want %q", flood.Area, "Reading; Oxford")
+	}
+	if want := time.Date(2024, 3, 10, 8, 0, 0, 0, time.UTC); !flood.Effective.Equal(want) {
+		t.Errorf("flood effective = %v, want the onset %v", flood.Effective, want)
+	}
+	if want := time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC); !flood.Expires.Equal(want) {
+		t.Errorf("flood expires = %v, want %v", flood.Expires, want)
+	}
+	if flood.Description != "River levels are rising." || flood.Instruction != "Move to higher ground." {
+		t.Errorf("flood description, instruction = %q, %q", flood.Description, flood.Instruction)
+	}
+	if len(flood.Sources) != 1 || flood.Sources[0] != "met" {
+		t.Errorf("flood sources = %v, want [met]", flood.Sources)
+	}
+
+	wind := alerts[1]
+	if want := time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC); !wind.Effective.Equal(want) {
+		t.Errorf("wind effective = %v, want the sent time %v", wind.Effective, want)
+	}
+	if !wind.Expires.IsZero() {
+		t.Errorf("wind expires = %v, want zero for a malformed time", wind.Expires)
+	}
+}
+
+func TestParseCAPRejectsMalformedXML(t *testing.T) {
+	if _, err := ParseCAP([]byte("<alert><identifier>x</alert>"), "met"); err == nil {
+		t.Error("ParseCAP accepted malformed XML")
+	}
+}
+
+func TestCAPFeedFetchFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "feed.xml")
+	if err := os.WriteFile(file, []byte(feed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewCAPFeed("", "", file, "Reading,UK")
+	if f.Name() != file {
+		t.Errorf("Name() = %q, want the file path", f.Name())
+	}
+	alerts, err := f.Fetch(context.Background())
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	for _, alert := range alerts {
+		if alert.Location != "Reading,UK" || alert.Sources[0] != file {
+			t.Errorf("alert %s location, sources = %q, %v", alert.ID, alert.Location, alert.Sources)
+		}
+	}
+
+	if _, err := NewCAPFeed("missing", "", filepath.Join(t.TempDir(), "missing.xml"), "").Fetch(context.Background()); err == nil {
+		t.Error("Fetch of a missing file succeeded")
+	}
+}
+
+// alert returns an alert reported by source that took effect an hour ago and expires after expiresIn
+func alert(id, source, event, area, severity string, expiresIn time.Duration) models.Alert {
+	now := time.Now().Truncate(time.Minute)
+	return models.Alert{
+		ID:        id,
+		Sources:   []string{source},
+		Event:     event,
+		Area:      area,
+		Severity:  severity,
+		Effective: now.Add(-time.Hour),
+		Expires:   now.Add(expiresIn),
+	}
+}
+
+func TestStoreMergesDuplicates(t *testing.T) {
+	store := NewStore()
+
+	first := alert("a-1", "met", "Flood Warning", "Reading; Oxford", "Severe", time.Hour)
+	first.Sources = append(make([]string, 0, 4), "met")
+	second := alert("b-7", "nws", "FLOOD  warning", "Reading, Oxford", "Severe", time.Hour)
+	second.Location = "Reading,UK"
+	second.Instruction = "Move to higher ground."
+	// Sources format times differently; seconds don't make a different alert
+	second.Expires = second.Expires.Add(20 * time.Second)
+
+	store.AddAlerts([]models.Alert{first})
+	store.AddAlerts([]models.Alert{second, second})
+
+	active := store.Active()
+	if len(active) != 1 {
+		t.Fatalf("got %d alerts, want one merged alert: %+v", len(active), active)
+	}
+	merged := active[0]
+	if merged.ID != "a-1" {
+		t.Errorf("ID = %q, want the first report's", merged.ID)
+	}
+	if len(merged.Sources) != 2 || merged.Sources[0] != "met" || merged.Sources[1] != "nws" {
+		t.Errorf("Sources = %v, want [met nws]", merged.Sources)
+	}
+	if merged.Location != "Reading,UK" || merged.Instruction != "Move to higher ground." {
+		t.Errorf("location, instruction = %q, %q, want them filled from the duplicate", merged.Location, merged.Instruction)
+	}
+
+	// Merges don't write into the spare capacity of the caller's slice
+	if spare := first.Sources[:2]; spare[1] != "" {
+		t.Errorf("caller's sources changed to %v", spare)
+	}
+}
+
+func TestStoreDropsExpiredAlerts(t *testing.T) {
+	store := NewStore()
+	store.AddAlerts([]models.Alert{
+		alert("expired", "met", "Wind Warning", "Oxford", "Minor", -time.Minute),
+		alert("soon", "met", "Frost", "Oxford", "Minor", time.Hour),
+	})
+
+	// An alert without an expiry time never expires
+	open := alert("open", "met", "Drought", "Oxford", "Minor", 0)
+	open.Expires = time.Time{}
+	store.AddAlerts([]models.Alert{open})
+
+	active := store.Active()
+	ids := make(map[string]bool)
+	for _, a := range active {
+		ids[a.ID] = true
+	}
+	if ids["expired"] || !ids["soon"] || len(active) != 2 {
+		t.Errorf("active alerts = %v, want soon and the open-ended alert", ids)
+	}
+
+	// An alert that expires while stored is pruned
+	store.mutex.Lock()
+	for key, a := range store.alerts {
+		if a.ID == "soon" {
+			a.Expires = time.Now().Add(-time.Second)
+			store.alerts[key] = a
+		}
+	}
+	store.mutex.Unlock()
+	if removed := store.PruneExpired(); removed != 1 {
+		t.Errorf("PruneExpired() = %d, want 1", removed)
+	}
+	if active := store.Active(); len(active) != 1 || !active[0].Expires.IsZero() {
+		t.Errorf("after pruning, active = %+v, want only the open-ended alert", active)
+	}
+}
+
+func TestStoreForLocation(t *testing.T) {
+	store := NewStore()
+	fetched := alert("fetched", "nws", "Heat Advisory", "Thames Valley", "Moderate", time.Hour)
+	fetched.Location = "Reading,UK"
+	store.AddAlerts([]models.Alert{
+		fetched,
+		alert("named", "met", "Flood Warning", "Oxford; Reading", "Severe", time.Hour),
+		alert("substring", "met", "Fog", "Readington Township", "Minor", time.Hour),
+		alert("elsewhere", "met", "Snow", "Aberdeen", "Extreme", time.Hour),
+	})
+
+	var ids []string
+	for _, a := range store.ForLocation("reading,uk") {
+		ids = append(ids, a.ID)
+	}
+	if len(ids) != 2 || ids[0] != "named" || ids[1] != "fetched" {
+		t.Errorf("ForLocation = %v, want [named fetched]", ids)
+	}
+}
+
+func TestStoreOrdersBySeverity(t *testing.T) {
+	store := NewStore()
+	later := alert("later", "met", "Gale", "Oxford", "Severe", time.Hour)
+	later.Effective = later.Effective.Add(30 * time.Minute)
+	store.AddAlerts([]models.Alert{
+		alert("unknown", "met", "Notice", "Oxford", "Unknown", time.Hour),
+		alert("minor", "met", "Fog", "Oxford", "Minor", time.Hour),
+		later,
+		alert("extreme", "met", "Tornado", "Oxford", "Extreme", time.Hour),
+		alert("earlier", "met", "Flood", "Oxford", "severe", time.Hour),
+		alert("moderate", "met", "Heat", "Oxford", "Moderate", time.Hour),
+	})
+
+	want := []string{"extreme", "earlier", "later", "moderate", "minor", "unknown"}
+	active := store.Active()
+	if len(active) != len(want) {
+		t.Fatalf("got %d alerts, want %d", len(active), len(want))
+	}
+	for i, a := range active {
+		if a.ID != want[i] {
+			t.Errorf("alert %d = %s, want %s", i, a.ID, want[i])
+		}
+	}
+}
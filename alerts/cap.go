@@ -0,0 +1,217 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"weather-service/models"
+)
+
+// capAlert mirrors the parts of a CAP 1.2 <alert> element that we use
+type capAlert struct {
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Info       []capInfo `xml:"info"`
+}
+
+// capInfo mirrors a CAP 1.2 <info> block
+type capInfo struct {
+	Language    string `xml:"language"`
+	Event       string `xml:"event"`
+	Urgency     string `xml:"urgency"`
+	Severity    string `xml:"severity"`
+	Certainty   string `xml:"certainty"`
+	Effective   string `xml:"effective"`
+	Onset       string `xml:"onset"`
+	Expires     string `xml:"expires"`
+	Headline    string `xml:"headline"`
+	Description string `xml:"description"`
+	Instruction string `xml:"instruction"`
+	Areas       []struct {
+		AreaDesc string `xml:"areaDesc"`
+	} `xml:"area"`
+}
+
+// ParseCAP extracts alerts from CAP 1.2 XML. It accepts a single <alert> document
+// as well as feeds (e.g. Atom) that embed <alert> elements anywhere in their body.
+// Only actual alerts and updates are returned; tests, exercises and cancellations are skipped.
+func ParseCAP(data []byte, source string) ([]models.Alert, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var alerts []models.Alert
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CAP XML: %w", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "alert" {
+			continue
+		}
+
+		var alert capAlert
+		if err := decoder.DecodeElement(&alert, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse CAP alert: %w", err)
+		}
+
+		if converted, ok := convertCAP(alert, source); ok {
+			alerts = append(alerts, converted)
+		}
+	}
+
+	return alerts, nil
+}
+
+// convertCAP turns a CAP alert into our model, preferring the English info block
+func convertCAP(alert capAlert, source string) (models.Alert, bool) {
+	if !strings.EqualFold(alert.Status, "Actual") || strings.EqualFold(alert.MsgType, "Cancel") {
+		return models.Alert{}, false
+	}
+	if len(alert.Info) == 0 {
+		return models.Alert{}, false
+	}
+
+	info := alert.Info[0]
+	for _, candidate := range alert.Info {
+		if strings.HasPrefix(strings.ToLower(candidate.Language), "en") {
+			info = candidate
+			break
+		}
+	}
+
+	areas := make([]string, 0, len(info.Areas))
+	for _, area := range info.Areas {
+		areas = append(areas, strings.TrimSpace(area.AreaDesc))
+	}
+
+	// CAP allows effective to be omitted, in which case the sent time applies
+	effective := parseCAPTime(info.Effective)
+	if effective.IsZero() {
+		effective = parseCAPTime(info.Onset)
+	}
+	if effective.IsZero() {
+		effective = parseCAPTime(alert.Sent)
+	}
+
+	return models.Alert{
+		ID:          alert.Identifier,
+		Sources:     []string{source},
+		Event:       strings.TrimSpace(info.Event),
+		Headline:    strings.TrimSpace(info.Headline),
+		Severity:    info.Severity,
+		Urgency:     info.Urgency,
+		Certainty:   info.Certainty,
+		Area:        strings.Join(areas, "; "),
+		Effective:   effective,
+		Expires:     parseCAPTime(info.Expires),
+		Description: strings.TrimSpace(info.Description),
+		Instruction: strings.TrimSpace(info.Instruction),
+	}, true
+}
+
+// parseCAPTime parses a CAP date-time, returning the zero time if it is missing or malformed
+func parseCAPTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// CAPFeed is a CAP 1.2 alert feed read from a URL or a local file
+type CAPFeed struct {
+	name     string
+	url      string
+	file     string
+	location string
+	client   *http.Client
+}
+
+// NewCAPFeed creates a feed; exactly one of url or file should be set.
+// If location is set, every alert from the feed is attributed to it.
+func NewCAPFeed(name, url, file, location string) *CAPFeed {
+	if name == "" {
+		name = url + file
+	}
+	return &CAPFeed{
+		name:     name,
+		url:      url,
+		file:     file,
+		location: location,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// Name returns the feed name
+func (f *CAPFeed) Name() string {
+	return f.name
+}
+
+// Fetch reads and parses the feed
+func (f *CAPFeed) Fetch(ctx context.Context) ([]models.Alert, error) {
+	var data []byte
+	var err error
+
+	if f.file != "" {
+		data, err = os.ReadFile(f.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CAP file: %w", err)
+		}
+	} else {
+		data, err = f.download(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	alerts, err := ParseCAP(data, f.name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range alerts {
+		alerts[i].Location = f.location
+	}
+	return alerts, nil
+}
+
+// download fetches the feed over HTTP
+func (f *CAPFeed) download(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", f.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/cap+xml, application/atom+xml, application/xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned non-200 status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, nil
+}
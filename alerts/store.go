@@ -0,0 +1,175 @@
+package alerts
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// Store holds active alerts, merging duplicates reported by different sources
+type Store struct {
+	alerts map[string]models.Alert // key is the deduplication key
+	mutex  sync.RWMutex
+}
+
+// NewStore creates a new in-memory alert store
+func NewStore() *Store {
+	return &Store{
+		alerts: make(map[string]models.Alert),
+	}
+}
+
+// dedupKey identifies the same alert across sources by event, area and validity period.
+// Sources format text differently, so the key uses normalized text and minute-level times.
+func dedupKey(alert models.Alert) string {
+	return strings.Join([]string{
+		normalize(alert.Event),
+		normalize(alert.Area),
+		alert.Effective.UTC().Truncate(time.Minute).Format(time.RFC3339),
+		alert.Expires.UTC().Truncate(time.Minute).Format(time.RFC3339),
+	}, "|")
+}
+
+// normalize lowercases text and collapses whitespace and punctuation
+func normalize(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(fields, " ")
+}
+
+// AddAlerts stores alerts, merging any that are already known and dropping expired ones
+func (s *Store) AddAlerts(alerts []models.Alert) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, alert := range alerts {
+		if isExpired(alert, now) {
+			continue
+		}
+
+		key := dedupKey(alert)
+		existing, found := s.alerts[key]
+		if !found {
+			// Copy the sources so later merges don't write into the caller's slice
+			alert.Sources = append([]string(nil), alert.Sources...)
+			s.alerts[key] = alert
+			continue
+		}
+
+		// Keep the first report but remember every source that sent it
+		for _, source := range alert.Sources {
+			if !containsString(existing.Sources, source) {
+				existing.Sources = append(existing.Sources, source)
+			}
+		}
+		if existing.Location == "" {
+			existing.Location = alert.Location
+		}
+		if existing.Instruction == "" {
+			existing.Instruction = alert.Instruction
+		}
+		s.alerts[key] = existing
+	}
+
+	s.pruneLocked(now)
+}
+
+// PruneExpired removes alerts whose expiry time has passed and returns how many were removed
+func (s *Store) PruneExpired() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pruneLocked(time.Now())
+}
+
+// pruneLocked removes expired alerts; the caller must hold the write lock
+func (s *Store) pruneLocked(now time.Time) int {
+	removed := 0
+	for key, alert := range s.alerts {
+		if isExpired(alert, now) {
+			delete(s.alerts, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Active returns all alerts that haven't expired, most severe first
+func (s *Store) Active() []models.Alert {
+	return s.filter(func(models.Alert) bool { return true })
+}
+
+// ForLocation returns active alerts fetched for a location or whose area mentions its place name
+func (s *Store) ForLocation(location string) []models.Alert {
+	place := normalize(strings.Split(location, ",")[0])
+	return s.filter(func(alert models.Alert) bool {
+		if strings.EqualFold(alert.Location, location) {
+			return true
+		}
+		return place != "" && strings.Contains(" "+normalize(alert.Area)+" ", " "+place+" ")
+	})
+}
+
+// filter returns active alerts matching the predicate in a stable order
+func (s *Store) filter(match func(models.Alert) bool) []models.Alert {
+	now := time.Now()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make([]models.Alert, 0)
+	for _, alert := range s.alerts {
+		if !isExpired(alert, now) && match(alert) {
+			result = append(result, alert)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if severityRank(a.Severity) != severityRank(b.Severity) {
+			return severityRank(a.Severity) < severityRank(b.Severity)
+		}
+		if !a.Effective.Equal(b.Effective) {
+			return a.Effective.Before(b.Effective)
+		}
+		return a.ID < b.ID
+	})
+
+	return result
+}
+
+// isExpired reports whether an alert's expiry time has passed; open-ended alerts never expire
+func isExpired(alert models.Alert, now time.Time) bool {
+	return !alert.Expires.IsZero() && !alert.Expires.After(now)
+}
+
+// severityRank orders CAP severities from most to least severe
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "extreme":
+		return 0
+	case "severe":
+		return 1
+	case "moderate":
+		return 2
+	case "minor":
+		return 3
+	default:
+		return 4
+	}
+}
+
+// containsString reports whether a slice contains a string
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
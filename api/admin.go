@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/datasource"
+	"weather-service/popularity"
+)
+
+// RegisterNegativeCache sets the cache of provider errors that admins can inspect and clear
+func (s *Server) RegisterNegativeCache(negative *cache.NegativeCache) {
+	s.negativeCache = negative
+}
+
+// handleNegativeCache reports negative cache counts on GET and clears cached errors on DELETE,
+// optionally only those of one kind
+func (s *Server) handleNegativeCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.negativeCache == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Negative caching is not enabled",
+		})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kinds":     s.negativeCache.Stats(),
+			"timestamp": time.Now(),
+		})
+		return
+	}
+
+	kind := datasource.ErrorKind(r.URL.Query().Get("kind"))
+	switch kind {
+	case "", datasource.ErrorNotFound, datasource.ErrorAuth, datasource.ErrorRateLimited, datasource.ErrorUpstream:
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Unknown error kind: %s", kind),
+		})
+		return
+	}
+
+	removed := s.negativeCache.Clear(kind)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"cleared":   removed,
+		"kind":      kind,
+		"timestamp": time.Now(),
+	})
+}
+
+// RegisterPopularity sets the tracker that counts requests per location and picks popular ones to refresh
+func (s *Server) RegisterPopularity(tracker *popularity.Tracker) {
+	s.popular = tracker
+}
+
+// handlePopularLocations reports the dynamic refresh set and the calls it uses
+func (s *Server) handlePopularLocations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.popular == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Popular location refresh is not enabled",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"popular":   s.popular.Stats(time.Now()),
+		"timestamp": time.Now(),
+	})
+}
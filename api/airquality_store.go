@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// AirQualityStore holds the latest air quality data organized by location and provider
+type AirQualityStore struct {
+	data  map[string]map[string]models.AirQuality // key is normalized location, then provider
+	mutex sync.RWMutex
+}
+
+// NewAirQualityStore creates a new in-memory air quality data store
+func NewAirQualityStore() *AirQualityStore {
+	return &AirQualityStore{
+		data: make(map[string]map[string]models.AirQuality),
+	}
+}
+
+// UpdateAirQuality adds or updates air quality data for a location
+func (s *AirQualityStore) UpdateAirQuality(data models.AirQuality) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	location := models.NormalizeLocation(data.Location)
+	if _, exists := s.data[location]; !exists {
+		s.data[location] = make(map[string]models.AirQuality)
+	}
+	s.data[location][data.Provider] = data
+}
+
+// GetAirQualityByLocation retrieves all air quality data for a location, ordered by provider name
+func (s *AirQualityStore) GetAirQualityByLocation(location string) ([]models.AirQuality, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	providerMap, exists := s.data[models.NormalizeLocation(location)]
+	if !exists {
+		return nil, false
+	}
+
+	readings := make([]models.AirQuality, 0, len(providerMap))
+	for _, aq := range providerMap {
+		readings = append(readings, aq)
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		return readings[i].Provider < readings[j].Provider
+	})
+
+	return readings, true
+}
+
+// PruneOldAirQuality removes readings fetched longer ago than maxAge, such as those of popular locations
+// that are no longer refreshed, and returns how many were removed
+func (s *AirQualityStore) PruneOldAirQuality(maxAge time.Duration) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	prunedCount := 0
+	for location, providers := range s.data {
+		for provider, aq := range providers {
+			if aq.FetchedAt.Before(cutoff) {
+				delete(providers, provider)
+				prunedCount++
+			}
+		}
+		if len(providers) == 0 {
+			delete(s.data, location)
+		}
+	}
+	return prunedCount
+}
+
+// Snapshot encodes the stored air quality readings as JSON
+func (s *AirQualityStore) Snapshot() (json.RawMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	readings := []models.AirQuality{}
+	for _, providers := range s.data {
+		for _, aq := range providers {
+			readings = append(readings, aq)
+		}
+	}
+	return json.Marshal(readings)
+}
+
+// Restore replaces the stored readings with a snapshot
+func (s *AirQualityStore) Restore(data json.RawMessage) error {
+	var readings []models.AirQuality
+	if err := json.Unmarshal(data, &readings); err != nil {
+		return fmt.Errorf("failed to parse air quality snapshot: %w", err)
+	}
+
+	restored := make(map[string]map[string]models.AirQuality)
+	for _, aq := range readings {
+		location := models.NormalizeLocation(aq.Location)
+		if _, exists := restored[location]; !exists {
+			restored[location] = make(map[string]models.AirQuality)
+		}
+		restored[location][aq.Provider] = aq
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data = restored
+	return nil
+}
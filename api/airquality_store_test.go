@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+func TestAirQualityStoreNormalizesLocations(t *testing.T) {
+	store := NewAirQualityStore()
+	store.UpdateAirQuality(models.AirQuality{Provider: "A", Location: "London,UK", FetchedAt: time.Now()})
+	store.UpdateAirQuality(models.AirQuality{Provider: "B", Location: " london, uk ", FetchedAt: time.Now()})
+
+	readings, exists := store.GetAirQualityByLocation("LONDON,UK")
+	if !exists || len(readings) != 2 || readings[0].Provider != "A" || readings[1].Provider != "B" {
+		t.Fatalf("readings = %+v, %v; want A and B", readings, exists)
+	}
+}
+
+func TestAirQualityStorePrune(t *testing.T) {
+	store := NewAirQualityStore()
+	now := time.Now()
+	store.UpdateAirQuality(models.AirQuality{Provider: "A", Location: "London,UK", FetchedAt: now})
+	store.UpdateAirQuality(models.AirQuality{Provider: "B", Location: "London,UK", FetchedAt: now.Add(-2 * time.Hour)})
+	store.UpdateAirQuality(models.AirQuality{Provider: "A", Location: "Paris,FR", FetchedAt: now.Add(-2 * time.Hour)})
+
+	if pruned := store.PruneOldAirQuality(time.Hour); pruned != 2 {
+		t.Errorf("pruned %d readings, want 2", pruned)
+	}
+	if readings, _ := store.GetAirQualityByLocation("London,UK"); len(readings) != 1 || readings[0].Provider != "A" {
+		t.Errorf("London readings = %+v, want only A", readings)
+	}
+	if _, exists := store.GetAirQualityByLocation("Paris,FR"); exists {
+		t.Error("Paris should have been removed with its last reading")
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"weather-service/alerts"
+	"weather-service/auth"
+	"weather-service/models"
+	"weather-service/verification"
+)
+
+func TestLocationRestrictedKeys(t *testing.T) {
+	keys := auth.NewKeyStore()
+	if _, err := keys.AddEntry(auth.KeyEntry{Name: "london", Key: "secret", Locations: []string{"London,UK"}}); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(NewWeatherStore(), NewForecastStore(), 0)
+	server.RegisterKeyStore(keys)
+	server.SetAuthRequired(true)
+
+	tests := []struct {
+		path      string
+		forbidden bool
+	}{
+		{"/weather/location/London,UK", false},
+		{"/weather/location/london,%20uk", false},
+		{"/weather/location/Paris,FR", true},
+		{"/weather/location/Paris,FR/history", true},
+		{"/weather/locations", false},
+		{"/alerts/active", false},
+		{"/stats/accuracy", false},
+		{"/stats/accuracy?location=London,UK", false},
+		{"/stats/accuracy?location=Paris,FR", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, r)
+		if forbidden := w.Code == http.StatusForbidden; forbidden != tt.forbidden || w.Code == http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want forbidden %v (%s)", tt.path, w.Code, tt.forbidden, w.Body.String())
+		}
+	}
+}
+
+func TestLocationRestrictedListings(t *testing.T) {
+	keys := auth.NewKeyStore()
+	if _, err := keys.AddEntry(auth.KeyEntry{Name: "london", Key: "secret", Locations: []string{"London,UK"}}); err != nil {
+		t.Fatal(err)
+	}
+	issued := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	weatherStore := NewWeatherStore()
+	verifier := verification.NewVerifier(verification.DefaultOptions())
+	for _, location := range []string{"London,UK", "Paris,FR"} {
+		weatherStore.UpdateWeather(models.WeatherData{Provider: "Fake", Location: location, Timestamp: time.Now()})
+		verifier.RecordForecast(models.ForecastData{
+			Provider: "Fake", Location: location, Updated: issued,
+			Forecasts: []models.Forecast{{Timestamp: issued.Add(3 * time.Hour)}},
+		})
+		verifier.RecordObservation(models.WeatherData{
+			Provider: "Fake", Location: location, ObservedAt: issued.Add(3 * time.Hour), Timestamp: issued.Add(4 * time.Hour),
+		})
+	}
+	alertStore := alerts.NewStore()
+	alertStore.AddAlerts([]models.Alert{
+		{ID: "1", Location: "London,UK", Event: "Flood Warning", Effective: time.Now()},
+		{ID: "2", Location: "Paris,FR", Event: "Wind Warning", Effective: time.Now()},
+		{ID: "3", Event: "Heat Advisory", Effective: time.Now()},
+	})
+
+	server := NewServer(weatherStore, NewForecastStore(), 0)
+	server.RegisterKeyStore(keys)
+	server.RegisterAlertStore(alertStore)
+	server.RegisterVerifier(verifier)
+	server.SetAuthRequired(true)
+
+	var listing struct {
+		Locations []string               `json:"locations"`
+		Alerts    []models.Alert         `json:"alerts"`
+		Stats     []models.AccuracyStats `json:"stats"`
+	}
+	for _, path := range []string{"/weather/locations", "/alerts/active", "/stats/accuracy"} {
+		w := get(server, path, map[string]string{"X-API-Key": "secret"})
+		if err := json.NewDecoder(w.Body).Decode(&listing); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, %v", path, w.Code, err)
+		}
+	}
+	if len(listing.Locations) != 1 || listing.Locations[0] != "london,uk" {
+		t.Errorf("locations = %v, want only London", listing.Locations)
+	}
+	if len(listing.Alerts) != 1 || listing.Alerts[0].ID != "1" {
+		t.Errorf("alerts = %+v, want only the London alert", listing.Alerts)
+	}
+	if len(listing.Stats) != 1 || listing.Stats[0].Location != "london,uk" {
+		t.Errorf("stats = %+v, want only London's", listing.Stats)
+	}
+
+	// Unrestricted callers see everything
+	server.SetAuthRequired(false)
+	if err := json.NewDecoder(get(server, "/alerts/active", nil).Body).Decode(&listing); err != nil || len(listing.Alerts) != 3 {
+		t.Errorf("alerts without authentication = %+v, %v; want all three", listing.Alerts, err)
+	}
+}
+
+func TestWithAuthStatuses(t *testing.T) {
+	keys := auth.NewKeyStore()
+	expired := time.Now().Add(-time.Hour)
+	for _, entry := range []auth.KeyEntry{
+		{Name: "reader", Key: "reader-secret"},
+		{Name: "revoked", Key: "revoked-secret", Revoked: true},
+		{Name: "expired", Key: "expired-secret", ExpiresAt: &expired},
+		{Name: "limited", Key: "limited-secret", DailyQuota: 1},
+	} {
+		if _, err := keys.AddEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewServer(NewWeatherStore(), NewForecastStore(), 0)
+	server.RegisterKeyStore(keys)
+	server.SetAuthRequired(true)
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"public endpoint", "/health", nil, http.StatusOK},
+		{"missing key", "/weather/locations", nil, http.StatusUnauthorized},
+		{"unknown key", "/weather/locations", map[string]string{"X-API-Key": "guess"}, http.StatusUnauthorized},
+		{"other scheme", "/weather/locations", map[string]string{"Authorization": "Basic reader-secret"}, http.StatusUnauthorized},
+		{"header key", "/weather/locations", map[string]string{"X-API-Key": " reader-secret "}, http.StatusOK},
+		{"bearer token", "/weather/locations", map[string]string{"Authorization": "bearer reader-secret"}, http.StatusOK},
+		{"header before bearer", "/weather/locations", map[string]string{"X-API-Key": "reader-secret", "Authorization": "Bearer guess"}, http.StatusOK},
+		{"revoked key", "/weather/locations", map[string]string{"X-API-Key": "revoked-secret"}, http.StatusForbidden},
+		{"expired key", "/weather/locations", map[string]string{"X-API-Key": "expired-secret"}, http.StatusForbidden},
+		{"missing scope", "/admin/keys", map[string]string{"X-API-Key": "reader-secret"}, http.StatusForbidden},
+		{"within quota", "/weather/locations", map[string]string{"X-API-Key": "limited-secret"}, http.StatusOK},
+		{"over quota", "/weather/locations", map[string]string{"X-API-Key": "limited-secret"}, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		w := get(server, tt.path, tt.headers)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+		challenge := w.Header().Get("WWW-Authenticate")
+		if (w.Code == http.StatusUnauthorized) != (challenge != "") {
+			t.Errorf("%s: status %d with WWW-Authenticate %q", tt.name, w.Code, challenge)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: 429 without Retry-After", tt.name)
+		}
+	}
+}
+
+func TestAdminEndpointsNeedAKeyWithoutAuth(t *testing.T) {
+	keys := auth.NewKeyStore()
+	for _, entry := range []auth.KeyEntry{
+		{Name: "reader", Key: "reader-secret"},
+		{Name: "operator", Key: "admin-secret", Scopes: []auth.Scope{auth.ScopeAdmin}},
+	} {
+		if _, err := keys.AddEntry(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := NewServer(NewWeatherStore(), NewForecastStore(), 0)
+	server.RegisterKeyStore(keys)
+
+	tests := []struct {
+		path string
+		key  string
+		want int
+	}{
+		{"/weather/locations", "", http.StatusOK},
+		{"/admin/keys", "", http.StatusUnauthorized},
+		{"/admin/popular", "", http.StatusUnauthorized},
+		{"/admin/cache/negative", "", http.StatusUnauthorized},
+		{"/admin/keys", "reader-secret", http.StatusForbidden},
+		{"/admin/keys", "admin-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := get(server, tt.path, map[string]string{"X-API-Key": tt.key})
+		if w.Code != tt.want {
+			t.Errorf("%s with key %q: status %d, want %d (%s)", tt.path, tt.key, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
@@ -1,105 +1,87 @@
 package api
 
 import (
-	"sync"
+	"log"
 	"time"
 
 	"weather-service/models"
+	"weather-service/storage"
 )
 
 // ForecastStore holds the latest forecast data organized by location and provider
 type ForecastStore struct {
-	data  map[string]map[string]models.ForecastData // key is location, then provider
-	mutex sync.RWMutex
+	backend storage.ForecastBackend
 }
 
 // NewForecastStore creates a new in-memory forecast data store
 func NewForecastStore() *ForecastStore {
+	return NewForecastStoreWithBackend(storage.NewMemoryBackend())
+}
+
+// NewForecastStoreWithBackend creates a forecast data store on top of a storage backend
+func NewForecastStoreWithBackend(backend storage.ForecastBackend) *ForecastStore {
 	return &ForecastStore{
-		data: make(map[string]map[string]models.ForecastData),
+		backend: backend,
 	}
 }
 
-// UpdateForecast adds or updates forecast data for a location
+// UpdateForecast adds or updates forecast data for a location, kept under the normalized location
 func (s *ForecastStore) UpdateForecast(data models.ForecastData) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	location := data.Location
-	provider := data.Provider
-
-	// Check if we already have data for this location
-	if _, exists := s.data[location]; !exists {
-		s.data[location] = make(map[string]models.ForecastData)
+	data.Location = models.NormalizeLocation(data.Location)
+	if err := s.backend.PutForecast(data); err != nil {
+		log.Printf("Error storing forecast for %s from %s: %v", data.Location, data.Provider, err)
 	}
-
-	// Store the forecast data
-	s.data[location][provider] = data
 }
 
-// GetForecastByLocation retrieves all forecast data for a specific location
+// GetForecastByLocation retrieves all forecast data for a specific location, ordered by provider name
 func (s *ForecastStore) GetForecastByLocation(location string) ([]models.ForecastData, bool) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	providerMap, exists := s.data[location]
-	if !exists {
+	forecasts, err := s.backend.GetForecasts(models.NormalizeLocation(location))
+	if err != nil {
+		log.Printf("Error reading forecasts for %s: %v", location, err)
 		return nil, false
 	}
-
-	forecasts := make([]models.ForecastData, 0, len(providerMap))
-	for _, forecast := range providerMap {
-		forecasts = append(forecasts, forecast)
+	if len(forecasts) == 0 {
+		return nil, false
 	}
-
 	return forecasts, true
 }
 
 // GetForecastByProvider retrieves forecast data for a specific location and provider
 func (s *ForecastStore) GetForecastByProvider(location, provider string) (models.ForecastData, bool) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	providerMap, exists := s.data[location]
-	if !exists {
+	forecast, exists, err := s.backend.GetForecast(models.NormalizeLocation(location), provider)
+	if err != nil {
+		log.Printf("Error reading forecast for %s from %s: %v", location, provider, err)
 		return models.ForecastData{}, false
 	}
-
-	forecast, exists := providerMap[provider]
 	return forecast, exists
 }
 
 // GetAllForecastLocations returns a list of all locations with forecast data
 func (s *ForecastStore) GetAllForecastLocations() []string {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	locations := make([]string, 0, len(s.data))
-	for loc := range s.data {
-		locations = append(locations, loc)
+	locations, err := s.backend.ForecastLocations()
+	if err != nil {
+		log.Printf("Error listing forecast locations: %v", err)
+		return []string{}
 	}
 	return locations
 }
 
 // PruneOldForecasts removes forecasts older than the specified duration
 func (s *ForecastStore) PruneOldForecasts(maxAge time.Duration) int {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
 	cutoff := time.Now().Add(-maxAge)
 	prunedCount := 0
 
-	for location, providers := range s.data {
-		for provider, forecast := range providers {
-			if forecast.Updated.Before(cutoff) {
-				delete(s.data[location], provider)
-				prunedCount++
+	for _, location := range s.GetAllForecastLocations() {
+		forecasts, _ := s.GetForecastByLocation(location)
+		for _, forecast := range forecasts {
+			if !forecast.Updated.Before(cutoff) {
+				continue
 			}
-		}
-
-		// If location has no more forecasts, remove it
-		if len(s.data[location]) == 0 {
-			delete(s.data, location)
+			if err := s.backend.DeleteForecast(location, forecast.Provider); err != nil {
+				log.Printf("Error pruning forecast for %s from %s: %v", location, forecast.Provider, err)
+				continue
+			}
+			prunedCount++
 		}
 	}
 
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"weather-service/models"
+)
+
+// revision identifies one stored record behind a response
+type revision struct {
+	key      string    // provider and freshness state, which decides how the record is served
+	modified time.Time // when the data was observed or issued
+	fetched  time.Time // when the service fetched it, which sets when it is next refreshed; Last-Modified is the later
+}
+
+// SetRefreshInterval sets how often stored data is refreshed, which bounds how long clients may cache responses
+func (s *Server) SetRefreshInterval(interval time.Duration) {
+	s.refreshInterval = interval
+}
+
+// weatherRevisions describes served weather readings for conditional requests
+func weatherRevisions(data []models.WeatherData) []revision {
+	revisions := make([]revision, 0, len(data))
+	for _, d := range data {
+		fetched := d.FetchedAt
+		if fetched.IsZero() {
+			fetched = d.Timestamp
+		}
+		revisions = append(revisions, revision{
+			key:      d.Provider + "|" + freshnessState(d.Freshness),
+			modified: d.ObservationTime(),
+			fetched:  fetched,
+		})
+	}
+	return revisions
+}
+
+// forecastRevisions describes served forecasts for conditional requests; a forecast is issued when it is fetched
+func forecastRevisions(forecasts ...models.ForecastData) []revision {
+	revisions := make([]revision, 0, len(forecasts))
+	for _, f := range forecasts {
+		revisions = append(revisions, revision{
+			key:      f.Provider + "|" + freshnessState(f.Freshness),
+			modified: f.Updated,
+			fetched:  f.Updated,
+		})
+	}
+	return revisions
+}
+
+// airQualityRevisions describes served air quality readings for conditional requests
+func airQualityRevisions(readings []models.AirQuality) []revision {
+	revisions := make([]revision, 0, len(readings))
+	for _, aq := range readings {
+		fetched := aq.FetchedAt
+		if fetched.IsZero() {
+			fetched = aq.Timestamp
+		}
+		revisions = append(revisions, revision{
+			key:      aq.Provider + "|" + freshnessState(aq.Freshness),
+			modified: aq.Timestamp,
+			fetched:  fetched,
+		})
+	}
+	return revisions
+}
+
+// freshnessState returns the state of annotated data, or an empty string if it wasn't annotated
+func freshnessState(f *models.Freshness) string {
+	if f == nil {
+		return ""
+	}
+	return string(f.State)
+}
+
+// notModified sets ETag, Last-Modified and Cache-Control for a response built from the given records and reports
+// whether the client's copy is still current, in which case 304 Not Modified has been written.
+// The ETag is weak because the body also carries the time of the request and the age of the data.
+func (s *Server) notModified(w http.ResponseWriter, r *http.Request, revisions []revision, expired []string) bool {
+	h := sha256.New()
+	io.WriteString(h, r.URL.RawQuery)
+	var lastModified, oldest time.Time
+	for _, rev := range revisions {
+		fmt.Fprintf(h, "\x00%s|%d|%d", rev.key, rev.modified.UnixNano(), rev.fetched.UnixNano())
+		// A record fetched again changes the response even if the provider reports an older observation
+		if rev.modified.After(lastModified) {
+			lastModified = rev.modified
+		}
+		if rev.fetched.After(lastModified) {
+			lastModified = rev.fetched
+		}
+		if oldest.IsZero() || rev.fetched.Before(oldest) {
+			oldest = rev.fetched
+		}
+	}
+	for _, provider := range expired {
+		io.WriteString(h, "\x00expired|"+provider)
+	}
+	etag := fmt.Sprintf(`W/"%x"`, h.Sum(nil)[:12])
+
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// Responses stay current until the first of their records is refreshed
+	if s.refreshInterval > 0 && !oldest.IsZero() {
+		maxAge := time.Until(oldest.Add(s.refreshInterval))
+		if maxAge < 0 {
+			maxAge = 0
+		}
+		// Shared caches can't check API keys, so authenticated responses are only cached by the client
+		visibility := "public"
+		if s.authRequired {
+			visibility = "private"
+		}
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since when both are sent
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatches(match, etag) {
+			return false
+		}
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(since)
+		if err != nil || lastModified.Truncate(time.Second).After(t) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	// A 304 carries the validators but no body
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header lists the ETag, comparing weakly
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+// get requests a path with the given headers
+func get(server *Server, path string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestConditionalWeatherRequests(t *testing.T) {
+	weatherStore := NewWeatherStore()
+	observed := time.Now().Add(-5 * time.Minute)
+	fetched := time.Now().Add(-time.Minute).Truncate(time.Second)
+	weatherStore.UpdateWeather(models.WeatherData{
+		Provider: "Fake", Location: "London,UK", Temperature: 12, Timestamp: fetched, ObservedAt: observed, FetchedAt: fetched,
+	})
+	server := NewServer(weatherStore, NewForecastStore(), 0)
+	server.SetRefreshInterval(10 * time.Minute)
+
+	first := get(server, "/weather/location/London,UK", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("first request: status %d, ETag %q", first.Code, etag)
+	}
+	if got := first.Header().Get("Last-Modified"); got != fetched.UTC().Format(http.TimeFormat) {
+		t.Errorf("Last-Modified = %q, want the fetch time", got)
+	}
+	// The reading is refreshed nine minutes from now
+	cacheControl := first.Header().Get("Cache-Control")
+	maxAge, err := strconv.Atoi(strings.TrimPrefix(cacheControl, "public, max-age="))
+	if err != nil || maxAge < 530 || maxAge > 540 {
+		t.Errorf("Cache-Control = %q, want public for about nine minutes", cacheControl)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"matching ETag", "/weather/location/London,UK", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"strong form of the ETag", "/weather/location/London,UK", map[string]string{"If-None-Match": `"x", ` + strings.TrimPrefix(etag, "W/")}, http.StatusNotModified},
+		{"any ETag", "/weather/location/London,UK", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"other ETag", "/weather/location/London,UK", map[string]string{"If-None-Match": `W/"other"`}, http.StatusOK},
+		{"other query", "/weather/location/London,UK?includeExpired=true", map[string]string{"If-None-Match": etag}, http.StatusOK},
+		{"unmodified since", "/weather/location/London,UK", map[string]string{"If-Modified-Since": fetched.UTC().Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", "/weather/location/London,UK", map[string]string{"If-Modified-Since": fetched.Add(-time.Second).UTC().Format(http.TimeFormat)}, http.StatusOK},
+		{"unparseable date", "/weather/location/London,UK", map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+		{"ETag takes precedence", "/weather/location/London,UK", map[string]string{
+			"If-None-Match": `W/"other"`, "If-Modified-Since": fetched.UTC().Format(http.TimeFormat),
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := get(server, tt.path, tt.headers)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if w.Code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("ETag") != etag) {
+			t.Errorf("%s: 304 with body %q and ETag %q", tt.name, w.Body.String(), w.Header().Get("ETag"))
+		}
+	}
+
+	// A new reading changes the ETag and Last-Modified, even from a provider reporting an older observation
+	since := first.Header().Get("Last-Modified")
+	weatherStore.UpdateWeather(models.WeatherData{
+		Provider: "Other", Location: "London,UK", Temperature: 13, Timestamp: time.Now(), ObservedAt: observed.Add(-10 * time.Minute), FetchedAt: time.Now(),
+	})
+	if w := get(server, "/weather/location/London,UK", map[string]string{"If-None-Match": etag}); w.Code != http.StatusOK {
+		t.Errorf("after an update: status %d, want 200", w.Code)
+	}
+	if w := get(server, "/weather/location/London,UK", map[string]string{"If-Modified-Since": since}); w.Code != http.StatusOK {
+		t.Errorf("after an update with an older observation: status %d, want 200", w.Code)
+	}
+}
+
+func TestCacheControlVisibility(t *testing.T) {
+	weatherStore := NewWeatherStore()
+	weatherStore.UpdateWeather(models.WeatherData{Provider: "Fake", Location: "London,UK", Timestamp: time.Now(), FetchedAt: time.Now()})
+	server := NewServer(weatherStore, NewForecastStore(), 0)
+
+	if got := get(server, "/weather/location/London,UK", nil).Header().Get("Cache-Control"); got != "no-cache" {
+		t.Errorf("Cache-Control without a refresh interval = %q, want no-cache", got)
+	}
+
+	server.SetRefreshInterval(time.Minute)
+	server.SetAuthRequired(true)
+	w := httptest.NewRecorder()
+	server.handleGetWeatherByLocation(w, httptest.NewRequest(http.MethodGet, "/weather/location/London,UK", nil))
+	if got := w.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private, max-age=") {
+		t.Errorf("Cache-Control with authentication = %q, want private", got)
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"weather-service/auth"
+)
+
+// keyInfo is an API key as listed by the admin endpoints, with its usage
+type keyInfo struct {
+	auth.Key
+	Usage     auth.Usage `json:"usage"`
+	Remaining *int       `json:"remaining,omitempty"` // requests left today, for keys with a quota
+}
+
+// describeKey adds a key's usage and remaining quota
+func (s *Server) describeKey(key auth.Key) keyInfo {
+	info := keyInfo{Key: key, Usage: s.keys.Usage(key.ID)}
+	if key.DailyQuota > 0 {
+		remaining := max(key.DailyQuota-info.Usage.Today, 0)
+		info.Remaining = &remaining
+	}
+	return info
+}
+
+// handleAPIKeys lists keys on GET and creates a key on POST. The body of a POST has the key's name, scopes,
+// locations, dailyQuota, rate limit tier, and either expiresAt (RFC 3339) or expiresIn (a duration such as "720h").
+func (s *Server) handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		keys := s.keys.List()
+		infos := make([]keyInfo, 0, len(keys))
+		for _, key := range keys {
+			infos = append(infos, s.describeKey(key))
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys":      infos,
+			"count":     len(infos),
+			"timestamp": time.Now(),
+		})
+		return
+	}
+
+	var request struct {
+		auth.KeySpec
+		ExpiresIn string `json:"expiresIn"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Invalid key request: %v", err),
+		})
+		return
+	}
+	if request.ExpiresIn != "" {
+		d, err := time.ParseDuration(request.ExpiresIn)
+		if err != nil || d <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Invalid expiresIn: %s", request.ExpiresIn),
+			})
+			return
+		}
+		expires := time.Now().Add(d)
+		request.ExpiresAt = &expires
+	}
+	if request.Name == "" || request.DailyQuota < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "A key needs a name and a dailyQuota of 0 (unlimited) or more",
+		})
+		return
+	}
+	if request.Tier != "" && s.limiter != nil && !s.limiter.HasTier(request.Tier) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Unknown rate limit tier: %s", request.Tier),
+		})
+		return
+	}
+
+	secret, key, err := s.keys.Create(request.KeySpec)
+	if err != nil {
+		s.writeKeyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"key":       secret,
+		"details":   s.describeKey(key),
+		"note":      "The key is shown only once; store it now",
+		"timestamp": time.Now(),
+	})
+}
+
+// handleAPIKey shows a key and its usage on GET, revokes it on DELETE, and rotates it on POST to
+// /admin/keys/{id}/rotate, optionally keeping the old key working for ?grace= (e.g. "24h")
+func (s *Server) handleAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/keys/"), "/")
+
+	switch {
+	case action == "" && (r.Method == http.MethodGet || r.Method == http.MethodDelete):
+	case action == "rotate" && r.Method == http.MethodPost:
+	case action != "" && action != "rotate":
+		http.NotFound(w, r)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case action == "rotate":
+		var grace time.Duration
+		if value := r.URL.Query().Get("grace"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": fmt.Sprintf("Invalid grace period: %s", value),
+				})
+				return
+			}
+			grace = d
+		}
+
+		secret, key, err := s.keys.Rotate(id, grace)
+		if err != nil {
+			s.writeKeyError(w, err)
+			return
+		}
+		old, _ := s.keys.Get(id)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":       secret,
+			"details":   s.describeKey(key),
+			"replaced":  s.describeKey(old),
+			"note":      "The key is shown only once; store it now",
+			"timestamp": time.Now(),
+		})
+
+	case r.Method == http.MethodDelete:
+		key, err := s.keys.RevokeID(id)
+		if err != nil {
+			s.writeKeyError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"revoked":   s.describeKey(key),
+			"timestamp": time.Now(),
+		})
+
+	default:
+		key, exists := s.keys.Get(id)
+		if !exists {
+			s.writeKeyError(w, auth.ErrKeyNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"key":       s.describeKey(key),
+			"timestamp": time.Now(),
+		})
+	}
+}
+
+// writeKeyError reports a failed key management request: unknown keys get 404, unknown scopes and rotating
+// a revoked key get 400, and failing to generate or save a key gets 500
+func (s *Server) writeKeyError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, auth.ErrUnknownScope), errors.Is(err, auth.ErrRevokedKey):
+		status = http.StatusBadRequest
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+func TestPageBounds(t *testing.T) {
+	tests := []struct {
+		offset, limit, total int
+		start, end           int
+	}{
+		{0, 10, 25, 0, 10},
+		{20, 10, 25, 20, 25},
+		{25, 10, 25, 25, 25},
+		{30, 10, 25, 25, 25},
+		{0, 10, 0, 0, 0},
+		{math.MaxInt, maxHistoryLimit, 25, 25, 25},
+		{10, math.MaxInt, 25, 10, 25},
+	}
+	for _, tt := range tests {
+		start, end := pageStart(tt.offset, tt.total), pageEnd(tt.offset, tt.limit, tt.total)
+		if start != tt.start || end != tt.end {
+			t.Errorf("page(offset=%d, limit=%d, total=%d) = [%d:%d], want [%d:%d]",
+				tt.offset, tt.limit, tt.total, start, end, tt.start, tt.end)
+		}
+	}
+}
+
+func TestHistoryPagination(t *testing.T) {
+	weatherStore := NewWeatherStore()
+	to := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		weatherStore.UpdateWeather(models.WeatherData{
+			Provider:  "Test",
+			Location:  "London,UK",
+			Timestamp: to.Add(-time.Duration(i+1) * time.Hour),
+		})
+	}
+	server := NewServer(weatherStore, NewForecastStore(), 0)
+
+	get := func(query string) (int, map[string]interface{}) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		url := "/weather/location/London,UK/history?to=" + to.Format(time.RFC3339) + "&" + query
+		server.server.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		var body map[string]interface{}
+		json.NewDecoder(recorder.Body).Decode(&body)
+		return recorder.Code, body
+	}
+
+	tests := []struct {
+		query      string
+		status     int
+		items      int
+		nextOffset interface{}
+	}{
+		{"limit=2", http.StatusOK, 2, 2.0},
+		{"limit=2&offset=2", http.StatusOK, 2, 4.0},
+		{"limit=2&offset=4", http.StatusOK, 1, nil},
+		{"limit=2&offset=10", http.StatusOK, 0, nil},
+		{"offset=" + strconv.Itoa(maxHistoryOffset), http.StatusOK, 0, nil},
+		{"offset=" + strconv.Itoa(maxHistoryOffset+1), http.StatusBadRequest, 0, nil},
+		{"limit=1000&offset=" + strconv.Itoa(math.MaxInt), http.StatusBadRequest, 0, nil},
+		{"offset=-1", http.StatusBadRequest, 0, nil},
+		{"limit=0", http.StatusBadRequest, 0, nil},
+	}
+	for _, tt := range tests {
+		status, body := get(tt.query)
+		if status != tt.status {
+			t.Errorf("%s: status %d, want %d (%v)", tt.query, status, tt.status, body["error"])
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		data, _ := body["data"].([]interface{})
+		if len(data) != tt.items || body["total"] != 5.0 || body["nextOffset"] != tt.nextOffset {
+			t.Errorf("%s: %d items of %v, nextOffset %v; want %d of 5, nextOffset %v",
+				tt.query, len(data), body["total"], body["nextOffset"], tt.items, tt.nextOffset)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"weather-service/models"
+	"weather-service/popularity"
+)
+
+func TestOnlyFoundLocationsArePopular(t *testing.T) {
+	weatherStore := NewWeatherStore()
+	weatherStore.UpdateWeather(models.WeatherData{Provider: "Fake", Location: "Paris,FR", Timestamp: time.Now()})
+	server := NewServer(weatherStore, NewForecastStore(), 0)
+	tracker := popularity.NewTracker(popularity.DefaultOptions(), nil)
+	server.RegisterPopularity(tracker)
+
+	for _, path := range []string{"/weather/location/Atlantis", "/forecast/location/Atlantis", "/airquality/location/Atlantis"} {
+		if w := get(server, path, nil); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, w.Code)
+		}
+	}
+	if w := get(server, "/weather/location/Paris,FR", nil); w.Code != http.StatusOK {
+		t.Fatalf("Paris: status %d", w.Code)
+	}
+
+	stats := tracker.Stats(time.Now())
+	if stats.Tracked != 1 || len(stats.Candidates) != 1 || stats.Candidates[0].Location != "Paris,FR" {
+		t.Errorf("stats = %+v, want only Paris tracked", stats)
+	}
+}
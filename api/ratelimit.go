@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"weather-service/ratelimit"
+)
+
+// RegisterRateLimiter sets the per-client rate limiter applied to every endpoint except the public ones
+func (s *Server) RegisterRateLimiter(limiter *ratelimit.Limiter) {
+	s.limiter = limiter
+}
+
+// allowRequest takes a request from its client's bucket: the named client's, such as a valid key's, in its tier,
+// or the IP address's when client is empty. It sets the RateLimit headers, and for refused requests writes 429
+// and returns false.
+func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, client, tierName string, now time.Time) bool {
+	if s.limiter == nil {
+		return true
+	}
+
+	tier := s.limiter.Tier(tierName)
+	if client == "" {
+		client, tier = "ip:"+s.limiter.ClientIP(r), s.limiter.AnonymousTier()
+	}
+
+	decision := s.limiter.Allow(client, tier, now)
+	if decision.Limit == 0 {
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := max(ceilSeconds(decision.RetryAfter), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": fmt.Sprintf("Rate limit exceeded (%g requests per second, bursts of %d); retry in %d seconds",
+			tier.Rate, tier.Burst, retryAfter),
+	})
+	return false
+}
+
+// ceilSeconds rounds a duration up to whole seconds, as rate limit headers are given
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
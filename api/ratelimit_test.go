@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"weather-service/ratelimit"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	options := ratelimit.DefaultOptions()
+	options.Tiers["anonymous"] = ratelimit.Tier{Rate: 0.5, Burst: 2}
+	limiter, err := ratelimit.NewLimiter(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(NewWeatherStore(), NewForecastStore(), 0)
+	server.RegisterRateLimiter(limiter)
+
+	get := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/weather/locations", nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		status                  int
+		limit, remaining, reset string
+		retryAfter              string
+	}{
+		{http.StatusOK, "2", "1", "2", ""},
+		{http.StatusOK, "2", "0", "4", ""},
+		{http.StatusTooManyRequests, "2", "0", "4", "2"},
+	}
+	for i, tt := range tests {
+		w := get("192.0.2.1:1234")
+		h := w.Header()
+		if w.Code != tt.status || h.Get("RateLimit-Limit") != tt.limit || h.Get("RateLimit-Remaining") != tt.remaining ||
+			h.Get("RateLimit-Reset") != tt.reset || h.Get("Retry-After") != tt.retryAfter {
+			t.Errorf("request %d: %d, limit %s, remaining %s, reset %s, retry after %q; want %d, %s, %s, %s, %q",
+				i, w.Code, h.Get("RateLimit-Limit"), h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"),
+				h.Get("Retry-After"), tt.status, tt.limit, tt.remaining, tt.reset, tt.retryAfter)
+		}
+	}
+
+	// Another address has its own bucket
+	if w := get("192.0.2.2:1234"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("another address: %d, remaining %s", w.Code, w.Header().Get("RateLimit-Remaining"))
+	}
+}
@@ -2,87 +2,172 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"weather-service/alerts"
+	"weather-service/astronomy"
+	"weather-service/auth"
+	"weather-service/cache"
+	"weather-service/climate"
+	"weather-service/consensus"
 	"weather-service/datasource"
+	"weather-service/derived"
+	"weather-service/freshness"
 	"weather-service/models"
+	"weather-service/popularity"
+	"weather-service/ratelimit"
+	"weather-service/retention"
+	"weather-service/storage"
+	"weather-service/timeseries"
+	"weather-service/verification"
 )
 
 // WeatherStore holds the latest weather data by location
 type WeatherStore struct {
-	data  map[string][]models.WeatherData // key is location, value is array of provider data
-	mutex sync.RWMutex
+	backend storage.WeatherBackend
 }
 
 // NewWeatherStore creates a new in-memory weather data store
 func NewWeatherStore() *WeatherStore {
+	return NewWeatherStoreWithBackend(storage.NewMemoryBackend())
+}
+
+// NewWeatherStoreWithBackend creates a weather data store on top of a storage backend
+func NewWeatherStoreWithBackend(backend storage.WeatherBackend) *WeatherStore {
 	return &WeatherStore{
-		data: make(map[string][]models.WeatherData),
+		backend: backend,
 	}
 }
 
-// UpdateWeather adds or updates weather data for a location
+// UpdateWeather replaces the provider's latest reading for a location and appends it to the location's history.
+// Readings are kept under the normalized location, so callers set it to the location they asked for rather
+// than the name the provider reports, which differs between providers.
 func (s *WeatherStore) UpdateWeather(data models.WeatherData) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	data.Location = models.NormalizeLocation(data.Location)
+	if err := s.backend.PutWeather(data); err != nil {
+		log.Printf("Error storing weather for %s from %s: %v", data.Location, data.Provider, err)
+	}
+	if err := s.backend.AppendObservation(data); err != nil {
+		log.Printf("Error appending observation for %s from %s: %v", data.Location, data.Provider, err)
+	}
+}
 
-	location := data.Location
+// GetObservations returns the readings stored for a location with from <= timestamp < to,
+// ordered by time; an empty provider returns every provider's readings
+func (s *WeatherStore) GetObservations(location, provider string, from, to time.Time) ([]models.WeatherData, error) {
+	return s.backend.Observations(models.NormalizeLocation(location), provider, from, to)
+}
 
-	// Check if we already have data for this location
-	if _, exists := s.data[location]; !exists {
-		s.data[location] = []models.WeatherData{}
+// GetWeatherByLocation retrieves weather data for a specific location
+func (s *WeatherStore) GetWeatherByLocation(location string) ([]models.WeatherData, bool) {
+	data, err := s.backend.GetWeather(models.NormalizeLocation(location))
+	if err != nil {
+		log.Printf("Error reading weather for %s: %v", location, err)
+		return nil, false
 	}
+	return data, len(data) > 0
+}
 
-	// Find if we already have data from this provider
-	found := false
-	for i, existingData := range s.data[location] {
-		if existingData.Provider == data.Provider {
-			// Update existing entry
-			s.data[location][i] = data
-			found = true
-			break
+// GetCoordinates returns the coordinates reported by any provider for a location
+func (s *WeatherStore) GetCoordinates(location string) (lat, lon float64, found bool) {
+	data, _ := s.GetWeatherByLocation(location)
+	for _, d := range data {
+		if d.Latitude != 0 || d.Longitude != 0 {
+			return d.Latitude, d.Longitude, true
 		}
 	}
+	return 0, 0, false
+}
 
-	// If no data from this provider exists, append it
-	if !found {
-		s.data[location] = append(s.data[location], data)
+// GetAggregates summarises a location's readings per interval. For whole-hour and whole-day intervals,
+// periods whose raw readings have been compacted away are filled from the stored hourly and daily rollups.
+func (s *WeatherStore) GetAggregates(location, provider string, from, to time.Time, interval time.Duration) ([]models.ObservationAggregate, error) {
+	location = models.NormalizeLocation(location)
+	observations, err := s.backend.Observations(location, provider, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if interval%time.Hour != 0 {
+		return timeseries.Aggregate(observations, interval), nil
 	}
-}
 
-// GetWeatherByLocation retrieves weather data for a specific location
-func (s *WeatherStore) GetWeatherByLocation(location string) ([]models.WeatherData, bool) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+	// Raw readings take precedence over rollups covering the same hour
+	hourly := timeseries.Aggregate(observations, time.Hour)
+	covered := make(map[string]bool)
+	for _, a := range hourly {
+		covered[a.Provider+"|"+a.Start.Format(time.RFC3339)] = true
+	}
 
-	data, exists := s.data[location]
-	return data, exists
+	stored, err := s.backend.Rollups(storage.ResolutionHourly, location, provider, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range stored {
+		if !covered[a.Provider+"|"+a.Start.Format(time.RFC3339)] {
+			hourly = append(hourly, a)
+		}
+	}
+
+	if interval%(24*time.Hour) == 0 {
+		days := make(map[string]bool)
+		for _, a := range hourly {
+			days[a.Provider+"|"+a.Start.Truncate(24*time.Hour).Format(time.RFC3339)] = true
+		}
+
+		daily, err := s.backend.Rollups(storage.ResolutionDaily, location, provider, from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range daily {
+			if !days[a.Provider+"|"+a.Start.Format(time.RFC3339)] {
+				hourly = append(hourly, a)
+			}
+		}
+	}
+
+	return timeseries.Rollup(hourly, interval), nil
 }
 
 // GetAllLocations returns a list of all available locations
 func (s *WeatherStore) GetAllLocations() []string {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
-
-	locations := make([]string, 0, len(s.data))
-	for loc := range s.data {
-		locations = append(locations, loc)
+	locations, err := s.backend.WeatherLocations()
+	if err != nil {
+		log.Printf("Error listing weather locations: %v", err)
+		return []string{}
 	}
 	return locations
 }
 
 // Server represents the API server
 type Server struct {
-	weatherStore    *WeatherStore
-	forecastStore   *ForecastStore
-	server          *http.Server
-	forecastSources []datasource.ForecastSource
-	apiKeys         map[string]bool // Store valid API keys
+	weatherStore      *WeatherStore
+	forecastStore     *ForecastStore
+	airQualityStore   *AirQualityStore
+	server            *http.Server
+	forecastSources   []datasource.ForecastSource
+	airQualitySources []datasource.AirQualitySource
+	alertStore        *alerts.Store
+	historySources    []datasource.HistorySource
+	climateStore      *climate.Store
+	marineSources     []datasource.MarineSource
+	compactor         *retention.Compactor
+	negativeCache     *cache.NegativeCache
+	popular           *popularity.Tracker
+	refreshInterval   time.Duration // how often stored data is refreshed, for Cache-Control
+	keys              *auth.KeyStore
+	limiter           *ratelimit.Limiter
+	tokens            *auth.TokenValidator
+	authRequired      bool
+	consensus         consensus.Thresholds
+	freshness         freshness.Policies
+	verifier          *verification.Verifier
 }
 
 // APIEndpoint represents an API endpoint with its documentation
@@ -99,19 +184,35 @@ func NewServer(weatherStore *WeatherStore, forecastStore *ForecastStore, port in
 	mux := http.NewServeMux()
 
 	server := &Server{
-		weatherStore:  weatherStore,
-		forecastStore: forecastStore,
-		apiKeys:       make(map[string]bool),
+		weatherStore:    weatherStore,
+		forecastStore:   forecastStore,
+		airQualityStore: NewAirQualityStore(),
+		alertStore:      alerts.NewStore(),
+		keys:            auth.NewKeyStore(),
+		consensus:       consensus.DefaultThresholds(),
+		freshness:       freshness.DefaultPolicies(),
 		server: &http.Server{
 			Addr:    fmt.Sprintf(":%d", port),
 			Handler: mux,
 		},
 	}
 
-	// Register handlers with authentication middleware
-	mux.HandleFunc("/weather/location/", server.withAuth(server.handleGetWeatherByLocation))
-	mux.HandleFunc("/weather/locations", server.withAuth(server.handleGetAllLocations))
-	mux.HandleFunc("/forecast/location/", server.withAuth(server.handleGetForecastByLocation))
+	// Register handlers with authentication middleware and the scope each needs
+	mux.HandleFunc("/weather/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetWeatherByLocation))
+	mux.HandleFunc("/weather/locations", server.withAuth(auth.ScopeWeatherRead, server.handleGetAllLocations))
+	mux.HandleFunc("/forecast/location/", server.withAuth(auth.ScopeForecastRead, server.handleGetForecastByLocation))
+	mux.HandleFunc("/stats/accuracy", server.withAuth(auth.ScopeForecastRead, server.handleGetAccuracyStats))
+	mux.HandleFunc("/stats/retention", server.withAuth(auth.ScopeWeatherRead, server.handleGetRetentionStats))
+	mux.HandleFunc("/astronomy/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetAstronomyByLocation))
+	mux.HandleFunc("/airquality/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetAirQualityByLocation))
+	mux.HandleFunc("/alerts/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetAlertsByLocation))
+	mux.HandleFunc("/alerts/active", server.withAuth(auth.ScopeWeatherRead, server.handleGetActiveAlerts))
+	mux.HandleFunc("/history/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetHistoryByLocation))
+	mux.HandleFunc("/marine/location/", server.withAuth(auth.ScopeWeatherRead, server.handleGetMarineByLocation))
+	mux.HandleFunc("/admin/cache/negative", server.withAuth(auth.ScopeAdmin, server.handleNegativeCache))
+	mux.HandleFunc("/admin/popular", server.withAuth(auth.ScopeAdmin, server.handlePopularLocations))
+	mux.HandleFunc("/admin/keys", server.withAuth(auth.ScopeAdmin, server.handleAPIKeys))
+	mux.HandleFunc("/admin/keys/", server.withAuth(auth.ScopeAdmin, server.handleAPIKey))
 
 	// Public endpoints without authentication
 	mux.HandleFunc("/health", server.handleHealthCheck)
@@ -125,6 +226,52 @@ func (s *Server) RegisterForecastSources(sources []datasource.ForecastSource) {
 	s.forecastSources = sources
 }
 
+// SetConsensusThresholds configures how the best estimate is merged and when disagreements are flagged
+func (s *Server) SetConsensusThresholds(thresholds consensus.Thresholds) {
+	s.consensus = thresholds
+}
+
+// SetFreshnessPolicies configures when served data is reported as stale and when it expires
+func (s *Server) SetFreshnessPolicies(policies freshness.Policies) {
+	s.freshness = policies
+}
+
+// RegisterAirQualitySources adds air quality sources and the store their data is kept in
+func (s *Server) RegisterAirQualitySources(sources []datasource.AirQualitySource, store *AirQualityStore) {
+	s.airQualitySources = sources
+	s.airQualityStore = store
+}
+
+// RegisterAlertStore sets the store severe weather alerts are served from
+func (s *Server) RegisterAlertStore(store *alerts.Store) {
+	s.alertStore = store
+}
+
+// RegisterHistorySources adds historical data sources, tried in order until one has data
+func (s *Server) RegisterHistorySources(sources []datasource.HistorySource) {
+	s.historySources = sources
+}
+
+// RegisterMarineSources adds marine forecast sources; marine data is fetched on demand
+func (s *Server) RegisterMarineSources(sources []datasource.MarineSource) {
+	s.marineSources = sources
+}
+
+// RegisterCompactor sets the compactor whose retention policy and last report are served
+func (s *Server) RegisterCompactor(compactor *retention.Compactor) {
+	s.compactor = compactor
+}
+
+// RegisterClimateStore sets the climate normals used to annotate readings and forecasts with anomalies
+func (s *Server) RegisterClimateStore(store *climate.Store) {
+	s.climateStore = store
+}
+
+// RegisterVerifier sets the forecast verifier used for accuracy statistics
+func (s *Server) RegisterVerifier(verifier *verification.Verifier) {
+	s.verifier = verifier
+}
+
 // Start begins the API server
 func (s *Server) Start() error {
 	fmt.Printf("Starting API server on %s\n", s.server.Addr)
@@ -133,20 +280,139 @@ func (s *Server) Start() error {
 
 // AddAPIKey adds a valid API key to the server
 func (s *Server) AddAPIKey(key string) {
-	s.apiKeys[key] = true
+	s.keys.Add("", key)
 }
 
-// RemoveAPIKey removes an API key from the server
+// RemoveAPIKey revokes an API key, so requests using it are refused with 403
 func (s *Server) RemoveAPIKey(key string) {
-	delete(s.apiKeys, key)
+	s.keys.Revoke(key)
+}
+
+// RegisterKeyStore sets the store of issued API keys
+func (s *Server) RegisterKeyStore(keys *auth.KeyStore) {
+	s.keys = keys
 }
 
-// withAuth wraps an http.HandlerFunc with API key authentication
-func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
+// SetAuthRequired turns API key authentication on or off for every endpoint except the public ones and the
+// admin ones, which always need a key or token with the admin scope
+func (s *Server) SetAuthRequired(required bool) {
+	s.authRequired = required
+}
+
+// RegisterTokenValidator accepts JWTs from an identity provider as bearer tokens alongside API keys
+func (s *Server) RegisterTokenValidator(tokens *auth.TokenValidator) {
+	s.tokens = tokens
+}
+
+// withAuth wraps an http.HandlerFunc with API key or JWT authentication and per-client rate limiting. Requests
+// without a key or with an unknown key or invalid token get 401; revoked or expired keys, keys or tokens lacking
+// the scope, and keys lacking the location a request names get 403; clients over their rate limit or keys over
+// their daily quota get 429. Admin endpoints are authenticated even when authentication is otherwise off.
+func (s *Server) withAuth(scope auth.Scope, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Authentication removed - all requests are allowed
-		next(w, r)
+		now := time.Now()
+		if !s.authRequired && scope != auth.ScopeAdmin {
+			if s.allowRequest(w, r, "", "", now) {
+				next(w, r)
+			}
+			return
+		}
+
+		// Rate limiting comes first so refused requests don't count against the daily quota, and by address
+		// for requests without a valid key so keys can't be guessed at full speed
+		secret := auth.Credential(r)
+		if s.tokens != nil && auth.IsJWT(secret) {
+			s.authorizeToken(w, r, secret, scope, now, next)
+			return
+		}
+
+		var client, tier string
+		if key, err := s.keys.Lookup(secret); err == nil {
+			client, tier = "key:"+key.ID, key.Tier
+		}
+		if !s.allowRequest(w, r, client, tier, now) {
+			return
+		}
+
+		key, err := s.keys.Authorize(secret, scope, requestLocation(r), now)
+		if err != nil {
+			status, message := http.StatusForbidden, err.Error()
+			switch {
+			case errors.Is(err, auth.ErrMissingKey), errors.Is(err, auth.ErrUnknownKey):
+				status = http.StatusUnauthorized
+				message = fmt.Sprintf("%v; send it in the X-API-Key header or as a bearer token", err)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="weather-service"`)
+			case errors.Is(err, auth.ErrQuotaExceeded):
+				status = http.StatusTooManyRequests
+				reset := auth.QuotaReset(now)
+				w.Header().Set("Retry-After", strconv.Itoa(int(reset.Sub(now).Seconds())+1))
+				message = fmt.Sprintf("%v (%d requests per day, resets at %s)", err, key.DailyQuota, reset.Format(time.RFC3339))
+			case errors.Is(err, auth.ErrRevokedKey):
+				log.Printf("Refused request to %s with revoked API key %s (%s)", r.URL.Path, key.ID, key.Name)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": message,
+			})
+			return
+		}
+
+		next(w, r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{KeyID: key.ID, Name: key.Name, Locations: key.Locations})))
+	}
+}
+
+// authorizeToken authenticates a request bearing a JWT. Tokens carry no location restrictions or quota, so
+// only their scopes are checked.
+func (s *Server) authorizeToken(w http.ResponseWriter, r *http.Request, token string, scope auth.Scope,
+	now time.Time, next http.HandlerFunc) {
+	validated, err := s.tokens.Validate(r.Context(), token, now)
+
+	var client string
+	if err == nil {
+		client = "jwt:" + validated.Issuer + "#" + validated.Subject
+	}
+	if !s.allowRequest(w, r, client, s.tokens.Tier(), now) {
+		return
+	}
+
+	if err == nil && !validated.HasScope(scope) {
+		err = fmt.Errorf("bearer token lacks the required scope %s", scope)
+	}
+	if err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, auth.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+			w.Header().Set("WWW-Authenticate", `Bearer realm="weather-service", error="invalid_token"`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	principal := auth.Principal{Name: validated.Subject, Issuer: validated.Issuer}
+	next(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+}
+
+// callerAllows reports whether the caller of a request may see data for a location; requests without a
+// principal, made while authentication is off, may see every location
+func callerAllows(r *http.Request, location string) bool {
+	principal, _ := auth.FromContext(r.Context())
+	return principal.AllowsLocation(location)
+}
+
+// requestLocation returns the location a request queries, taken from the path segment after /location/ or
+// the location query parameter, or "" for requests that aren't about one location
+func requestLocation(r *http.Request) string {
+	_, rest, found := strings.Cut(r.URL.Path, "/location/")
+	if !found {
+		return r.URL.Query().Get("location")
 	}
+	location, _, _ := strings.Cut(rest, "/")
+	return location
 }
 
 // handleGetWeatherByLocation handles requests for weather data by location
@@ -164,6 +430,10 @@ func (s *Server) handleGetWeatherByLocation(w http.ResponseWriter, r *http.Reque
 	}
 
 	location := path[len("/weather/location/"):]
+	if strings.HasSuffix(location, "/history") {
+		s.handleGetWeatherHistory(w, r, strings.TrimSuffix(location, "/history"))
+		return
+	}
 	data, exists := s.weatherStore.GetWeatherByLocation(location)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -176,16 +446,233 @@ func (s *Server) handleGetWeatherByLocation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Only locations that turn out to exist count towards popularity, so misspellings are never refreshed
+	s.popular.Record(location, time.Now())
+
+	// Expired readings are left out unless asked for, and always listed so they're never served silently
+	data, expired := s.freshness.Weather(data, time.Now(), includeExpired(r))
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   fmt.Sprintf("Weather data for location %s has expired; use ?includeExpired=true to see it", location),
+			"expired": expired,
+		})
+		return
+	}
+
+	// Clients holding the current version of this data get 304 Not Modified
+	if s.notModified(w, r, weatherRevisions(data), expired) {
+		return
+	}
+
+	// The estimate only merges current readings unless nothing else is left
+	current := make([]models.WeatherData, 0, len(data))
+	for _, d := range data {
+		if d.Freshness.State != models.FreshnessExpired {
+			current = append(current, d)
+		}
+	}
+	if len(current) == 0 {
+		current = data
+	}
+
+	estimate := consensus.Merge(location, current, s.consensus, time.Now())
+	estimate.Data.Freshness = freshness.Stalest(current)
+	metrics := derived.Compute(estimate.Data.Temperature, estimate.Data.Humidity, estimate.Data.WindSpeed)
+	estimate.Data.Derived = &metrics
+	if s.climateStore != nil {
+		estimate.Data.Anomalies = s.climateStore.Anomalies(location, estimate.Data.Timestamp,
+			estimate.Data.Temperature, estimate.Data.Precipitation)
+	}
+
 	response := map[string]interface{}{
 		"location":  location,
-		"data":      data,
+		"data":      s.climateStore.AnnotateWeather(derived.AnnotateWeather(data)),
+		"estimate":  estimate,
+		"expired":   expired,
+		"timestamp": time.Now(),
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// includeExpired reports whether a request asked for expired data to be included, flagged, in the response
+func includeExpired(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("includeExpired"))
+	return include
+}
+
+// Paging limits for observation history
+const (
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+	maxHistoryOffset    = 1000000
+)
+
+// handleGetWeatherHistory returns a page of a location's observation time series, raw or aggregated
+func (s *Server) handleGetWeatherHistory(w http.ResponseWriter, r *http.Request, location string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	badRequest := func(message string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	to := time.Now()
+	if toStr := query.Get("to"); toStr != "" {
+		parsed, dateOnly, err := parseTimeParam(toStr)
+		if err != nil {
+			badRequest(fmt.Sprintf("Invalid to (expected RFC3339 or YYYY-MM-DD): %s", toStr))
+			return
+		}
+		// A date means the whole day is included
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1)
+		}
+		to = parsed
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if fromStr := query.Get("from"); fromStr != "" {
+		parsed, _, err := parseTimeParam(fromStr)
+		if err != nil {
+			badRequest(fmt.Sprintf("Invalid from (expected RFC3339 or YYYY-MM-DD): %s", fromStr))
+			return
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		badRequest("The from time must be before the to time")
+		return
+	}
+
+	var interval time.Duration
+	if intervalStr := query.Get("interval"); intervalStr != "" {
+		parsed, err := parseIntervalParam(intervalStr)
+		if err != nil || parsed < time.Minute {
+			badRequest(fmt.Sprintf("Invalid interval (expected a duration of at least 1m, e.g. 1h or 1d): %s", intervalStr))
+			return
+		}
+		interval = parsed
+	}
+
+	limit, offset := defaultHistoryLimit, 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 || parsed > maxHistoryLimit {
+			badRequest(fmt.Sprintf("Invalid limit (expected 1-%d): %s", maxHistoryLimit, limitStr))
+			return
+		}
+		limit = parsed
+	}
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 || parsed > maxHistoryOffset {
+			badRequest(fmt.Sprintf("Invalid offset (expected 0-%d): %s", maxHistoryOffset, offsetStr))
+			return
+		}
+		offset = parsed
+	}
+
+	// Match the provider name case-insensitively against the names stored for the location
+	provider := query.Get("provider")
+	if provider != "" {
+		if current, exists := s.weatherStore.GetWeatherByLocation(location); exists {
+			for _, d := range current {
+				if strings.EqualFold(d.Provider, provider) {
+					provider = d.Provider
+				}
+			}
+		}
+	}
+
+	var observations []models.WeatherData
+	var aggregates []models.ObservationAggregate
+	var err error
+	if interval > 0 {
+		aggregates, err = s.weatherStore.GetAggregates(location, provider, from, to, interval)
+	} else {
+		observations, err = s.weatherStore.GetObservations(location, provider, from, to)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to read history: %v", err),
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"location":  location,
+		"from":      from,
+		"to":        to,
+		"limit":     limit,
+		"offset":    offset,
 		"timestamp": time.Now(),
 	}
+	if provider != "" {
+		response["provider"] = provider
+	}
+
+	var total int
+	if interval > 0 {
+		total = len(aggregates)
+		response["interval"] = interval.String()
+		response["data"] = aggregates[pageStart(offset, total):pageEnd(offset, limit, total)]
+	} else {
+		total = len(observations)
+		response["data"] = observations[pageStart(offset, total):pageEnd(offset, limit, total)]
+	}
+	response["total"] = total
+	if offset < total-limit {
+		response["nextOffset"] = offset + limit
+	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
 
+// parseTimeParam parses an RFC3339 time or a YYYY-MM-DD date (midnight UTC), reporting which it was
+func parseTimeParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
+
+// parseIntervalParam parses a Go duration, also accepting whole days such as "1d" or "7d"
+func parseIntervalParam(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// pageStart clamps a page offset to the number of items
+func pageStart(offset, total int) int {
+	if offset > total {
+		return total
+	}
+	return offset
+}
+
+// pageEnd returns the end index of a page; it compares against the items left so offset+limit can't overflow
+func pageEnd(offset, limit, total int) int {
+	if offset >= total || limit > total-offset {
+		return total
+	}
+	return offset + limit
+}
+
 // handleGetAllLocations returns a list of all locations with weather data
 func (s *Server) handleGetAllLocations(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -193,7 +680,14 @@ func (s *Server) handleGetAllLocations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	locations := s.weatherStore.GetAllLocations()
+	// Keys restricted to some locations only see those
+	all := s.weatherStore.GetAllLocations()
+	locations := make([]string, 0, len(all))
+	for _, location := range all {
+		if callerAllows(r, location) {
+			locations = append(locations, location)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -236,15 +730,22 @@ func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
 		{
 			Path:        "/weather/location/{location}",
 			Method:      "GET",
-			Description: "Get current weather data for a specific location",
-			Parameters:  "{location} - City name and country code (e.g., London,UK)",
+			Description: "Get current weather data for a specific location, with a merged best estimate, provider disagreement flags, derived comfort indices, anomalies against climate normals and freshness (observed/fetched time, age, fresh/stale/expired); expired readings are listed under expired",
+			Parameters:  "{location} - City name and country code (e.g., London,UK), ?includeExpired=true (optional, serve expired readings flagged)",
 			Example:     "/weather/location/London,UK",
 		},
+		{
+			Path:        "/weather/location/{location}/history",
+			Method:      "GET",
+			Description: "Get the stored time series of observations for a location, optionally aggregated to min/max/avg per interval; older periods are served from hourly and daily rollups once raw readings expire",
+			Parameters:  fmt.Sprintf("{location} - City name and country code, ?from=&to= (optional, RFC3339 or YYYY-MM-DD, default=last 24 hours), ?provider= (optional), ?interval= (optional, e.g. 1h, 1d), ?limit= (optional, default=%d, max=%d), ?offset= (optional, max=%d)", defaultHistoryLimit, maxHistoryLimit, maxHistoryOffset),
+			Example:     "/weather/location/London,GB/history?from=2024-03-01&to=2024-03-08&interval=1d",
+		},
 		{
 			Path:        "/forecast/location/{location}",
 			Method:      "GET",
-			Description: "Get forecast data for a specific location",
-			Parameters:  "{location} - City name and country code (e.g., London,UK), ?days=n (optional, default=3)",
+			Description: "Get forecast data for a specific location; the default forecast comes from the provider ranked best by recent verification skill. Each forecast reports its freshness and expired forecasts are listed under expired",
+			Parameters:  "{location} - City name and country code (e.g., London,UK), ?days=n (optional, default=3), ?includeExpired=true (optional, serve expired forecasts flagged)",
 			Example:     "/forecast/location/London,UK?days=5",
 		},
 		{
@@ -254,6 +755,97 @@ func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
 			Parameters:  "{location} - City name and country code, {provider} - Provider name (e.g., WeatherAPI)",
 			Example:     "/forecast/location/London,UK/WeatherAPI",
 		},
+		{
+			Path:        "/stats/accuracy",
+			Method:      "GET",
+			Description: "Get forecast verification scores (temperature MAE/bias, precipitation hit rate and false alarm ratio) by provider and lead time",
+			Parameters:  "?location= (optional), ?provider= (optional), ?lead=hours (optional, selects the lead time bucket)",
+			Example:     "/stats/accuracy?location=London,GB&provider=OpenWeatherMap&lead=24",
+		},
+		{
+			Path:        "/stats/retention",
+			Method:      "GET",
+			Description: "Get the storage retention policy and what the last compaction rolled up and removed",
+			Parameters:  "None",
+			Example:     "/stats/retention",
+		},
+		{
+			Path:        "/astronomy/location/{location}",
+			Method:      "GET",
+			Description: "Get locally computed sunrise/sunset, twilight, solar position and moon phase/rise/set for a location",
+			Parameters:  "{location} - City name and country code, ?date=YYYY-MM-DD (optional, default=today), ?lat=&lon= (optional, override stored coordinates)",
+			Example:     "/astronomy/location/London,GB?date=2024-06-21",
+		},
+		{
+			Path:        "/airquality/location/{location}",
+			Method:      "GET",
+			Description: "Get current air quality (PM2.5, PM10, O3, NO2, SO2, CO) with US/EU AQI, category, health guidance and freshness; expired readings are refetched",
+			Parameters:  "{location} - City name and country code (e.g., London,UK), ?includeExpired=true (optional, serve expired readings flagged)",
+			Example:     "/airquality/location/London,UK",
+		},
+		{
+			Path:        "/alerts/location/{location}",
+			Method:      "GET",
+			Description: "Get active severe weather alerts for a location, deduplicated across sources",
+			Parameters:  "{location} - City name and country code (e.g., London,UK)",
+			Example:     "/alerts/location/Houston,United States of America",
+		},
+		{
+			Path:        "/alerts/active",
+			Method:      "GET",
+			Description: "Get all active severe weather alerts, most severe first",
+			Parameters:  "None",
+			Example:     "/alerts/active",
+		},
+		{
+			Path:        "/history/location/{location}",
+			Method:      "GET",
+			Description: "Get hourly observed weather for past days; complete past days are cached permanently",
+			Parameters:  fmt.Sprintf("{location} - City name and country code, ?from=YYYY-MM-DD (required), ?to=YYYY-MM-DD (optional, default=from, at most %d days), ?provider= (optional)", maxHistoryDays),
+			Example:     "/history/location/London,UK?from=2024-03-03",
+		},
+		{
+			Path:        "/marine/location/{location}",
+			Method:      "GET",
+			Description: "Get hourly wave height, swell period/direction and water temperature with high and low tides for a coastal location",
+			Parameters:  fmt.Sprintf("{location} - City name or coordinates (e.g., Brighton,UK or 50.82,-0.14), ?days=n (optional, default=3, max=%d), ?provider= (optional)", maxMarineDays),
+			Example:     "/marine/location/Brighton,UK?days=2",
+		},
+		{
+			Path:        "/admin/cache/negative",
+			Method:      "GET, DELETE",
+			Description: "Get negative cache counts per provider error kind (entries, stored, hits, cleared), or clear cached errors so failing lookups reach the provider again",
+			Parameters:  "?kind= (optional for DELETE, one of not_found, auth, rate_limited, upstream; default=all)",
+			Example:     "/admin/cache/negative?kind=not_found",
+		},
+		{
+			Path:        "/admin/keys",
+			Method:      "GET, POST",
+			Description: "List API keys with their usage, or create a key; the new key is returned once (admin scope)",
+			Parameters:  "POST body: {\"name\", \"scopes\": [weather:read, forecast:read, admin], \"locations\": [...], \"dailyQuota\": n, \"tier\": rate limit tier, \"expiresAt\" or \"expiresIn\": \"720h\"}",
+			Example:     "/admin/keys",
+		},
+		{
+			Path:        "/admin/keys/{id}",
+			Method:      "GET, DELETE",
+			Description: "Get an API key with its usage today and in total, or revoke it (admin scope)",
+			Parameters:  "{id} - key ID as listed by /admin/keys",
+			Example:     "/admin/keys/2bb80d53",
+		},
+		{
+			Path:        "/admin/keys/{id}/rotate",
+			Method:      "POST",
+			Description: "Replace an API key with a new one with the same settings; the old key is revoked, or expires after the grace period (admin scope)",
+			Parameters:  "{id} - key ID, ?grace= (optional duration, e.g. 24h)",
+			Example:     "/admin/keys/2bb80d53/rotate?grace=24h",
+		},
+		{
+			Path:        "/admin/popular",
+			Method:      "GET",
+			Description: "Get the unconfigured locations promoted to background refresh by request volume, the busiest other locations, and the share of background provider calls they use",
+			Parameters:  "None",
+			Example:     "/admin/popular",
+		},
 	}
 
 	// Information about the API
@@ -262,7 +854,13 @@ func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
 		"version":     "1.0.0",
 		"description": "API for fetching weather and forecast data from various providers",
 		"endpoints":   endpoints,
-		"basePath":    fmt.Sprintf("http://%s", r.Host),
+		"authentication": "Send an API key in the X-API-Key header or as \"Authorization: Bearer <key>\", or, when enabled, " +
+			"a JWT from the identity provider as a bearer token; its scopes come from its claims. " +
+			"/health and /discovery are public. Missing or invalid keys or tokens get 401; revoked or expired keys, or keys lacking the " +
+			"endpoint's scope or the location, get 403; keys over their daily quota get 429. When rate limiting is on, " +
+			"each key, or each address for requests without a valid key, is limited by its tier; responses carry " +
+			"RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset, and refused requests get 429 with Retry-After.",
+		"basePath": fmt.Sprintf("http://%s", r.Host),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -311,26 +909,47 @@ func (s *Server) handleGetForecastByLocation(w http.ResponseWriter, r *http.Requ
 	// If a provider is specified, return just that provider's forecast
 	if provider != "" {
 		forecast, exists := s.forecastStore.GetForecastByProvider(location, provider)
+
+		// An expired forecast is refetched rather than served as current
+		if exists && !includeExpired(r) {
+			exists = s.freshness.Forecast(forecast, time.Now()).Freshness.State != models.FreshnessExpired
+		}
 		if !exists {
 			// If we have forecast sources, try to fetch on-demand
 			if len(s.forecastSources) > 0 && provider != "" {
 				for _, source := range s.forecastSources {
-					if strings.EqualFold(source.Name(), provider) {
+					// Wrapped sources add suffixes such as [Rate Limited] to the provider name
+					if strings.HasPrefix(strings.ToLower(source.Name()), strings.ToLower(provider)) {
 						// This is an on-demand fetch for this provider
 						ctx := r.Context()
 						forecast, err := source.FetchForecast(ctx, location, days)
 						if err != nil {
-							w.WriteHeader(http.StatusInternalServerError)
+							status := http.StatusInternalServerError
+							if datasource.ErrorKindOf(err) == datasource.ErrorNotFound {
+								status = http.StatusNotFound
+							}
+							w.WriteHeader(status)
 							json.NewEncoder(w).Encode(map[string]string{
 								"error": fmt.Sprintf("Failed to fetch forecast: %v", err),
 							})
 							return
 						}
 
-						// Store the forecast for future use
-						s.forecastStore.UpdateForecast(forecast)
+						s.popular.Record(location, time.Now())
+
+						// Store the forecast for future use under the requested location; stale forecasts from the
+						// cache are served but not stored again. Only the updater's forecasts are verified, since
+						// observations only arrive for the locations it tracks.
+						if forecast.Stale == nil {
+							forecast.Location = location
+							s.forecastStore.UpdateForecast(forecast)
+						}
 
 						// Return the forecast
+						forecast = s.annotateForecast(forecast)
+						if s.notModified(w, r, forecastRevisions(forecast), nil) {
+							return
+						}
 						response := map[string]interface{}{
 							"location":  location,
 							"provider":  provider,
@@ -353,6 +972,11 @@ func (s *Server) handleGetForecastByLocation(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
+		s.popular.Record(location, time.Now())
+		forecast = s.annotateForecast(forecast)
+		if s.notModified(w, r, forecastRevisions(forecast), nil) {
+			return
+		}
 		response := map[string]interface{}{
 			"location":  location,
 			"provider":  provider,
@@ -374,10 +998,45 @@ func (s *Server) handleGetForecastByLocation(w http.ResponseWriter, r *http.Requ
 		})
 		return
 	}
+	s.popular.Record(location, time.Now())
+
+	// Expired forecasts are left out unless asked for, and always listed so they're never served silently
+	forecasts, expired := s.freshness.Forecasts(forecasts, time.Now(), includeExpired(r))
+	if len(forecasts) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   fmt.Sprintf("Forecast data for location %s has expired; use ?includeExpired=true to see it", location),
+			"expired": expired,
+		})
+		return
+	}
+
+	if s.notModified(w, r, forecastRevisions(forecasts...), expired) {
+		return
+	}
+
+	// Pick the default forecast from the provider with the best recent verification score
+	ranking := s.rankProviders(location, forecasts)
+	ordered := make([]models.ForecastData, 0, len(forecasts))
+	for _, rank := range ranking {
+		for _, forecast := range forecasts {
+			if forecast.Provider == rank.Provider {
+				ordered = append(ordered, s.annotateForecast(forecast))
+			}
+		}
+	}
 
 	response := map[string]interface{}{
 		"location":  location,
-		"forecasts": forecasts,
+		"forecast":  ordered[0],
+		"forecasts": ordered,
+		"ranking":   ranking,
+		"selection": map[string]string{
+			"provider": ranking[0].Provider,
+			"method":   "best-scoring provider",
+			"reason":   ranking[0].Reason,
+		},
+		"expired":   expired,
 		"timestamp": time.Now(),
 	}
 
@@ -385,6 +1044,525 @@ func (s *Server) handleGetForecastByLocation(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(response)
 }
 
+// annotateForecast adds the forecast's freshness, and derived comfort indices and climate anomalies to every forecast point
+func (s *Server) annotateForecast(forecast models.ForecastData) models.ForecastData {
+	forecast = s.freshness.Forecast(forecast, time.Now())
+	return s.climateStore.AnnotateForecast(derived.AnnotateForecast(forecast))
+}
+
+// rankProviders ranks the providers of the given forecasts by verification skill over the lead times they reach
+func (s *Server) rankProviders(location string, forecasts []models.ForecastData) []models.ProviderRank {
+	providers := make([]string, 0, len(forecasts))
+	var horizon time.Duration
+	for _, forecast := range forecasts {
+		providers = append(providers, forecast.Provider)
+		if n := len(forecast.Forecasts); n > 0 {
+			if lead := forecast.Forecasts[n-1].Timestamp.Sub(forecast.Updated); lead > horizon {
+				horizon = lead
+			}
+		}
+	}
+
+	if s.verifier != nil {
+		return s.verifier.Rank(location, providers, horizon)
+	}
+
+	// Without verification there is nothing to score, so fall back to a stable alphabetical order
+	sort.Strings(providers)
+	ranking := make([]models.ProviderRank, len(providers))
+	for i, provider := range providers {
+		ranking[i] = models.ProviderRank{
+			Rank:     i + 1,
+			Provider: provider,
+			Reason:   "forecast verification is not enabled, ranked alphabetically",
+		}
+	}
+	return ranking
+}
+
+// handleGetAccuracyStats returns forecast verification scores by provider, variable and lead time
+func (s *Server) handleGetAccuracyStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.verifier == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Forecast verification is not enabled",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	location := query.Get("location")
+	provider := query.Get("provider")
+
+	// Lead time is given in hours; without it every lead time bucket is returned
+	lead := time.Duration(-1)
+	if leadStr := query.Get("lead"); leadStr != "" {
+		hours, err := strconv.Atoi(leadStr)
+		if err != nil || hours < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Invalid lead time: %s", leadStr),
+			})
+			return
+		}
+		lead = time.Duration(hours) * time.Hour
+	}
+
+	stats := make([]models.AccuracyStats, 0)
+	for _, row := range s.verifier.Stats(location, provider, lead) {
+		if callerAllows(r, row.Location) {
+			stats = append(stats, row)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"stats":     stats,
+		"count":     len(stats),
+		"timestamp": time.Now(),
+	})
+}
+
+// handleGetRetentionStats returns the retention policy and the last compaction report
+func (s *Server) handleGetRetentionStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.compactor == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Retention is not enabled",
+		})
+		return
+	}
+
+	// Zero retention means the tier is kept forever
+	describe := func(d time.Duration) string {
+		if d <= 0 {
+			return "forever"
+		}
+		return d.String()
+	}
+	policy := s.compactor.Policy()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policy": map[string]string{
+			"raw":       describe(policy.Raw),
+			"hourly":    describe(policy.Hourly),
+			"daily":     describe(policy.Daily),
+			"forecasts": describe(policy.Forecasts),
+		},
+		"lastRun":   s.compactor.LastReport(),
+		"timestamp": time.Now(),
+	})
+}
+
+// handleGetAstronomyByLocation computes sun and moon data for a location without any provider call
+func (s *Server) handleGetAstronomyByLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract location from URL path
+	path := r.URL.Path
+	if len(path) <= len("/astronomy/location/") {
+		http.Error(w, "Location not specified", http.StatusBadRequest)
+		return
+	}
+	location := path[len("/astronomy/location/"):]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	// Default to today's local date at the location once coordinates are known
+	var date time.Time
+	if dateStr := query.Get("date"); dateStr != "" {
+		parsed, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Invalid date (expected YYYY-MM-DD): %s", dateStr),
+			})
+			return
+		}
+		date = parsed
+	}
+
+	// Coordinates come from the query if given, otherwise from stored provider data
+	lat, lon, found := s.weatherStore.GetCoordinates(location)
+	if latStr, lonStr := query.Get("lat"), query.Get("lon"); latStr != "" && lonStr != "" {
+		parsedLat, latErr := strconv.ParseFloat(latStr, 64)
+		parsedLon, lonErr := strconv.ParseFloat(lonStr, 64)
+		if latErr != nil || lonErr != nil || parsedLat < -90 || parsedLat > 90 || parsedLon < -180 || parsedLon > 180 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid coordinates",
+			})
+			return
+		}
+		lat, lon, found = parsedLat, parsedLon, true
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("No coordinates known for location: %s", location),
+		})
+		return
+	}
+
+	now := time.Now()
+	if date.IsZero() {
+		date = now.UTC().Add(time.Duration(lon / 15 * float64(time.Hour)))
+	}
+
+	response := map[string]interface{}{
+		"location":  location,
+		"data":      astronomy.Compute(location, lat, lon, date, now),
+		"timestamp": now,
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetAirQualityByLocation handles requests for air quality data by location
+func (s *Server) handleGetAirQualityByLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract location from URL path
+	path := r.URL.Path
+	if len(path) <= len("/airquality/location/") {
+		http.Error(w, "Location not specified", http.StatusBadRequest)
+		return
+	}
+	location := path[len("/airquality/location/"):]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	readings, exists := s.airQualityStore.GetAirQualityByLocation(location)
+	note := ""
+
+	// Expired readings are refetched if nothing current is left, and always listed so they're never served silently
+	readings, expired := s.freshness.AirQuality(readings, time.Now(), includeExpired(r))
+	if len(readings) == 0 {
+		exists = false
+	}
+
+	// Fetch on demand for locations that aren't refreshed in the background. The sources cache these
+	// readings and failed lookups in bounded caches, so they aren't added to the store, which only holds
+	// the locations the updater refreshes.
+	if !exists && len(s.airQualitySources) > 0 {
+		var errs []string
+		for _, source := range s.airQualitySources {
+			aq, err := source.FetchAirQuality(r.Context(), location)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", source.Name(), err))
+				continue
+			}
+			aq.Location = location
+			readings = append(readings, aq)
+		}
+		readings, _ = s.freshness.AirQuality(readings, time.Now(), true)
+
+		if len(readings) == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":   fmt.Sprintf("Failed to fetch air quality for location: %s", location),
+				"details": errs,
+			})
+			return
+		}
+		exists = true
+		note = "On-demand air quality fetch"
+	}
+
+	if !exists {
+		message := fmt.Sprintf("No air quality data found for location: %s", location)
+		if len(expired) > 0 {
+			message = fmt.Sprintf("Air quality data for location %s has expired; use ?includeExpired=true to see it", location)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   message,
+			"expired": expired,
+		})
+		return
+	}
+
+	s.popular.Record(location, time.Now())
+	if s.notModified(w, r, airQualityRevisions(readings), expired) {
+		return
+	}
+
+	response := map[string]interface{}{
+		"location":  location,
+		"data":      readings,
+		"expired":   expired,
+		"timestamp": time.Now(),
+	}
+	if note != "" {
+		response["note"] = note
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetAlertsByLocation handles requests for active alerts by location
+func (s *Server) handleGetAlertsByLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract location from URL path
+	path := r.URL.Path
+	if len(path) <= len("/alerts/location/") {
+		http.Error(w, "Location not specified", http.StatusBadRequest)
+		return
+	}
+	location := path[len("/alerts/location/"):]
+
+	// An empty list is a valid answer: no alerts are in force
+	activeAlerts := s.alertStore.ForLocation(location)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"location":  location,
+		"alerts":    activeAlerts,
+		"count":     len(activeAlerts),
+		"timestamp": time.Now(),
+	})
+}
+
+// handleGetActiveAlerts returns every active alert
+func (s *Server) handleGetActiveAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Keys restricted to some locations only see alerts fetched for those
+	activeAlerts := make([]models.Alert, 0)
+	for _, alert := range s.alertStore.Active() {
+		if callerAllows(r, alert.Location) {
+			activeAlerts = append(activeAlerts, alert)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts":    activeAlerts,
+		"count":     len(activeAlerts),
+		"timestamp": time.Now(),
+	})
+}
+
+// maxHistoryDays limits how many days a single history request may cover
+const maxHistoryDays = 31
+
+// handleGetHistoryByLocation handles requests for past hourly observations by location
+func (s *Server) handleGetHistoryByLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract location from URL path
+	path := r.URL.Path
+	if len(path) <= len("/history/location/") {
+		http.Error(w, "Location not specified", http.StatusBadRequest)
+		return
+	}
+	location := path[len("/history/location/"):]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	badRequest := func(message string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	fromStr := query.Get("from")
+	if fromStr == "" {
+		badRequest("Missing from date (expected YYYY-MM-DD)")
+		return
+	}
+	from, err := time.Parse("2006-01-02", fromStr)
+	if err != nil {
+		badRequest(fmt.Sprintf("Invalid from date (expected YYYY-MM-DD): %s", fromStr))
+		return
+	}
+
+	to := from
+	if toStr := query.Get("to"); toStr != "" {
+		to, err = time.Parse("2006-01-02", toStr)
+		if err != nil {
+			badRequest(fmt.Sprintf("Invalid to date (expected YYYY-MM-DD): %s", toStr))
+			return
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	switch {
+	case to.Before(from):
+		badRequest("The to date is before the from date")
+		return
+	case from.After(today):
+		badRequest("History is only available for past days; use /forecast for future dates")
+		return
+	case to.Sub(from) >= maxHistoryDays*24*time.Hour:
+		badRequest(fmt.Sprintf("Date range is limited to %d days", maxHistoryDays))
+		return
+	}
+	if to.After(today) {
+		to = today
+	}
+
+	provider := strings.ToLower(query.Get("provider"))
+
+	// Sources cover different periods, so use the first one that returns observations
+	var errs []string
+	for _, source := range s.historySources {
+		if provider != "" && !strings.HasPrefix(strings.ToLower(source.Name()), provider) {
+			continue
+		}
+
+		history, err := source.FetchHistory(r.Context(), location, from, to)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source.Name(), err))
+			continue
+		}
+		if len(history.Hours) == 0 {
+			errs = append(errs, fmt.Sprintf("%s: no observations for this period", source.Name()))
+			continue
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"location":  location,
+			"data":      history,
+			"count":     len(history.Hours),
+			"timestamp": time.Now(),
+		})
+		return
+	}
+
+	if len(errs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "No history source available",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusBadGateway)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":   fmt.Sprintf("Failed to fetch history for location: %s", location),
+		"details": errs,
+	})
+}
+
+// maxMarineDays limits how many days of marine forecast can be requested
+const maxMarineDays = 7
+
+// handleGetMarineByLocation handles requests for marine forecasts and tides by location
+func (s *Server) handleGetMarineByLocation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract location from URL path
+	path := r.URL.Path
+	if len(path) <= len("/marine/location/") {
+		http.Error(w, "Location not specified", http.StatusBadRequest)
+		return
+	}
+	location := path[len("/marine/location/"):]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	days := 3
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		parsed, err := strconv.Atoi(daysStr)
+		if err != nil || parsed < 1 || parsed > maxMarineDays {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Invalid days parameter (expected 1-%d): %s", maxMarineDays, daysStr),
+			})
+			return
+		}
+		days = parsed
+	}
+
+	if len(s.marineSources) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "No marine source available",
+		})
+		return
+	}
+
+	provider := strings.ToLower(r.URL.Query().Get("provider"))
+
+	// Marine data is only needed for a few coastal locations, so it is fetched on demand through the cache
+	var data []models.MarineData
+	var errs []string
+	for _, source := range s.marineSources {
+		if provider != "" && !strings.HasPrefix(strings.ToLower(source.Name()), provider) {
+			continue
+		}
+
+		marine, err := source.FetchMarine(r.Context(), location, days)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", source.Name(), err))
+			continue
+		}
+		data = append(data, marine)
+	}
+
+	if len(data) == 0 {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   fmt.Sprintf("Failed to fetch marine data for location: %s", location),
+			"details": errs,
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"location":  location,
+		"days":      days,
+		"data":      data,
+		"timestamp": time.Now(),
+	})
+}
+
 // handleHealthCheck provides a simple health check endpoint
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
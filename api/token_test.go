@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"weather-service/auth"
+)
+
+// signES256 encodes a JWT with the given claims signed by key
+func signES256(t *testing.T, key *ecdsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// tokenServer returns a server requiring authentication that accepts JWTs signed by key
+func tokenServer(t *testing.T, key *ecdsa.PrivateKey) *Server {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks, err := auth.PublicKeySet(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keySet := auth.NewKeySet("", file)
+	if err := keySet.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	options := auth.DefaultTokenOptions()
+	options.Issuer, options.Audience = "https://idp.example.com/", "weather-service"
+	tokens, err := auth.NewTokenValidator(keySet, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(NewWeatherStore(), NewForecastStore(), 0)
+	server.RegisterKeyStore(auth.NewKeyStore())
+	server.RegisterTokenValidator(tokens)
+	server.SetAuthRequired(true)
+	return server
+}
+
+func TestBearerTokens(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unpublished, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := tokenServer(t, key)
+
+	now := time.Now()
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": "https://idp.example.com/", "aud": "weather-service", "sub": "dashboard",
+			"exp": now.Add(time.Hour).Unix(), "scope": "weather:read",
+		}
+		for name, value := range changes {
+			c[name] = value
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		want  int
+	}{
+		{"valid token", signES256(t, key, claims(nil)), "/weather/locations", http.StatusOK},
+		{"missing scope", signES256(t, key, claims(nil)), "/admin/keys", http.StatusForbidden},
+		{"expired", signES256(t, key, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()})), "/weather/locations", http.StatusUnauthorized},
+		{"not yet valid", signES256(t, key, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()})), "/weather/locations", http.StatusUnauthorized},
+		{"other audience", signES256(t, key, claims(map[string]interface{}{"aud": "billing"})), "/weather/locations", http.StatusUnauthorized},
+		{"other issuer", signES256(t, key, claims(map[string]interface{}{"iss": "https://evil.example.com/"})), "/weather/locations", http.StatusUnauthorized},
+		{"unpublished key", signES256(t, unpublished, claims(nil)), "/weather/locations", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		w := get(server, tt.path, map[string]string{"Authorization": "Bearer " + tt.token})
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, w.Code, tt.want, w.Body.String())
+		}
+		challenge := w.Header().Get("WWW-Authenticate")
+		if (w.Code == http.StatusUnauthorized) != strings.Contains(challenge, `error="invalid_token"`) {
+			t.Errorf("%s: status %d with WWW-Authenticate %q", tt.name, w.Code, challenge)
+		}
+	}
+
+	// Credentials that aren't JWTs are still checked as API keys
+	if w := get(server, "/weather/locations", map[string]string{"Authorization": "Bearer not-a-key"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown API key as a bearer token: status %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package astronomy
+
+import (
+	"time"
+
+	"weather-service/models"
+)
+
+// Compute returns the sun, moon and positional astronomy for a location on a local calendar date.
+// Positions are given for the requested instant when it falls on that date, otherwise at solar noon.
+func Compute(location string, lat, lon float64, date time.Time, at time.Time) models.AstronomyData {
+	sun := SunTimes(date, lat, lon)
+
+	noon := LocalNoon(date, lon)
+	positionTime := sun.SolarNoon
+	if at.After(noon.Add(-12*time.Hour)) && at.Before(noon.Add(12*time.Hour)) {
+		positionTime = at
+	}
+
+	return models.AstronomyData{
+		Location:      location,
+		Latitude:      lat,
+		Longitude:     lon,
+		Date:          date.Format("2006-01-02"),
+		Sun:           sun,
+		SolarPosition: SolarPosition(positionTime, lat, lon),
+		Moon:          MoonInfo(date, lat, lon),
+		MoonPosition:  MoonPosition(positionTime, lat, lon),
+	}
+}
+
+// FillSunTimes sets a reading's Sunrise and Sunset from its coordinates when the provider didn't supply them
+func FillSunTimes(data *models.WeatherData) {
+	if data.Latitude == 0 && data.Longitude == 0 {
+		return
+	}
+	if !data.Sunrise.IsZero() && !data.Sunset.IsZero() {
+		return
+	}
+
+	date := data.Timestamp
+	if date.IsZero() {
+		date = time.Now()
+	}
+	// Shift to local mean time so the reading's own calendar day is used
+	local := date.UTC().Add(time.Duration(data.Longitude / 15 * float64(time.Hour)))
+
+	sun := SunTimes(local, data.Latitude, data.Longitude)
+	if sun.Sunrise != nil && data.Sunrise.IsZero() {
+		data.Sunrise = *sun.Sunrise
+	}
+	if sun.Sunset != nil && data.Sunset.IsZero() {
+		data.Sunset = *sun.Sunset
+	}
+}
@@ -0,0 +1,164 @@
+package astronomy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+// Reference times are from published almanac tables, which are given to the minute
+const tolerance = 2 * time.Minute
+
+func utc(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func near(got *time.Time, want time.Time) bool {
+	if got == nil {
+		return false
+	}
+	d := got.Sub(want)
+	return d > -tolerance && d < tolerance
+}
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		name            string
+		date            time.Time
+		lat, lon        float64
+		sunrise, sunset time.Time
+		noon            time.Time
+	}{
+		{"Greenwich solstice", utc(2024, 6, 21, 0, 0), 51.4769, 0, utc(2024, 6, 21, 3, 43), utc(2024, 6, 21, 20, 21), utc(2024, 6, 21, 12, 2)},
+		{"Greenwich equinox", utc(2024, 3, 20, 0, 0), 51.4769, 0, utc(2024, 3, 20, 6, 2), utc(2024, 3, 20, 18, 14), utc(2024, 3, 20, 12, 7)},
+		// Sydney's local day starts the previous UTC day
+		{"Sydney winter", utc(2024, 6, 21, 0, 0), -33.87, 151.21, utc(2024, 6, 20, 21, 0), utc(2024, 6, 21, 6, 54), utc(2024, 6, 21, 1, 57)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sun := SunTimes(tt.date, tt.lat, tt.lon)
+			if !near(sun.Sunrise, tt.sunrise) || !near(sun.Sunset, tt.sunset) || !near(&sun.SolarNoon, tt.noon) {
+				t.Errorf("sunrise %v, sunset %v, noon %v; want %v, %v, %v",
+					sun.Sunrise, sun.Sunset, sun.SolarNoon, tt.sunrise, tt.sunset, tt.noon)
+			}
+			if sun.PolarDay || sun.PolarNight {
+				t.Error("not a polar day or night")
+			}
+			// Twilight comes in order before sunrise
+			if sun.CivilDawn == nil || sun.NauticalDawn == nil || !sun.NauticalDawn.Before(*sun.CivilDawn) ||
+				!sun.CivilDawn.Before(*sun.Sunrise) {
+				t.Errorf("twilight out of order: nautical %v, civil %v", sun.NauticalDawn, sun.CivilDawn)
+			}
+		})
+	}
+}
+
+func TestSunTimesPolar(t *testing.T) {
+	summer := SunTimes(utc(2024, 6, 21, 0, 0), 69.65, 18.96)
+	if !summer.PolarDay || summer.PolarNight || summer.Sunrise != nil || summer.Sunset != nil {
+		t.Errorf("Tromsø midsummer = %+v, want polar day", summer)
+	}
+
+	winter := SunTimes(utc(2024, 12, 21, 0, 0), 69.65, 18.96)
+	if winter.PolarDay || !winter.PolarNight || winter.Sunrise != nil {
+		t.Errorf("Tromsø midwinter = %+v, want polar night", winter)
+	}
+	// The sun still comes within 6° of the horizon, so there is civil twilight
+	if winter.CivilDawn == nil || winter.AstronomicalDawn == nil {
+		t.Error("Tromsø midwinter should have twilight")
+	}
+}
+
+func TestSolarPosition(t *testing.T) {
+	// Noon sun at the solstice stands at 90° - latitude + axial tilt, due south
+	pos := SolarPosition(utc(2024, 6, 21, 12, 2), 51.4769, 0)
+	if math.Abs(pos.Elevation-(90-51.4769+23.44)) > 0.1 || math.Abs(pos.Azimuth-180) > 1 {
+		t.Errorf("Greenwich solstice noon = %+v", pos)
+	}
+
+	pos = SolarPosition(utc(2024, 3, 20, 12, 7), 0, 0)
+	if pos.Elevation < 89.5 {
+		t.Errorf("equator equinox noon elevation = %v, want overhead", pos.Elevation)
+	}
+
+	pos = SolarPosition(utc(2024, 6, 21, 0, 0), 51.4769, 0)
+	if pos.Elevation > -10 {
+		t.Errorf("Greenwich midnight elevation = %v, want below the horizon", pos.Elevation)
+	}
+}
+
+func TestMoonPhase(t *testing.T) {
+	tests := []struct {
+		at           time.Time
+		phase        float64
+		illumination float64
+		name         string
+	}{
+		{utc(2024, 1, 11, 11, 57), 0, 0, "new moon"},
+		{utc(2024, 1, 18, 3, 53), 0.25, 0.5, "first quarter"},
+		{utc(2024, 1, 25, 17, 54), 0.5, 1, "full moon"},
+		{utc(2024, 2, 2, 23, 18), 0.75, 0.5, "last quarter"},
+	}
+	for _, tt := range tests {
+		phase, illumination := MoonPhase(tt.at)
+		phaseError := math.Min(math.Abs(phase-tt.phase), 1-math.Abs(phase-tt.phase))
+		if phaseError > 0.02 || math.Abs(illumination-tt.illumination) > 0.02 || PhaseName(phase) != tt.name {
+			t.Errorf("%s: phase %.3f (%s), illumination %.3f; want %.2f, %.2f",
+				tt.at.Format(time.RFC3339), phase, PhaseName(phase), illumination, tt.phase, tt.illumination)
+		}
+	}
+}
+
+func TestMoonInfo(t *testing.T) {
+	moon := MoonInfo(utc(2024, 1, 25, 0, 0), 51.4769, 0)
+	if !near(moon.Moonrise, utc(2024, 1, 25, 16, 4)) || !near(moon.Moonset, utc(2024, 1, 25, 8, 29)) {
+		t.Errorf("moonrise %v, moonset %v", moon.Moonrise, moon.Moonset)
+	}
+	if moon.PhaseName != "full moon" || moon.AlwaysUp || moon.AlwaysDown {
+		t.Errorf("moon = %+v", moon)
+	}
+}
+
+func TestFillSunTimes(t *testing.T) {
+	data := models.WeatherData{Latitude: 51.4769, Longitude: 0, Timestamp: utc(2024, 6, 21, 15, 0)}
+	FillSunTimes(&data)
+	if !near(&data.Sunrise, utc(2024, 6, 21, 3, 43)) || !near(&data.Sunset, utc(2024, 6, 21, 20, 21)) {
+		t.Errorf("sunrise %v, sunset %v", data.Sunrise, data.Sunset)
+	}
+
+	// Provider times and readings without coordinates are left alone
+	provided := utc(2024, 6, 21, 4, 0)
+	data = models.WeatherData{Latitude: 51.4769, Sunrise: provided, Sunset: provided, Timestamp: provided}
+	FillSunTimes(&data)
+	if !data.Sunrise.Equal(provided) {
+		t.Errorf("provider sunrise replaced with %v", data.Sunrise)
+	}
+	// A missing time is computed without replacing the provider's other one
+	data = models.WeatherData{Latitude: 51.4769, Sunrise: provided, Timestamp: provided}
+	FillSunTimes(&data)
+	if !data.Sunrise.Equal(provided) || !near(&data.Sunset, utc(2024, 6, 21, 20, 21)) {
+		t.Errorf("sunrise %v, sunset %v; want the provider's sunrise and a computed sunset", data.Sunrise, data.Sunset)
+	}
+
+	data = models.WeatherData{Timestamp: provided}
+	FillSunTimes(&data)
+	if !data.Sunrise.IsZero() {
+		t.Error("reading without coordinates should get no sunrise")
+	}
+}
+
+func TestCompute(t *testing.T) {
+	at := utc(2024, 6, 21, 15, 0)
+	data := Compute("london,uk", 51.4769, 0, utc(2024, 6, 21, 0, 0), at)
+	if data.Date != "2024-06-21" || !data.SolarPosition.Time.Equal(at) || !data.MoonPosition.Time.Equal(at) {
+		t.Errorf("positions at %v for %s, want %v", data.SolarPosition.Time, data.Date, at)
+	}
+
+	// Another day's instant falls back to solar noon
+	data = Compute("london,uk", 51.4769, 0, utc(2024, 6, 25, 0, 0), at)
+	if !data.SolarPosition.Time.Equal(data.Sun.SolarNoon) {
+		t.Errorf("position at %v, want solar noon %v", data.SolarPosition.Time, data.Sun.SolarNoon)
+	}
+}
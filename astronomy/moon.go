@@ -0,0 +1,181 @@
+package astronomy
+
+import (
+	"math"
+	"time"
+
+	"weather-service/models"
+)
+
+// sunDistance is the mean distance from the Earth to the sun in km
+const sunDistance = 149598000.0
+
+// moonCoords returns the moon's geocentric ecliptic coordinates
+func moonCoords(d float64) (dec, ra, dist float64) {
+	l := rad * (218.316 + 13.176396*d) // ecliptic longitude
+	m := rad * (134.963 + 13.064993*d) // mean anomaly
+	f := rad * (93.272 + 13.229350*d)  // mean distance
+
+	lng := l + rad*6.289*math.Sin(m)
+	lat := rad * 5.128 * math.Sin(f)
+	dist = 385001 - 20905*math.Cos(m)
+
+	return declination(lng, lat), rightAscension(lng, lat), dist
+}
+
+// moonAltitude returns the moon's altitude in radians, corrected for refraction
+func moonAltitude(t time.Time, lat, lon float64) (alt, az float64) {
+	lw := rad * -lon
+	phi := rad * lat
+	d := toDays(t)
+
+	dec, ra, _ := moonCoords(d)
+	h := siderealTime(d, lw) - ra
+	alt = altitude(h, phi, dec)
+	alt += astroRefraction(alt)
+
+	return alt, azimuth(h, phi, dec)
+}
+
+// MoonPosition returns the moon's elevation and azimuth at a time and place
+func MoonPosition(t time.Time, lat, lon float64) models.CelestialPosition {
+	alt, az := moonAltitude(t, lat, lon)
+	return models.CelestialPosition{
+		Time:      t,
+		Elevation: alt / rad,
+		Azimuth:   toDegreesFromNorth(az),
+	}
+}
+
+// MoonPhase returns the moon's phase (0-1, 0 new moon, 0.5 full moon) and illuminated fraction at a time
+func MoonPhase(t time.Time) (phase, illumination float64) {
+	d := toDays(t)
+	sDec, sRa := sunCoords(d)
+	mDec, mRa, mDist := moonCoords(d)
+
+	elongation := math.Acos(math.Sin(sDec)*math.Sin(mDec) + math.Cos(sDec)*math.Cos(mDec)*math.Cos(sRa-mRa))
+	inc := math.Atan2(sunDistance*math.Sin(elongation), mDist-sunDistance*math.Cos(elongation))
+	angle := math.Atan2(math.Cos(sDec)*math.Sin(sRa-mRa),
+		math.Sin(sDec)*math.Cos(mDec)-math.Cos(sDec)*math.Sin(mDec)*math.Cos(sRa-mRa))
+
+	sign := 1.0
+	if angle < 0 {
+		sign = -1.0
+	}
+
+	illumination = (1 + math.Cos(inc)) / 2
+	phase = 0.5 + 0.5*inc*sign/math.Pi
+	return phase, illumination
+}
+
+// PhaseName returns the conventional name for a moon phase value
+func PhaseName(phase float64) string {
+	switch {
+	case phase < 0.0625 || phase >= 0.9375:
+		return "new moon"
+	case phase < 0.1875:
+		return "waxing crescent"
+	case phase < 0.3125:
+		return "first quarter"
+	case phase < 0.4375:
+		return "waxing gibbous"
+	case phase < 0.5625:
+		return "full moon"
+	case phase < 0.6875:
+		return "waning gibbous"
+	case phase < 0.8125:
+		return "last quarter"
+	default:
+		return "waning crescent"
+	}
+}
+
+// MoonInfo computes the moon phase and the moonrise/moonset times for the local calendar date at a location.
+// Rise and set are found by fitting a parabola through the moon's altitude every hour of the local day.
+func MoonInfo(date time.Time, lat, lon float64) models.MoonInfo {
+	start := LocalNoon(date, lon).Add(-12 * time.Hour)
+	horizon := 0.133 * rad // moon's apparent radius and parallax
+
+	altAt := func(hours float64) float64 {
+		alt, _ := moonAltitude(start.Add(time.Duration(hours*float64(time.Hour))), lat, lon)
+		return alt - horizon
+	}
+
+	var rise, set float64
+	var hasRise, hasSet bool
+	var ye float64
+
+	h0 := altAt(0)
+	for i := 1.0; i <= 24; i += 2 {
+		h1 := altAt(i)
+		h2 := altAt(i + 1)
+
+		a := (h0+h2)/2 - h1
+		b := (h2 - h0) / 2
+		xe := -b / (2 * a)
+		ye = (a*xe+b)*xe + h1
+		d := b*b - 4*a*h1
+		roots := 0
+		var x1, x2 float64
+
+		if d >= 0 {
+			dx := math.Sqrt(d) / (math.Abs(a) * 2)
+			x1 = xe - dx
+			x2 = xe + dx
+			if math.Abs(x1) <= 1 {
+				roots++
+			}
+			if math.Abs(x2) <= 1 {
+				roots++
+			}
+			if x1 < -1 {
+				x1 = x2
+			}
+		}
+
+		if roots == 1 {
+			if h0 < 0 {
+				rise, hasRise = i+x1, true
+			} else {
+				set, hasSet = i+x1, true
+			}
+		} else if roots == 2 {
+			if ye < 0 {
+				rise, set = i+x2, i+x1
+			} else {
+				rise, set = i+x1, i+x2
+			}
+			hasRise, hasSet = true, true
+		}
+
+		if hasRise && hasSet {
+			break
+		}
+		h0 = h2
+	}
+
+	phase, illumination := MoonPhase(LocalNoon(date, lon))
+	info := models.MoonInfo{
+		Phase:        phase,
+		PhaseName:    PhaseName(phase),
+		Illumination: illumination,
+	}
+
+	if hasRise {
+		t := start.Add(time.Duration(rise * float64(time.Hour)))
+		info.Moonrise = &t
+	}
+	if hasSet {
+		t := start.Add(time.Duration(set * float64(time.Hour)))
+		info.Moonset = &t
+	}
+	if !hasRise && !hasSet {
+		if ye > 0 {
+			info.AlwaysUp = true
+		} else {
+			info.AlwaysDown = true
+		}
+	}
+
+	return info
+}
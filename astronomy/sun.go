@@ -0,0 +1,168 @@
+package astronomy
+
+import (
+	"math"
+	"time"
+
+	"weather-service/models"
+)
+
+// The formulas follow the low-precision algorithms from Jean Meeus' "Astronomical Algorithms"
+// as popularised by the suncalc library; they are accurate to about a minute for sun events.
+
+const (
+	rad       = math.Pi / 180
+	dayHours  = 24.0
+	j1970     = 2440588.0
+	j2000     = 2451545.0
+	j0        = 0.0009
+	obliquity = rad * 23.4397 // obliquity of the Earth's axis
+)
+
+// Sun altitudes (in degrees) that define each pair of events
+const (
+	sunriseAltitude      = -0.833 // accounts for refraction and the sun's apparent radius
+	civilAltitude        = -6.0
+	nauticalAltitude     = -12.0
+	astronomicalAltitude = -18.0
+)
+
+// toJulian converts a time to a Julian date
+func toJulian(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(dayHours*float64(time.Hour)) - 0.5 + j1970
+}
+
+// fromJulian converts a Julian date to a UTC time
+func fromJulian(j float64) time.Time {
+	return time.Unix(0, int64((j+0.5-j1970)*dayHours*float64(time.Hour))).UTC()
+}
+
+// toDays returns the number of days since J2000.0
+func toDays(t time.Time) float64 {
+	return toJulian(t) - j2000
+}
+
+func rightAscension(l, b float64) float64 {
+	return math.Atan2(math.Sin(l)*math.Cos(obliquity)-math.Tan(b)*math.Sin(obliquity), math.Cos(l))
+}
+
+func declination(l, b float64) float64 {
+	return math.Asin(math.Sin(b)*math.Cos(obliquity) + math.Cos(b)*math.Sin(obliquity)*math.Sin(l))
+}
+
+// azimuth is measured from south, turning westward
+func azimuth(h, phi, dec float64) float64 {
+	return math.Atan2(math.Sin(h), math.Cos(h)*math.Sin(phi)-math.Tan(dec)*math.Cos(phi))
+}
+
+func altitude(h, phi, dec float64) float64 {
+	return math.Asin(math.Sin(phi)*math.Sin(dec) + math.Cos(phi)*math.Cos(dec)*math.Cos(h))
+}
+
+func siderealTime(d, lw float64) float64 {
+	return rad*(280.16+360.9856235*d) - lw
+}
+
+// astroRefraction approximates atmospheric refraction for an altitude in radians
+func astroRefraction(h float64) float64 {
+	if h < 0 {
+		h = 0
+	}
+	return 0.0002967 / math.Tan(h+0.00312536/(h+0.08901179))
+}
+
+func solarMeanAnomaly(d float64) float64 {
+	return rad * (357.5291 + 0.98560028*d)
+}
+
+func eclipticLongitude(m float64) float64 {
+	center := rad * (1.9148*math.Sin(m) + 0.02*math.Sin(2*m) + 0.0003*math.Sin(3*m))
+	perihelion := rad * 102.9372
+	return m + center + perihelion + math.Pi
+}
+
+// sunCoords returns the sun's declination and right ascension
+func sunCoords(d float64) (dec, ra float64) {
+	l := eclipticLongitude(solarMeanAnomaly(d))
+	return declination(l, 0), rightAscension(l, 0)
+}
+
+// toDegreesFromNorth converts a south-based azimuth in radians to degrees clockwise from north
+func toDegreesFromNorth(az float64) float64 {
+	deg := math.Mod(az/rad+180, 360)
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// SolarPosition returns the sun's elevation and azimuth at a time and place
+func SolarPosition(t time.Time, lat, lon float64) models.CelestialPosition {
+	lw := rad * -lon
+	phi := rad * lat
+	d := toDays(t)
+
+	dec, ra := sunCoords(d)
+	h := siderealTime(d, lw) - ra
+
+	return models.CelestialPosition{
+		Time:      t,
+		Elevation: altitude(h, phi, dec) / rad,
+		Azimuth:   toDegreesFromNorth(azimuth(h, phi, dec)),
+	}
+}
+
+// LocalNoon returns the approximate instant of local mean noon for a calendar date at a longitude.
+// It is used to pick the right solar day regardless of the location's time zone.
+func LocalNoon(date time.Time, lon float64) time.Time {
+	y, m, d := date.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	return noon.Add(-time.Duration(lon / 15 * float64(time.Hour)))
+}
+
+// SunTimes computes sunrise, sunset and twilight times for the local calendar date at a location
+func SunTimes(date time.Time, lat, lon float64) models.SunTimes {
+	lw := rad * -lon
+	phi := rad * lat
+	d := toDays(LocalNoon(date, lon))
+
+	n := math.Round(d - j0 - lw/(2*math.Pi))
+	ds := j0 + lw/(2*math.Pi) + n
+	m := solarMeanAnomaly(ds)
+	l := eclipticLongitude(m)
+	dec := declination(l, 0)
+	jNoon := j2000 + ds + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*l)
+
+	times := models.SunTimes{SolarNoon: fromJulian(jNoon)}
+
+	// event computes the rising and setting times for the sun reaching a given altitude
+	event := func(altitudeDeg float64) (rise, set *time.Time, ok bool) {
+		cosH := (math.Sin(altitudeDeg*rad) - math.Sin(phi)*math.Sin(dec)) / (math.Cos(phi) * math.Cos(dec))
+		if cosH < -1 || cosH > 1 {
+			return nil, nil, false
+		}
+		w := math.Acos(cosH)
+		a := j0 + (w+lw)/(2*math.Pi) + n
+		jSet := j2000 + a + 0.0053*math.Sin(m) - 0.0069*math.Sin(2*l)
+		jRise := jNoon - (jSet - jNoon)
+
+		riseTime, setTime := fromJulian(jRise), fromJulian(jSet)
+		return &riseTime, &setTime, true
+	}
+
+	var ok bool
+	times.Sunrise, times.Sunset, ok = event(sunriseAltitude)
+	if !ok {
+		// Without a sunrise the sun is either always up or always down; noon elevation tells which
+		if SolarPosition(times.SolarNoon, lat, lon).Elevation > sunriseAltitude {
+			times.PolarDay = true
+		} else {
+			times.PolarNight = true
+		}
+	}
+	times.CivilDawn, times.CivilDusk, _ = event(civilAltitude)
+	times.NauticalDawn, times.NauticalDusk, _ = event(nauticalAltitude)
+	times.AstronomicalDawn, times.AstronomicalDusk, _ = event(astronomicalAltitude)
+
+	return times
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// keysFile is the layout of the keys file
+type keysFile struct {
+	Keys []KeyEntry `json:"keys"`
+}
+
+// SetPath sets the keys file that keys created, rotated or revoked through the store are saved to
+func (s *KeyStore) SetPath(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.path = path
+}
+
+// LoadFile adds the keys listed in a JSON file of the form {"keys": [{"name": ..., "hash": ...}]}.
+// Keys in the file replace configured keys with the same hash.
+func (s *KeyStore) LoadFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read keys file: %w", err)
+	}
+
+	var file keysFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, fmt.Errorf("failed to parse keys file %s: %w", path, err)
+	}
+
+	keys := make([]*Key, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		k, err := entry.key()
+		if err != nil {
+			return 0, fmt.Errorf("keys file %s: %w", path, err)
+		}
+		k.persist = true
+		keys = append(keys, k)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, k := range keys {
+		s.put(k)
+	}
+	return len(keys), nil
+}
+
+// save writes the managed keys to the keys file, replacing it atomically; the caller holds the lock
+func (s *KeyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	file := keysFile{Keys: []KeyEntry{}}
+	for _, k := range s.keys {
+		if k.persist {
+			file.Keys = append(file.Keys, k.entry())
+		}
+	}
+	sort.Slice(file.Keys, func(i, j int) bool {
+		if !file.Keys[i].CreatedAt.Equal(*file.Keys[j].CreatedAt) {
+			return file.Keys[i].CreatedAt.Before(*file.Keys[j].CreatedAt)
+		}
+		return file.Keys[i].Hash < file.Keys[j].Hash
+	})
+
+	encoded, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode keys: %w", err)
+	}
+
+	// CreateTemp makes the file readable by the owner only, which suits a credential store even of hashes
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create keys file: %w", err)
+	}
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write keys file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write keys file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace keys file: %w", err)
+	}
+
+	return nil
+}
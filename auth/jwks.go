@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// minKeyRefresh is the least time between refreshes forced by tokens signed with an unknown key ID, so
+// tokens with made-up key IDs can't make every request fetch the key set
+const minKeyRefresh = 30 * time.Second
+
+// ErrUnknownSigningKey is returned for tokens signed with a key that isn't in the key set
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// jwk is a JSON Web Key as published in a key set; only RSA and EC signing keys are used
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // EC curve
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// signingKey is a public key from a key set with the algorithm it verifies
+type signingKey struct {
+	alg string // RS256 or ES256
+	key crypto.PublicKey
+}
+
+// KeySet is a JSON Web Key Set read from a URL or a local file. Keys are replaced on every refresh, so keys
+// an identity provider rotates in are picked up and keys it retires stop being accepted.
+type KeySet struct {
+	url    string
+	file   string
+	client *http.Client
+
+	mutex     sync.RWMutex
+	keys      map[string]signingKey // by key ID
+	refreshed time.Time
+}
+
+// NewKeySet creates a key set; exactly one of url or file should be set. It holds no keys until refreshed.
+func NewKeySet(url, file string) *KeySet {
+	return &KeySet{
+		url:  url,
+		file: file,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		keys: make(map[string]signingKey),
+	}
+}
+
+// Refresh reads the key set again and replaces the keys; on failure the current keys are kept
+func (s *KeySet) Refresh(ctx context.Context) error {
+	var data []byte
+	var err error
+
+	if s.file != "" {
+		data, err = os.ReadFile(s.file)
+		if err != nil {
+			return fmt.Errorf("failed to read JWKS file: %w", err)
+		}
+	} else {
+		data, err = s.download(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys = keys
+	s.refreshed = time.Now()
+	return nil
+}
+
+// download fetches the key set over HTTP
+func (s *KeySet) download(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS returned non-200 status: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, nil
+}
+
+// parseKeySet decodes the RS256 and ES256 signing keys of a key set, skipping keys of other types or uses
+func parseKeySet(data []byte) (map[string]signingKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]signingKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.signingKey()
+		if err != nil {
+			log.Printf("Warning: skipping JWKS key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS has no usable RS256 or ES256 keys")
+	}
+	return keys, nil
+}
+
+// signingKey decodes a key's public key and checks it suits the algorithm it names, if any
+func (k jwk) signingKey() (signingKey, error) {
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != "RS256" {
+			return signingKey{}, fmt.Errorf("unsupported algorithm %s", k.Alg)
+		}
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return signingKey{}, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return signingKey{}, fmt.Errorf("invalid exponent")
+		}
+		if n.BitLen() < 2048 {
+			return signingKey{}, fmt.Errorf("RSA key of %d bits is too short", n.BitLen())
+		}
+		return signingKey{alg: "RS256", key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+
+	case "EC":
+		if k.Alg != "" && k.Alg != "ES256" {
+			return signingKey{}, fmt.Errorf("unsupported algorithm %s", k.Alg)
+		}
+		if k.Crv != "P-256" {
+			return signingKey{}, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return signingKey{}, fmt.Errorf("invalid P-256 coordinates")
+		}
+		// The ecdh package rejects points that aren't on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return signingKey{}, fmt.Errorf("invalid P-256 point: %w", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return signingKey{alg: "ES256", key: key}, nil
+
+	default:
+		return signingKey{}, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// key returns the key with an ID. An unknown ID refreshes the set first, at most every minKeyRefresh, since
+// it usually means the identity provider has rotated in a new key.
+func (s *KeySet) key(ctx context.Context, kid string) (signingKey, error) {
+	s.mutex.RLock()
+	key, exists := s.keys[kid]
+	refreshed := s.refreshed
+	s.mutex.RUnlock()
+	if exists {
+		return key, nil
+	}
+
+	if time.Since(refreshed) < minKeyRefresh {
+		return signingKey{}, fmt.Errorf("%w %q", ErrUnknownSigningKey, kid)
+	}
+	if err := s.Refresh(ctx); err != nil {
+		// Hold off retrying as after a successful refresh
+		s.mutex.Lock()
+		s.refreshed = time.Now()
+		s.mutex.Unlock()
+		log.Printf("Warning: failed to refresh JWKS for key %q: %v", kid, err)
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if key, exists := s.keys[kid]; exists {
+		return key, nil
+	}
+	return signingKey{}, fmt.Errorf("%w %q", ErrUnknownSigningKey, kid)
+}
+
+// Len returns the number of keys in the set
+func (s *KeySet) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.keys)
+}
+
+// Run refreshes the key set every interval until stop is closed
+func (s *KeySet) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := s.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh JWKS: %v", err)
+			}
+			cancel()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// PublicKeySet returns a key set with the RSA or P-256 public keys in a PEM file, with the given key IDs in order,
+// so tokens signed with locally generated keys can be validated
+func PublicKeySet(pemData []byte, kids ...string) ([]byte, error) {
+	var keys []jwk
+	for i := 0; ; i++ {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key %d: %w", i+1, err)
+		}
+
+		k := jwk{Use: "sig", Kid: fmt.Sprintf("key-%d", i+1)}
+		if i < len(kids) {
+			k.Kid = kids[i]
+		}
+		switch public := public.(type) {
+		case *rsa.PublicKey:
+			k.Kty, k.Alg = "RSA", "RS256"
+			k.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			k.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			if public.Curve != elliptic.P256() {
+				return nil, fmt.Errorf("public key %d is not on P-256", i+1)
+			}
+			point, err := public.ECDH()
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key %d: %w", i+1, err)
+			}
+			uncompressed := point.Bytes()
+			k.Kty, k.Alg, k.Crv = "EC", "ES256", "P-256"
+			k.X = base64.RawURLEncoding.EncodeToString(uncompressed[1:33])
+			k.Y = base64.RawURLEncoding.EncodeToString(uncompressed[33:])
+		default:
+			return nil, fmt.Errorf("public key %d is neither RSA nor EC", i+1)
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM public keys found")
+	}
+	return json.MarshalIndent(map[string][]jwk{"keys": keys}, "", "  ")
+}
@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned for bearer tokens that are malformed, badly signed, expired, not yet valid,
+// or issued by or for someone else
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// TokenOptions controls which JWTs are accepted and the scopes their claims grant
+type TokenOptions struct {
+	Issuer   string        // required iss claim
+	Audience string        // required among the aud claim
+	Leeway   time.Duration // clock skew allowed when checking exp and nbf
+
+	// Values of ScopeClaim (a space-separated string or an array) that name a scope, e.g. "weather:read",
+	// grant it. ClaimScopes grants scopes for other claim values, by claim and value, e.g.
+	// {"groups": {"weather-ops": ["admin"]}}, and can rename values of ScopeClaim.
+	ScopeClaim  string
+	ClaimScopes map[string]map[string][]Scope
+
+	Tier string // rate limit tier of token holders; empty gets the default
+}
+
+// DefaultTokenOptions returns options reading scopes from the OAuth "scope" claim with a minute of leeway
+func DefaultTokenOptions() TokenOptions {
+	return TokenOptions{
+		Leeway:     time.Minute,
+		ScopeClaim: "scope",
+	}
+}
+
+// Token is a validated JWT
+type Token struct {
+	Subject string
+	Issuer  string
+	Scopes  []Scope
+	Expires time.Time
+}
+
+// HasScope reports whether a token's claims grant a scope
+func (t Token) HasScope(scope Scope) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenValidator validates RS256 and ES256 JWTs against a key set
+type TokenValidator struct {
+	keys    *KeySet
+	options TokenOptions
+}
+
+// NewTokenValidator creates a validator; tokens are only accepted from one issuer for one audience
+func NewTokenValidator(keys *KeySet, options TokenOptions) (*TokenValidator, error) {
+	if options.Issuer == "" || options.Audience == "" {
+		return nil, fmt.Errorf("JWT validation needs an issuer and an audience")
+	}
+	for claim, values := range options.ClaimScopes {
+		for value, scopes := range values {
+			if err := validScopes(scopes); err != nil {
+				return nil, fmt.Errorf("claim %s value %q: %w", claim, value, err)
+			}
+		}
+	}
+	return &TokenValidator{keys: keys, options: options}, nil
+}
+
+// Tier returns the rate limit tier of token holders
+func (v *TokenValidator) Tier() string {
+	return v.options.Tier
+}
+
+// IsJWT reports whether a credential has the form of a JWT rather than an API key
+func IsJWT(credential string) bool {
+	return strings.Count(credential, ".") == 2
+}
+
+// Validate checks a JWT's signature, issuer, audience, expiry and not-before time, and maps its claims to scopes
+func (v *TokenValidator) Validate(ctx context.Context, token string, now time.Time) (Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Token{}, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Token{}, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	// The algorithm comes from the key, never from the token alone, so "none" or HMAC with a public key
+	// as the secret can't be used to forge tokens
+	key, err := v.keys.key(ctx, header.Kid)
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if header.Alg != key.alg {
+		return Token{}, fmt.Errorf("%w: algorithm %q does not match key %q", ErrInvalidToken, header.Alg, header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	if !verifySignature(key, parts[0]+"."+parts[1], signature) {
+		return Token{}, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	claims := make(map[string]json.RawMessage)
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Token{}, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	return v.checkClaims(claims, now)
+}
+
+// decodeSegment decodes a base64url JSON segment of a token
+func decodeSegment(segment string, dest interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(dest)
+}
+
+// verifySignature checks a signature over a token's header and claims
+func verifySignature(key signingKey, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch public := key.key.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS ES256 signatures are r and s as 32 bytes each, not ASN.1
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(public, digest[:], r, s)
+	}
+	return false
+}
+
+// checkClaims checks the registered claims and maps the rest to scopes
+func (v *TokenValidator) checkClaims(claims map[string]json.RawMessage, now time.Time) (Token, error) {
+	var token Token
+	if err := json.Unmarshal(claims["iss"], &token.Issuer); err != nil || token.Issuer != v.options.Issuer {
+		return Token{}, fmt.Errorf("%w: issuer %q is not trusted", ErrInvalidToken, token.Issuer)
+	}
+	if !containsString(audiences(claims["aud"]), v.options.Audience) {
+		return Token{}, fmt.Errorf("%w: not issued for audience %q", ErrInvalidToken, v.options.Audience)
+	}
+	json.Unmarshal(claims["sub"], &token.Subject)
+	if token.Subject == "" {
+		return Token{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	expires, ok := numericDate(claims["exp"])
+	if !ok {
+		return Token{}, fmt.Errorf("%w: no expiry", ErrInvalidToken)
+	}
+	if !now.Before(expires.Add(v.options.Leeway)) {
+		return Token{}, fmt.Errorf("%w: expired at %s", ErrInvalidToken, expires.Format(time.RFC3339))
+	}
+	token.Expires = expires
+	if _, present := claims["nbf"]; present {
+		notBefore, ok := numericDate(claims["nbf"])
+		if !ok {
+			return Token{}, fmt.Errorf("%w: invalid nbf", ErrInvalidToken)
+		}
+		if now.Add(v.options.Leeway).Before(notBefore) {
+			return Token{}, fmt.Errorf("%w: not valid before %s", ErrInvalidToken, notBefore.Format(time.RFC3339))
+		}
+	}
+
+	token.Scopes = v.scopes(claims)
+	return token, nil
+}
+
+// scopes returns the scopes granted by a token's claims, each once
+func (v *TokenValidator) scopes(claims map[string]json.RawMessage) []Scope {
+	var scopes []Scope
+	grant := func(scope Scope) {
+		for _, granted := range scopes {
+			if granted == scope {
+				return
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+
+	if v.options.ScopeClaim != "" {
+		for _, value := range claimStrings(claims[v.options.ScopeClaim]) {
+			if _, mapped := v.options.ClaimScopes[v.options.ScopeClaim][value]; mapped {
+				continue
+			}
+			if validScopes([]Scope{Scope(value)}) == nil {
+				grant(Scope(value))
+			}
+		}
+	}
+	for claim, values := range v.options.ClaimScopes {
+		for _, value := range claimStrings(claims[claim]) {
+			for _, scope := range values[value] {
+				grant(scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// claimStrings reads a claim given as a space-separated string or an array of strings
+func claimStrings(raw json.RawMessage) []string {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return strings.Fields(single)
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		return list
+	}
+	return nil
+}
+
+// audiences reads the aud claim, a single string or an array of strings; unlike scopes, a string isn't split
+func audiences(raw json.RawMessage) []string {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return []string{single}
+	}
+	var list []string
+	json.Unmarshal(raw, &list)
+	return list
+}
+
+// numericDate reads a claim of seconds since the epoch, which may have a fraction
+func numericDate(raw json.RawMessage) (time.Time, bool) {
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*1e9)), true
+}
+
+// containsString reports whether a list holds a value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
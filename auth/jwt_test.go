@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://idp.example.com/"
+	testAudience = "weather-service"
+)
+
+// testKeys are signing keys generated once for the package's tests
+var testKeys struct {
+	once  sync.Once
+	rsa   *rsa.PrivateKey
+	ec    *ecdsa.PrivateKey
+	other *ecdsa.PrivateKey // never published
+}
+
+func generateKeys(t *testing.T) {
+	t.Helper()
+	testKeys.once.Do(func() {
+		var err error
+		if testKeys.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatalf("failed to generate RSA key: %v", err)
+		}
+		if testKeys.ec, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatalf("failed to generate P-256 key: %v", err)
+		}
+		if testKeys.other, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			t.Fatalf("failed to generate P-256 key: %v", err)
+		}
+	})
+}
+
+// publicKeySet builds a JWKS for public keys through PublicKeySet, as the -jwks-from-pem flag does
+func publicKeySet(t *testing.T, kids []string, keys ...crypto.PublicKey) []byte {
+	t.Helper()
+	var pemData []byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("failed to encode public key: %v", err)
+		}
+		pemData = append(pemData, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	jwks, err := PublicKeySet(pemData, kids...)
+	if err != nil {
+		t.Fatalf("PublicKeySet: %v", err)
+	}
+	return jwks
+}
+
+// sign encodes a JWT with the given header algorithm and key ID, signed with key (nil leaves it unsigned)
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("failed to sign: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// jwksServer serves a key set that can be replaced, counting the requests for it
+type jwksServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	jwks     []byte
+	requests int
+}
+
+func newJWKSServer(t *testing.T, jwks []byte) *jwksServer {
+	t.Helper()
+	s := &jwksServer{jwks: jwks}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		s.requests++
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Write(s.jwks)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) publish(jwks []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jwks = jwks
+}
+
+func (s *jwksServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// newValidator returns a validator for a key set with the RSA key as "rsa-1" and the P-256 key as "ec-1"
+func newValidator(t *testing.T, options TokenOptions) (*TokenValidator, *KeySet, *jwksServer) {
+	t.Helper()
+	generateKeys(t)
+	server := newJWKSServer(t, publicKeySet(t, []string{"rsa-1", "ec-1"}, &testKeys.rsa.PublicKey, &testKeys.ec.PublicKey))
+
+	keys := NewKeySet(server.URL, "")
+	if err := keys.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	options.Issuer, options.Audience = testIssuer, testAudience
+	validator, err := NewTokenValidator(keys, options)
+	if err != nil {
+		t.Fatalf("NewTokenValidator: %v", err)
+	}
+	return validator, keys, server
+}
+
+func TestValidate(t *testing.T) {
+	validator, _, _ := newValidator(t, DefaultTokenOptions())
+	now := time.Now()
+
+	// claims returns valid claims with some changed; a nil value removes the claim
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   testIssuer,
+			"aud":   testAudience,
+			"sub":   "svc-reports",
+			"exp":   now.Add(5 * time.Minute).Unix(),
+			"scope": "weather:read",
+		}
+		for name, value := range changes {
+			if value == nil {
+				delete(c, name)
+			} else {
+				c[name] = value
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", sign(t, "RS256", "rsa-1", testKeys.rsa, claims(nil)), true},
+		{"ES256", sign(t, "ES256", "ec-1", testKeys.ec, claims(nil)), true},
+		{"audience in a list", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"aud": []string{"other", testAudience}})), true},
+		{"nbf in the past", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"nbf": now.Add(-time.Minute).Unix()})), true},
+		{"fractional exp", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"exp": float64(now.Add(time.Minute).UnixMilli()) / 1000})), true},
+
+		{"bad signature", sign(t, "ES256", "ec-1", testKeys.other, claims(nil)), false},
+		{"claims changed after signing", tamper(t, sign(t, "ES256", "ec-1", testKeys.ec, claims(nil))), false},
+		{"ES256 header on RSA key", sign(t, "ES256", "rsa-1", testKeys.ec, claims(nil)), false},
+		{"RS256 header on EC key", sign(t, "RS256", "ec-1", testKeys.rsa, claims(nil)), false},
+		{"HS256", sign(t, "HS256", "rsa-1", nil, claims(nil)), false},
+		{"alg none", sign(t, "none", "rsa-1", nil, claims(nil)), false},
+		{"alg none without key ID", sign(t, "none", "", nil, claims(nil)), false},
+
+		{"expired beyond leeway", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()})), false},
+		{"expired within leeway", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()})), true},
+		{"nbf beyond leeway", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()})), false},
+		{"nbf within leeway", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()})), true},
+		{"invalid nbf", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"nbf": "soon"})), false},
+		{"no exp", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"exp": nil})), false},
+
+		{"wrong issuer", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"iss": "https://evil.example.com/"})), false},
+		{"no issuer", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"iss": nil})), false},
+		{"wrong audience", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"aud": "other"})), false},
+		{"audience is not split on spaces", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"aud": "other " + testAudience})), false},
+		{"wrong audience list", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"aud": []string{"a", "b"}})), false},
+		{"no subject", sign(t, "ES256", "ec-1", testKeys.ec, claims(map[string]interface{}{"sub": nil})), false},
+
+		{"not a JWT", "wsk_abc", false},
+		{"bad base64", "!!.!!.!!", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := validator.Validate(context.Background(), test.token, now)
+			if test.valid {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				if token.Subject != "svc-reports" || token.Issuer != testIssuer {
+					t.Errorf("token = %+v, want subject svc-reports from %s", token, testIssuer)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("Validate = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+// tamper replaces a token's claims with a different subject, keeping the signature
+func tamper(t *testing.T, token string) string {
+	t.Helper()
+	var parts [3]string
+	copy(parts[:], strings.Split(token, "."))
+	payload, _ := json.Marshal(map[string]interface{}{
+		"iss": testIssuer, "aud": testAudience, "sub": "admin", "exp": time.Now().Add(time.Hour).Unix(), "scope": "admin",
+	})
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	return parts[0] + "." + parts[1] + "." + parts[2]
+}
+
+func TestValidateUnknownKeyRefreshes(t *testing.T) {
+	validator, keys, server := newValidator(t, DefaultTokenOptions())
+	claims := map[string]interface{}{"iss": testIssuer, "aud": testAudience, "sub": "svc", "exp": time.Now().Add(time.Hour).Unix()}
+	rotated := sign(t, "ES256", "ec-2", testKeys.other, claims)
+
+	// The provider rotates in a new key and retires the RSA one
+	server.publish(publicKeySet(t, []string{"ec-1", "ec-2"}, &testKeys.ec.PublicKey, &testKeys.other.PublicKey))
+	fetched := server.count()
+
+	// Just after a refresh, unknown key IDs don't fetch the key set again
+	for i := 0; i < 3; i++ {
+		if _, err := validator.Validate(context.Background(), rotated, time.Now()); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("Validate with unknown key = %v, want ErrInvalidToken", err)
+		}
+	}
+	if n := server.count() - fetched; n != 0 {
+		t.Fatalf("key set fetched %d times within the refresh throttle, want 0", n)
+	}
+
+	// Once the throttle has passed, an unknown key ID fetches the key set once and finds the new key
+	keys.mutex.Lock()
+	keys.refreshed = time.Now().Add(-2 * minKeyRefresh)
+	keys.mutex.Unlock()
+	if _, err := validator.Validate(context.Background(), rotated, time.Now()); err != nil {
+		t.Fatalf("Validate with rotated key: %v", err)
+	}
+	if n := server.count() - fetched; n != 1 {
+		t.Fatalf("key set fetched %d times, want 1", n)
+	}
+
+	// The retired key is no longer accepted, and asking for it again is throttled
+	retired := sign(t, "RS256", "rsa-1", testKeys.rsa, claims)
+	if _, err := validator.Validate(context.Background(), retired, time.Now()); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Validate with retired key = %v, want ErrInvalidToken", err)
+	}
+	if n := server.count() - fetched; n != 1 {
+		t.Errorf("key set fetched %d times, want 1", n)
+	}
+}
+
+func TestRefreshKeepsKeysOnFailure(t *testing.T) {
+	_, keys, server := newValidator(t, DefaultTokenOptions())
+
+	server.publish([]byte(`{"keys": []}`))
+	if err := keys.Refresh(context.Background()); err == nil {
+		t.Error("Refresh accepted a key set without usable keys")
+	}
+	if keys.Len() != 2 {
+		t.Errorf("key set holds %d keys after a failed refresh, want the 2 it had", keys.Len())
+	}
+}
+
+func TestScopes(t *testing.T) {
+	options := DefaultTokenOptions()
+	options.ClaimScopes = map[string]map[string][]Scope{
+		"groups": {
+			"weather-ops": {ScopeAdmin, ScopeWeatherRead},
+			"forecasters": {ScopeForecastRead},
+		},
+		"scope": {"read": {ScopeWeatherRead, ScopeForecastRead}},
+	}
+	validator, _, _ := newValidator(t, options)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   []Scope
+	}{
+		{"no scope claims", nil, nil},
+		{"scope string", map[string]interface{}{"scope": "weather:read forecast:read"}, []Scope{ScopeWeatherRead, ScopeForecastRead}},
+		{"scope array", map[string]interface{}{"scope": []string{"admin"}}, []Scope{ScopeAdmin}},
+		{"unknown scopes ignored", map[string]interface{}{"scope": "weather:write openid"}, nil},
+		{"renamed scope value", map[string]interface{}{"scope": "read"}, []Scope{ScopeWeatherRead, ScopeForecastRead}},
+		{"group", map[string]interface{}{"groups": []string{"weather-ops"}}, []Scope{ScopeAdmin, ScopeWeatherRead}},
+		{"unmapped group", map[string]interface{}{"groups": []string{"sales"}}, nil},
+		{"scopes granted once", map[string]interface{}{"scope": "weather:read", "groups": []string{"weather-ops", "forecasters"}},
+			[]Scope{ScopeWeatherRead, ScopeAdmin, ScopeForecastRead}},
+		{"claim of the wrong type", map[string]interface{}{"groups": 42}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := map[string]interface{}{"iss": testIssuer, "aud": testAudience, "sub": "svc", "exp": time.Now().Add(time.Hour).Unix()}
+			for name, value := range test.claims {
+				claims[name] = value
+			}
+			token, err := validator.Validate(context.Background(), sign(t, "ES256", "ec-1", testKeys.ec, claims), time.Now())
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if !sameScopes(token.Scopes, test.want) {
+				t.Errorf("scopes = %v, want %v", token.Scopes, test.want)
+			}
+			for _, scope := range test.want {
+				if !token.HasScope(scope) {
+					t.Errorf("HasScope(%s) = false", scope)
+				}
+			}
+		})
+	}
+}
+
+// sameScopes compares scopes regardless of order, since claim mappings are applied in map order
+func sameScopes(got, want []Scope) bool {
+	set := func(scopes []Scope) map[Scope]int {
+		counts := make(map[Scope]int)
+		for _, scope := range scopes {
+			counts[scope]++
+		}
+		return counts
+	}
+	return reflect.DeepEqual(set(got), set(want))
+}
+
+func TestNewTokenValidatorRequiresIssuerAndAudience(t *testing.T) {
+	keys := NewKeySet("", "unused.json")
+	for _, options := range []TokenOptions{
+		{Audience: testAudience},
+		{Issuer: testIssuer},
+		{Issuer: testIssuer, Audience: testAudience, ClaimScopes: map[string]map[string][]Scope{"groups": {"ops": {"weather:write"}}}},
+	} {
+		if _, err := NewTokenValidator(keys, options); err == nil {
+			t.Errorf("NewTokenValidator(%+v) succeeded", options)
+		}
+	}
+}
+
+func TestParseKeySetSkipsUnusableKeys(t *testing.T) {
+	generateKeys(t)
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	json.Unmarshal(publicKeySet(t, []string{"ec-1", "small"}, &testKeys.ec.PublicKey, &small.PublicKey), &set)
+	set.Keys = append(set.Keys,
+		jwk{Kty: "oct", Kid: "hmac"},
+		jwk{Kty: "EC", Kid: "enc", Use: "enc", Crv: "P-256", X: set.Keys[0].X, Y: set.Keys[0].Y},
+		jwk{Kty: "EC", Kid: "off-curve", Crv: "P-256", X: set.Keys[0].X, Y: set.Keys[0].X},
+	)
+	data, _ := json.Marshal(set)
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		t.Fatalf("parseKeySet: %v", err)
+	}
+	if len(keys) != 1 || keys["ec-1"].alg != "ES256" {
+		t.Errorf("parsed keys = %v, want only ec-1", keys)
+	}
+}
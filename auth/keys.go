@@ -0,0 +1,415 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// hashPrefix marks a stored key hash, e.g. "sha256:9f86d0..."
+const hashPrefix = "sha256:"
+
+// keyPrefix starts every generated key so leaked keys are easy to recognise
+const keyPrefix = "wsk_"
+
+var (
+	// ErrMissingKey is returned when a request carries no credentials
+	ErrMissingKey = errors.New("API key required")
+	// ErrUnknownKey is returned for keys that were never issued
+	ErrUnknownKey = errors.New("invalid API key")
+	// ErrRevokedKey is returned for keys that were issued and later revoked
+	ErrRevokedKey = errors.New("API key has been revoked")
+	// ErrExpiredKey is returned for keys past their expiry
+	ErrExpiredKey = errors.New("API key has expired")
+	// ErrKeyNotFound is returned when managing a key ID that doesn't exist
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// Key is an issued API key; only the hash of the secret is kept
+type Key struct {
+	ID         string     `json:"id"`   // short prefix of the hash, safe to log
+	Name       string     `json:"name"` // who the key was issued to
+	Hash       string     `json:"-"`
+	Scopes     []Scope    `json:"scopes"`
+	Locations  []string   `json:"locations,omitempty"` // locations the key may query; empty allows all
+	DailyQuota int        `json:"dailyQuota"`          // requests per UTC day; 0 is unlimited
+	Tier       string     `json:"tier,omitempty"`      // rate limit tier; empty gets the default
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Revoked    bool       `json:"revoked"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	RotatedTo  string     `json:"rotatedTo,omitempty"` // ID of the key that replaced this one
+
+	persist bool // written to the keys file; keys only listed in configuration aren't
+}
+
+// KeySpec describes a key to create
+type KeySpec struct {
+	Name       string     `json:"name"`
+	Scopes     []Scope    `json:"scopes"`     // defaults to the read scopes
+	Locations  []string   `json:"locations"`  // empty allows all
+	DailyQuota int        `json:"dailyQuota"` // 0 is unlimited
+	Tier       string     `json:"tier"`       // rate limit tier; empty gets the default
+	ExpiresAt  *time.Time `json:"expiresAt"`  // nil never expires
+}
+
+// KeyEntry is a key as written in configuration or a keys file, given either as the plain key or its hash
+type KeyEntry struct {
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"` // plain key, hashed when loaded
+	Hash       string     `json:"hash,omitempty"`
+	Scopes     []Scope    `json:"scopes,omitempty"`
+	Locations  []string   `json:"locations,omitempty"`
+	DailyQuota int        `json:"dailyQuota,omitempty"`
+	Tier       string     `json:"tier,omitempty"`
+	CreatedAt  *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	Revoked    bool       `json:"revoked,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	RotatedTo  string     `json:"rotatedTo,omitempty"`
+}
+
+// HashKey returns the stored form of an API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new random API key
+func GenerateKey() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// KeyStore holds issued API keys by hash and is safe for concurrent use
+type KeyStore struct {
+	mutex sync.RWMutex
+	keys  map[string]*Key   // by hash
+	ids   map[string]string // hash by key ID
+	usage map[string]*Usage // by key ID
+	path  string            // keys file that managed keys are saved to; empty keeps them in memory
+}
+
+// NewKeyStore creates an empty key store
+func NewKeyStore() *KeyStore {
+	return &KeyStore{
+		keys:  make(map[string]*Key),
+		ids:   make(map[string]string),
+		usage: make(map[string]*Usage),
+	}
+}
+
+// Add issues a key with the read scopes, or reinstates it if it was revoked; it is not saved to the keys file
+func (s *KeyStore) Add(name, key string) Key {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.put(&Key{Name: name, Hash: HashKey(key), CreatedAt: time.Now()})
+}
+
+// AddEntry adds a key from configuration
+func (s *KeyStore) AddEntry(entry KeyEntry) (Key, error) {
+	k, err := entry.key()
+	if err != nil {
+		return Key{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.put(k), nil
+}
+
+// key converts an entry to a key, validating its hash and scopes
+func (e KeyEntry) key() (*Key, error) {
+	hash := strings.ToLower(e.Hash)
+	switch {
+	case e.Key != "":
+		hash = HashKey(e.Key)
+	case hash == "":
+		return nil, fmt.Errorf("key %q has neither key nor hash", e.Name)
+	case !strings.HasPrefix(hash, hashPrefix) || len(hash) != len(hashPrefix)+2*sha256.Size:
+		return nil, fmt.Errorf("key %q has an invalid hash, expected %s followed by 64 hex digits", e.Name, hashPrefix)
+	}
+	if err := validScopes(e.Scopes); err != nil {
+		return nil, fmt.Errorf("key %q: %w", e.Name, err)
+	}
+
+	k := &Key{
+		Name:       e.Name,
+		Hash:       hash,
+		Scopes:     e.Scopes,
+		Locations:  e.Locations,
+		DailyQuota: e.DailyQuota,
+		Tier:       e.Tier,
+		CreatedAt:  time.Now(),
+		ExpiresAt:  e.ExpiresAt,
+		Revoked:    e.Revoked,
+		RevokedAt:  e.RevokedAt,
+		RotatedTo:  e.RotatedTo,
+	}
+	if e.CreatedAt != nil {
+		k.CreatedAt = *e.CreatedAt
+	}
+	if k.Revoked && k.RevokedAt == nil {
+		k.RevokedAt = &k.CreatedAt
+	}
+	return k, nil
+}
+
+// entry converts a key to the form saved in the keys file
+func (k *Key) entry() KeyEntry {
+	created := k.CreatedAt
+	return KeyEntry{
+		Name:       k.Name,
+		Hash:       k.Hash,
+		Scopes:     k.Scopes,
+		Locations:  k.Locations,
+		DailyQuota: k.DailyQuota,
+		Tier:       k.Tier,
+		CreatedAt:  &created,
+		ExpiresAt:  k.ExpiresAt,
+		Revoked:    k.Revoked,
+		RevokedAt:  k.RevokedAt,
+		RotatedTo:  k.RotatedTo,
+	}
+}
+
+// put stores a key by hash, filling in its ID and default scopes; the caller holds the lock
+func (s *KeyStore) put(k *Key) Key {
+	k.ID = k.Hash[len(hashPrefix) : len(hashPrefix)+8]
+	if len(k.Scopes) == 0 {
+		k.Scopes = DefaultScopes()
+	}
+	s.keys[k.Hash] = k
+	s.ids[k.ID] = k.Hash
+	return *k
+}
+
+// Create issues a new key and saves it to the keys file; the plain key is returned only here
+func (s *KeyStore) Create(spec KeySpec) (string, Key, error) {
+	if err := validScopes(spec.Scopes); err != nil {
+		return "", Key{}, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	secret, k, err := s.create(spec)
+	if err != nil {
+		return "", Key{}, err
+	}
+	// A key that can't be saved would be lost on restart, so it isn't issued at all
+	if err := s.save(); err != nil {
+		s.remove(k)
+		return "", Key{}, err
+	}
+	return secret, k, nil
+}
+
+// create generates a key whose ID isn't taken yet; the caller holds the lock
+func (s *KeyStore) create(spec KeySpec) (string, Key, error) {
+	for {
+		secret, err := GenerateKey()
+		if err != nil {
+			return "", Key{}, err
+		}
+		hash := HashKey(secret)
+		if _, taken := s.ids[hash[len(hashPrefix):len(hashPrefix)+8]]; taken {
+			continue
+		}
+		return secret, s.put(&Key{
+			Name:       spec.Name,
+			Hash:       hash,
+			Scopes:     spec.Scopes,
+			Locations:  spec.Locations,
+			DailyQuota: spec.DailyQuota,
+			Tier:       spec.Tier,
+			CreatedAt:  time.Now(),
+			ExpiresAt:  spec.ExpiresAt,
+			persist:    true,
+		}), nil
+	}
+}
+
+// remove deletes a key; the caller holds the lock
+func (s *KeyStore) remove(k Key) {
+	delete(s.keys, k.Hash)
+	delete(s.ids, k.ID)
+}
+
+// Get returns the key with an ID
+func (s *KeyStore) Get(id string) (Key, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	hash, exists := s.ids[id]
+	if !exists {
+		return Key{}, false
+	}
+	return *s.keys[hash], true
+}
+
+// List returns every key, oldest first
+func (s *KeyStore) List() []Key {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, *k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Revoke marks a key as revoked so requests using it are refused; it reports whether the key was known.
+// The revocation is kept in memory only, like keys added with Add.
+func (s *KeyStore) Revoke(key string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	k, exists := s.keys[HashKey(key)]
+	if !exists {
+		return false
+	}
+	k.revoke(time.Now())
+	return true
+}
+
+// RevokeID revokes the key with an ID and saves the revocation to the keys file
+func (s *KeyStore) RevokeID(id string) (Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hash, exists := s.ids[id]
+	if !exists {
+		return Key{}, ErrKeyNotFound
+	}
+	k := s.keys[hash]
+	before := *k
+	k.revoke(time.Now())
+	k.persist = true
+	if err := s.save(); err != nil {
+		*k = before
+		return Key{}, err
+	}
+	return *k, nil
+}
+
+// Rotate issues a replacement for a key with the same name, scopes, locations, quota, tier and expiry. The old key
+// is revoked, or keeps working for the grace period if one is given so clients can switch over.
+func (s *KeyStore) Rotate(id string, grace time.Duration) (string, Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	hash, exists := s.ids[id]
+	if !exists {
+		return "", Key{}, ErrKeyNotFound
+	}
+	old := s.keys[hash]
+	if old.Revoked {
+		return "", Key{}, ErrRevokedKey
+	}
+
+	secret, replacement, err := s.create(KeySpec{
+		Name:       old.Name,
+		Scopes:     old.Scopes,
+		Locations:  old.Locations,
+		DailyQuota: old.DailyQuota,
+		Tier:       old.Tier,
+		ExpiresAt:  old.ExpiresAt,
+	})
+	if err != nil {
+		return "", Key{}, err
+	}
+
+	before := *old
+	now := time.Now()
+	if end := now.Add(grace); grace > 0 && (old.ExpiresAt == nil || end.Before(*old.ExpiresAt)) {
+		old.ExpiresAt = &end
+	} else if grace <= 0 {
+		old.revoke(now)
+	}
+	old.RotatedTo = replacement.ID
+	old.persist = true
+
+	// Both keys change together or not at all
+	if err := s.save(); err != nil {
+		*old = before
+		s.remove(replacement)
+		return "", Key{}, err
+	}
+	return secret, replacement, nil
+}
+
+// revoke marks a key as revoked unless it already is
+func (k *Key) revoke(now time.Time) {
+	if !k.Revoked {
+		k.Revoked = true
+		k.RevokedAt = &now
+	}
+}
+
+// Lookup returns the key matching a presented secret, or ErrMissingKey, ErrUnknownKey, ErrRevokedKey or ErrExpiredKey
+func (s *KeyStore) Lookup(key string) (Key, error) {
+	if key == "" {
+		return Key{}, ErrMissingKey
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	k, exists := s.keys[HashKey(key)]
+	if !exists {
+		return Key{}, ErrUnknownKey
+	}
+	if k.Revoked {
+		return *k, ErrRevokedKey
+	}
+	if k.ExpiresAt != nil && !time.Now().Before(*k.ExpiresAt) {
+		return *k, ErrExpiredKey
+	}
+	return *k, nil
+}
+
+// Len returns the number of keys, including revoked ones
+func (s *KeyStore) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.keys)
+}
+
+// AllowsLocation reports whether a key may query a location
+func (k Key) AllowsLocation(location string) bool {
+	return allowsLocation(k.Locations, location)
+}
+
+// allowsLocation reports whether a location is in a list of allowed ones; an empty list allows every location
+func allowsLocation(allowed []string, location string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	location = models.NormalizeLocation(location)
+	for _, a := range allowed {
+		if models.NormalizeLocation(a) == location {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashKey(t *testing.T) {
+	hash := HashKey("test")
+	if hash != "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08" {
+		t.Errorf("HashKey(test) = %s", hash)
+	}
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := GenerateKey()
+	if !strings.HasPrefix(key, keyPrefix) || key == other {
+		t.Errorf("GenerateKey returned %q and %q", key, other)
+	}
+}
+
+func TestKeyEntryValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry KeyEntry
+		valid bool
+	}{
+		{"plain key", KeyEntry{Name: "a", Key: "secret"}, true},
+		{"hash", KeyEntry{Name: "a", Hash: HashKey("secret")}, true},
+		{"upper case hash", KeyEntry{Name: "a", Hash: strings.ToUpper(HashKey("secret"))}, true},
+		{"neither", KeyEntry{Name: "a"}, false},
+		{"short hash", KeyEntry{Name: "a", Hash: "sha256:abcd"}, false},
+		{"unknown scope", KeyEntry{Name: "a", Key: "secret", Scopes: []Scope{"weather:write"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyStore().AddEntry(tt.entry)
+			if (err == nil) != tt.valid {
+				t.Errorf("AddEntry error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	store := NewKeyStore()
+	past := time.Now().Add(-time.Hour)
+	store.AddEntry(KeyEntry{Name: "live", Key: "live"})
+	store.AddEntry(KeyEntry{Name: "revoked", Key: "revoked", Revoked: true})
+	store.AddEntry(KeyEntry{Name: "expired", Key: "expired", ExpiresAt: &past})
+
+	tests := []struct {
+		secret string
+		want   error
+	}{
+		{"live", nil},
+		{"", ErrMissingKey},
+		{"unknown", ErrUnknownKey},
+		{"revoked", ErrRevokedKey},
+		{"expired", ErrExpiredKey},
+	}
+	for _, tt := range tests {
+		if _, err := store.Lookup(tt.secret); !errors.Is(err, tt.want) {
+			t.Errorf("Lookup(%q) = %v, want %v", tt.secret, err, tt.want)
+		}
+	}
+
+	k, _ := store.Lookup("live")
+	if len(k.Scopes) == 0 || k.ID != HashKey("live")[len(hashPrefix):len(hashPrefix)+8] {
+		t.Errorf("key %+v should have the default scopes and an ID from its hash", k)
+	}
+}
+
+func TestAuthorizeQuotaAndLocations(t *testing.T) {
+	store := NewKeyStore()
+	store.AddEntry(KeyEntry{Name: "a", Key: "secret", DailyQuota: 2, Locations: []string{"London, UK"}})
+	day := time.Date(2024, 3, 10, 23, 0, 0, 0, time.UTC)
+
+	if _, err := store.Authorize("secret", ScopeAdmin, "", day); !errors.Is(err, ErrMissingScope) {
+		t.Errorf("admin scope: %v, want missing scope", err)
+	}
+	if _, err := store.Authorize("secret", ScopeWeatherRead, "Paris,FR", day); !errors.Is(err, ErrLocationNotAllowed) {
+		t.Errorf("other location: %v, want not allowed", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := store.Authorize("secret", ScopeWeatherRead, "london,uk", day); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if _, err := store.Authorize("secret", ScopeWeatherRead, "london,uk", day); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("third request: %v, want quota exceeded", err)
+	}
+
+	// Refused requests don't count, and the quota resets at midnight UTC
+	k, _ := store.Lookup("secret")
+	if usage := store.usageFor(k.ID, day); usage.Today != 2 || usage.Total != 2 || usage.Refused != 1 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if _, err := store.Authorize("secret", ScopeWeatherRead, "london,uk", day.Add(2*time.Hour)); err != nil {
+		t.Errorf("next day: %v", err)
+	}
+	if reset := QuotaReset(day); !reset.Equal(time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("QuotaReset = %s", reset)
+	}
+}
+
+func TestKeysFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store := NewKeyStore()
+	store.SetPath(path)
+	store.Add("configured", "configured")
+
+	secret, created, err := store.Create(KeySpec{Name: "client", Locations: []string{"London,UK"}, DailyQuota: 10})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	newSecret, replacement, err := store.Rotate(created.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	loaded := NewKeyStore()
+	if n, err := loaded.LoadFile(path); err != nil || n != 2 {
+		t.Fatalf("LoadFile = %d, %v; want the 2 managed keys only", n, err)
+	}
+	old, err := loaded.Lookup(secret)
+	if err != nil || old.RotatedTo != replacement.ID || old.ExpiresAt == nil || old.DailyQuota != 10 {
+		t.Errorf("old key = %+v, %v; want it rotated with a grace period", old, err)
+	}
+	if k, err := loaded.Lookup(newSecret); err != nil || k.Name != "client" || len(k.Locations) != 1 {
+		t.Errorf("replacement = %+v, %v", k, err)
+	}
+	if _, err := loaded.Lookup("configured"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("configured key should not be saved, got %v", err)
+	}
+}
+
+func TestFailedSaveChangesNothing(t *testing.T) {
+	dir := t.TempDir()
+	store := NewKeyStore()
+	store.SetPath(filepath.Join(dir, "keys.json"))
+	secret, k, err := store.Create(KeySpec{Name: "client"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Saves now fail, as the keys file's directory doesn't exist
+	store.SetPath(filepath.Join(dir, "missing", "keys.json"))
+
+	if secret, _, err := store.Create(KeySpec{Name: "unsaved"}); err == nil || secret != "" {
+		t.Errorf("Create = %q, %v; want an error and no key", secret, err)
+	}
+	if store.Len() != 1 {
+		t.Errorf("store has %d keys after a failed create, want 1", store.Len())
+	}
+
+	if _, err := store.RevokeID(k.ID); err == nil {
+		t.Error("RevokeID should fail")
+	}
+	if _, err := store.Lookup(secret); err != nil {
+		t.Errorf("key should still work after a failed revoke: %v", err)
+	}
+
+	if _, _, err := store.Rotate(k.ID, 0); err == nil {
+		t.Error("Rotate should fail")
+	}
+	if current, err := store.Lookup(secret); err != nil || current.RotatedTo != "" || store.Len() != 1 {
+		t.Errorf("after a failed rotate: %+v, %v, %d keys; want the key unchanged and no replacement", current, err, store.Len())
+	}
+}
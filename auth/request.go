@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	KeyID  string // ID of the API key used; empty for bearer tokens
+	Name   string // who the key was issued to, or the token's subject
+	Issuer string // issuer of the bearer token, for callers authenticated with a JWT
+
+	// Locations the key is restricted to; empty allows every location
+	Locations []string
+}
+
+// AllowsLocation reports whether the caller may see data for a location
+func (p Principal) AllowsLocation(location string) bool {
+	return allowsLocation(p.Locations, location)
+}
+
+type principalKey struct{}
+
+// Credential returns the API key or JWT presented in the X-API-Key header or as a bearer token, or "" if there is none
+func Credential(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	scheme, token, found := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// WithPrincipal returns a context carrying the authenticated caller
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the authenticated caller of a request, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
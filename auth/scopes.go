@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	// ScopeWeatherRead allows reading observations and everything derived from them, such as history,
+	// air quality, alerts, astronomy and marine data
+	ScopeWeatherRead Scope = "weather:read"
+	// ScopeForecastRead allows reading forecasts
+	ScopeForecastRead Scope = "forecast:read"
+	// ScopeAdmin allows the admin endpoints, including key management
+	ScopeAdmin Scope = "admin"
+)
+
+var (
+	// ErrMissingScope is returned when a key lacks the scope a request needs
+	ErrMissingScope = errors.New("API key lacks the required scope")
+	// ErrUnknownScope is returned when a key is given a scope that doesn't exist
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+// DefaultScopes returns the scopes of keys that don't list any: every read scope, but not admin
+func DefaultScopes() []Scope {
+	return []Scope{ScopeWeatherRead, ScopeForecastRead}
+}
+
+// HasScope reports whether a key was granted a scope
+func (k Key) HasScope(scope Scope) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// validScopes checks that every scope is known
+func validScopes(scopes []Scope) error {
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeWeatherRead, ScopeForecastRead, ScopeAdmin:
+		default:
+			return fmt.Errorf("%w %q", ErrUnknownScope, scope)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrLocationNotAllowed is returned when a key restricted to some locations queries another
+	ErrLocationNotAllowed = errors.New("API key is not allowed to query this location")
+	// ErrQuotaExceeded is returned when a key has used its requests for the day
+	ErrQuotaExceeded = errors.New("API key daily quota exceeded")
+)
+
+// Usage counts a key's requests; the daily count resets at midnight UTC
+type Usage struct {
+	Day      string     `json:"day"`      // UTC day of the daily count, e.g. "2024-03-03"
+	Today    int        `json:"today"`    // requests accepted on that day
+	Total    int64      `json:"total"`    // requests accepted since the key was issued
+	Refused  int64      `json:"refused"`  // requests refused for exceeding the quota
+	LastUsed *time.Time `json:"lastUsed"` // time of the last accepted request
+}
+
+// Authorize checks a presented key against the scope a request needs and the location it queries, if any,
+// then counts the request against the key's daily quota. Requests that don't name a location, such as
+// listings, are allowed to keys restricted to some locations, and the handlers leave other locations out.
+func (s *KeyStore) Authorize(secret string, scope Scope, location string, now time.Time) (Key, error) {
+	k, err := s.Lookup(secret)
+	if err != nil {
+		return k, err
+	}
+	if !k.HasScope(scope) {
+		return k, fmt.Errorf("%w %s", ErrMissingScope, scope)
+	}
+	if location != "" && !k.AllowsLocation(location) {
+		return k, ErrLocationNotAllowed
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	usage := s.usageFor(k.ID, now)
+	if k.DailyQuota > 0 && usage.Today >= k.DailyQuota {
+		usage.Refused++
+		return k, ErrQuotaExceeded
+	}
+	usage.Today++
+	usage.Total++
+	usage.LastUsed = &now
+	return k, nil
+}
+
+// usageFor returns a key's usage with the daily count rolled over to now's day; the caller holds the lock
+func (s *KeyStore) usageFor(id string, now time.Time) *Usage {
+	usage, exists := s.usage[id]
+	if !exists {
+		usage = &Usage{}
+		s.usage[id] = usage
+	}
+	if day := now.UTC().Format("2006-01-02"); usage.Day != day {
+		usage.Day = day
+		usage.Today = 0
+	}
+	return usage
+}
+
+// Usage returns the requests counted for a key
+func (s *KeyStore) Usage(id string) Usage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return *s.usageFor(id, time.Now())
+}
+
+// QuotaReset returns when daily quotas next reset
+func QuotaReset(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
+
+// Snapshot encodes the usage counters as JSON so quotas survive a restart
+func (s *KeyStore) Snapshot() (json.RawMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return json.Marshal(s.usage)
+}
+
+// Restore replaces the usage counters with a snapshot
+func (s *KeyStore) Restore(data json.RawMessage) error {
+	usage := make(map[string]*Usage)
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return fmt.Errorf("failed to decode key usage: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.usage = usage
+	return nil
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// CachedAirQualitySource wraps an AirQualitySource and adds caching functionality
+type CachedAirQualitySource struct {
+	source         datasource.AirQualitySource
+	backend        Backend
+	prefix         string // keeps this source's keys apart in a shared backend
+	mutex          sync.RWMutex
+	cacheDuration  time.Duration
+	negative       *NegativeCache
+	cacheHitCount  int
+	cacheMissCount int
+}
+
+// airQualityCacheEntry represents a cached air quality reading with its timestamp
+type airQualityCacheEntry struct {
+	Data      models.AirQuality
+	Timestamp time.Time
+}
+
+// NewCachedAirQualitySource creates a new cached wrapper around an air quality source, held in a bounded in-memory LRU
+func NewCachedAirQualitySource(source datasource.AirQualitySource, cacheDuration time.Duration) *CachedAirQualitySource {
+	return NewCachedAirQualitySourceWithBackend(source, cacheDuration, NewLRUBackend(DefaultMaxEntries, DefaultMaxBytes))
+}
+
+// NewCachedAirQualitySourceWithBackend creates a new cached wrapper around an air quality source on top of a cache backend
+func NewCachedAirQualitySourceWithBackend(source datasource.AirQualitySource, cacheDuration time.Duration, backend Backend) *CachedAirQualitySource {
+	return &CachedAirQualitySource{
+		source:        source,
+		backend:       backend,
+		prefix:        "airquality:" + source.Name() + ":",
+		cacheDuration: cacheDuration,
+	}
+}
+
+// Name returns the name of the underlying air quality source with [Cached] prefix
+func (c *CachedAirQualitySource) Name() string {
+	return c.source.Name() + " [Cached]"
+}
+
+// SetNegativeCache sets the cache of provider errors consulted before fetching; nil disables it
+func (c *CachedAirQualitySource) SetNegativeCache(negative *NegativeCache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.negative = negative
+}
+
+// FetchAirQuality fetches air quality, using cache when available
+func (c *CachedAirQualitySource) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	cacheKey := models.NormalizeLocation(location)
+
+	c.mutex.RLock()
+	negative := c.negative
+	c.mutex.RUnlock()
+
+	// First check if we have this reading in the cache
+	var entry airQualityCacheEntry
+	found := getEntry(ctx, c.backend, c.prefix+cacheKey, &entry)
+
+	// If found and not expired, return the cached data
+	if found && time.Since(entry.Timestamp) < c.cacheDuration {
+		c.mutex.Lock()
+		c.cacheHitCount++
+		c.mutex.Unlock()
+
+		fmt.Printf("Air Quality Cache HIT for %s from %s (age: %s)\n",
+			location, c.source.Name(), time.Since(entry.Timestamp).Round(time.Second))
+
+		return entry.Data, nil
+	}
+
+	// Cache miss or expired, fetch fresh data
+	c.mutex.Lock()
+	c.cacheMissCount++
+	c.mutex.Unlock()
+
+	fmt.Printf("Air Quality Cache MISS for %s from %s, fetching fresh data...\n", location, c.source.Name())
+
+	// Requests that recently failed for a cacheable reason, such as an unknown location, aren't repeated
+	if err := negative.Lookup(c.prefix, cacheKey); err != nil {
+		return models.AirQuality{}, err
+	}
+	aq, err := c.source.FetchAirQuality(ctx, location)
+	if err != nil {
+		negative.Store(c.prefix, cacheKey, err)
+		return models.AirQuality{}, err
+	}
+
+	// Store in cache
+	setEntry(ctx, c.backend, c.prefix+cacheKey, airQualityCacheEntry{
+		Data:      aq,
+		Timestamp: time.Now(),
+	}, c.cacheDuration)
+
+	return aq, nil
+}
+
+// Snapshot encodes the cached entries as JSON, keeping the time each was fetched
+func (c *CachedAirQualitySource) Snapshot() (json.RawMessage, error) {
+	return snapshotBackend(c.backend)
+}
+
+// Restore replaces the cached entries with a snapshot
+func (c *CachedAirQualitySource) Restore(data json.RawMessage) error {
+	return restoreBackend(c.backend, data)
+}
+
+// CacheStats returns statistics about cache hits and misses, and entries evicted by the backend
+func (c *CachedAirQualitySource) CacheStats() (hits, misses, evictions int) {
+	evictions = int(c.backend.Evictions())
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cacheHitCount, c.cacheMissCount, evictions
+}
+
+// Ensure CachedAirQualitySource implements AirQualitySource
+var _ datasource.AirQualitySource = (*CachedAirQualitySource)(nil)
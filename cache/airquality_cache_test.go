@@ -0,0 +1,71 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// fakeAirQualitySource knows a single location and counts its calls
+type fakeAirQualitySource struct {
+	known string
+	calls int
+}
+
+func (f *fakeAirQualitySource) Name() string { return "Fake" }
+
+func (f *fakeAirQualitySource) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	f.calls++
+	if location != f.known {
+		return models.AirQuality{}, &datasource.ProviderError{Provider: "Fake", Kind: datasource.ErrorNotFound, Message: "location not found"}
+	}
+	return models.AirQuality{Provider: "Fake", Location: location, PM25: 12}, nil
+}
+
+func TestCachedAirQualitySource(t *testing.T) {
+	source := &fakeAirQualitySource{known: "London,UK"}
+	cached := cache.NewCachedAirQualitySourceWithBackend(source, time.Minute, cache.NewLRUBackend(1, 0))
+	cached.SetNegativeCache(cache.NewNegativeCache(cache.DefaultNegativeTTLs(), 10))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		aq, err := cached.FetchAirQuality(ctx, "London,UK")
+		if err != nil || aq.PM25 != 12 {
+			t.Fatalf("FetchAirQuality London = %+v, %v", aq, err)
+		}
+		if _, err := cached.FetchAirQuality(ctx, "Atlantis"); datasource.ErrorKindOf(err) != datasource.ErrorNotFound {
+			t.Fatalf("FetchAirQuality Atlantis = %v, want not found", err)
+		}
+	}
+	if source.calls != 2 {
+		t.Errorf("source called %d times, want once per location", source.calls)
+	}
+
+	hits, misses, _ := cached.CacheStats()
+	if hits != 2 || misses != 4 {
+		t.Errorf("hits, misses = %d, %d; want 2, 4", hits, misses)
+	}
+}
+
+func TestCachedAirQualitySourceIsBounded(t *testing.T) {
+	source := &fakeAirQualitySource{known: "London,UK"}
+	backend := cache.NewLRUBackend(1, 0)
+	cached := cache.NewCachedAirQualitySourceWithBackend(source, time.Minute, backend)
+	ctx := context.Background()
+
+	source.known = "Paris,FR"
+	cached.FetchAirQuality(ctx, "Paris,FR")
+	source.known = "London,UK"
+	cached.FetchAirQuality(ctx, "London,UK")
+
+	if backend.Len() != 1 {
+		t.Errorf("backend holds %d entries, want 1", backend.Len())
+	}
+	if _, _, evictions := cached.CacheStats(); evictions != 1 {
+		t.Errorf("evictions = %d, want 1", evictions)
+	}
+}
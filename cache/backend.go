@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Default bounds for the in-memory backend used when a cache is created without one
+const (
+	DefaultMaxEntries = 1000
+	DefaultMaxBytes   = 32 << 20 // 32 MiB
+)
+
+// Backend stores encoded cache entries by key. Implementations bound their own size
+// and report how many entries they removed to make room.
+type Backend interface {
+	// Get returns the value stored under key, and false if there is none
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores a value under key; a ttl of zero keeps it until it is evicted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete removes the value stored under key, if any
+	Delete(ctx context.Context, key string) error
+
+	// Evictions returns the number of entries removed to stay within the size bounds
+	Evictions() int64
+}
+
+// snapshotter is implemented by backends whose entries are lost on restart
+type snapshotter interface {
+	Snapshot() (json.RawMessage, error)
+	Restore(data json.RawMessage) error
+}
+
+// getEntry reads and decodes the entry stored under key. Backend errors are logged
+// and treated as a miss so an unavailable cache never fails a request.
+func getEntry(ctx context.Context, backend Backend, key string, entry interface{}) bool {
+	value, found, err := backend.Get(ctx, key)
+	if err != nil {
+		log.Printf("Error reading cache entry %s: %v", key, err)
+		return false
+	}
+	if !found {
+		return false
+	}
+	if err := json.Unmarshal(value, entry); err != nil {
+		log.Printf("Error decoding cache entry %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// setEntry encodes and stores an entry under key, logging any failure
+func setEntry(ctx context.Context, backend Backend, key string, entry interface{}, ttl time.Duration) {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding cache entry %s: %v", key, err)
+		return
+	}
+	if err := backend.Set(ctx, key, value, ttl); err != nil {
+		log.Printf("Error storing cache entry %s: %v", key, err)
+	}
+}
+
+// snapshotBackend snapshots the backend's entries if they are held in memory.
+// Shared backends such as Redis outlive the process, so there is nothing to keep.
+func snapshotBackend(backend Backend) (json.RawMessage, error) {
+	if s, ok := backend.(snapshotter); ok {
+		return s.Snapshot()
+	}
+	return json.RawMessage("null"), nil
+}
+
+// restoreBackend restores a snapshot taken by snapshotBackend
+func restoreBackend(backend Backend, data json.RawMessage) error {
+	s, ok := backend.(snapshotter)
+	if !ok || string(data) == "null" {
+		return nil
+	}
+	return s.Restore(data)
+}
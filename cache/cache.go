@@ -2,7 +2,9 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -13,9 +15,13 @@ import (
 // CachedDataSource wraps a DataSource and adds caching functionality
 type CachedDataSource struct {
 	source         datasource.DataSource
-	cache          map[string]cacheEntry
+	backend        Backend
+	prefix         string // keeps this source's keys apart in a shared backend
 	mutex          sync.RWMutex
 	cacheDuration  time.Duration
+	stale          StaleOptions
+	negative       *NegativeCache
+	refreshing     map[string]bool // locations with a background refresh running
 	cacheHitCount  int
 	cacheMissCount int
 }
@@ -26,15 +32,36 @@ type cacheEntry struct {
 	Timestamp time.Time
 }
 
-// NewCachedDataSource creates a new cached wrapper around a data source
+// NewCachedDataSource creates a new cached wrapper around a data source, held in a bounded in-memory LRU
 func NewCachedDataSource(source datasource.DataSource, cacheDuration time.Duration) *CachedDataSource {
+	return NewCachedDataSourceWithBackend(source, cacheDuration, NewLRUBackend(DefaultMaxEntries, DefaultMaxBytes))
+}
+
+// NewCachedDataSourceWithBackend creates a new cached wrapper around a data source on top of a cache backend
+func NewCachedDataSourceWithBackend(source datasource.DataSource, cacheDuration time.Duration, backend Backend) *CachedDataSource {
 	return &CachedDataSource{
 		source:        source,
-		cache:         make(map[string]cacheEntry),
+		backend:       backend,
+		prefix:        "weather:" + source.Name() + ":",
 		cacheDuration: cacheDuration,
+		refreshing:    make(map[string]bool),
 	}
 }
 
+// SetStaleOptions configures serving expired entries while revalidating or when the source fails
+func (c *CachedDataSource) SetStaleOptions(options StaleOptions) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stale = options
+}
+
+// SetNegativeCache sets the cache of provider errors consulted before fetching; nil disables it
+func (c *CachedDataSource) SetNegativeCache(negative *NegativeCache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.negative = negative
+}
+
 // Name returns the name of the underlying data source with [Cached] prefix
 func (c *CachedDataSource) Name() string {
 	return c.source.Name() + " [Cached]"
@@ -42,13 +69,17 @@ func (c *CachedDataSource) Name() string {
 
 // FetchWeatherData fetches weather data, using cache when available
 func (c *CachedDataSource) FetchWeatherData(ctx context.Context, location string) (models.WeatherData, error) {
-	// First check if we have this data in the cache
 	c.mutex.RLock()
-	entry, found := c.cache[location]
+	stale, negative := c.stale, c.negative
 	c.mutex.RUnlock()
 
+	// First check if we have this data in the cache
+	var entry cacheEntry
+	found := getEntry(ctx, c.backend, c.prefix+location, &entry)
+	age := time.Since(entry.Timestamp)
+
 	// If found and not expired, return the cached data
-	if found && time.Since(entry.Timestamp) < c.cacheDuration {
+	if found && age < c.cacheDuration {
 		c.mutex.Lock()
 		c.cacheHitCount++
 		c.mutex.Unlock()
@@ -59,6 +90,20 @@ func (c *CachedDataSource) FetchWeatherData(ctx context.Context, location string
 		return entry.Data, nil
 	}
 
+	// Recently expired data is served while a background refresh replaces it
+	if found && age < c.cacheDuration+stale.WhileRevalidate {
+		c.mutex.Lock()
+		c.cacheHitCount++
+		c.mutex.Unlock()
+
+		fmt.Printf("Cache STALE for %s from %s (age: %s), revalidating in background\n",
+			location, c.source.Name(), age.Round(time.Second))
+
+		c.revalidate(location, stale)
+		entry.Data.Stale = staleNotice(models.StaleRevalidating, age, nil)
+		return entry.Data, nil
+	}
+
 	// Cache miss or expired, fetch fresh data
 	c.mutex.Lock()
 	c.cacheMissCount++
@@ -67,27 +112,85 @@ func (c *CachedDataSource) FetchWeatherData(ctx context.Context, location string
 	fmt.Printf("Cache MISS for %s from %s, fetching fresh data...\n",
 		location, c.source.Name())
 
-	data, err := c.source.FetchWeatherData(ctx, location)
+	// Requests that recently failed for a cacheable reason, such as an unknown location, aren't repeated
+	var data models.WeatherData
+	err := negative.Lookup(c.prefix, location)
+	if err == nil {
+		data, err = c.source.FetchWeatherData(ctx, location)
+		if err != nil {
+			negative.Store(c.prefix, location, err)
+		}
+	}
 	if err != nil {
+		// The last good data is better than none while the source is failing
+		if found && age < c.cacheDuration+stale.IfError {
+			log.Printf("Serving stale weather for %s from %s (age: %s) after error: %v",
+				location, c.source.Name(), age.Round(time.Second), err)
+			entry.Data.Stale = staleNotice(models.StaleSourceError, age, err)
+			return entry.Data, nil
+		}
 		return models.WeatherData{}, err
 	}
 
-	// Store in cache
-	c.mutex.Lock()
-	c.cache[location] = cacheEntry{
+	c.store(ctx, location, data, stale)
+	return data, nil
+}
+
+// store caches data, keeping it long enough past its lifetime to be served stale
+func (c *CachedDataSource) store(ctx context.Context, location string, data models.WeatherData, stale StaleOptions) {
+	setEntry(ctx, c.backend, c.prefix+location, cacheEntry{
 		Data:      data,
 		Timestamp: time.Now(),
+	}, c.cacheDuration+stale.retention())
+}
+
+// revalidate refreshes a location in the background unless a refresh is already running
+func (c *CachedDataSource) revalidate(location string, stale StaleOptions) {
+	c.mutex.Lock()
+	if c.refreshing[location] {
+		c.mutex.Unlock()
+		return
 	}
+	c.refreshing[location] = true
 	c.mutex.Unlock()
 
-	return data, nil
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.refreshing, location)
+			c.mutex.Unlock()
+		}()
+
+		// The request that triggered the refresh may already be done, so don't use its context
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+
+		data, err := c.source.FetchWeatherData(ctx, location)
+		if err != nil {
+			log.Printf("Error revalidating cached weather for %s from %s: %v", location, c.source.Name(), err)
+			return
+		}
+		c.store(ctx, location, data, stale)
+	}()
+}
+
+// Snapshot encodes the cached entries as JSON, keeping the time each was fetched
+func (c *CachedDataSource) Snapshot() (json.RawMessage, error) {
+	return snapshotBackend(c.backend)
+}
+
+// Restore replaces the cached entries with a snapshot
+func (c *CachedDataSource) Restore(data json.RawMessage) error {
+	return restoreBackend(c.backend, data)
 }
 
-// CacheStats returns statistics about cache hits and misses
-func (c *CachedDataSource) CacheStats() (hits, misses int) {
+// CacheStats returns statistics about cache hits and misses, and entries evicted by the backend
+func (c *CachedDataSource) CacheStats() (hits, misses, evictions int) {
+	evictions = int(c.backend.Evictions())
+
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.cacheHitCount, c.cacheMissCount
+	return c.cacheHitCount, c.cacheMissCount, evictions
 }
 
 // Ensure CachedDataSource implements the DataSource interface
@@ -2,7 +2,9 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -13,84 +15,207 @@ import (
 // CachedForecastSource wraps a ForecastSource and adds caching functionality
 type CachedForecastSource struct {
 	source         datasource.ForecastSource
-	cache          map[string]forecastCacheEntry // key is location:days
+	backend        Backend
+	prefix         string // keeps this source's keys apart in a shared backend
 	mutex          sync.RWMutex
 	cacheDuration  time.Duration
+	stale          StaleOptions
+	negative       *NegativeCache
+	refreshing     map[string]bool // cache keys with a background refresh running
 	cacheHitCount  int
 	cacheMissCount int
 }
 
-// forecastCacheEntry represents a cached forecast with its timestamp
+// forecastCacheEntry represents a cached forecast with its timestamp and the days it was fetched for
 type forecastCacheEntry struct {
 	Data      models.ForecastData
 	Timestamp time.Time
+	Days      int
 }
 
-// NewCachedForecastSource creates a new cached wrapper around a forecast source
+// NewCachedForecastSource creates a new cached wrapper around a forecast source, held in a bounded in-memory LRU
 func NewCachedForecastSource(source datasource.ForecastSource, cacheDuration time.Duration) *CachedForecastSource {
+	return NewCachedForecastSourceWithBackend(source, cacheDuration, NewLRUBackend(DefaultMaxEntries, DefaultMaxBytes))
+}
+
+// NewCachedForecastSourceWithBackend creates a new cached wrapper around a forecast source on top of a cache backend
+func NewCachedForecastSourceWithBackend(source datasource.ForecastSource, cacheDuration time.Duration, backend Backend) *CachedForecastSource {
 	return &CachedForecastSource{
 		source:        source,
-		cache:         make(map[string]forecastCacheEntry),
+		backend:       backend,
+		prefix:        "forecast:" + source.Name() + ":",
 		cacheDuration: cacheDuration,
+		refreshing:    make(map[string]bool),
 	}
 }
 
+// SetStaleOptions configures serving expired entries while revalidating or when the source fails
+func (c *CachedForecastSource) SetStaleOptions(options StaleOptions) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stale = options
+}
+
+// SetNegativeCache sets the cache of provider errors consulted before fetching; nil disables it
+func (c *CachedForecastSource) SetNegativeCache(negative *NegativeCache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.negative = negative
+}
+
 // Name returns the name of the underlying forecast source with [Cached] prefix
 func (c *CachedForecastSource) Name() string {
 	return c.source.Name() + " [Cached]"
 }
 
-// FetchForecast fetches forecast data, using cache when available
+// FetchForecast fetches forecast data, using cache when available. One entry per location holds the
+// longest forecast the source offers, and shorter requests are served by cutting it down.
 func (c *CachedForecastSource) FetchForecast(ctx context.Context, location string, days int) (models.ForecastData, error) {
-	// Create a cache key that combines location and days
-	cacheKey := fmt.Sprintf("%s:%d", location, days)
+	cacheKey := models.NormalizeLocation(location)
 
-	// First check if we have this forecast in the cache
 	c.mutex.RLock()
-	entry, found := c.cache[cacheKey]
+	stale, negative := c.stale, c.negative
 	c.mutex.RUnlock()
 
+	// First check if we have a forecast covering enough days in the cache
+	var entry forecastCacheEntry
+	found := getEntry(ctx, c.backend, c.prefix+cacheKey, &entry) && entry.Days >= days
+	age := time.Since(entry.Timestamp)
+
 	// If found and not expired, return the cached forecast
-	if found && time.Since(entry.Timestamp) < c.cacheDuration {
+	if found && age < c.cacheDuration {
 		c.mutex.Lock()
 		c.cacheHitCount++
 		c.mutex.Unlock()
 
-		fmt.Printf("Forecast Cache HIT for %s (days=%d) from %s (age: %s)\n",
-			location, days, c.source.Name(), time.Since(entry.Timestamp).Round(time.Second))
+		fmt.Printf("Forecast Cache HIT for %s (days=%d of %d) from %s (age: %s)\n",
+			location, days, entry.Days, c.source.Name(), time.Since(entry.Timestamp).Round(time.Second))
 
-		return entry.Data, nil
+		return sliceForecast(entry.Data, days), nil
 	}
 
-	// Cache miss or expired, fetch fresh forecast
+	// A recently expired forecast is served while a background refresh replaces it
+	if found && age < c.cacheDuration+stale.WhileRevalidate {
+		c.mutex.Lock()
+		c.cacheHitCount++
+		c.mutex.Unlock()
+
+		fmt.Printf("Forecast Cache STALE for %s (days=%d of %d) from %s (age: %s), revalidating in background\n",
+			location, days, entry.Days, c.source.Name(), age.Round(time.Second))
+
+		c.revalidate(cacheKey, location, entry.Days, stale)
+		forecast := sliceForecast(entry.Data, days)
+		forecast.Stale = staleNotice(models.StaleRevalidating, age, nil)
+		return forecast, nil
+	}
+
+	// Cache miss or expired, fetch fresh forecast as far ahead as the source goes
+	fetchDays := max(days, datasource.MaxForecastDays(c.source), entry.Days)
+
 	c.mutex.Lock()
 	c.cacheMissCount++
 	c.mutex.Unlock()
 
-	fmt.Printf("Forecast Cache MISS for %s (days=%d) from %s, fetching fresh data...\n",
-		location, days, c.source.Name())
-
-	forecast, err := c.source.FetchForecast(ctx, location, days)
+	fmt.Printf("Forecast Cache MISS for %s (days=%d) from %s, fetching %d days...\n",
+		location, days, c.source.Name(), fetchDays)
+
+	// Requests that recently failed for a cacheable reason, such as an unknown location, aren't repeated
+	var forecast models.ForecastData
+	err := negative.Lookup(c.prefix, cacheKey)
+	if err == nil {
+		forecast, err = c.source.FetchForecast(ctx, location, fetchDays)
+		if err != nil {
+			negative.Store(c.prefix, cacheKey, err)
+		}
+	}
 	if err != nil {
+		// The last good forecast is better than none while the source is failing
+		if found && age < c.cacheDuration+stale.IfError {
+			log.Printf("Serving stale forecast for %s (days=%d) from %s (age: %s) after error: %v",
+				location, days, c.source.Name(), age.Round(time.Second), err)
+			forecast := sliceForecast(entry.Data, days)
+			forecast.Stale = staleNotice(models.StaleSourceError, age, err)
+			return forecast, nil
+		}
 		return models.ForecastData{}, err
 	}
 
-	// Store in cache
-	c.mutex.Lock()
-	c.cache[cacheKey] = forecastCacheEntry{
+	c.store(ctx, cacheKey, forecast, fetchDays, stale)
+	return sliceForecast(forecast, days), nil
+}
+
+// store caches a forecast, keeping it long enough past its lifetime to be served stale
+func (c *CachedForecastSource) store(ctx context.Context, cacheKey string, forecast models.ForecastData, days int, stale StaleOptions) {
+	setEntry(ctx, c.backend, c.prefix+cacheKey, forecastCacheEntry{
 		Data:      forecast,
 		Timestamp: time.Now(),
+		Days:      days,
+	}, c.cacheDuration+stale.retention())
+}
+
+// revalidate refreshes a forecast in the background unless a refresh is already running
+func (c *CachedForecastSource) revalidate(cacheKey, location string, days int, stale StaleOptions) {
+	c.mutex.Lock()
+	if c.refreshing[cacheKey] {
+		c.mutex.Unlock()
+		return
 	}
+	c.refreshing[cacheKey] = true
 	c.mutex.Unlock()
 
-	return forecast, nil
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.refreshing, cacheKey)
+			c.mutex.Unlock()
+		}()
+
+		// The request that triggered the refresh may already be done, so don't use its context
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+
+		forecast, err := c.source.FetchForecast(ctx, location, days)
+		if err != nil {
+			log.Printf("Error revalidating cached forecast for %s (days=%d) from %s: %v", location, days, c.source.Name(), err)
+			return
+		}
+		c.store(ctx, cacheKey, forecast, days, stale)
+	}()
+}
+
+// sliceForecast keeps the points within the first days of a forecast, counted from its first point.
+// This matches both 3-hour steps starting now and hourly steps starting at local midnight.
+func sliceForecast(forecast models.ForecastData, days int) models.ForecastData {
+	if len(forecast.Forecasts) == 0 {
+		return forecast
+	}
+
+	end := forecast.Forecasts[0].Timestamp.Add(time.Duration(days) * 24 * time.Hour)
+	n := 0
+	for n < len(forecast.Forecasts) && forecast.Forecasts[n].Timestamp.Before(end) {
+		n++
+	}
+	forecast.Forecasts = forecast.Forecasts[:n:n]
+	return forecast
 }
 
-// CacheStats returns statistics about cache hits and misses
-func (c *CachedForecastSource) CacheStats() (hits, misses int) {
+// Snapshot encodes the cached entries as JSON, keeping the time each was fetched
+func (c *CachedForecastSource) Snapshot() (json.RawMessage, error) {
+	return snapshotBackend(c.backend)
+}
+
+// Restore replaces the cached entries with a snapshot
+func (c *CachedForecastSource) Restore(data json.RawMessage) error {
+	return restoreBackend(c.backend, data)
+}
+
+// CacheStats returns statistics about cache hits and misses, and entries evicted by the backend
+func (c *CachedForecastSource) CacheStats() (hits, misses, evictions int) {
+	evictions = int(c.backend.Evictions())
+
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
-	return c.cacheHitCount, c.cacheMissCount
+	return c.cacheHitCount, c.cacheMissCount, evictions
 }
 
 // Ensure CachedForecastSource implements ForecastSource
@@ -0,0 +1,78 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/models"
+)
+
+// fakeForecastSource returns a point every three hours for the days asked for and records each request
+type fakeForecastSource struct {
+	requests []int
+}
+
+func (f *fakeForecastSource) Name() string { return "Fake" }
+
+func (f *fakeForecastSource) FetchForecast(ctx context.Context, location string, days int) (models.ForecastData, error) {
+	f.requests = append(f.requests, days)
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	forecast := models.ForecastData{Provider: "Fake", Location: location}
+	for i := 0; i < days*8; i++ {
+		forecast.Forecasts = append(forecast.Forecasts, models.Forecast{Timestamp: start.Add(time.Duration(i) * 3 * time.Hour)})
+	}
+	return forecast, nil
+}
+
+// horizonSource is a fakeForecastSource that forecasts five days ahead
+type horizonSource struct {
+	fakeForecastSource
+}
+
+func (h *horizonSource) MaxForecastDays() int { return 5 }
+
+func TestCachedForecastFetchesTheWholeHorizonOnce(t *testing.T) {
+	source := &horizonSource{}
+	cached := cache.NewCachedForecastSource(source, time.Minute)
+	ctx := context.Background()
+
+	for _, request := range []struct {
+		location string
+		days     int
+	}{{"London,UK", 1}, {"london, uk", 3}, {"LONDON,UK", 5}, {"London,UK", 2}} {
+		forecast, err := cached.FetchForecast(ctx, request.location, request.days)
+		if err != nil {
+			t.Fatalf("FetchForecast(%s, %d): %v", request.location, request.days, err)
+		}
+		if len(forecast.Forecasts) != request.days*8 {
+			t.Errorf("FetchForecast(%s, %d) = %d points, want %d", request.location, request.days, len(forecast.Forecasts), request.days*8)
+		}
+	}
+
+	if len(source.requests) != 1 || source.requests[0] != 5 {
+		t.Errorf("source requests = %v, want one for the five-day horizon", source.requests)
+	}
+	if hits, misses, _ := cached.CacheStats(); hits != 3 || misses != 1 {
+		t.Errorf("hits, misses = %d, %d; want 3, 1", hits, misses)
+	}
+}
+
+func TestCachedForecastRefetchesLongerRequests(t *testing.T) {
+	source := &fakeForecastSource{}
+	cached := cache.NewCachedForecastSource(source, time.Minute)
+	ctx := context.Background()
+
+	for _, days := range []int{2, 3, 1, 3} {
+		forecast, err := cached.FetchForecast(ctx, "London,UK", days)
+		if err != nil || len(forecast.Forecasts) != days*8 {
+			t.Fatalf("FetchForecast(%d) = %d points, %v", days, len(forecast.Forecasts), err)
+		}
+	}
+
+	// Without a known horizon the source is asked for what was requested, and the longest forecast is kept
+	if len(source.requests) != 2 || source.requests[0] != 2 || source.requests[1] != 3 {
+		t.Errorf("source requests = %v, want [2 3]", source.requests)
+	}
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// CachedHistorySource wraps a HistorySource and caches complete past days permanently.
+// Past observations don't change, so only today and days the source hasn't finished are refetched.
+type CachedHistorySource struct {
+	source         datasource.HistorySource
+	backend        Backend
+	prefix         string // keeps this source's keys apart in a shared backend
+	mutex          sync.RWMutex
+	cacheHitCount  int
+	cacheMissCount int
+}
+
+// historyCacheEntry represents one cached day of hourly observations
+type historyCacheEntry struct {
+	Location string
+	Hours    []models.WeatherData
+}
+
+// NewCachedHistorySource creates a new cached wrapper around a history source, held in a bounded in-memory LRU
+func NewCachedHistorySource(source datasource.HistorySource) *CachedHistorySource {
+	return NewCachedHistorySourceWithBackend(source, NewLRUBackend(DefaultMaxEntries, DefaultMaxBytes))
+}
+
+// NewCachedHistorySourceWithBackend creates a new cached wrapper around a history source on top of a cache backend
+func NewCachedHistorySourceWithBackend(source datasource.HistorySource, backend Backend) *CachedHistorySource {
+	return &CachedHistorySource{
+		source:  source,
+		backend: backend,
+		prefix:  "history:" + source.Name() + ":",
+	}
+}
+
+// Name returns the name of the underlying history source with [Cached] prefix
+func (c *CachedHistorySource) Name() string {
+	return c.source.Name() + " [Cached]"
+}
+
+// FetchHistory returns cached days where possible and fetches each run of missing days in one request
+func (c *CachedHistorySource) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	from, to = startOfDay(from), startOfDay(to)
+	if to.Before(from) {
+		return models.HistoryData{}, fmt.Errorf("history range ends before it starts")
+	}
+
+	history := models.HistoryData{
+		Location: location,
+		From:     from,
+		To:       to,
+		Hours:    []models.WeatherData{},
+	}
+
+	days := make(map[string]historyCacheEntry)
+	var missingFrom time.Time
+	flush := func(end time.Time) error {
+		if missingFrom.IsZero() {
+			return nil
+		}
+		fetched, err := c.fetchDays(ctx, location, missingFrom, end)
+		if err != nil {
+			return err
+		}
+		for key, entry := range fetched {
+			days[key] = entry
+		}
+		missingFrom = time.Time{}
+		return nil
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := historyCacheKey(location, day)
+
+		var entry historyCacheEntry
+		found := getEntry(ctx, c.backend, c.prefix+key, &entry)
+
+		if found {
+			c.mutex.Lock()
+			c.cacheHitCount++
+			c.mutex.Unlock()
+
+			if err := flush(day.AddDate(0, 0, -1)); err != nil {
+				return models.HistoryData{}, err
+			}
+			days[key] = entry
+			continue
+		}
+
+		c.mutex.Lock()
+		c.cacheMissCount++
+		c.mutex.Unlock()
+
+		if missingFrom.IsZero() {
+			missingFrom = day
+		}
+	}
+	if err := flush(to); err != nil {
+		return models.HistoryData{}, err
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		entry := days[historyCacheKey(location, day)]
+		if entry.Location != "" {
+			history.Location = entry.Location
+		}
+		history.Hours = append(history.Hours, entry.Hours...)
+	}
+	if len(history.Hours) > 0 {
+		history.Provider = history.Hours[0].Provider
+	} else {
+		history.Provider = c.source.Name()
+	}
+
+	return history, nil
+}
+
+// fetchDays fetches a run of days from the source, caching every day that is complete
+func (c *CachedHistorySource) fetchDays(ctx context.Context, location string, from, to time.Time) (map[string]historyCacheEntry, error) {
+	fmt.Printf("History cache MISS for %s from %s (%s to %s), fetching...\n",
+		location, c.source.Name(), from.Format("2006-01-02"), to.Format("2006-01-02"))
+
+	data, err := c.source.FetchHistory(ctx, location, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	days := make(map[string]historyCacheEntry)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days[historyCacheKey(location, day)] = historyCacheEntry{Location: data.Location}
+	}
+	for _, hour := range data.Hours {
+		key := historyCacheKey(location, hour.Timestamp)
+		entry, ok := days[key]
+		if !ok {
+			continue
+		}
+		entry.Hours = append(entry.Hours, hour)
+		days[key] = entry
+	}
+
+	// Only days that have ended and have all 24 hours are final
+	today := startOfDay(time.Now())
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := historyCacheKey(location, day)
+		if day.Before(today) && len(days[key].Hours) >= 24 {
+			setEntry(ctx, c.backend, c.prefix+key, days[key], 0)
+		}
+	}
+
+	return days, nil
+}
+
+// Snapshot encodes the cached entries as JSON
+func (c *CachedHistorySource) Snapshot() (json.RawMessage, error) {
+	return snapshotBackend(c.backend)
+}
+
+// Restore replaces the cached entries with a snapshot
+func (c *CachedHistorySource) Restore(data json.RawMessage) error {
+	return restoreBackend(c.backend, data)
+}
+
+// CacheStats returns statistics about cache hits and misses, counted per day, and entries evicted by the backend
+func (c *CachedHistorySource) CacheStats() (hits, misses, evictions int) {
+	evictions = int(c.backend.Evictions())
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cacheHitCount, c.cacheMissCount, evictions
+}
+
+// historyCacheKey builds the cache key for a location and day
+func historyCacheKey(location string, day time.Time) string {
+	return fmt.Sprintf("%s:%s", location, day.UTC().Format("2006-01-02"))
+}
+
+// startOfDay truncates a time to midnight UTC of its day
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Ensure CachedHistorySource implements the HistorySource interface
+var _ datasource.HistorySource = (*CachedHistorySource)(nil)
@@ -0,0 +1,150 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/models"
+)
+
+// fakeHistorySource returns an observation for every hour of the range asked for, except the hours
+// after noon of days it hasn't finished, and records each range it was asked for
+type fakeHistorySource struct {
+	unfinished map[string]bool // days, as 2006-01-02, with only half their hours
+	calls      [][2]string
+}
+
+func (f *fakeHistorySource) Name() string { return "Fake" }
+
+func (f *fakeHistorySource) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	f.calls = append(f.calls, [2]string{from.Format("2006-01-02"), to.Format("2006-01-02")})
+
+	history := models.HistoryData{Provider: "Fake", Location: location + " (resolved)", From: from, To: to}
+	for hour := from; hour.Before(to.AddDate(0, 0, 1)); hour = hour.Add(time.Hour) {
+		if f.unfinished[hour.Format("2006-01-02")] && hour.Hour() >= 12 {
+			continue
+		}
+		history.Hours = append(history.Hours, models.WeatherData{Provider: "Fake", Location: location, Timestamp: hour})
+	}
+	return history, nil
+}
+
+func day(d int) time.Time {
+	return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+// checkHours fails unless history has every hour from the start of day from through the end of day to, in order
+func checkHours(t *testing.T, history models.HistoryData, from, to int) {
+	t.Helper()
+	if want := (to - from + 1) * 24; len(history.Hours) != want {
+		t.Fatalf("got %d hours, want %d", len(history.Hours), want)
+	}
+	for i, hour := range history.Hours {
+		if want := day(from).Add(time.Duration(i) * time.Hour); !hour.Timestamp.Equal(want) {
+			t.Fatalf("hour %d is %v, want %v", i, hour.Timestamp, want)
+		}
+	}
+}
+
+func TestCachedHistorySourceFetchesOnlyMissingDays(t *testing.T) {
+	source := &fakeHistorySource{}
+	cached := cache.NewCachedHistorySource(source)
+	ctx := context.Background()
+
+	if _, err := cached.FetchHistory(ctx, "London,UK", day(2), day(3).Add(15*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Days 2 and 3 are cached, so days 1 and 4-5 are fetched as two runs
+	history, err := cached.FetchHistory(ctx, "London,UK", day(1).Add(8*time.Hour), day(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHours(t, history, 1, 5)
+	if !history.From.Equal(day(1)) || !history.To.Equal(day(5)) {
+		t.Errorf("range = %v to %v, want whole days", history.From, history.To)
+	}
+	if history.Provider != "Fake" || history.Location != "London,UK (resolved)" {
+		t.Errorf("provider, location = %q, %q", history.Provider, history.Location)
+	}
+
+	want := [][2]string{{"2024-03-02", "2024-03-03"}, {"2024-03-01", "2024-03-01"}, {"2024-03-04", "2024-03-05"}}
+	if len(source.calls) != len(want) {
+		t.Fatalf("source calls = %v, want %v", source.calls, want)
+	}
+	for i := range want {
+		if source.calls[i] != want[i] {
+			t.Errorf("source call %d = %v, want %v", i, source.calls[i], want[i])
+		}
+	}
+
+	// Everything is cached now
+	history, err = cached.FetchHistory(ctx, "London,UK", day(1), day(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHours(t, history, 1, 5)
+	if len(source.calls) != len(want) {
+		t.Errorf("source called again: %v", source.calls[len(want):])
+	}
+
+	hits, misses, _ := cached.CacheStats()
+	if hits != 2+5 || misses != 2+3 {
+		t.Errorf("hits, misses = %d, %d; want 7, 5", hits, misses)
+	}
+}
+
+func TestCachedHistorySourceRefetchesUnfinishedDays(t *testing.T) {
+	source := &fakeHistorySource{unfinished: map[string]bool{"2024-03-02": true}}
+	cached := cache.NewCachedHistorySource(source)
+	ctx := context.Background()
+
+	history, err := cached.FetchHistory(ctx, "London,UK", day(1), day(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Hours) != 24+12+24 {
+		t.Errorf("got %d hours, want the unfinished day's 12 included", len(history.Hours))
+	}
+
+	// The source has finished the day since; only it is fetched again
+	source.unfinished = nil
+	history, err = cached.FetchHistory(ctx, "London,UK", day(1), day(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHours(t, history, 1, 3)
+	if len(source.calls) != 2 || source.calls[1] != [2]string{"2024-03-02", "2024-03-02"} {
+		t.Errorf("source calls = %v, want the unfinished day refetched alone", source.calls)
+	}
+}
+
+func TestCachedHistorySourceRefetchesToday(t *testing.T) {
+	source := &fakeHistorySource{}
+	cached := cache.NewCachedHistorySource(source)
+	ctx := context.Background()
+
+	today := time.Now().UTC()
+	for i := 0; i < 2; i++ {
+		if _, err := cached.FetchHistory(ctx, "London,UK", today, today); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(source.calls) != 2 {
+		t.Errorf("source called %d times, want today fetched every time", len(source.calls))
+	}
+}
+
+func TestCachedHistorySourceRejectsReversedRange(t *testing.T) {
+	source := &fakeHistorySource{}
+	cached := cache.NewCachedHistorySource(source)
+
+	if _, err := cached.FetchHistory(context.Background(), "London,UK", day(5), day(4).Add(23*time.Hour)); err == nil {
+		t.Error("FetchHistory accepted a range ending the day before it starts")
+	}
+	if len(source.calls) != 0 {
+		t.Errorf("source called for a reversed range: %v", source.calls)
+	}
+}
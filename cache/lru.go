@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// LRUBackend is an in-memory Backend bounded by entry count and total size.
+// When either bound is exceeded the least recently used entries are evicted.
+type LRUBackend struct {
+	maxEntries int   // zero means no entry limit
+	maxBytes   int64 // zero means no size limit
+
+	items     map[string]*list.Element
+	order     *list.List // front is the most recently used
+	bytes     int64
+	evictions int64
+	mutex     sync.Mutex
+}
+
+// lruItem is a cached value with its expiry
+type lruItem struct {
+	key     string
+	value   []byte
+	expires time.Time // zero if the entry never expires
+}
+
+// size returns the memory an item is charged for
+func (i *lruItem) size() int64 {
+	return int64(len(i.key) + len(i.value))
+}
+
+// NewLRUBackend creates an LRU backend holding at most maxEntries entries and maxBytes of keys and values
+func NewLRUBackend(maxEntries int, maxBytes int64) *LRUBackend {
+	return &LRUBackend{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used
+func (b *LRUBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	element, found := b.items[key]
+	if !found {
+		return nil, false, nil
+	}
+
+	item := element.Value.(*lruItem)
+	if !item.expires.IsZero() && time.Now().After(item.expires) {
+		b.remove(element)
+		return nil, false, nil
+	}
+
+	b.order.MoveToFront(element)
+	return item.value, true, nil
+}
+
+// Set stores a value under key, evicting the least recently used entries to stay within bounds
+func (b *LRUBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.set(&lruItem{key: key, value: value, expires: expires})
+	return nil
+}
+
+// set stores an item as the most recently used; the caller must hold the mutex
+func (b *LRUBackend) set(item *lruItem) {
+	if element, found := b.items[item.key]; found {
+		b.remove(element)
+	}
+
+	// A value that can never fit would only flush everything else out
+	if b.maxBytes > 0 && item.size() > b.maxBytes {
+		b.evictions++
+		return
+	}
+
+	b.items[item.key] = b.order.PushFront(item)
+	b.bytes += item.size()
+
+	for (b.maxEntries > 0 && b.order.Len() > b.maxEntries) || (b.maxBytes > 0 && b.bytes > b.maxBytes) {
+		b.remove(b.order.Back())
+		b.evictions++
+	}
+}
+
+// remove drops an element; the caller must hold the mutex
+func (b *LRUBackend) remove(element *list.Element) {
+	item := element.Value.(*lruItem)
+	b.order.Remove(element)
+	delete(b.items, item.key)
+	b.bytes -= item.size()
+}
+
+// Delete removes the value stored under key
+func (b *LRUBackend) Delete(ctx context.Context, key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if element, found := b.items[key]; found {
+		b.remove(element)
+	}
+	return nil
+}
+
+// Evictions returns the number of entries evicted to stay within bounds
+func (b *LRUBackend) Evictions() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.evictions
+}
+
+// Len returns the number of entries held
+func (b *LRUBackend) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.order.Len()
+}
+
+// Bytes returns the total size of the keys and values held
+func (b *LRUBackend) Bytes() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.bytes
+}
+
+// lruSnapshotEntry is an entry as written to a snapshot
+type lruSnapshotEntry struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// Snapshot encodes the entries as JSON, least recently used first
+func (b *LRUBackend) Snapshot() (json.RawMessage, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entries := make([]lruSnapshotEntry, 0, b.order.Len())
+	for element := b.order.Back(); element != nil; element = element.Prev() {
+		item := element.Value.(*lruItem)
+		entries = append(entries, lruSnapshotEntry{Key: item.key, Value: item.value, Expires: item.expires})
+	}
+	return json.Marshal(entries)
+}
+
+// Restore replaces the entries with a snapshot, dropping expired ones and applying the current bounds
+func (b *LRUBackend) Restore(data json.RawMessage) error {
+	var entries []lruSnapshotEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse cache snapshot: %w", err)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.items = make(map[string]*list.Element)
+	b.order = list.New()
+	b.bytes = 0
+
+	now := time.Now()
+	for _, entry := range entries {
+		if !entry.Expires.IsZero() && now.After(entry.Expires) {
+			continue
+		}
+		b.set(&lruItem{key: entry.Key, value: entry.Value, expires: entry.Expires})
+	}
+	return nil
+}
+
+// Ensure LRUBackend implements the Backend interface
+var _ Backend = (*LRUBackend)(nil)
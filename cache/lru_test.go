@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+)
+
+// has reports whether the backend holds key
+func has(t *testing.T, backend cache.Backend, key string) bool {
+	t.Helper()
+	_, found, err := backend.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get %s: %v", key, err)
+	}
+	return found
+}
+
+func TestLRUBackendEvictsLeastRecentlyUsed(t *testing.T) {
+	backend := cache.NewLRUBackend(2, 0)
+	ctx := context.Background()
+
+	backend.Set(ctx, "a", []byte("1"), 0)
+	backend.Set(ctx, "b", []byte("2"), 0)
+	has(t, backend, "a") // a is now more recently used than b
+	backend.Set(ctx, "c", []byte("3"), 0)
+
+	if !has(t, backend, "a") || has(t, backend, "b") || !has(t, backend, "c") {
+		t.Error("want b evicted and a and c kept")
+	}
+	if backend.Len() != 2 || backend.Evictions() != 1 {
+		t.Errorf("len %d, evictions %d; want 2 and 1", backend.Len(), backend.Evictions())
+	}
+
+	// Replacing a value neither evicts nor grows the cache
+	backend.Set(ctx, "a", []byte("4"), 0)
+	if value, _, _ := backend.Get(ctx, "a"); string(value) != "4" || backend.Len() != 2 || backend.Evictions() != 1 {
+		t.Errorf("after replacing a: value %q, len %d, evictions %d", value, backend.Len(), backend.Evictions())
+	}
+
+	backend.Delete(ctx, "a")
+	backend.Delete(ctx, "missing")
+	if has(t, backend, "a") || backend.Len() != 1 {
+		t.Errorf("after deleting a: len %d", backend.Len())
+	}
+}
+
+func TestLRUBackendBoundsBytes(t *testing.T) {
+	// Each entry is charged for its one-byte key and its value
+	backend := cache.NewLRUBackend(0, 10)
+	ctx := context.Background()
+
+	backend.Set(ctx, "a", []byte("1234"), 0)
+	backend.Set(ctx, "b", []byte("1234"), 0)
+	if backend.Bytes() != 10 || backend.Len() != 2 {
+		t.Fatalf("bytes %d, len %d; want 10 and 2", backend.Bytes(), backend.Len())
+	}
+
+	backend.Set(ctx, "c", []byte("12"), 0)
+	if has(t, backend, "a") || backend.Bytes() != 8 {
+		t.Errorf("bytes %d with a kept = %v; want a evicted", backend.Bytes(), has(t, backend, "a"))
+	}
+
+	// A value that can never fit is refused rather than flushing everything else out
+	backend.Set(ctx, "huge", []byte(strings.Repeat("x", 20)), 0)
+	if has(t, backend, "huge") || !has(t, backend, "b") || !has(t, backend, "c") {
+		t.Error("want the oversized value refused and the others kept")
+	}
+	if backend.Evictions() != 2 {
+		t.Errorf("evictions = %d, want 2", backend.Evictions())
+	}
+}
+
+func TestLRUBackendExpiry(t *testing.T) {
+	backend := cache.NewLRUBackend(0, 0)
+	ctx := context.Background()
+
+	backend.Set(ctx, "short", []byte("1"), time.Millisecond)
+	backend.Set(ctx, "forever", []byte("2"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if has(t, backend, "short") || !has(t, backend, "forever") {
+		t.Error("want the expired entry gone and the other kept")
+	}
+	if backend.Len() != 1 || backend.Bytes() != int64(len("forever")+1) {
+		t.Errorf("len %d, bytes %d after expiry", backend.Len(), backend.Bytes())
+	}
+	if backend.Evictions() != 0 {
+		t.Errorf("evictions = %d; expiry is not an eviction", backend.Evictions())
+	}
+}
+
+func TestLRUBackendSnapshotKeepsOrderAndExpiry(t *testing.T) {
+	backend := cache.NewLRUBackend(3, 0)
+	ctx := context.Background()
+	backend.Set(ctx, "a", []byte("1"), 0)
+	backend.Set(ctx, "b", []byte("2"), 0)
+	backend.Set(ctx, "short", []byte("3"), 20*time.Millisecond)
+	has(t, backend, "a")
+
+	snapshot, err := backend.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Restoring into a smaller cache keeps the most recently used entries
+	restored := cache.NewLRUBackend(1, 0)
+	restored.Set(ctx, "old", []byte("x"), 0)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if has(t, restored, "old") || has(t, restored, "short") || has(t, restored, "b") || !has(t, restored, "a") {
+		t.Error("want only a restored: old replaced, short expired and b least recently used")
+	}
+	if restored.Bytes() != 2 {
+		t.Errorf("bytes = %d, want 2", restored.Bytes())
+	}
+
+	if err := restored.Restore([]byte(`{`)); err == nil {
+		t.Error("Restore accepted malformed JSON")
+	}
+}
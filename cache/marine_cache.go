@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// CachedMarineSource wraps a MarineSource and adds caching functionality
+type CachedMarineSource struct {
+	source         datasource.MarineSource
+	backend        Backend
+	prefix         string // keeps this source's keys apart in a shared backend
+	mutex          sync.RWMutex
+	cacheDuration  time.Duration
+	negative       *NegativeCache
+	cacheHitCount  int
+	cacheMissCount int
+}
+
+// marineCacheEntry represents a cached marine forecast with its timestamp
+type marineCacheEntry struct {
+	Data      models.MarineData
+	Timestamp time.Time
+}
+
+// NewCachedMarineSource creates a new cached wrapper around a marine source, held in a bounded in-memory LRU
+func NewCachedMarineSource(source datasource.MarineSource, cacheDuration time.Duration) *CachedMarineSource {
+	return NewCachedMarineSourceWithBackend(source, cacheDuration, NewLRUBackend(DefaultMaxEntries, DefaultMaxBytes))
+}
+
+// NewCachedMarineSourceWithBackend creates a new cached wrapper around a marine source on top of a cache backend
+func NewCachedMarineSourceWithBackend(source datasource.MarineSource, cacheDuration time.Duration, backend Backend) *CachedMarineSource {
+	return &CachedMarineSource{
+		source:        source,
+		backend:       backend,
+		prefix:        "marine:" + source.Name() + ":",
+		cacheDuration: cacheDuration,
+	}
+}
+
+// Name returns the name of the underlying marine source with [Cached] prefix
+func (c *CachedMarineSource) Name() string {
+	return c.source.Name() + " [Cached]"
+}
+
+// SetNegativeCache sets the cache of provider errors consulted before fetching; nil disables it
+func (c *CachedMarineSource) SetNegativeCache(negative *NegativeCache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.negative = negative
+}
+
+// FetchMarine fetches marine data, using cache when available
+func (c *CachedMarineSource) FetchMarine(ctx context.Context, location string, days int) (models.MarineData, error) {
+	// Create a cache key that combines location and days
+	cacheKey := fmt.Sprintf("%s:%d", location, days)
+
+	c.mutex.RLock()
+	negative := c.negative
+	c.mutex.RUnlock()
+
+	// First check if we have this marine forecast in the cache
+	var entry marineCacheEntry
+	found := getEntry(ctx, c.backend, c.prefix+cacheKey, &entry)
+
+	// If found and not expired, return the cached data
+	if found && time.Since(entry.Timestamp) < c.cacheDuration {
+		c.mutex.Lock()
+		c.cacheHitCount++
+		c.mutex.Unlock()
+
+		fmt.Printf("Marine Cache HIT for %s (days=%d) from %s (age: %s)\n",
+			location, days, c.source.Name(), time.Since(entry.Timestamp).Round(time.Second))
+
+		return entry.Data, nil
+	}
+
+	// Cache miss or expired, fetch fresh data
+	c.mutex.Lock()
+	c.cacheMissCount++
+	c.mutex.Unlock()
+
+	fmt.Printf("Marine Cache MISS for %s (days=%d) from %s, fetching fresh data...\n",
+		location, days, c.source.Name())
+
+	// Requests that recently failed for a cacheable reason, such as an unknown location, aren't repeated
+	if err := negative.Lookup(c.prefix, location); err != nil {
+		return models.MarineData{}, err
+	}
+	marine, err := c.source.FetchMarine(ctx, location, days)
+	if err != nil {
+		negative.Store(c.prefix, location, err)
+		return models.MarineData{}, err
+	}
+
+	// Store in cache
+	setEntry(ctx, c.backend, c.prefix+cacheKey, marineCacheEntry{
+		Data:      marine,
+		Timestamp: time.Now(),
+	}, c.cacheDuration)
+
+	return marine, nil
+}
+
+// Snapshot encodes the cached entries as JSON, keeping the time each was fetched
+func (c *CachedMarineSource) Snapshot() (json.RawMessage, error) {
+	return snapshotBackend(c.backend)
+}
+
+// Restore replaces the cached entries with a snapshot
+func (c *CachedMarineSource) Restore(data json.RawMessage) error {
+	return restoreBackend(c.backend, data)
+}
+
+// CacheStats returns statistics about cache hits and misses, and entries evicted by the backend
+func (c *CachedMarineSource) CacheStats() (hits, misses, evictions int) {
+	evictions = int(c.backend.Evictions())
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.cacheHitCount, c.cacheMissCount, evictions
+}
+
+// Ensure CachedMarineSource implements MarineSource
+var _ datasource.MarineSource = (*CachedMarineSource)(nil)
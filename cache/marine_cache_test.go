@@ -0,0 +1,74 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// fakeMarineSource knows a single coastal location and counts its calls
+type fakeMarineSource struct {
+	known string
+	calls int
+}
+
+func (f *fakeMarineSource) Name() string { return "Fake" }
+
+func (f *fakeMarineSource) FetchMarine(ctx context.Context, location string, days int) (models.MarineData, error) {
+	f.calls++
+	if location != f.known {
+		return models.MarineData{}, &datasource.ProviderError{Provider: "Fake", Kind: datasource.ErrorNotFound, Message: "location not found"}
+	}
+	return models.MarineData{Provider: "Fake", Location: location, Hours: make([]models.MarineConditions, days*24)}, nil
+}
+
+func TestCachedMarineSourceKeysByDays(t *testing.T) {
+	source := &fakeMarineSource{known: "Falmouth,UK"}
+	cached := cache.NewCachedMarineSource(source, time.Minute)
+	cached.SetNegativeCache(cache.NewNegativeCache(cache.DefaultNegativeTTLs(), 10))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		for _, days := range []int{1, 3} {
+			marine, err := cached.FetchMarine(ctx, "Falmouth,UK", days)
+			if err != nil || len(marine.Hours) != days*24 {
+				t.Fatalf("FetchMarine(%d days) = %d hours, %v", days, len(marine.Hours), err)
+			}
+		}
+		// An unknown location is remembered whatever the number of days
+		if _, err := cached.FetchMarine(ctx, "Atlantis", i+1); datasource.ErrorKindOf(err) != datasource.ErrorNotFound {
+			t.Fatalf("FetchMarine Atlantis = %v, want not found", err)
+		}
+	}
+	if source.calls != 3 {
+		t.Errorf("source called %d times, want once for each number of days and once for Atlantis", source.calls)
+	}
+}
+
+func TestCachedMarineSourceSnapshot(t *testing.T) {
+	source := &fakeMarineSource{known: "Falmouth,UK"}
+	cached := cache.NewCachedMarineSource(source, time.Minute)
+	ctx := context.Background()
+	cached.FetchMarine(ctx, "Falmouth,UK", 2)
+
+	snapshot, err := cached.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	restored := cache.NewCachedMarineSource(source, time.Minute)
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	marine, err := restored.FetchMarine(ctx, "Falmouth,UK", 2)
+	if err != nil || len(marine.Hours) != 48 {
+		t.Fatalf("FetchMarine after restore = %d hours, %v", len(marine.Hours), err)
+	}
+	if source.calls != 1 {
+		t.Errorf("source called %d times, want the restored entry used", source.calls)
+	}
+}
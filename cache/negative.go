@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"weather-service/datasource"
+)
+
+// NegativeTTLs sets how long each kind of provider error is cached; kinds without a TTL aren't cached
+type NegativeTTLs map[datasource.ErrorKind]time.Duration
+
+// DefaultNegativeTTLs caches unknown locations long enough to stop repeated misspellings
+// reaching the provider, and auth and quota failures only briefly so a fix takes effect quickly
+func DefaultNegativeTTLs() NegativeTTLs {
+	return NegativeTTLs{
+		datasource.ErrorNotFound:    10 * time.Minute,
+		datasource.ErrorAuth:        time.Minute,
+		datasource.ErrorRateLimited: 30 * time.Second,
+	}
+}
+
+// NegativeStats counts negative cache activity for one kind of error
+type NegativeStats struct {
+	Kind    datasource.ErrorKind `json:"kind"`
+	TTL     string               `json:"ttl"`
+	Entries int                  `json:"entries"` // errors currently cached
+	Stored  int64                `json:"stored"`  // errors cached since startup
+	Hits    int64                `json:"hits"`    // requests answered from the cache instead of the provider
+	Cleared int64                `json:"cleared"` // entries removed by an admin clear
+}
+
+// NegativeCache remembers provider errors so repeated requests that are bound to fail don't
+// spend provider quota. It is shared by the cached sources and holds at most maxEntries errors.
+type NegativeCache struct {
+	ttls       NegativeTTLs
+	maxEntries int
+	entries    map[string]negativeEntry
+	stats      map[datasource.ErrorKind]*NegativeStats
+	mutex      sync.Mutex
+}
+
+// negativeEntry is a cached provider error
+type negativeEntry struct {
+	err     *datasource.ProviderError
+	expires time.Time
+}
+
+// NewNegativeCache creates a negative cache with a TTL per error kind
+func NewNegativeCache(ttls NegativeTTLs, maxEntries int) *NegativeCache {
+	return &NegativeCache{
+		ttls:       ttls,
+		maxEntries: maxEntries,
+		entries:    make(map[string]negativeEntry),
+		stats:      make(map[datasource.ErrorKind]*NegativeStats),
+	}
+}
+
+// negativeKey builds the key an error is cached under. Auth failures apply to every
+// request to the source, so they are keyed by the source alone.
+func negativeKey(source, key string, kind datasource.ErrorKind) string {
+	if kind == datasource.ErrorAuth {
+		return source
+	}
+	return source + key
+}
+
+// Lookup returns the cached error for a request to a source, or nil if there is none
+func (n *NegativeCache) Lookup(source, key string) error {
+	if n == nil {
+		return nil
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	for _, k := range []string{source, source + key} {
+		entry, found := n.entries[k]
+		if !found {
+			continue
+		}
+		if now.After(entry.expires) {
+			delete(n.entries, k)
+			continue
+		}
+		n.statsFor(entry.err.Kind).Hits++
+		return fmt.Errorf("%w (cached for %s)", entry.err, entry.expires.Sub(now).Round(time.Second))
+	}
+	return nil
+}
+
+// Store caches an error from a request to a source if its kind has a TTL
+func (n *NegativeCache) Store(source, key string, err error) {
+	if n == nil {
+		return
+	}
+	var providerErr *datasource.ProviderError
+	if !errors.As(err, &providerErr) {
+		return
+	}
+	ttl := n.ttls[providerErr.Kind]
+	if ttl <= 0 {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	k := negativeKey(source, key, providerErr.Kind)
+	if _, exists := n.entries[k]; !exists && n.maxEntries > 0 && len(n.entries) >= n.maxEntries {
+		n.pruneExpired()
+		if len(n.entries) >= n.maxEntries {
+			// Full of live entries; the next failure will simply reach the provider
+			return
+		}
+	}
+
+	n.entries[k] = negativeEntry{err: providerErr, expires: time.Now().Add(ttl)}
+	n.statsFor(providerErr.Kind).Stored++
+}
+
+// Clear removes cached errors of a kind, or every cached error for an empty kind, and returns how many were removed
+func (n *NegativeCache) Clear(kind datasource.ErrorKind) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	removed := 0
+	for k, entry := range n.entries {
+		if kind != "" && entry.err.Kind != kind {
+			continue
+		}
+		delete(n.entries, k)
+		n.statsFor(entry.err.Kind).Cleared++
+		removed++
+	}
+	return removed
+}
+
+// Stats returns counts for every error kind with a TTL or cache activity, ordered by kind
+func (n *NegativeCache) Stats() []NegativeStats {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.pruneExpired()
+	for kind := range n.ttls {
+		n.statsFor(kind)
+	}
+	entries := make(map[datasource.ErrorKind]int)
+	for _, entry := range n.entries {
+		entries[entry.err.Kind]++
+	}
+
+	stats := make([]NegativeStats, 0, len(n.stats))
+	for kind, s := range n.stats {
+		stat := *s
+		stat.TTL = n.ttls[kind].String()
+		stat.Entries = entries[kind]
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Kind < stats[j].Kind
+	})
+	return stats
+}
+
+// statsFor returns the counters for a kind; the caller must hold the mutex
+func (n *NegativeCache) statsFor(kind datasource.ErrorKind) *NegativeStats {
+	s, found := n.stats[kind]
+	if !found {
+		s = &NegativeStats{Kind: kind}
+		n.stats[kind] = s
+	}
+	return s
+}
+
+// pruneExpired drops expired entries; the caller must hold the mutex
+func (n *NegativeCache) pruneExpired() {
+	now := time.Now()
+	for k, entry := range n.entries {
+		if now.After(entry.expires) {
+			delete(n.entries, k)
+		}
+	}
+}
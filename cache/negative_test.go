@@ -0,0 +1,123 @@
+package cache_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/datasource"
+)
+
+func providerError(kind datasource.ErrorKind) error {
+	return fmt.Errorf("fetching: %w", &datasource.ProviderError{Provider: "Fake", Kind: kind, Message: string(kind)})
+}
+
+func TestNegativeCacheStoresCacheableKinds(t *testing.T) {
+	negative := cache.NewNegativeCache(cache.DefaultNegativeTTLs(), 10)
+
+	negative.Store("weather:Fake:", "atlantis", providerError(datasource.ErrorNotFound))
+	negative.Store("weather:Fake:", "london", providerError(datasource.ErrorUpstream))
+	negative.Store("weather:Fake:", "paris", errors.New("connection reset"))
+
+	err := negative.Lookup("weather:Fake:", "atlantis")
+	if datasource.ErrorKindOf(err) != datasource.ErrorNotFound {
+		t.Errorf("Lookup atlantis = %v, want the cached not found error", err)
+	}
+	// Other sources and locations are unaffected, and transient failures aren't cached
+	for _, request := range [][2]string{{"forecast:Fake:", "atlantis"}, {"weather:Fake:", "london"}, {"weather:Fake:", "paris"}} {
+		if err := negative.Lookup(request[0], request[1]); err != nil {
+			t.Errorf("Lookup %s%s = %v, want nil", request[0], request[1], err)
+		}
+	}
+}
+
+func TestNegativeCacheAuthFailuresCoverTheSource(t *testing.T) {
+	negative := cache.NewNegativeCache(cache.DefaultNegativeTTLs(), 10)
+	negative.Store("weather:Fake:", "london", providerError(datasource.ErrorAuth))
+
+	if err := negative.Lookup("weather:Fake:", "paris"); datasource.ErrorKindOf(err) != datasource.ErrorAuth {
+		t.Errorf("Lookup paris = %v, want the source's auth failure", err)
+	}
+	if err := negative.Lookup("weather:Other:", "paris"); err != nil {
+		t.Errorf("Lookup on another source = %v, want nil", err)
+	}
+}
+
+func TestNegativeCacheExpiry(t *testing.T) {
+	negative := cache.NewNegativeCache(cache.NegativeTTLs{datasource.ErrorNotFound: 10 * time.Millisecond}, 10)
+	negative.Store("weather:Fake:", "atlantis", providerError(datasource.ErrorNotFound))
+	time.Sleep(20 * time.Millisecond)
+
+	if err := negative.Lookup("weather:Fake:", "atlantis"); err != nil {
+		t.Errorf("Lookup after the TTL = %v, want nil", err)
+	}
+}
+
+func TestNegativeCacheIsBounded(t *testing.T) {
+	negative := cache.NewNegativeCache(cache.NegativeTTLs{datasource.ErrorNotFound: time.Minute}, 2)
+	for _, location := range []string{"a", "b", "c"} {
+		negative.Store("weather:Fake:", location, providerError(datasource.ErrorNotFound))
+	}
+
+	// A full cache of live entries keeps them rather than evicting one
+	if negative.Lookup("weather:Fake:", "a") == nil || negative.Lookup("weather:Fake:", "b") == nil {
+		t.Error("earlier entries were evicted")
+	}
+	if err := negative.Lookup("weather:Fake:", "c"); err != nil {
+		t.Errorf("Lookup c = %v, want it not stored", err)
+	}
+
+	// Replacing an existing entry doesn't need room
+	negative.Store("weather:Fake:", "a", providerError(datasource.ErrorNotFound))
+	if stats := negative.Stats(); stats[0].Entries != 2 || stats[0].Stored != 3 {
+		t.Errorf("stats = %+v, want 2 entries from 3 stores", stats)
+	}
+}
+
+func TestNegativeCacheStatsAndClear(t *testing.T) {
+	negative := cache.NewNegativeCache(cache.DefaultNegativeTTLs(), 10)
+	negative.Store("weather:Fake:", "atlantis", providerError(datasource.ErrorNotFound))
+	negative.Store("weather:Fake:", "lemuria", providerError(datasource.ErrorNotFound))
+	negative.Store("forecast:Fake:", "london", providerError(datasource.ErrorRateLimited))
+	negative.Lookup("weather:Fake:", "atlantis")
+	negative.Lookup("weather:Fake:", "atlantis")
+
+	if removed := negative.Clear(datasource.ErrorNotFound); removed != 2 {
+		t.Errorf("Clear(not found) = %d, want 2", removed)
+	}
+	if err := negative.Lookup("weather:Fake:", "atlantis"); err != nil {
+		t.Errorf("Lookup after clearing = %v, want nil", err)
+	}
+
+	stats := negative.Stats()
+	want := map[datasource.ErrorKind]cache.NegativeStats{
+		datasource.ErrorAuth:        {Kind: datasource.ErrorAuth, TTL: "1m0s"},
+		datasource.ErrorNotFound:    {Kind: datasource.ErrorNotFound, TTL: "10m0s", Stored: 2, Hits: 2, Cleared: 2},
+		datasource.ErrorRateLimited: {Kind: datasource.ErrorRateLimited, TTL: "30s", Entries: 1, Stored: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("stats = %+v, want one row per kind with a TTL", stats)
+	}
+	for i, s := range stats {
+		if i > 0 && stats[i-1].Kind >= s.Kind {
+			t.Errorf("stats not ordered by kind: %s before %s", stats[i-1].Kind, s.Kind)
+		}
+		if s != want[s.Kind] {
+			t.Errorf("stats for %s = %+v, want %+v", s.Kind, s, want[s.Kind])
+		}
+	}
+
+	if removed := negative.Clear(""); removed != 1 {
+		t.Errorf("Clear(all) = %d, want the rate limit entry", removed)
+	}
+}
+
+func TestNilNegativeCacheIsDisabled(t *testing.T) {
+	var negative *cache.NegativeCache
+	negative.Store("weather:Fake:", "atlantis", providerError(datasource.ErrorNotFound))
+	if err := negative.Lookup("weather:Fake:", "atlantis"); err != nil {
+		t.Errorf("Lookup on a nil cache = %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisTimeout bounds each command when the context has no deadline
+const redisTimeout = 5 * time.Second
+
+// RedisBackend is a Backend stored in Redis, so entries are shared between instances
+// and survive restarts. Redis bounds its own memory with its maxmemory policy.
+// It speaks the Redis protocol (RESP) over a single connection that is redialled after errors.
+type RedisBackend struct {
+	addr     string
+	password string
+	db       int
+
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+}
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// NewRedisBackend creates a Redis backend; the connection is made on first use
+func NewRedisBackend(addr, password string, db int) *RedisBackend {
+	return &RedisBackend{
+		addr:     addr,
+		password: password,
+		db:       db,
+	}
+}
+
+// Get returns the value stored under key
+func (r *RedisBackend) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores a value under key with an optional expiry
+func (r *RedisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		// PX takes whole milliseconds; anything shorter would never be stored
+		ms := ttl.Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Delete removes the value stored under key
+func (r *RedisBackend) Delete(ctx context.Context, key string) error {
+	_, err := r.do(ctx, "DEL", key)
+	return err
+}
+
+// Evictions returns the number of keys the server has evicted under its maxmemory policy.
+// The count is for the whole server, not just this service's keys.
+func (r *RedisBackend) Evictions() int64 {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	reply, err := r.do(ctx, "INFO", "stats")
+	if err != nil {
+		log.Printf("Error reading Redis eviction count: %v", err)
+		return 0
+	}
+	info, _ := reply.([]byte)
+	for _, line := range strings.Split(string(info), "\n") {
+		if value, found := strings.CutPrefix(strings.TrimSpace(line), "evicted_keys:"); found {
+			evicted, _ := strconv.ParseInt(value, 10, 64)
+			return evicted
+		}
+	}
+	return 0
+}
+
+// Ping checks that the server is reachable
+func (r *RedisBackend) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+// Close closes the connection
+func (r *RedisBackend) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.closeConn()
+}
+
+// do sends a command and reads its reply, connecting first if needed
+func (r *RedisBackend) do(ctx context.Context, args ...string) (interface{}, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := r.roundTrip(ctx, args)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection state is unknown after a network or protocol error
+		r.closeConn()
+	}
+	return reply, err
+}
+
+// connect dials the server, authenticates and selects the database; the caller must hold the mutex
+func (r *RedisBackend) connect(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", r.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis at %s: %w", r.addr, err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := r.roundTrip(ctx, []string{"AUTH", r.password}); err != nil {
+			r.closeConn()
+			return fmt.Errorf("failed to authenticate with redis: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := r.roundTrip(ctx, []string{"SELECT", strconv.Itoa(r.db)}); err != nil {
+			r.closeConn()
+			return fmt.Errorf("failed to select redis database %d: %w", r.db, err)
+		}
+	}
+	return nil
+}
+
+// closeConn closes the connection if open; the caller must hold the mutex
+func (r *RedisBackend) closeConn() error {
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	r.reader = nil
+	return err
+}
+
+// roundTrip writes a command as an array of bulk strings and reads one reply
+func (r *RedisBackend) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := r.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(r.conn, b.String()); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", args[0], err)
+	}
+
+	return ReadReply(r.reader)
+}
+
+// ReadReply reads one RESP reply. Simple strings are returned as string, bulk strings as []byte,
+// integers as int64, arrays as []interface{}, nil bulk strings and arrays as nil,
+// and error replies as a RedisError.
+func ReadReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis integer %q: %w", line, err)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis bulk length %q: %w", line, err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, fmt.Errorf("failed to read redis bulk string: %w", err)
+		}
+		return value[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis array length %q: %w", line, err)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = ReadReply(reader); err != nil {
+				var redisErr RedisError
+				if !errors.As(err, &redisErr) {
+					return nil, err
+				}
+				values[i] = redisErr
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unexpected redis reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read redis reply: %w", err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// Ensure RedisBackend implements the Backend interface
+var _ Backend = (*RedisBackend)(nil)
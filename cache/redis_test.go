@@ -0,0 +1,215 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/cache/redistest"
+	"weather-service/models"
+)
+
+// newRedis starts a stand-in server and a backend connected to it, both closed when the test ends
+func newRedis(t *testing.T) (*redistest.Server, *cache.RedisBackend) {
+	t.Helper()
+	server, err := redistest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start redis stand-in: %v", err)
+	}
+	backend := cache.NewRedisBackend(server.Addr(), "", 0)
+	t.Cleanup(func() {
+		backend.Close()
+		server.Close()
+	})
+	return server, backend
+}
+
+func TestRedisBackendGetSetDelete(t *testing.T) {
+	_, backend := newRedis(t)
+	ctx := context.Background()
+
+	if _, found, err := backend.Get(ctx, "missing"); err != nil || found {
+		t.Fatalf("Get(missing) = found %v, err %v; want not found", found, err)
+	}
+
+	// Values are binary-safe, including the protocol's line endings
+	value := []byte("line one\r\nline two\x00")
+	if err := backend.Set(ctx, "key", value, 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, found, err := backend.Get(ctx, "key")
+	if err != nil || !found || string(got) != string(value) {
+		t.Fatalf("Get(key) = %q, %v, %v; want %q", got, found, err, value)
+	}
+
+	if err := backend.Set(ctx, "key", []byte("replaced"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _, _ := backend.Get(ctx, "key"); string(got) != "replaced" {
+		t.Errorf("Get after overwrite = %q, want %q", got, "replaced")
+	}
+
+	if err := backend.Delete(ctx, "key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, found, _ := backend.Get(ctx, "key"); found {
+		t.Error("key still present after Delete")
+	}
+}
+
+func TestRedisBackendTTL(t *testing.T) {
+	server, backend := newRedis(t)
+	ctx := context.Background()
+
+	if err := backend.Set(ctx, "short", []byte("v"), 50*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := backend.Set(ctx, "forever", []byte("v"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// A TTL under a millisecond is rounded up rather than dropped
+	if err := backend.Set(ctx, "tiny", []byte("v"), time.Microsecond); err != nil {
+		t.Fatalf("Set with sub-millisecond TTL: %v", err)
+	}
+
+	if _, found, _ := backend.Get(ctx, "short"); !found {
+		t.Fatal("key with TTL missing before it expired")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if _, found, _ := backend.Get(ctx, "short"); found {
+		t.Error("key still present after its TTL")
+	}
+	if _, found, _ := backend.Get(ctx, "tiny"); found {
+		t.Error("key with sub-millisecond TTL still present")
+	}
+	if _, found, _ := backend.Get(ctx, "forever"); !found {
+		t.Error("key without TTL expired")
+	}
+	if n := server.Len(); n != 1 {
+		t.Errorf("server holds %d live keys, want 1", n)
+	}
+}
+
+func TestRedisBackendPassword(t *testing.T) {
+	server, err := redistest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start redis stand-in: %v", err)
+	}
+	defer server.Close()
+	server.RequirePassword("secret")
+	ctx := context.Background()
+
+	wrong := cache.NewRedisBackend(server.Addr(), "wrong", 0)
+	defer wrong.Close()
+	if err := wrong.Ping(ctx); err == nil {
+		t.Error("Ping with the wrong password succeeded")
+	}
+
+	right := cache.NewRedisBackend(server.Addr(), "secret", 0)
+	defer right.Close()
+	if err := right.Ping(ctx); err != nil {
+		t.Errorf("Ping with the right password: %v", err)
+	}
+}
+
+func TestRedisBackendReconnects(t *testing.T) {
+	server, backend := newRedis(t)
+	ctx := context.Background()
+
+	if err := backend.Set(ctx, "key", []byte("v"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// The first command after the connection drops may fail; the backend must redial for the next one
+	server.DropConnections()
+	backend.Get(ctx, "key")
+
+	got, found, err := backend.Get(ctx, "key")
+	if err != nil || !found || string(got) != "v" {
+		t.Fatalf("Get after reconnect = %q, %v, %v; want %q", got, found, err, "v")
+	}
+}
+
+func TestRedisBackendServerDown(t *testing.T) {
+	server, backend := newRedis(t)
+	ctx := context.Background()
+
+	if err := backend.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	server.Close()
+
+	// Retry past a failure on the stale connection to reach a failed redial
+	var err error
+	for i := 0; i < 2 && err == nil; i++ {
+		_, _, err = backend.Get(ctx, "key")
+	}
+	if err == nil {
+		t.Fatal("Get succeeded with the server down")
+	}
+	if err := backend.Set(ctx, "key", []byte("v"), 0); err == nil {
+		t.Error("Set succeeded with the server down")
+	}
+}
+
+func TestRedisBackendErrorReply(t *testing.T) {
+	server, backend := newRedis(t)
+	server.RequirePassword("secret")
+
+	// Error replies are returned as RedisError and leave the connection usable
+	err := backend.Ping(context.Background())
+	var redisErr cache.RedisError
+	if !errors.As(err, &redisErr) {
+		t.Fatalf("Ping without auth = %v, want a RedisError", err)
+	}
+}
+
+// fakeSource returns a fixed reading and counts its calls
+type fakeSource struct {
+	name  string
+	calls int
+}
+
+func (f *fakeSource) Name() string { return f.name }
+
+func (f *fakeSource) FetchWeatherData(ctx context.Context, location string) (models.WeatherData, error) {
+	f.calls++
+	return models.WeatherData{Provider: f.name, Location: location, Temperature: float64(len(f.name))}, nil
+}
+
+func TestRedisKeyPrefixes(t *testing.T) {
+	_, backend := newRedis(t)
+	ctx := context.Background()
+
+	// Two sources sharing one backend keep their entries apart
+	first := &fakeSource{name: "First"}
+	second := &fakeSource{name: "Second"}
+	firstCache := cache.NewCachedDataSourceWithBackend(first, time.Minute, backend)
+	secondCache := cache.NewCachedDataSourceWithBackend(second, time.Minute, backend)
+
+	for i := 0; i < 2; i++ {
+		for _, c := range []*cache.CachedDataSource{firstCache, secondCache} {
+			if _, err := c.FetchWeatherData(ctx, "London"); err != nil {
+				t.Fatalf("FetchWeatherData: %v", err)
+			}
+		}
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Errorf("source calls = %d and %d, want 1 each", first.calls, second.calls)
+	}
+
+	for _, key := range []string{"weather:First:London", "weather:Second:London"} {
+		if _, found, err := backend.Get(ctx, key); err != nil || !found {
+			t.Errorf("key %q not stored: found %v, err %v", key, found, err)
+		}
+	}
+
+	data, _ := secondCache.FetchWeatherData(ctx, "London")
+	if data.Provider != "Second" {
+		t.Errorf("second source served %q's reading", data.Provider)
+	}
+}
@@ -0,0 +1,290 @@
+// Package redistest provides an in-process stand-in for a Redis server, so the Redis cache
+// backend can be exercised without running Redis. It implements the subset of commands the
+// backend uses and can evict keys to simulate a maxmemory policy.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"weather-service/cache"
+)
+
+// Server is a Redis stand-in listening on a local port
+type Server struct {
+	listener net.Listener
+	password string
+	maxKeys  int // zero means unbounded
+
+	data    map[string]entry
+	written int64 // write counter used to evict the oldest key first
+	evicted int64
+	conns   map[net.Conn]bool
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+}
+
+// entry is a stored value with its expiry
+type entry struct {
+	value   []byte
+	expires time.Time // zero if the key never expires
+	written int64
+}
+
+// NewServer starts a stand-in on a free local port
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+
+	s := &Server{
+		listener: listener,
+		data:     make(map[string]entry),
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address clients should connect to
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// RequirePassword makes the server reject commands from connections that haven't authenticated
+func (s *Server) RequirePassword(password string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.password = password
+}
+
+// SetMaxKeys bounds the number of keys; the oldest written key is evicted to make room
+func (s *Server) SetMaxKeys(maxKeys int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxKeys = maxKeys
+}
+
+// Len returns the number of keys that haven't expired
+func (s *Server) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	count := 0
+	for key := range s.data {
+		if _, found := s.get(key); found {
+			count++
+		}
+	}
+	return count
+}
+
+// DropConnections closes every open connection while the server keeps listening, as after a network failure
+func (s *Server) DropConnections() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server, closing any open connections
+func (s *Server) Close() error {
+	err := s.listener.Close()
+
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		s.conns[conn] = true
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// handle answers commands on one connection until it is closed
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	authenticated := false
+	for {
+		request, err := cache.ReadReply(reader)
+		if err != nil {
+			return
+		}
+
+		args, ok := commandArgs(request)
+		if !ok {
+			io.WriteString(conn, "-ERR protocol error\r\n")
+			return
+		}
+
+		reply := s.execute(args, &authenticated)
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// commandArgs converts a request array of bulk strings into command arguments
+func commandArgs(request interface{}) ([]string, bool) {
+	values, ok := request.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, false
+	}
+	args := make([]string, len(values))
+	for i, v := range values {
+		b, ok := v.([]byte)
+		if !ok {
+			return nil, false
+		}
+		args[i] = string(b)
+	}
+	return args, true
+}
+
+// execute runs one command and returns the encoded reply
+func (s *Server) execute(args []string, authenticated *bool) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	command := strings.ToUpper(args[0])
+	if s.password != "" && !*authenticated && command != "AUTH" {
+		return "-NOAUTH Authentication required.\r\n"
+	}
+
+	switch command {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH":
+		if len(args) != 2 || args[1] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authenticated = true
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 2 {
+			return wrongArgs(command)
+		}
+		value, found := s.get(args[1])
+		if !found {
+			return "$-1\r\n"
+		}
+		return bulk(value)
+	case "SET":
+		return s.set(args)
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, found := s.get(key); found {
+				deleted++
+			}
+			delete(s.data, key)
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "FLUSHALL", "FLUSHDB":
+		s.data = make(map[string]entry)
+		return "+OK\r\n"
+	case "DBSIZE":
+		return fmt.Sprintf(":%d\r\n", len(s.data))
+	case "INFO":
+		return bulk([]byte(fmt.Sprintf("# Stats\r\nevicted_keys:%d\r\n", s.evicted)))
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// get returns a key's value, dropping it if it has expired; the caller must hold the mutex
+func (s *Server) get(key string) ([]byte, bool) {
+	e, found := s.data[key]
+	if !found {
+		return nil, false
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		delete(s.data, key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// set handles SET key value [EX seconds | PX milliseconds]; the caller must hold the mutex
+func (s *Server) set(args []string) string {
+	if len(args) != 3 && len(args) != 5 {
+		return wrongArgs("SET")
+	}
+
+	var expires time.Time
+	if len(args) == 5 {
+		n, err := strconv.ParseInt(args[4], 10, 64)
+		if err != nil || n <= 0 {
+			return "-ERR invalid expire time in 'set' command\r\n"
+		}
+		switch strings.ToUpper(args[3]) {
+		case "EX":
+			expires = time.Now().Add(time.Duration(n) * time.Second)
+		case "PX":
+			expires = time.Now().Add(time.Duration(n) * time.Millisecond)
+		default:
+			return "-ERR syntax error\r\n"
+		}
+	}
+
+	s.written++
+	s.data[args[1]] = entry{value: []byte(args[2]), expires: expires, written: s.written}
+
+	for s.maxKeys > 0 && len(s.data) > s.maxKeys {
+		oldest := ""
+		for key, e := range s.data {
+			if oldest == "" || e.written < s.data[oldest].written {
+				oldest = key
+			}
+		}
+		delete(s.data, oldest)
+		s.evicted++
+	}
+	return "+OK\r\n"
+}
+
+// bulk encodes a bulk string reply
+func bulk(value []byte) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+// wrongArgs is the reply for a command with the wrong number of arguments
+func wrongArgs(command string) string {
+	return fmt.Sprintf("-ERR wrong number of arguments for '%s' command\r\n", strings.ToLower(command))
+}
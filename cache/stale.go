@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"time"
+
+	"weather-service/models"
+)
+
+// revalidateTimeout bounds a background refresh, which outlives the request that started it
+const revalidateTimeout = 30 * time.Second
+
+// StaleOptions controls serving cache entries past their lifetime. Both windows are measured
+// from when the entry expired; zero disables that behaviour.
+type StaleOptions struct {
+	// WhileRevalidate serves an expired entry while a background refresh replaces it
+	WhileRevalidate time.Duration
+
+	// IfError serves an expired entry when the source fails to refresh it
+	IfError time.Duration
+}
+
+// retention returns how long entries must be kept past their lifetime to be served stale
+func (o StaleOptions) retention() time.Duration {
+	if o.IfError > o.WhileRevalidate {
+		return o.IfError
+	}
+	return o.WhileRevalidate
+}
+
+// staleNotice describes why an entry of the given age is served stale
+func staleNotice(reason string, age time.Duration, err error) *models.StaleNotice {
+	notice := &models.StaleNotice{
+		Reason:     reason,
+		AgeSeconds: int64(age / time.Second),
+	}
+	if err != nil {
+		notice.Error = err.Error()
+	}
+	return notice
+}
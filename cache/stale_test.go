@@ -0,0 +1,131 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"weather-service/cache"
+	"weather-service/models"
+)
+
+// flakySource returns a reading numbered by call, or an error while failing. While gate is set,
+// fetches wait for it to close, so a test can hold a background refresh in flight.
+type flakySource struct {
+	mutex   sync.Mutex
+	calls   int
+	failing bool
+	gate    chan struct{}
+}
+
+func (f *flakySource) Name() string { return "Flaky" }
+
+func (f *flakySource) FetchWeatherData(ctx context.Context, location string) (models.WeatherData, error) {
+	f.mutex.Lock()
+	f.calls++
+	call, failing, gate := f.calls, f.failing, f.gate
+	f.mutex.Unlock()
+
+	if gate != nil {
+		<-gate
+	}
+	if failing {
+		return models.WeatherData{}, errors.New("upstream unavailable")
+	}
+	return models.WeatherData{Provider: "Flaky", Location: location, Temperature: float64(call)}, nil
+}
+
+func (f *flakySource) set(failing bool, gate chan struct{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.failing, f.gate = failing, gate
+}
+
+func (f *flakySource) callCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.calls
+}
+
+func TestStaleWhileRevalidate(t *testing.T) {
+	source := &flakySource{}
+	cached := cache.NewCachedDataSource(source, 20*time.Millisecond)
+	cached.SetStaleOptions(cache.StaleOptions{WhileRevalidate: time.Second})
+	ctx := context.Background()
+
+	if data, err := cached.FetchWeatherData(ctx, "London"); err != nil || data.Temperature != 1 || data.Stale != nil {
+		t.Fatalf("first fetch = %+v, %v", data, err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	// Expired data is served at once, marked stale, while a single refresh runs
+	gate := make(chan struct{})
+	source.set(false, gate)
+	for i := 0; i < 3; i++ {
+		data, err := cached.FetchWeatherData(ctx, "London")
+		if err != nil || data.Temperature != 1 {
+			t.Fatalf("stale fetch = %+v, %v; want the cached reading", data, err)
+		}
+		if data.Stale == nil || data.Stale.Reason != models.StaleRevalidating || data.Stale.AgeSeconds != 0 {
+			t.Errorf("stale notice = %+v, want revalidating", data.Stale)
+		}
+	}
+	source.set(false, nil)
+	close(gate)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		data, err := cached.FetchWeatherData(ctx, "London")
+		if err == nil && data.Stale == nil && data.Temperature == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("refreshed reading never served; last = %+v, %v", data, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if calls := source.callCount(); calls != 2 {
+		t.Errorf("source called %d times, want one background refresh", calls)
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	source := &flakySource{}
+	cached := cache.NewCachedDataSource(source, 20*time.Millisecond)
+	cached.SetStaleOptions(cache.StaleOptions{IfError: 100 * time.Millisecond})
+	ctx := context.Background()
+
+	cached.FetchWeatherData(ctx, "London")
+	source.set(true, nil)
+	time.Sleep(30 * time.Millisecond)
+
+	data, err := cached.FetchWeatherData(ctx, "London")
+	if err != nil || data.Temperature != 1 {
+		t.Fatalf("fetch while failing = %+v, %v; want the last good reading", data, err)
+	}
+	if data.Stale == nil || data.Stale.Reason != models.StaleSourceError || data.Stale.Error != "upstream unavailable" {
+		t.Errorf("stale notice = %+v, want the source error", data.Stale)
+	}
+
+	// Past the window the error is returned
+	time.Sleep(100 * time.Millisecond)
+	if _, err := cached.FetchWeatherData(ctx, "London"); err == nil {
+		t.Error("fetch past the stale-if-error window succeeded")
+	}
+}
+
+func TestExpiredDataIsNotServedWithoutStaleOptions(t *testing.T) {
+	source := &flakySource{}
+	cached := cache.NewCachedDataSource(source, 20*time.Millisecond)
+	ctx := context.Background()
+
+	cached.FetchWeatherData(ctx, "London")
+	source.set(true, nil)
+	time.Sleep(30 * time.Millisecond)
+
+	if data, err := cached.FetchWeatherData(ctx, "London"); err == nil {
+		t.Errorf("fetch of expired data while failing = %+v, want the error", data)
+	}
+}
@@ -0,0 +1,104 @@
+package climate
+
+import (
+	"fmt"
+	"math"
+
+	"weather-service/models"
+)
+
+// minSamples is the fewest historical observations a normal needs before anomalies are reported
+const minSamples = 30
+
+// Percentiles at which anomalies are called out as notable
+const (
+	unusualPercentile     = 90
+	exceptionalPercentile = 97
+)
+
+// Anomalies compares a temperature (°C) and hourly precipitation (mm) with a normal
+func Anomalies(normal models.ClimateNormal, temperature, precipitation float64) []models.Anomaly {
+	if normal.Samples < minSamples {
+		return nil
+	}
+
+	return []models.Anomaly{
+		temperatureAnomaly(normal, temperature),
+		precipitationAnomaly(normal, precipitation),
+	}
+}
+
+// temperatureAnomaly describes a temperature against its normal, calling out unusual warmth or cold
+func temperatureAnomaly(normal models.ClimateNormal, temperature float64) models.Anomaly {
+	anomaly := newAnomaly("temperature", "°C", normal.Temperature, temperature)
+
+	switch {
+	case anomaly.Percentile >= exceptionalPercentile:
+		anomaly.Callout = "exceptionally warm"
+	case anomaly.Percentile >= unusualPercentile:
+		anomaly.Callout = "unusually warm"
+	case anomaly.Percentile <= 100-exceptionalPercentile:
+		anomaly.Callout = "exceptionally cold"
+	case anomaly.Percentile <= 100-unusualPercentile:
+		anomaly.Callout = "unusually cold"
+	}
+	return anomaly
+}
+
+// precipitationAnomaly describes hourly precipitation against its normal.
+// Most hours are dry, so only heavy rain is called out; a dry hour is never unusual.
+func precipitationAnomaly(normal models.ClimateNormal, precipitation float64) models.Anomaly {
+	anomaly := newAnomaly("precipitation", "mm", normal.Precipitation, precipitation)
+
+	if precipitation >= wetThreshold {
+		switch {
+		case anomaly.Percentile >= 99:
+			anomaly.Callout = "exceptionally wet"
+		case anomaly.Percentile >= exceptionalPercentile:
+			anomaly.Callout = "unusually wet"
+		}
+	}
+	return anomaly
+}
+
+// newAnomaly fills in the comparison shared by every variable
+func newAnomaly(variable, unit string, stats models.NormalStats, value float64) models.Anomaly {
+	difference := round2(value - stats.Mean)
+	// Values outside the record are reported at the 1st or 99th percentile rather than 0th or 100th
+	percentile := math.Min(math.Max(math.Round(PercentileRank(stats, value)), 1), 99)
+
+	direction := "above"
+	if difference < 0 {
+		direction = "below"
+	}
+	separator := ""
+	if unit != "°C" {
+		separator = " "
+	}
+
+	return models.Anomaly{
+		Variable:   variable,
+		Value:      value,
+		Normal:     stats.Mean,
+		Difference: difference,
+		Unit:       unit,
+		Percentile: percentile,
+		Description: fmt.Sprintf("%+.1f%s%s %s normal, %s percentile",
+			difference, separator, unit, direction, ordinal(int(percentile))),
+	}
+}
+
+// ordinal formats a number as 1st, 2nd, 3rd, 4th, ...
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
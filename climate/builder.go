@@ -0,0 +1,119 @@
+package climate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// Options controls how climate normals are built
+type Options struct {
+	Years           int           // length of the historical period in years
+	RefreshInterval time.Duration // how often a location's normals are rebuilt
+	ArchiveLag      time.Duration // how far behind today archives are complete
+}
+
+// DefaultOptions returns ten-year normals rebuilt monthly
+func DefaultOptions() Options {
+	return Options{
+		Years:           10,
+		RefreshInterval: 30 * 24 * time.Hour,
+		ArchiveLag:      7 * 24 * time.Hour,
+	}
+}
+
+// Builder computes climate normals from history sources and keeps them in a store
+type Builder struct {
+	sources []datasource.HistorySource
+	store   *Store
+	options Options
+}
+
+// NewBuilder creates a builder; sources are tried in order for each year of history
+func NewBuilder(sources []datasource.HistorySource, store *Store, options Options) *Builder {
+	return &Builder{
+		sources: sources,
+		store:   store,
+		options: options,
+	}
+}
+
+// Refresh builds normals for every location that has none yet or whose normals are older than the refresh interval
+func (b *Builder) Refresh(ctx context.Context, locations []string) {
+	for _, location := range locations {
+		if ctx.Err() != nil {
+			return
+		}
+
+		built := b.store.Built(location)
+		if !built.IsZero() && time.Since(built) < b.options.RefreshInterval {
+			continue
+		}
+
+		if err := b.Build(ctx, location); err != nil {
+			log.Printf("Error building climate normals for %s: %v", location, err)
+		}
+	}
+}
+
+// Build fetches the historical period for a location one year at a time and stores the resulting normals
+func (b *Builder) Build(ctx context.Context, location string) error {
+	if len(b.sources) == 0 {
+		return fmt.Errorf("no history sources configured")
+	}
+
+	end := time.Now().UTC().Add(-b.options.ArchiveLag).Truncate(24 * time.Hour)
+	start := end.AddDate(-b.options.Years, 0, 1)
+
+	var hours []models.WeatherData
+	provider := ""
+	for from := start; !from.After(end); from = from.AddDate(1, 0, 0) {
+		to := from.AddDate(1, 0, -1)
+		if to.After(end) {
+			to = end
+		}
+
+		history, err := b.fetch(ctx, location, from, to)
+		if err != nil {
+			return err
+		}
+		hours = append(hours, history.Hours...)
+		provider = history.Provider
+	}
+
+	if len(hours) == 0 {
+		return fmt.Errorf("no historical observations returned")
+	}
+
+	normals := ComputeNormals(models.ClimateBaseline{
+		Location: location,
+		Provider: provider,
+		From:     start,
+		To:       end,
+		Built:    time.Now(),
+	}, hours)
+	b.store.SetNormals(location, normals)
+
+	log.Printf("Built climate normals for %s from %d hourly observations (%s to %s, %s)",
+		location, len(hours), start.Format("2006-01-02"), end.Format("2006-01-02"), provider)
+	return nil
+}
+
+// fetch returns the first successful history for a period
+func (b *Builder) fetch(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	var lastErr error
+	for _, source := range b.sources {
+		history, err := source.FetchHistory(ctx, location, from, to)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", source.Name(), err)
+			continue
+		}
+		return history, nil
+	}
+	return models.HistoryData{}, fmt.Errorf("failed to fetch history from %s to %s: %w",
+		from.Format("2006-01-02"), to.Format("2006-01-02"), lastErr)
+}
@@ -0,0 +1,311 @@
+package climate
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"weather-service/datasource"
+	"weather-service/models"
+)
+
+// linear returns stats for the values 1 to 21, whose percentile table is 1, 2, ... 21
+func linear() models.NormalStats {
+	values := make([]float64, 0, 21)
+	for v := 21.0; v >= 1; v-- {
+		values = append(values, v)
+	}
+	return summarize(values)
+}
+
+// mostlyDry returns a precipitation table that is zero up to the 50th percentile, then 1, 2, ... 10
+func mostlyDry() models.NormalStats {
+	table := make([]float64, 21)
+	for i := 11; i <= 20; i++ {
+		table[i] = float64(i - 10)
+	}
+	return models.NormalStats{Mean: 1, Percentiles: table}
+}
+
+func TestDayIndexFoldsLeapDay(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want int
+	}{
+		{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), 58},
+		{time.Date(2024, 2, 29, 23, 0, 0, 0, time.UTC), 58},
+		{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 59},
+		{time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC), 59},
+		{time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 364},
+		{time.Date(2100, 3, 1, 0, 0, 0, 0, time.UTC), 59},
+		// Days are UTC days
+		{time.Date(2023, 1, 1, 8, 0, 0, 0, time.FixedZone("AEST", 10*60*60)), 364},
+	}
+	for _, tt := range tests {
+		if got := dayIndex(tt.date); got != tt.want {
+			t.Errorf("dayIndex(%v) = %d, want %d", tt.date, got, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	stats := linear()
+	if stats.Mean != 11 {
+		t.Errorf("mean = %v, want 11", stats.Mean)
+	}
+	if len(stats.Percentiles) != 21 {
+		t.Fatalf("got %d percentiles, want 21", len(stats.Percentiles))
+	}
+	for i, v := range stats.Percentiles {
+		if v != float64(i+1) {
+			t.Errorf("percentile %d = %v, want %d", i*percentileStep, v, i+1)
+		}
+	}
+
+	if got := quantile([]float64{1, 2, 3, 4}, 0.5); got != 2.5 {
+		t.Errorf("median of 1-4 = %v, want 2.5", got)
+	}
+	if got := summarize(nil); got.Mean != 0 || got.Percentiles != nil {
+		t.Errorf("summarize(nil) = %+v, want empty stats", got)
+	}
+}
+
+func TestPercentileRank(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats models.NormalStats
+		value float64
+		want  float64
+	}{
+		{"below the record", linear(), 0, 0},
+		{"above the record", linear(), 22, 100},
+		{"on an entry", linear(), 11, 50},
+		{"between entries", linear(), 3.5, 12.5},
+		{"middle of a tied run", mostlyDry(), 0, 25},
+		{"after a tied run", mostlyDry(), 0.5, 52.5},
+		{"no table", models.NormalStats{}, 3, 50},
+	}
+	for _, tt := range tests {
+		if got := PercentileRank(tt.stats, tt.value); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: PercentileRank(%v) = %v, want %v", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestComputeNormalsPoolsAWindowAcrossTheNewYear(t *testing.T) {
+	var hours []models.WeatherData
+	for year := 2014; year < 2024; year++ {
+		precipitation := 0.0
+		if year%2 == 0 {
+			precipitation = wetThreshold
+		}
+		hours = append(hours,
+			models.WeatherData{Timestamp: time.Date(year, 1, 1, 12, 0, 0, 0, time.UTC), Temperature: float64(year - 2014), Precipitation: precipitation},
+			// Seven days before January 1, so inside its window
+			models.WeatherData{Timestamp: time.Date(year, 12, 25, 12, 0, 0, 0, time.UTC), Temperature: 100},
+			// Eight days after, so outside it
+			models.WeatherData{Timestamp: time.Date(year, 1, 9, 12, 0, 0, 0, time.UTC), Temperature: -100},
+		)
+	}
+
+	normals := ComputeNormals(models.ClimateBaseline{Location: "London,UK"}, hours)
+	if normals.Baseline.Samples != 30 || normals.Baseline.Location != "London,UK" {
+		t.Errorf("baseline = %+v, want 30 samples for London,UK", normals.Baseline)
+	}
+
+	normal := normals.Normal(time.Date(2030, 1, 1, 12, 30, 0, 0, time.UTC))
+	if normal.DayOfYear != 1 || normal.Hour != 12 || normal.Samples != 20 {
+		t.Errorf("normal = day %d hour %d with %d samples, want day 1 hour 12 with 20", normal.DayOfYear, normal.Hour, normal.Samples)
+	}
+	if want := (45.0 + 1000) / 20; normal.Temperature.Mean != want {
+		t.Errorf("mean temperature = %v, want %v", normal.Temperature.Mean, want)
+	}
+	if normal.WetFraction != 0.25 {
+		t.Errorf("wet fraction = %v, want 0.25", normal.WetFraction)
+	}
+
+	if empty := normals.Normal(time.Date(2030, 1, 1, 13, 0, 0, 0, time.UTC)); empty.Samples != 0 || empty.WetFraction != 0 {
+		t.Errorf("hour without observations = %+v", empty)
+	}
+}
+
+func TestAnomalies(t *testing.T) {
+	normal := models.ClimateNormal{Samples: minSamples, Temperature: linear(), Precipitation: mostlyDry()}
+
+	tests := []struct {
+		temperature          float64
+		precipitation        float64
+		temperatureCallout   string
+		temperatureText      string
+		precipitationCallout string
+		precipitationText    string
+	}{
+		{21, 10, "exceptionally warm", "+10.0°C above normal, 99th percentile", "exceptionally wet", "+9.0 mm above normal, 99th percentile"},
+		{19.5, 9.5, "unusually warm", "+8.5°C above normal, 93rd percentile", "unusually wet", "+8.5 mm above normal, 98th percentile"},
+		{11, 0, "", "+0.0°C above normal, 50th percentile", "", "-1.0 mm below normal, 25th percentile"},
+		{2, 0.05, "unusually cold", "-9.0°C below normal, 5th percentile", "", "-0.9 mm below normal, 50th percentile"},
+		{1, 0, "exceptionally cold", "-10.0°C below normal, 1st percentile", "", "-1.0 mm below normal, 25th percentile"},
+	}
+	for _, tt := range tests {
+		anomalies := Anomalies(normal, tt.temperature, tt.precipitation)
+		if len(anomalies) != 2 {
+			t.Fatalf("got %d anomalies, want temperature and precipitation", len(anomalies))
+		}
+		temperature, precipitation := anomalies[0], anomalies[1]
+		if temperature.Variable != "temperature" || temperature.Callout != tt.temperatureCallout || temperature.Description != tt.temperatureText {
+			t.Errorf("%v°C: %s %q, %q; want %q, %q", tt.temperature, temperature.Variable,
+				temperature.Callout, temperature.Description, tt.temperatureCallout, tt.temperatureText)
+		}
+		if precipitation.Variable != "precipitation" || precipitation.Callout != tt.precipitationCallout || precipitation.Description != tt.precipitationText {
+			t.Errorf("%v mm: %s %q, %q; want %q, %q", tt.precipitation, precipitation.Variable,
+				precipitation.Callout, precipitation.Description, tt.precipitationCallout, tt.precipitationText)
+		}
+	}
+
+	normal.Samples = minSamples - 1
+	if anomalies := Anomalies(normal, 21, 10); anomalies != nil {
+		t.Errorf("anomalies from %d samples = %+v, want none", normal.Samples, anomalies)
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 13: "13th",
+		21: "21st", 22: "22nd", 93: "93rd", 99: "99th", 111: "111th"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// calmNormals returns normals built from decades of 10°C dry hours at 12:00 and 15:00 UTC on March 10
+func calmNormals() *Normals {
+	var hours []models.WeatherData
+	for year := 1990; year < 2020; year++ {
+		for _, hour := range []int{12, 15} {
+			hours = append(hours, models.WeatherData{Timestamp: time.Date(year, 3, 10, hour, 0, 0, 0, time.UTC), Temperature: 10})
+		}
+	}
+	return ComputeNormals(models.ClimateBaseline{Location: "London,UK"}, hours)
+}
+
+func TestStoreAnnotatesForecastWithHourlyPrecipitation(t *testing.T) {
+	store := NewStore()
+	store.SetNormals("London,UK", calmNormals())
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	forecast := models.ForecastData{
+		Location: "london, uk",
+		Forecasts: []models.Forecast{
+			{Timestamp: at, Temperature: 10, Precipitation: 2},
+			{Timestamp: at.Add(3 * time.Hour), Temperature: 10, Precipitation: 3},
+		},
+	}
+
+	annotated := store.AnnotateForecast(forecast)
+	if forecast.Forecasts[0].Anomalies != nil {
+		t.Error("AnnotateForecast changed its argument")
+	}
+	for i, want := range []float64{2, 1} {
+		anomalies := annotated.Forecasts[i].Anomalies
+		if len(anomalies) != 2 || anomalies[1].Value != want {
+			t.Errorf("point %d anomalies = %+v, want hourly precipitation %v", i, anomalies, want)
+		}
+	}
+
+	var missing *Store
+	if got := missing.AnnotateForecast(forecast); got.Forecasts[0].Anomalies != nil {
+		t.Error("a nil store annotated a forecast")
+	}
+}
+
+func TestStoreAnnotatesWeatherByReadingLocation(t *testing.T) {
+	store := NewStore()
+	store.SetNormals("London,UK", calmNormals())
+
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	annotated := store.AnnotateWeather([]models.WeatherData{
+		{Location: "London, UK", Timestamp: at, Temperature: 10},
+		{Location: "Paris,FR", Timestamp: at, Temperature: 10},
+	})
+	if len(annotated[0].Anomalies) != 2 || annotated[1].Anomalies != nil {
+		t.Errorf("anomalies = %+v and %+v, want them for London only", annotated[0].Anomalies, annotated[1].Anomalies)
+	}
+}
+
+// fakeHistorySource returns a dry 10°C observation at noon of every day asked for, or fails
+type fakeHistorySource struct {
+	name   string
+	fail   bool
+	ranges [][2]time.Time
+}
+
+func (f *fakeHistorySource) Name() string { return f.name }
+
+func (f *fakeHistorySource) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	f.ranges = append(f.ranges, [2]time.Time{from, to})
+	if f.fail {
+		return models.HistoryData{}, errors.New("archive unavailable")
+	}
+	history := models.HistoryData{Provider: f.name, Location: location, From: from, To: to}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		history.Hours = append(history.Hours, models.WeatherData{Timestamp: day.Add(12 * time.Hour), Temperature: 10})
+	}
+	return history, nil
+}
+
+func TestBuilderFetchesAYearAtATimeFromTheFirstWorkingSource(t *testing.T) {
+	broken := &fakeHistorySource{name: "Broken", fail: true}
+	archive := &fakeHistorySource{name: "Archive"}
+	store := NewStore()
+	builder := NewBuilder([]datasource.HistorySource{broken, archive}, store, Options{Years: 2, RefreshInterval: time.Hour, ArchiveLag: 48 * time.Hour})
+
+	builder.Refresh(context.Background(), []string{"London,UK"})
+
+	normals, found := store.GetNormals("london,uk")
+	if !found {
+		t.Fatal("no normals built")
+	}
+	if len(archive.ranges) != 2 || len(broken.ranges) != 2 {
+		t.Fatalf("fetched %v from the archive and %v from the broken source, want two years each", archive.ranges, broken.ranges)
+	}
+	first, second := archive.ranges[0], archive.ranges[1]
+	if !second[0].Equal(first[1].AddDate(0, 0, 1)) || !first[0].Equal(second[0].AddDate(-1, 0, 0)) {
+		t.Errorf("years %v and %v don't follow on", first, second)
+	}
+	if !second[1].Equal(normals.Baseline.To) || !first[0].Equal(normals.Baseline.From) {
+		t.Errorf("baseline %v to %v, want the fetched period", normals.Baseline.From, normals.Baseline.To)
+	}
+	if lag := time.Since(normals.Baseline.To); lag < 48*time.Hour || lag > 72*time.Hour {
+		t.Errorf("period ends %v ago, want the archive lag", lag)
+	}
+	days := int(normals.Baseline.To.Sub(normals.Baseline.From).Hours()/24) + 1
+	if normals.Baseline.Provider != "Archive" || normals.Baseline.Samples != days {
+		t.Errorf("baseline = %+v, want one sample for each of %d days from Archive", normals.Baseline, days)
+	}
+
+	// Normals built within the refresh interval are kept
+	builder.Refresh(context.Background(), []string{"London,UK"})
+	if len(archive.ranges) != 2 {
+		t.Errorf("normals rebuilt within the refresh interval")
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	store := NewStore()
+	if err := NewBuilder(nil, store, DefaultOptions()).Build(context.Background(), "London,UK"); err == nil {
+		t.Error("Build without sources succeeded")
+	}
+
+	broken := &fakeHistorySource{name: "Broken", fail: true}
+	err := NewBuilder([]datasource.HistorySource{broken}, store, DefaultOptions()).Build(context.Background(), "London,UK")
+	if err == nil || !strings.Contains(err.Error(), "Broken: archive unavailable") {
+		t.Errorf("Build = %v, want the source's error", err)
+	}
+	if _, found := store.GetNormals("London,UK"); found {
+		t.Error("normals stored after a failed build")
+	}
+}
@@ -0,0 +1,178 @@
+package climate
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"weather-service/models"
+)
+
+const (
+	// daysPerYear is the number of climatological days; February 29 is folded into February 28
+	daysPerYear = 365
+
+	// windowDays is how many days either side of a date contribute to its normal
+	windowDays = 7
+
+	// percentileStep is the spacing of the stored percentile table
+	percentileStep = 5
+
+	// wetThreshold is the hourly precipitation (mm) counted as a wet hour
+	wetThreshold = 0.1
+)
+
+// Normals holds the climate normals for one location, indexed by climatological day and UTC hour
+type Normals struct {
+	Baseline models.ClimateBaseline
+	normals  [daysPerYear][24]models.ClimateNormal
+}
+
+// dayIndex returns the zero-based climatological day of a time, folding February 29 into February 28
+func dayIndex(t time.Time) int {
+	t = t.UTC()
+	day := t.YearDay() - 1
+	if isLeap(t.Year()) && day >= 59 {
+		day--
+	}
+	return day
+}
+
+// isLeap reports whether a year has February 29
+func isLeap(year int) bool {
+	return year%4 == 0 && (year%100 != 0 || year%400 == 0)
+}
+
+// ComputeNormals builds normals from hourly observations. Each day's normal pools the same UTC hour
+// from a window of days around it, so a decade of data gives a few hundred samples per slot.
+func ComputeNormals(baseline models.ClimateBaseline, hours []models.WeatherData) *Normals {
+	var temperatures, precipitation [daysPerYear][24][]float64
+	for _, h := range hours {
+		day, hour := dayIndex(h.Timestamp), h.Timestamp.UTC().Hour()
+		temperatures[day][hour] = append(temperatures[day][hour], h.Temperature)
+		precipitation[day][hour] = append(precipitation[day][hour], h.Precipitation)
+	}
+
+	normals := &Normals{Baseline: baseline}
+	normals.Baseline.Samples = len(hours)
+
+	for day := 0; day < daysPerYear; day++ {
+		for hour := 0; hour < 24; hour++ {
+			var temps, precips []float64
+			for offset := -windowDays; offset <= windowDays; offset++ {
+				d := (day + offset + daysPerYear) % daysPerYear
+				temps = append(temps, temperatures[d][hour]...)
+				precips = append(precips, precipitation[d][hour]...)
+			}
+
+			wet := 0
+			for _, p := range precips {
+				if p >= wetThreshold {
+					wet++
+				}
+			}
+
+			normal := models.ClimateNormal{
+				DayOfYear:     day + 1,
+				Hour:          hour,
+				Samples:       len(temps),
+				Temperature:   summarize(temps),
+				Precipitation: summarize(precips),
+			}
+			if len(precips) > 0 {
+				normal.WetFraction = float64(wet) / float64(len(precips))
+			}
+			normals.normals[day][hour] = normal
+		}
+	}
+
+	return normals
+}
+
+// Normal returns the normal for the climatological day and UTC hour of a time
+func (n *Normals) Normal(t time.Time) models.ClimateNormal {
+	return n.normals[dayIndex(t)][t.UTC().Hour()]
+}
+
+// summarize computes the mean and percentile table of a sample
+func summarize(values []float64) models.NormalStats {
+	if len(values) == 0 {
+		return models.NormalStats{}
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	percentiles := make([]float64, 0, 100/percentileStep+1)
+	for p := 0; p <= 100; p += percentileStep {
+		percentiles = append(percentiles, round2(quantile(sorted, float64(p)/100)))
+	}
+
+	return models.NormalStats{
+		Mean:        round2(sum / float64(len(sorted))),
+		Percentiles: percentiles,
+	}
+}
+
+// quantile interpolates the q-th quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// PercentileRank returns where a value falls (0-100) in a distribution given as a percentile table.
+// Values tied with a run of equal entries, such as the many dry hours of a precipitation record,
+// get the middle of that run.
+func PercentileRank(stats models.NormalStats, value float64) float64 {
+	table := stats.Percentiles
+	if len(table) < 2 {
+		return 50
+	}
+	last := len(table) - 1
+	step := 100 / float64(last)
+
+	if value < table[0] {
+		return 0
+	}
+	if value > table[last] {
+		return 100
+	}
+
+	// Find the run of entries equal to the value, if any
+	low, high := -1, -1
+	for i, v := range table {
+		if v == value {
+			if low < 0 {
+				low = i
+			}
+			high = i
+		}
+	}
+	if low >= 0 {
+		return float64(low+high) / 2 * step
+	}
+
+	// Otherwise interpolate between the surrounding entries
+	for i := 1; i <= last; i++ {
+		if value < table[i] {
+			fraction := (value - table[i-1]) / (table[i] - table[i-1])
+			return (float64(i-1) + fraction) * step
+		}
+	}
+	return 100
+}
+
+// round2 rounds to two decimal places
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,107 @@
+package climate
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// Store holds the climate normals built for each location
+type Store struct {
+	normals map[string]*Normals // key is the normalized location
+	mutex   sync.RWMutex
+}
+
+// NewStore creates a new in-memory climate normals store
+func NewStore() *Store {
+	return &Store{
+		normals: make(map[string]*Normals),
+	}
+}
+
+// SetNormals stores or replaces the normals for a location
+func (s *Store) SetNormals(location string, normals *Normals) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.normals[models.NormalizeLocation(location)] = normals
+}
+
+// GetNormals returns the normals for a location
+func (s *Store) GetNormals(location string) (*Normals, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	normals, found := s.normals[models.NormalizeLocation(location)]
+	return normals, found
+}
+
+// Built returns when the normals for a location were last built, or the zero time if they don't exist
+func (s *Store) Built(location string) time.Time {
+	normals, found := s.GetNormals(location)
+	if !found {
+		return time.Time{}
+	}
+	return normals.Baseline.Built
+}
+
+// Baselines describes the normals held for every location, sorted by location
+func (s *Store) Baselines() []models.ClimateBaseline {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	baselines := make([]models.ClimateBaseline, 0, len(s.normals))
+	for _, normals := range s.normals {
+		baselines = append(baselines, normals.Baseline)
+	}
+	sort.Slice(baselines, func(i, j int) bool {
+		return baselines[i].Location < baselines[j].Location
+	})
+	return baselines
+}
+
+// Anomalies compares a temperature (°C) and hourly precipitation (mm) at a location and time with the normals.
+// It returns nil if no normals have been built for the location yet.
+func (s *Store) Anomalies(location string, at time.Time, temperature, precipitation float64) []models.Anomaly {
+	normals, found := s.GetNormals(location)
+	if !found {
+		return nil
+	}
+	return Anomalies(normals.Normal(at), temperature, precipitation)
+}
+
+// AnnotateWeather returns a copy of the readings with anomalies filled in; a nil store leaves them unchanged
+func (s *Store) AnnotateWeather(data []models.WeatherData) []models.WeatherData {
+	if s == nil {
+		return data
+	}
+
+	annotated := make([]models.WeatherData, len(data))
+	for i, d := range data {
+		d.Anomalies = s.Anomalies(d.Location, d.Timestamp, d.Temperature, d.Precipitation)
+		annotated[i] = d
+	}
+	return annotated
+}
+
+// AnnotateForecast returns a copy of the forecast with anomalies filled in for every point.
+// Precipitation is converted to an hourly rate first, since providers forecast in steps of up to three hours.
+func (s *Store) AnnotateForecast(data models.ForecastData) models.ForecastData {
+	if s == nil {
+		return data
+	}
+
+	forecasts := make([]models.Forecast, len(data.Forecasts))
+	for i, f := range data.Forecasts {
+		hourly := f.Precipitation
+		if i > 0 {
+			if step := f.Timestamp.Sub(data.Forecasts[i-1].Timestamp).Hours(); step > 1 {
+				hourly /= step
+			}
+		}
+		f.Anomalies = s.Anomalies(data.Location, f.Timestamp, f.Temperature, hourly)
+		forecasts[i] = f
+	}
+	data.Forecasts = forecasts
+	return data
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// fetchLog records when each kind of data was last fetched for a location from a source.
+// It is snapshotted with the stores so a restart can tell which restored entries are still fresh.
+type fetchLog struct {
+	fetched map[string]time.Time // key is kind|source|location
+	mutex   sync.RWMutex
+}
+
+// newFetchLog creates an empty fetch log
+func newFetchLog() *fetchLog {
+	return &fetchLog{
+		fetched: make(map[string]time.Time),
+	}
+}
+
+// fetchKey builds the log key for a kind of data, source and location
+func fetchKey(kind, source, location string) string {
+	return kind + "|" + source + "|" + location
+}
+
+// Record notes a successful fetch
+func (l *fetchLog) Record(kind, source, location string, at time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.fetched[fetchKey(kind, source, location)] = at
+}
+
+// Age returns how long ago data was last fetched, and false if it never was
+func (l *fetchLog) Age(kind, source, location string) (time.Duration, bool) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	at, found := l.fetched[fetchKey(kind, source, location)]
+	if !found {
+		return 0, false
+	}
+	return time.Since(at), true
+}
+
+// Snapshot encodes the fetch times as JSON
+func (l *fetchLog) Snapshot() (json.RawMessage, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return json.Marshal(l.fetched)
+}
+
+// Restore replaces the fetch times with a snapshot
+func (l *fetchLog) Restore(data json.RawMessage) error {
+	var fetched map[string]time.Time
+	if err := json.Unmarshal(data, &fetched); err != nil {
+		return fmt.Errorf("failed to parse fetch log snapshot: %w", err)
+	}
+	if fetched == nil {
+		fetched = make(map[string]time.Time)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.fetched = fetched
+	return nil
+}
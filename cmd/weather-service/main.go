@@ -2,17 +2,30 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
+	"weather-service/alerts"
 	"weather-service/api"
+	"weather-service/auth"
+	"weather-service/cache"
+	"weather-service/climate"
+	"weather-service/consensus"
 	"weather-service/datasource"
+	"weather-service/freshness"
+	"weather-service/popularity"
+	"weather-service/ratelimit"
+	"weather-service/retention"
+	"weather-service/snapshot"
+	"weather-service/storage"
+	"weather-service/verification"
 
 	"github.com/joho/godotenv"
 )
@@ -28,8 +41,27 @@ func main() {
 	updateInterval := flag.Duration("update", 5*time.Minute, "Weather data update interval")
 	configFile := flag.String("config", "config.json", "Path to configuration file")
 	enableRateLimiting := flag.Bool("rate-limit", true, "Enable API rate limiting")
+	hashKey := flag.String("hash-key", "", "Print the hash of an API key for the keys file and exit")
+	jwksFromPEM := flag.String("jwks-from-pem", "", "Print a JWKS for the public keys in a PEM file, for validating locally signed JWTs, and exit")
 	flag.Parse()
 
+	if *hashKey != "" {
+		fmt.Println(auth.HashKey(*hashKey))
+		return
+	}
+	if *jwksFromPEM != "" {
+		data, err := os.ReadFile(*jwksFromPEM)
+		if err != nil {
+			log.Fatalf("Failed to read PEM file: %v", err)
+		}
+		jwks, err := auth.PublicKeySet(data)
+		if err != nil {
+			log.Fatalf("Failed to build JWKS: %v", err)
+		}
+		fmt.Println(string(jwks))
+		return
+	}
+
 	// Load configuration
 	config, err := datasource.LoadConfig(*configFile)
 	if err != nil {
@@ -39,6 +71,11 @@ func main() {
 	// Create the providers based on configuration
 	var providers []datasource.WeatherProvider
 	var forecastSources []datasource.ForecastSource
+	var airQualitySources []datasource.AirQualitySource
+	var alertSources []datasource.AlertSource
+	var historySources []datasource.HistorySource
+	var climateSources []datasource.HistorySource
+	var marineSources []datasource.MarineSource
 
 	if config.OpenWeatherMap.Enabled {
 		if config.OpenWeatherMap.APIKey == "" {
@@ -49,15 +86,17 @@ func main() {
 
 		// Apply rate limiting if enabled
 		if *enableRateLimiting {
-			// OpenWeatherMap free tier allows 60 calls/minute = 1 call per second
+			// OpenWeatherMap free tier allows 60 calls/minute = 1 call per second across every endpoint
 			// Allow bursts of up to 5 requests
-			rateLimitedProvider := datasource.NewRateLimitedProvider(owmProvider, 1.0, 1.0, 5)
+			rateLimitedProvider := datasource.NewRateLimitedProvider(owmProvider, 1.0, 5)
 			providers = append(providers, rateLimitedProvider)
 			forecastSources = append(forecastSources, rateLimitedProvider)
+			airQualitySources = append(airQualitySources, rateLimitedProvider)
 			log.Println("Applied rate limiting to OpenWeatherMap provider")
 		} else {
 			providers = append(providers, owmProvider)
 			forecastSources = append(forecastSources, owmProvider)
+			airQualitySources = append(airQualitySources, owmProvider)
 		}
 	}
 
@@ -70,15 +109,39 @@ func main() {
 
 		// Apply rate limiting if enabled
 		if *enableRateLimiting {
-			// WeatherAPI free tier allows ~23 calls/minute = 0.4 calls per second
+			// WeatherAPI free tier allows ~23 calls/minute = 0.4 calls per second across every endpoint
 			// Allow bursts of up to 3 requests
-			rateLimitedProvider := datasource.NewRateLimitedProvider(wapiProvider, 0.4, 0.4, 3)
+			rateLimitedProvider := datasource.NewRateLimitedProvider(wapiProvider, 0.4, 3)
 			providers = append(providers, rateLimitedProvider)
 			forecastSources = append(forecastSources, rateLimitedProvider)
+			airQualitySources = append(airQualitySources, rateLimitedProvider)
+			alertSources = append(alertSources, rateLimitedProvider)
+			historySources = append(historySources, rateLimitedProvider)
+			marineSources = append(marineSources, rateLimitedProvider)
 			log.Println("Applied rate limiting to WeatherAPI provider")
 		} else {
 			providers = append(providers, wapiProvider)
 			forecastSources = append(forecastSources, wapiProvider)
+			airQualitySources = append(airQualitySources, wapiProvider)
+			alertSources = append(alertSources, wapiProvider)
+			historySources = append(historySources, wapiProvider)
+			marineSources = append(marineSources, wapiProvider)
+		}
+	}
+
+	if config.OpenMeteo.Enabled {
+		omProvider := datasource.NewOpenMeteoProvider()
+
+		// Open-Meteo is only used for history, so it doesn't count as a weather provider
+		if *enableRateLimiting {
+			// The free API allows 600 calls/minute; stay well below it
+			rateLimitedSource := datasource.NewRateLimitedHistorySource(omProvider, 2.0, 5)
+			historySources = append(historySources, rateLimitedSource)
+			climateSources = append(climateSources, rateLimitedSource)
+			log.Println("Applied rate limiting to Open-Meteo provider")
+		} else {
+			historySources = append(historySources, omProvider)
+			climateSources = append(climateSources, omProvider)
 		}
 	}
 
@@ -86,13 +149,211 @@ func main() {
 		log.Fatal("No weather providers enabled in configuration")
 	}
 
-	// Create in-memory stores for weather and forecast data
-	weatherStore := api.NewWeatherStore()
-	forecastStore := api.NewForecastStore()
+	// Weather and forecast data live in the configured storage backend, so an on-disk
+	// backend can serve the last known data right after a restart
+	backend, err := storage.Open(config.Storage.Backend, config.Storage.Path)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer backend.Close()
+	log.Printf("Using %s storage backend", backendName(config.Storage.Backend))
+
+	weatherStore := api.NewWeatherStoreWithBackend(backend)
+	forecastStore := api.NewForecastStoreWithBackend(backend)
+	policy, compactionInterval := retentionPolicy(config)
+	compactor := retention.NewCompactor(backend, policy)
+	airQualityStore := api.NewAirQualityStore()
+	alertStore := alerts.NewStore()
+
+	// CAP feeds are polled alongside the provider alerts
+	var alertFeeds []*alerts.CAPFeed
+	for _, feed := range config.Alerts.Feeds {
+		if feed.URL == "" && feed.File == "" {
+			log.Printf("Warning: skipping CAP feed %q without url or file", feed.Name)
+			continue
+		}
+		alertFeeds = append(alertFeeds, alerts.NewCAPFeed(feed.Name, feed.URL, feed.File, feed.Location))
+	}
 
 	// Create API server
 	server := api.NewServer(weatherStore, forecastStore, *port)
-	server.RegisterForecastSources(forecastSources)
+	server.RegisterAlertStore(alertStore)
+	server.RegisterCompactor(compactor)
+
+	// Every endpoint except the public ones requires an API key when authentication is enabled, and the admin
+	// endpoints always do; keys managed through them are saved to the keys file
+	keys, err := apiKeys(config)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	server.RegisterKeyStore(keys)
+
+	// JWTs from the identity provider are validated against its key set, which is read again periodically so
+	// rotated keys are picked up
+	var keySet *auth.KeySet
+	if config.Auth.Enabled && config.Auth.JWT.Enabled {
+		var tokens *auth.TokenValidator
+		keySet, tokens, err = tokenValidator(config)
+		if err != nil {
+			log.Fatalf("Failed to set up JWT validation: %v", err)
+		}
+		server.RegisterTokenValidator(tokens)
+		log.Printf("JWT authentication enabled for issuer %s with %d signing keys", config.Auth.JWT.Issuer, keySet.Len())
+	}
+
+	if config.Auth.Enabled {
+		if keys.Len() == 0 && keySet == nil {
+			log.Println("Warning: authentication is enabled but no API keys are configured; all authenticated requests will be refused")
+		}
+		server.SetAuthRequired(true)
+		log.Printf("API key authentication enabled with %d keys", keys.Len())
+	}
+
+	// Clients are rate limited per key, or per address without a valid key, by tier
+	var limiter *ratelimit.Limiter
+	if config.RateLimit.Enabled {
+		limiter, err = ratelimit.NewLimiter(rateLimitOptions(config))
+		if err != nil {
+			log.Fatalf("Invalid rate limit configuration: %v", err)
+		}
+		server.RegisterRateLimiter(limiter)
+		log.Println("Per-client rate limiting enabled")
+	}
+
+	// Responses tell clients and proxies to cache them until the next update is due
+	server.SetRefreshInterval(*updateInterval)
+
+	// On-demand lookups accept any location, so every cache is bounded
+	newCacheBackend, err := cacheBackends(config)
+	if err != nil {
+		log.Fatalf("Failed to set up cache: %v", err)
+	}
+
+	// On-demand forecasts are cached; an expired forecast is served while it is refreshed or when the provider fails
+	// Errors such as unknown locations are cached briefly so repeated lookups don't spend provider quota
+	staleOptions := cacheStaleOptions(config)
+	negativeCache := cache.NewNegativeCache(negativeTTLs(config), negativeMaxEntries(config))
+	server.RegisterNegativeCache(negativeCache)
+	cachedForecastSources := make([]datasource.ForecastSource, 0, len(forecastSources))
+	for _, source := range forecastSources {
+		cached := cache.NewCachedForecastSourceWithBackend(source, 10*time.Minute, newCacheBackend())
+		cached.SetStaleOptions(staleOptions)
+		cached.SetNegativeCache(negativeCache)
+		cachedForecastSources = append(cachedForecastSources, cached)
+	}
+	server.RegisterForecastSources(cachedForecastSources)
+
+	// Past days don't change, so history is cached until it is evicted
+	cachedHistorySources := make([]datasource.HistorySource, 0, len(historySources))
+	for _, source := range historySources {
+		cachedHistorySources = append(cachedHistorySources, cache.NewCachedHistorySourceWithBackend(source, newCacheBackend()))
+	}
+	server.RegisterHistorySources(cachedHistorySources)
+
+	// Marine forecasts are fetched on demand, so cache them to keep repeat requests off the provider quota
+	cachedMarineSources := make([]datasource.MarineSource, 0, len(marineSources))
+	for _, source := range marineSources {
+		cached := cache.NewCachedMarineSourceWithBackend(source, 30*time.Minute, newCacheBackend())
+		cached.SetNegativeCache(negativeCache)
+		cachedMarineSources = append(cachedMarineSources, cached)
+	}
+	server.RegisterMarineSources(cachedMarineSources)
+
+	// The updater refreshes air quality of known locations into the store; other locations are fetched
+	// on demand through bounded caches, so arbitrary requested locations can't grow memory without limit
+	cachedAirQualitySources := make([]datasource.AirQualitySource, 0, len(airQualitySources))
+	for _, source := range airQualitySources {
+		cached := cache.NewCachedAirQualitySourceWithBackend(source, 30*time.Minute, newCacheBackend())
+		cached.SetNegativeCache(negativeCache)
+		cachedAirQualitySources = append(cachedAirQualitySources, cached)
+	}
+	server.RegisterAirQualitySources(cachedAirQualitySources, airQualityStore)
+
+	// Merge provider readings into a best estimate and log disagreements between them
+	thresholds := consensusThresholds(config)
+	server.SetConsensusThresholds(thresholds)
+	disagreementMonitor := consensus.NewMonitor(thresholds)
+
+	// Report how current served data is and hide expired data unless asked for
+	freshnessPolicies := freshnessPolicies(config)
+	server.SetFreshnessPolicies(freshnessPolicies)
+
+	// Climate normals need years of hourly data, which only the Open-Meteo archive serves in a few requests;
+	// WeatherAPI history costs one call per day, so it isn't used here
+	climateStore := climate.NewStore()
+	server.RegisterClimateStore(climateStore)
+	climateBuilder := climate.NewBuilder(climateSources, climateStore, climateOptions(config))
+	if len(climateSources) == 0 {
+		log.Println("Open-Meteo is disabled; climate anomalies will not be reported")
+	}
+
+	// Keep issued forecasts and score them against incoming observations
+	verifier := verification.NewVerifier(verificationOptions(config))
+	server.RegisterVerifier(verifier)
+
+	// Unconfigured locations with heavy traffic are refreshed in the background so they're served from the stores
+	var popular *popularity.Tracker
+	if config.Popular.Enabled {
+		popular = popularity.NewTracker(popularityOptions(config), config.Locations)
+		server.RegisterPopularity(popular)
+	}
+
+	// Snapshot in-memory state so a restart serves the last known data instead of refetching everything
+	snapshots, snapshotInterval := snapshotManager(config)
+	fetched := newFetchLog()
+	if snapshots != nil {
+		// The bolt backend already persists weather and forecasts
+		if memory, ok := backend.(*storage.MemoryBackend); ok {
+			snapshots.Register("storage", memory)
+		}
+		snapshots.Register("airquality", airQualityStore)
+		for _, source := range cachedForecastSources {
+			snapshots.Register("forecast/"+source.Name(), source.(snapshot.Source))
+		}
+		for _, source := range cachedHistorySources {
+			snapshots.Register("history/"+source.Name(), source.(snapshot.Source))
+		}
+		for _, source := range cachedMarineSources {
+			snapshots.Register("marine/"+source.Name(), source.(snapshot.Source))
+		}
+		for _, source := range cachedAirQualitySources {
+			snapshots.Register("airquality/"+source.Name(), source.(snapshot.Source))
+		}
+		snapshots.Register("updater", fetched)
+		snapshots.Register("apikeys", keys)
+		// Issued forecasts and their scores only live in memory, and take days to build up again
+		snapshots.Register("verification", verifier)
+	}
+
+	dataUpdater := &updater{
+		providers:         providers,
+		forecastSources:   forecastSources,
+		airQualitySources: airQualitySources,
+		alertSources:      alertSources,
+		alertFeeds:        alertFeeds,
+		weatherStore:      weatherStore,
+		forecastStore:     forecastStore,
+		airQualityStore:   airQualityStore,
+		alertStore:        alertStore,
+		monitor:           disagreementMonitor,
+		verifier:          verifier,
+		freshness:         freshnessPolicies,
+		config:            config,
+		popular:           popular,
+		fetched:           fetched,
+		interval:          *updateInterval,
+	}
+
+	// Restore the snapshot before the first update so data fetched recently isn't fetched again
+	if snapshots != nil {
+		taken, err := snapshots.Load()
+		if err != nil {
+			log.Printf("Warning: failed to load snapshot: %v", err)
+		} else if !taken.IsZero() {
+			log.Printf("Restored snapshot taken %s ago", time.Since(taken).Round(time.Second))
+			dataUpdater.warmStart = true
+		}
+	}
 
 	// Set up channels for graceful shutdown
 	shutdownChan := make(chan os.Signal, 1)
@@ -105,18 +366,74 @@ func main() {
 		defer ticker.Stop()
 
 		// Update weather and forecast data immediately on startup
-		updateData(providers, forecastSources, weatherStore, forecastStore, config)
+		dataUpdater.updateData()
 
 		for {
 			select {
 			case <-ticker.C:
-				updateData(providers, forecastSources, weatherStore, forecastStore, config)
+				dataUpdater.updateData()
 			case <-updateChan:
 				return
 			}
 		}
 	}()
 
+	// Build climate normals in the background for every location with weather data.
+	// Rebuilding is cheap to check, so look for new or stale locations every hour.
+	if len(climateSources) > 0 {
+		go func() {
+			// Give the first weather update time to populate the store
+			select {
+			case <-time.After(time.Minute):
+			case <-updateChan:
+				return
+			}
+
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+
+			for {
+				climateBuilder.Refresh(context.Background(), weatherStore.GetAllLocations())
+
+				select {
+				case <-ticker.C:
+				case <-updateChan:
+					return
+				}
+			}
+		}()
+	}
+
+	// Roll up stored observations and enforce retention, including old forecasts
+	go func() {
+		ticker := time.NewTicker(compactionInterval)
+		defer ticker.Stop()
+
+		for {
+			compactor.Run(time.Now())
+
+			select {
+			case <-ticker.C:
+			case <-updateChan:
+				return
+			}
+		}
+	}()
+
+	// Snapshot periodically so a crash loses at most one interval of state
+	if snapshots != nil {
+		go snapshots.Run(snapshotInterval, updateChan)
+	}
+
+	if keySet != nil {
+		go keySet.Run(jwksRefreshInterval(config), updateChan)
+	}
+
+	// Drop the buckets of clients that have gone quiet so the limiter doesn't grow with every address seen
+	if limiter != nil {
+		go limiter.Run(time.Minute, updateChan)
+	}
+
 	// Start the API server in a goroutine
 	go func() {
 		if err := server.Start(); err != nil {
@@ -131,85 +448,430 @@ func main() {
 	// Notify updater to stop
 	close(updateChan)
 
-	// Periodically clean up old forecasts (every 24 hours)
-	forecastPruneAge := 48 * time.Hour // Remove forecasts older than 2 days
-	go func() {
-		ticker := time.NewTicker(24 * time.Hour)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				forecastStore.PruneOldForecasts(forecastPruneAge)
-			case <-updateChan:
-				return
-			}
+	if snapshots != nil {
+		if err := snapshots.Save(); err != nil {
+			log.Printf("Failed to save snapshot: %v", err)
 		}
-	}()
+	}
 
 	fmt.Println("Shutdown complete")
 }
 
-// updateData fetches the latest weather and forecast data from all providers
-func updateData(
-	providers []datasource.WeatherProvider,
-	forecastSources []datasource.ForecastSource,
-	weatherStore *api.WeatherStore,
-	forecastStore *api.ForecastStore,
-	config *datasource.Config,
-) {
-	fmt.Println("Updating weather data...")
+// consensusThresholds builds disagreement thresholds from configuration, keeping defaults for unset values
+func consensusThresholds(config *datasource.Config) consensus.Thresholds {
+	thresholds := consensus.DefaultThresholds()
+	c := config.Consensus
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	if c.Temperature > 0 {
+		thresholds.Temperature = c.Temperature
+	}
+	if c.Humidity > 0 {
+		thresholds.Humidity = c.Humidity
+	}
+	if c.WindSpeed > 0 {
+		thresholds.WindSpeed = c.WindSpeed
+	}
+	if c.WindDeg > 0 {
+		thresholds.WindDeg = c.WindDeg
+	}
+	if c.Pressure > 0 {
+		thresholds.Pressure = c.Pressure
+	}
+	if c.RainThreshold > 0 {
+		thresholds.RainThreshold = c.RainThreshold
+	}
+	if c.RecencyHalfLife != "" {
+		if d, err := time.ParseDuration(c.RecencyHalfLife); err == nil {
+			thresholds.RecencyHalfLife = d
+		} else {
+			log.Printf("Warning: invalid consensus recencyHalfLife %q: %v", c.RecencyHalfLife, err)
+		}
+	}
 
-	// Create wait group for concurrent updates
-	var wg sync.WaitGroup
+	return thresholds
+}
 
-	// Update current weather data
-	for _, location := range config.Locations {
-		for _, provider := range providers {
-			wg.Add(1)
-			go func(loc string, prov datasource.WeatherProvider) {
-				defer wg.Done()
+// freshnessPolicies builds staleness policies from configuration, keeping defaults for unset values
+func freshnessPolicies(config *datasource.Config) freshness.Policies {
+	policies := freshness.DefaultPolicies()
+	for kind, policy := range stalenessPolicies("", config.Freshness.FreshnessConfig) {
+		defaults := policies.Defaults[kind]
+		if policy.StaleAfter > 0 {
+			defaults.StaleAfter = policy.StaleAfter
+		}
+		if policy.ExpireAfter > 0 {
+			defaults.ExpireAfter = policy.ExpireAfter
+		}
+		policies.Defaults[kind] = defaults
+	}
+	for provider, c := range config.Freshness.Providers {
+		policies.Providers[provider] = stalenessPolicies(provider+" ", c)
+	}
+	return policies
+}
 
-				// Get current weather
-				data, err := prov.GetWeather(ctx, loc)
-				if err != nil {
-					log.Printf("Error fetching weather for %s from %s: %v", loc, prov.Name(), err)
-					return
-				}
+// stalenessPolicies parses the staleness durations for each kind of data; unset values are left zero
+func stalenessPolicies(prefix string, c datasource.FreshnessConfig) map[freshness.Kind]freshness.Policy {
+	policies := make(map[freshness.Kind]freshness.Policy)
+	kinds := []struct {
+		kind   freshness.Kind
+		config datasource.StalenessConfig
+	}{
+		{freshness.KindWeather, c.Weather},
+		{freshness.KindForecast, c.Forecast},
+		{freshness.KindAirQuality, c.AirQuality},
+	}
+	for _, k := range kinds {
+		var policy freshness.Policy
+		parseDurations("freshness "+prefix+string(k.kind),
+			durationSetting{"stale", k.config.Stale, &policy.StaleAfter},
+			durationSetting{"expired", k.config.Expired, &policy.ExpireAfter},
+		)
+		policies[k.kind] = policy
+	}
+	return policies
+}
 
-				// Store the data
-				weatherStore.UpdateWeather(data)
-				log.Printf("Updated weather data for %s from %s", loc, prov.Name())
-			}(location, provider)
+// cacheBackends returns a constructor for each cache's backend: a bounded LRU per cache, or one
+// shared Redis connection whose keys are kept apart by each cache's prefix
+func cacheBackends(config *datasource.Config) (func() cache.Backend, error) {
+	c := config.Cache
+	switch c.Backend {
+	case "", "memory":
+		maxEntries, maxBytes := cache.DefaultMaxEntries, int64(cache.DefaultMaxBytes)
+		if c.MaxEntries > 0 {
+			maxEntries = c.MaxEntries
 		}
+		if c.MaxBytes > 0 {
+			maxBytes = c.MaxBytes
+		}
+		log.Printf("Using in-memory caches of up to %d entries and %d bytes each", maxEntries, maxBytes)
+		return func() cache.Backend {
+			return cache.NewLRUBackend(maxEntries, maxBytes)
+		}, nil
+	case "redis":
+		if c.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis cache backend needs an address")
+		}
+		redis := cache.NewRedisBackend(c.Redis.Addr, c.Redis.Password, c.Redis.DB)
+
+		// Redis may come up after the service, so an unreachable server is only a warning; lookups miss until it's back
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redis.Ping(ctx); err != nil {
+			log.Printf("Warning: Redis cache at %s is not reachable: %v", c.Redis.Addr, err)
+		} else {
+			log.Printf("Using Redis cache at %s", c.Redis.Addr)
+		}
+		return func() cache.Backend {
+			return redis
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", c.Backend)
 	}
+}
 
-	// Update forecast data (3 days by default)
-	for _, location := range config.Locations {
-		for _, source := range forecastSources {
-			wg.Add(1)
-			go func(loc string, src datasource.ForecastSource) {
-				defer wg.Done()
+// durationSetting is a configured duration and where to put it
+type durationSetting struct {
+	name  string
+	value string
+	dest  *time.Duration
+}
 
-				// Get forecast data (3 days)
-				forecast, err := src.FetchForecast(ctx, loc, 3)
-				if err != nil {
-					log.Printf("Error fetching forecast for %s from %s: %v", loc, src.Name(), err)
-					return
+// parseDurations sets each configured duration, keeping the current value of unset and invalid ones,
+// which are logged as part of a configuration section
+func parseDurations(section string, settings ...durationSetting) {
+	for _, d := range settings {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			log.Printf("Warning: invalid %s %s %q: %v", section, d.name, d.value, err)
+			continue
+		}
+		*d.dest = parsed
+	}
+}
+
+// cacheStaleOptions builds the options for serving expired cache entries from configuration
+func cacheStaleOptions(config *datasource.Config) cache.StaleOptions {
+	var options cache.StaleOptions
+	c := config.Cache
+
+	parseDurations("cache",
+		durationSetting{"staleWhileRevalidate", c.StaleWhileRevalidate, &options.WhileRevalidate},
+		durationSetting{"staleIfError", c.StaleIfError, &options.IfError},
+	)
+
+	return options
+}
+
+// negativeTTLs builds the negative cache TTLs from configuration, keeping defaults for unset kinds
+func negativeTTLs(config *datasource.Config) cache.NegativeTTLs {
+	ttls := cache.DefaultNegativeTTLs()
+	for kind, value := range config.Cache.NegativeTTLs {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			log.Printf("Warning: invalid cache negativeTTLs %s %q: %v", kind, value, err)
+			continue
+		}
+		ttls[datasource.ErrorKind(kind)] = parsed
+	}
+	return ttls
+}
+
+// negativeMaxEntries returns the configured negative cache bound, or a default
+func negativeMaxEntries(config *datasource.Config) int {
+	if config.Cache.NegativeMaxEntries > 0 {
+		return config.Cache.NegativeMaxEntries
+	}
+	return 10000
+}
+
+// backendName returns the storage backend name used when none is configured
+func backendName(backend string) string {
+	if backend == "" {
+		return storage.BackendMemory
+	}
+	return backend
+}
+
+// retentionPolicy builds the retention policy and compaction interval from configuration, keeping defaults for unset values
+func retentionPolicy(config *datasource.Config) (retention.Policy, time.Duration) {
+	policy := retention.DefaultPolicy()
+	interval := time.Hour
+	c := config.Retention
+
+	parseDurations("retention",
+		durationSetting{"raw", c.Raw, &policy.Raw},
+		durationSetting{"hourly", c.Hourly, &policy.Hourly},
+		durationSetting{"daily", c.Daily, &policy.Daily},
+		durationSetting{"forecasts", c.Forecasts, &policy.Forecasts},
+		durationSetting{"interval", c.Interval, &interval},
+	)
+
+	// Rollups are built from the finer tier before it is trimmed, so compaction must run more
+	// often than the shortest retention
+	if policy.Raw > 0 && interval >= policy.Raw {
+		log.Printf("Warning: retention interval %s is not shorter than raw retention %s; using %s", interval, policy.Raw, policy.Raw/2)
+		interval = policy.Raw / 2
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return policy, interval
+}
+
+// snapshotManager creates the snapshot manager and interval from configuration, or nil if snapshots are disabled
+func snapshotManager(config *datasource.Config) (*snapshot.Manager, time.Duration) {
+	c := config.Snapshot
+	if c.Path == "" {
+		return nil, 0
+	}
+
+	interval := 10 * time.Minute
+	if c.Interval != "" {
+		if d, err := time.ParseDuration(c.Interval); err == nil && d > 0 {
+			interval = d
+		} else {
+			log.Printf("Warning: invalid snapshot interval %q; using %s", c.Interval, interval)
+		}
+	}
+
+	return snapshot.NewManager(c.Path), interval
+}
+
+// climateOptions builds climate normal options from configuration, keeping defaults for unset values
+func climateOptions(config *datasource.Config) climate.Options {
+	options := climate.DefaultOptions()
+	c := config.Climate
+
+	if c.Years > 0 {
+		options.Years = c.Years
+	}
+	if c.RefreshInterval != "" {
+		if d, err := time.ParseDuration(c.RefreshInterval); err == nil {
+			options.RefreshInterval = d
+		} else {
+			log.Printf("Warning: invalid climate refreshInterval %q: %v", c.RefreshInterval, err)
+		}
+	}
+
+	return options
+}
+
+// apiKeys loads the API keys listed in configuration and in the keys file, which managed keys are saved to
+func apiKeys(config *datasource.Config) (*auth.KeyStore, error) {
+	keys := auth.NewKeyStore()
+	for _, k := range config.Auth.Keys {
+		entry := auth.KeyEntry{
+			Name:       k.Name,
+			Key:        k.Key,
+			Hash:       k.Hash,
+			Locations:  k.Locations,
+			DailyQuota: k.DailyQuota,
+			Tier:       k.Tier,
+			Revoked:    k.Revoked,
+		}
+		for _, scope := range k.Scopes {
+			entry.Scopes = append(entry.Scopes, auth.Scope(scope))
+		}
+		if k.ExpiresAt != "" {
+			expires, err := time.Parse(time.RFC3339, k.ExpiresAt)
+			if err != nil {
+				return nil, fmt.Errorf("key %q has an invalid expiresAt: %w", k.Name, err)
+			}
+			entry.ExpiresAt = &expires
+		}
+		if _, err := keys.AddEntry(entry); err != nil {
+			return nil, err
+		}
+	}
+	if config.Auth.KeysFile != "" {
+		if _, err := keys.LoadFile(config.Auth.KeysFile); errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Warning: API keys file %s does not exist", config.Auth.KeysFile)
+		} else if err != nil {
+			return nil, err
+		}
+		keys.SetPath(config.Auth.KeysFile)
+	}
+	return keys, nil
+}
+
+// tokenValidator sets up JWT validation from configuration and reads the key set. A key set that can't be read
+// yet is only a warning, since it is read again when a token names an unknown key.
+func tokenValidator(config *datasource.Config) (*auth.KeySet, *auth.TokenValidator, error) {
+	c := config.Auth.JWT
+	if (c.JWKSURL == "") == (c.JWKSFile == "") {
+		return nil, nil, fmt.Errorf("exactly one of jwksUrl and jwksFile must be set")
+	}
+
+	options := auth.DefaultTokenOptions()
+	options.Issuer = c.Issuer
+	options.Audience = c.Audience
+	options.Tier = c.Tier
+	if c.ScopeClaim != "" {
+		options.ScopeClaim = c.ScopeClaim
+	}
+	if c.Leeway != "" {
+		leeway, err := time.ParseDuration(c.Leeway)
+		if err != nil || leeway < 0 {
+			log.Printf("Warning: invalid JWT leeway %q", c.Leeway)
+		} else {
+			options.Leeway = leeway
+		}
+	}
+	if len(c.ClaimScopes) > 0 {
+		options.ClaimScopes = make(map[string]map[string][]auth.Scope)
+		for claim, values := range c.ClaimScopes {
+			options.ClaimScopes[claim] = make(map[string][]auth.Scope)
+			for value, scopes := range values {
+				for _, scope := range scopes {
+					options.ClaimScopes[claim][value] = append(options.ClaimScopes[claim][value], auth.Scope(scope))
 				}
+			}
+		}
+	}
+
+	keySet := auth.NewKeySet(c.JWKSURL, c.JWKSFile)
+	tokens, err := auth.NewTokenValidator(keySet, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := keySet.Refresh(ctx); err != nil {
+		log.Printf("Warning: failed to read JWKS: %v", err)
+	}
+	return keySet, tokens, nil
+}
+
+// jwksRefreshInterval returns how often the JWT key set is read again, an hour unless configured
+func jwksRefreshInterval(config *datasource.Config) time.Duration {
+	interval := time.Hour
+	if value := config.Auth.JWT.Refresh; value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Printf("Warning: invalid JWT refresh %q", value)
+		} else {
+			interval = d
+		}
+	}
+	return interval
+}
+
+// rateLimitOptions builds per-client rate limit options from configuration, keeping defaults for unset values
+func rateLimitOptions(config *datasource.Config) ratelimit.Options {
+	options := ratelimit.DefaultOptions()
+	c := config.RateLimit
 
-				// Store the forecast data
-				forecastStore.UpdateForecast(forecast)
-				log.Printf("Updated forecast data for %s from %s", loc, src.Name())
-			}(location, source)
+	for name, tier := range c.Tiers {
+		options.Tiers[name] = ratelimit.Tier{Rate: tier.Rate, Burst: tier.Burst}
+	}
+	if c.DefaultTier != "" {
+		options.DefaultTier = c.DefaultTier
+	}
+	if c.AnonymousTier != "" {
+		options.AnonymousTier = c.AnonymousTier
+	}
+	for _, name := range []string{options.DefaultTier, options.AnonymousTier} {
+		if _, exists := options.Tiers[name]; !exists {
+			log.Printf("Warning: rate limit tier %q is not configured; its clients are not limited", name)
 		}
 	}
+	if c.IdleAfter != "" {
+		if d, err := time.ParseDuration(c.IdleAfter); err != nil {
+			log.Printf("Warning: invalid rate limit idleAfter %q: %v", c.IdleAfter, err)
+		} else {
+			options.IdleAfter = d
+		}
+	}
+	options.TrustProxy = c.TrustProxy
+
+	return options
+}
+
+// popularityOptions builds popular location refresh options from configuration, keeping defaults for unset values
+func popularityOptions(config *datasource.Config) popularity.Options {
+	options := popularity.DefaultOptions()
+	c := config.Popular
+
+	parseDurations("popular",
+		durationSetting{"window", c.Window, &options.Window},
+		durationSetting{"idleAfter", c.IdleAfter, &options.IdleAfter},
+		durationSetting{"refreshAfter", c.RefreshAfter, &options.RefreshAfter},
+	)
+
+	if c.MinRequests > 0 {
+		options.MinRequests = c.MinRequests
+	}
+	if c.MaxLocations > 0 {
+		options.MaxLocations = c.MaxLocations
+	}
+	if c.QuotaShare > 0 {
+		options.QuotaShare = c.QuotaShare
+	}
+
+	return options
+}
+
+// verificationOptions builds forecast verification options from configuration, keeping defaults for unset values
+func verificationOptions(config *datasource.Config) verification.Options {
+	options := verification.DefaultOptions()
+	c := config.Verification
+
+	parseDurations("verification",
+		durationSetting{"tolerance", c.Tolerance, &options.Tolerance},
+		durationSetting{"issueInterval", c.IssueInterval, &options.IssueInterval},
+		durationSetting{"window", c.Window, &options.Window},
+	)
+
+	if c.LeadBucketHours > 0 {
+		options.LeadBucket = time.Duration(c.LeadBucketHours) * time.Hour
+	}
 
-	// Wait for all updates to complete
-	wg.Wait()
-	fmt.Println("Weather and forecast data update complete")
+	return options
 }
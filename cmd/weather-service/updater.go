@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"weather-service/alerts"
+	"weather-service/api"
+	"weather-service/consensus"
+	"weather-service/datasource"
+	"weather-service/freshness"
+	"weather-service/popularity"
+	"weather-service/verification"
+)
+
+// airQualityRetention is how long air quality readings are kept after their last refresh
+const airQualityRetention = 24 * time.Hour
+
+// updater refreshes the stores from every configured source
+type updater struct {
+	providers         []datasource.WeatherProvider
+	forecastSources   []datasource.ForecastSource
+	airQualitySources []datasource.AirQualitySource
+	alertSources      []datasource.AlertSource
+	alertFeeds        []*alerts.CAPFeed
+
+	weatherStore    *api.WeatherStore
+	forecastStore   *api.ForecastStore
+	airQualityStore *api.AirQualityStore
+	alertStore      *alerts.Store
+
+	monitor   *consensus.Monitor
+	verifier  *verification.Verifier
+	freshness freshness.Policies
+	config    *datasource.Config
+
+	// popular adds unconfigured locations with heavy traffic to the refresh, within its share of calls
+	popular *popularity.Tracker
+
+	// fetched records successful fetches; on a warm start, entries fetched within interval are not refetched
+	fetched   *fetchLog
+	interval  time.Duration
+	warmStart bool
+}
+
+// isFresh reports whether this is a warm start and the data was fetched recently enough to skip
+func (u *updater) isFresh(kind, source, location string) bool {
+	if !u.warmStart {
+		return false
+	}
+	age, found := u.fetched.Age(kind, source, location)
+	return found && age < u.interval
+}
+
+// updateData fetches the latest weather, forecast, air quality and alert data from all sources
+func (u *updater) updateData() {
+	fmt.Println("Updating weather data...")
+
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Create wait group for concurrent updates
+	var wg sync.WaitGroup
+	skipped := 0
+
+	// Popular locations are refreshed like configured ones, but only when due and within their quota share
+	perLocation := len(u.providers) + len(u.forecastSources) + len(u.airQualitySources)
+	staticCalls := len(u.config.Locations) * (perLocation + len(u.alertSources))
+	dynamic := u.popular.Plan(time.Now(), staticCalls, perLocation)
+	locations := append(append([]string{}, u.config.Locations...), dynamic...)
+	if len(dynamic) > 0 {
+		log.Printf("Refreshing %d popular locations: %v", len(dynamic), dynamic)
+	}
+
+	// Update current weather data
+	for _, location := range locations {
+		for _, provider := range u.providers {
+			if u.isFresh("weather", provider.Name(), location) {
+				skipped++
+				continue
+			}
+			wg.Add(1)
+			go func(loc string, prov datasource.WeatherProvider) {
+				defer wg.Done()
+
+				// Get current weather
+				data, err := prov.GetWeather(ctx, loc)
+				if err != nil {
+					log.Printf("Error fetching weather for %s from %s: %v", loc, prov.Name(), err)
+					return
+				}
+
+				// Store the data under the location asked for, so every provider's reading of it is merged
+				// and verified together, and verify earlier forecasts against it
+				data.Location = loc
+				u.weatherStore.UpdateWeather(data)
+				u.verifier.RecordObservation(data)
+				u.fetched.Record("weather", prov.Name(), loc, time.Now())
+				log.Printf("Updated weather data for %s from %s", loc, prov.Name())
+			}(location, provider)
+		}
+	}
+
+	// Update forecast data (3 days by default)
+	for _, location := range locations {
+		for _, source := range u.forecastSources {
+			if u.isFresh("forecast", source.Name(), location) {
+				skipped++
+				continue
+			}
+			wg.Add(1)
+			go func(loc string, src datasource.ForecastSource) {
+				defer wg.Done()
+
+				// Get forecast data (3 days)
+				forecast, err := src.FetchForecast(ctx, loc, 3)
+				if err != nil {
+					log.Printf("Error fetching forecast for %s from %s: %v", loc, src.Name(), err)
+					return
+				}
+
+				// Store the forecast data under the location asked for and keep the issue for verification
+				forecast.Location = loc
+				u.forecastStore.UpdateForecast(forecast)
+				u.verifier.RecordForecast(forecast)
+				u.fetched.Record("forecast", src.Name(), loc, time.Now())
+				log.Printf("Updated forecast data for %s from %s", loc, src.Name())
+			}(location, source)
+		}
+	}
+
+	// Update air quality data
+	for _, location := range locations {
+		for _, source := range u.airQualitySources {
+			if u.isFresh("airquality", source.Name(), location) {
+				skipped++
+				continue
+			}
+			wg.Add(1)
+			go func(loc string, src datasource.AirQualitySource) {
+				defer wg.Done()
+
+				aq, err := src.FetchAirQuality(ctx, loc)
+				if err != nil {
+					log.Printf("Error fetching air quality for %s from %s: %v", loc, src.Name(), err)
+					return
+				}
+
+				aq.Location = loc
+				u.airQualityStore.UpdateAirQuality(aq)
+				u.fetched.Record("airquality", src.Name(), loc, time.Now())
+				log.Printf("Updated air quality data for %s from %s", loc, src.Name())
+			}(location, source)
+		}
+	}
+
+	// Update alerts from providers and CAP feeds
+	for _, location := range u.config.Locations {
+		for _, source := range u.alertSources {
+			wg.Add(1)
+			go func(loc string, src datasource.AlertSource) {
+				defer wg.Done()
+
+				fetched, err := src.FetchAlerts(ctx, loc)
+				if err != nil {
+					log.Printf("Error fetching alerts for %s from %s: %v", loc, src.Name(), err)
+					return
+				}
+
+				u.alertStore.AddAlerts(fetched)
+				log.Printf("Updated alerts for %s from %s (%d alerts)", loc, src.Name(), len(fetched))
+			}(location, source)
+		}
+	}
+	for _, feed := range u.alertFeeds {
+		wg.Add(1)
+		go func(f *alerts.CAPFeed) {
+			defer wg.Done()
+
+			feedAlerts, err := f.Fetch(ctx)
+			if err != nil {
+				log.Printf("Error fetching CAP feed %s: %v", f.Name(), err)
+				return
+			}
+
+			u.alertStore.AddAlerts(feedAlerts)
+			log.Printf("Updated alerts from CAP feed %s (%d alerts)", f.Name(), len(feedAlerts))
+		}(feed)
+	}
+
+	// Wait for all updates to complete
+	wg.Wait()
+
+	// Only the first update after restoring a snapshot skips fresh data
+	if u.warmStart {
+		log.Printf("Warm start: skipped %d fetches still fresh from the snapshot", skipped)
+		u.warmStart = false
+	}
+
+	// Drop alerts that expired since the last update
+	if removed := u.alertStore.PruneExpired(); removed > 0 {
+		log.Printf("Removed %d expired alerts", removed)
+	}
+
+	// Only configured and popular locations are refreshed; forget readings of locations that dropped out
+	if removed := u.airQualityStore.PruneOldAirQuality(airQualityRetention); removed > 0 {
+		log.Printf("Removed %d air quality readings no longer refreshed", removed)
+	}
+
+	// Check the refreshed readings for provider disagreement; expired readings from a failing provider would only add noise
+	for _, location := range u.weatherStore.GetAllLocations() {
+		if data, exists := u.weatherStore.GetWeatherByLocation(location); exists {
+			if current, _ := u.freshness.Weather(data, time.Now(), false); len(current) > 0 {
+				u.monitor.Check(location, current)
+			}
+		}
+	}
+
+	fmt.Println("Weather and forecast data update complete")
+}
@@ -0,0 +1,297 @@
+package consensus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"weather-service/models"
+)
+
+// Variable names used for disagreement flags
+const (
+	VarTemperature   = "temperature"
+	VarHumidity      = "humidity"
+	VarWindSpeed     = "windSpeed"
+	VarWindDeg       = "windDeg"
+	VarPressure      = "pressure"
+	VarPrecipitation = "precipitation"
+)
+
+// Thresholds configures how readings are weighted and when providers are considered in disagreement
+type Thresholds struct {
+	Temperature     float64       // maximum spread in °C
+	Humidity        float64       // maximum spread in percentage points
+	WindSpeed       float64       // maximum spread in m/s
+	WindDeg         float64       // maximum angular spread in degrees
+	Pressure        float64       // maximum spread in hPa
+	RainThreshold   float64       // precipitation in mm at or above which a reading counts as rain
+	RecencyHalfLife time.Duration // age at which a reading counts half as much as a fresh one
+}
+
+// DefaultThresholds returns the thresholds used when none are configured
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Temperature:     3.0,
+		Humidity:        20.0,
+		WindSpeed:       5.0,
+		WindDeg:         90.0,
+		Pressure:        5.0,
+		RainThreshold:   0.1,
+		RecencyHalfLife: 30 * time.Minute,
+	}
+}
+
+// reading is a single provider value with its recency weight
+type reading struct {
+	provider string
+	value    float64
+	weight   float64
+}
+
+// Merge combines per-provider readings for one location into a best estimate.
+// Numeric variables use a recency-weighted median, wind direction a weighted circular mean.
+func Merge(location string, data []models.WeatherData, th Thresholds, now time.Time) models.WeatherEstimate {
+	estimate := models.WeatherEstimate{
+		Method: "recency-weighted median",
+		Flags:  make(map[string]bool),
+	}
+	if len(data) == 0 {
+		return estimate
+	}
+
+	weights := make([]float64, len(data))
+	best := 0
+	latest := data[0].Timestamp
+	latestObserved := data[0].ObservationTime()
+	for i, d := range data {
+		weights[i] = recencyWeight(now.Sub(d.ObservationTime()), th.RecencyHalfLife)
+		if weights[i] > weights[best] {
+			best = i
+		}
+		if d.Timestamp.After(latest) {
+			latest = d.Timestamp
+		}
+		if d.ObservationTime().After(latestObserved) {
+			latestObserved = d.ObservationTime()
+		}
+		estimate.Providers = append(estimate.Providers, d.Provider)
+	}
+	sort.Strings(estimate.Providers)
+
+	collect := func(value func(models.WeatherData) float64) []reading {
+		readings := make([]reading, len(data))
+		for i, d := range data {
+			readings[i] = reading{provider: d.Provider, value: value(d), weight: weights[i]}
+		}
+		return readings
+	}
+
+	temperature := collect(func(d models.WeatherData) float64 { return d.Temperature })
+	humidity := collect(func(d models.WeatherData) float64 { return d.Humidity })
+	windSpeed := collect(func(d models.WeatherData) float64 { return d.WindSpeed })
+	windDeg := collect(func(d models.WeatherData) float64 { return float64(d.WindDeg) })
+	pressure := collect(func(d models.WeatherData) float64 { return d.Pressure })
+	precipitation := collect(func(d models.WeatherData) float64 { return d.Precipitation })
+
+	// Description and icon can't be averaged, so take them from the freshest reading
+	estimate.Data = models.WeatherData{
+		Provider:      "Consensus",
+		Location:      location,
+		Temperature:   weightedMedian(temperature),
+		Humidity:      weightedMedian(humidity),
+		WindSpeed:     weightedMedian(windSpeed),
+		WindDeg:       int(math.Round(circularMean(windDeg))),
+		Pressure:      weightedMedian(pressure),
+		Precipitation: weightedMedian(precipitation),
+		Description:   data[best].Description,
+		Icon:          data[best].Icon,
+		Timestamp:     latest,
+		ObservedAt:    latestObserved,
+		FetchedAt:     latest,
+		Sunrise:       data[best].Sunrise,
+		Sunset:        data[best].Sunset,
+	}
+
+	disagreements := []*models.Disagreement{
+		checkSpread(VarTemperature, temperature, th.Temperature, "°C"),
+		checkSpread(VarHumidity, humidity, th.Humidity, "%"),
+		checkSpread(VarWindSpeed, windSpeed, th.WindSpeed, "m/s"),
+		checkSpread(VarPressure, pressure, th.Pressure, "hPa"),
+		checkWindDirection(windDeg, windSpeed, th.WindDeg),
+		checkRain(precipitation, th.RainThreshold),
+	}
+	for _, variable := range []string{VarTemperature, VarHumidity, VarWindSpeed, VarPressure, VarWindDeg, VarPrecipitation} {
+		estimate.Flags[variable] = false
+	}
+	for _, d := range disagreements {
+		if d != nil {
+			estimate.Flags[d.Variable] = true
+			estimate.Disagreements = append(estimate.Disagreements, *d)
+		}
+	}
+
+	return estimate
+}
+
+// checkSpread flags a variable whose readings spread further than the threshold
+func checkSpread(variable string, readings []reading, threshold float64, unit string) *models.Disagreement {
+	if len(readings) < 2 || threshold <= 0 {
+		return nil
+	}
+
+	low, high := readings[0].value, readings[0].value
+	for _, r := range readings[1:] {
+		low = math.Min(low, r.value)
+		high = math.Max(high, r.value)
+	}
+
+	spread := high - low
+	if spread <= threshold {
+		return nil
+	}
+
+	return &models.Disagreement{
+		Variable:  variable,
+		Spread:    spread,
+		Threshold: threshold,
+		Values:    valuesByProvider(readings),
+		Reason:    fmt.Sprintf("providers differ by %.1f%s (threshold %.1f%s)", spread, unit, threshold, unit),
+	}
+}
+
+// checkWindDirection flags wind directions that differ by more than the threshold.
+// Calm readings are ignored because their direction is meaningless.
+func checkWindDirection(directions, speeds []reading, threshold float64) *models.Disagreement {
+	if threshold <= 0 {
+		return nil
+	}
+
+	var moving []reading
+	for i, r := range directions {
+		if speeds[i].value >= 1.0 {
+			moving = append(moving, r)
+		}
+	}
+	if len(moving) < 2 {
+		return nil
+	}
+
+	spread := 0.0
+	for i := range moving {
+		for j := i + 1; j < len(moving); j++ {
+			spread = math.Max(spread, angularDistance(moving[i].value, moving[j].value))
+		}
+	}
+	if spread <= threshold {
+		return nil
+	}
+
+	return &models.Disagreement{
+		Variable:  VarWindDeg,
+		Spread:    spread,
+		Threshold: threshold,
+		Values:    valuesByProvider(moving),
+		Reason:    fmt.Sprintf("wind directions differ by %.0f° (threshold %.0f°)", spread, threshold),
+	}
+}
+
+// checkRain flags a split where some providers report rain and others don't
+func checkRain(readings []reading, rainThreshold float64) *models.Disagreement {
+	if len(readings) < 2 {
+		return nil
+	}
+
+	wet, dry := 0, 0
+	low, high := readings[0].value, readings[0].value
+	for _, r := range readings {
+		if r.value >= rainThreshold {
+			wet++
+		} else {
+			dry++
+		}
+		low = math.Min(low, r.value)
+		high = math.Max(high, r.value)
+	}
+	if wet == 0 || dry == 0 {
+		return nil
+	}
+
+	return &models.Disagreement{
+		Variable:  VarPrecipitation,
+		Spread:    high - low,
+		Threshold: rainThreshold,
+		Values:    valuesByProvider(readings),
+		Reason:    fmt.Sprintf("rain/no-rain split: %d provider(s) report rain, %d report none", wet, dry),
+	}
+}
+
+// valuesByProvider maps each reading to its provider name
+func valuesByProvider(readings []reading) map[string]float64 {
+	values := make(map[string]float64, len(readings))
+	for _, r := range readings {
+		values[r.provider] = r.value
+	}
+	return values
+}
+
+// recencyWeight halves a reading's weight for every half-life of age
+func recencyWeight(age, halfLife time.Duration) float64 {
+	if age < 0 || halfLife <= 0 {
+		return 1.0
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// weightedMedian returns the value at which half of the total weight lies on either side
+func weightedMedian(readings []reading) float64 {
+	sorted := make([]reading, len(readings))
+	copy(sorted, readings)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+
+	total := 0.0
+	for _, r := range sorted {
+		total += r.weight
+	}
+	if total == 0 {
+		return sorted[len(sorted)/2].value
+	}
+
+	cumulative := 0.0
+	for i, r := range sorted {
+		cumulative += r.weight
+		if cumulative > total/2 {
+			return r.value
+		}
+		// Exactly half the weight on each side: average the two middle values
+		if cumulative == total/2 && i+1 < len(sorted) {
+			return (r.value + sorted[i+1].value) / 2
+		}
+	}
+	return sorted[len(sorted)-1].value
+}
+
+// circularMean averages angles in degrees, weighting each reading
+func circularMean(readings []reading) float64 {
+	var x, y float64
+	for _, r := range readings {
+		rad := r.value * math.Pi / 180
+		x += r.weight * math.Cos(rad)
+		y += r.weight * math.Sin(rad)
+	}
+	deg := math.Atan2(y, x) * 180 / math.Pi
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+// angularDistance returns the smallest difference between two angles in degrees
+func angularDistance(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
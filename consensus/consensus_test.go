@@ -0,0 +1,143 @@
+package consensus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+var now = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// observation returns a provider's reading observed age before now
+func observation(provider string, age time.Duration, temperature float64) models.WeatherData {
+	return models.WeatherData{
+		Provider:    provider,
+		Location:    provider + " name for the place",
+		Temperature: temperature,
+		Humidity:    60,
+		WindSpeed:   3,
+		WindDeg:     200,
+		Pressure:    1013,
+		Timestamp:   now.Add(-age),
+		Description: provider + " sky",
+	}
+}
+
+func TestMergeWeightedMedian(t *testing.T) {
+	data := []models.WeatherData{
+		observation("A", 0, 10),
+		observation("B", 0, 11),
+		observation("C", 0, 30),
+	}
+	estimate := Merge("london,uk", data, DefaultThresholds(), now)
+
+	if estimate.Data.Temperature != 11 {
+		t.Errorf("temperature = %v, want the median 11", estimate.Data.Temperature)
+	}
+	if estimate.Data.Location != "london,uk" || estimate.Data.Provider != "Consensus" {
+		t.Errorf("merged reading is for %q from %q", estimate.Data.Location, estimate.Data.Provider)
+	}
+	if len(estimate.Providers) != 3 || estimate.Providers[0] != "A" {
+		t.Errorf("providers = %v", estimate.Providers)
+	}
+}
+
+func TestMergeFavoursRecentReadings(t *testing.T) {
+	th := DefaultThresholds()
+	data := []models.WeatherData{
+		observation("Stale", 2*th.RecencyHalfLife, 5),
+		observation("Fresh", 0, 9),
+	}
+	estimate := Merge("london,uk", data, th, now)
+
+	if estimate.Data.Temperature != 9 {
+		t.Errorf("temperature = %v, want the fresh reading 9", estimate.Data.Temperature)
+	}
+	if estimate.Data.Description != "Fresh sky" || !estimate.Data.Timestamp.Equal(now) {
+		t.Errorf("description %q at %s, want the freshest reading's", estimate.Data.Description, estimate.Data.Timestamp)
+	}
+}
+
+func TestWeightedMedianEvenSplit(t *testing.T) {
+	readings := []reading{{value: 10, weight: 1}, {value: 12, weight: 1}}
+	if got := weightedMedian(readings); got != 11 {
+		t.Errorf("weightedMedian = %v, want 11", got)
+	}
+	zero := []reading{{value: 1}, {value: 2}, {value: 3}}
+	if got := weightedMedian(zero); got != 2 {
+		t.Errorf("weightedMedian without weight = %v, want 2", got)
+	}
+}
+
+func TestCircularMean(t *testing.T) {
+	tests := []struct {
+		angles []float64
+		want   float64
+	}{
+		{[]float64{350, 10}, 0},
+		{[]float64{80, 100}, 90},
+		{[]float64{270}, 270},
+	}
+	for _, tt := range tests {
+		var readings []reading
+		for _, a := range tt.angles {
+			readings = append(readings, reading{value: a, weight: 1})
+		}
+		got := math.Mod(circularMean(readings)+360, 360)
+		if math.Abs(got-tt.want) > 1e-9 && math.Abs(got-tt.want-360) > 1e-9 {
+			t.Errorf("circularMean(%v) = %v, want %v", tt.angles, got, tt.want)
+		}
+	}
+	if d := angularDistance(350, 10); d != 20 {
+		t.Errorf("angularDistance(350, 10) = %v, want 20", d)
+	}
+}
+
+func TestMergeFlagsDisagreements(t *testing.T) {
+	a := observation("A", 0, 10)
+	b := observation("B", 0, 14) // 4°C apart, over the 3°C threshold
+	b.WindDeg = 20               // opposite direction at speed
+	b.Precipitation = 1.2        // rain where A reports none
+	estimate := Merge("london,uk", []models.WeatherData{a, b}, DefaultThresholds(), now)
+
+	want := map[string]bool{
+		VarTemperature:   true,
+		VarHumidity:      false,
+		VarWindSpeed:     false,
+		VarPressure:      false,
+		VarWindDeg:       true,
+		VarPrecipitation: true,
+	}
+	for variable, flagged := range want {
+		if estimate.Flags[variable] != flagged {
+			t.Errorf("flag %s = %v, want %v", variable, estimate.Flags[variable], flagged)
+		}
+	}
+	if len(estimate.Disagreements) != 3 {
+		t.Fatalf("got %d disagreements, want 3", len(estimate.Disagreements))
+	}
+	temperature := estimate.Disagreements[0]
+	if temperature.Variable != VarTemperature || temperature.Spread != 4 || temperature.Values["B"] != 14 {
+		t.Errorf("temperature disagreement = %+v", temperature)
+	}
+}
+
+func TestCalmWindDirectionIsIgnored(t *testing.T) {
+	a := observation("A", 0, 10)
+	b := observation("B", 0, 10)
+	a.WindSpeed, b.WindSpeed = 0.5, 0.5
+	b.WindDeg = 20
+	estimate := Merge("london,uk", []models.WeatherData{a, b}, DefaultThresholds(), now)
+	if estimate.Flags[VarWindDeg] {
+		t.Error("calm winds should not be flagged for direction")
+	}
+}
+
+func TestMergeWithoutData(t *testing.T) {
+	estimate := Merge("london,uk", nil, DefaultThresholds(), now)
+	if len(estimate.Providers) != 0 || len(estimate.Disagreements) != 0 {
+		t.Errorf("estimate = %+v, want empty", estimate)
+	}
+}
@@ -0,0 +1,52 @@
+package consensus
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// Monitor logs disagreement events when a variable starts or stops being flagged for a location
+type Monitor struct {
+	thresholds Thresholds
+	flagged    map[string]map[string]bool // key is location, then variable
+	mutex      sync.Mutex
+}
+
+// NewMonitor creates a new disagreement monitor
+func NewMonitor(thresholds Thresholds) *Monitor {
+	return &Monitor{
+		thresholds: thresholds,
+		flagged:    make(map[string]map[string]bool),
+	}
+}
+
+// Check merges the readings for a location and logs any change in flagged disagreements
+func (m *Monitor) Check(location string, data []models.WeatherData) models.WeatherEstimate {
+	estimate := Merge(location, data, m.thresholds, time.Now())
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	previous := m.flagged[location]
+	current := make(map[string]bool)
+
+	for _, d := range estimate.Disagreements {
+		current[d.Variable] = true
+		if !previous[d.Variable] {
+			log.Printf("EVENT provider_disagreement location=%q variable=%s spread=%.2f threshold=%.2f values=%v: %s",
+				location, d.Variable, d.Spread, d.Threshold, d.Values, d.Reason)
+		}
+	}
+
+	for variable := range previous {
+		if !current[variable] {
+			log.Printf("EVENT provider_disagreement_resolved location=%q variable=%s", location, variable)
+		}
+	}
+
+	m.flagged[location] = current
+	return estimate
+}
@@ -0,0 +1,71 @@
+package datasource
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrorKind classifies why a provider request failed
+type ErrorKind string
+
+// Kinds of provider errors
+const (
+	ErrorNotFound    ErrorKind = "not_found"    // the provider doesn't know the location
+	ErrorAuth        ErrorKind = "auth"         // the API key is missing, invalid or disabled
+	ErrorRateLimited ErrorKind = "rate_limited" // the provider rejected the request for exceeding its quota
+	ErrorUpstream    ErrorKind = "upstream"     // the provider failed or returned an unexpected response
+)
+
+// ProviderError is an error returned by a provider, classified by kind
+type ProviderError struct {
+	Provider   string
+	Kind       ErrorKind
+	StatusCode int // HTTP status, zero if the error wasn't an HTTP response
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+	}
+	return e.Message
+}
+
+// ErrorKindOf returns the kind of a provider error, or an empty kind if err isn't one
+func ErrorKindOf(err error) ErrorKind {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.Kind
+	}
+	return ""
+}
+
+// newHTTPError classifies an error response by its status code
+func newHTTPError(provider string, statusCode int, body []byte) *ProviderError {
+	kind := ErrorUpstream
+	switch statusCode {
+	case http.StatusNotFound:
+		kind = ErrorNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = ErrorAuth
+	case http.StatusTooManyRequests:
+		kind = ErrorRateLimited
+	}
+
+	return &ProviderError{
+		Provider:   provider,
+		Kind:       kind,
+		StatusCode: statusCode,
+		Message:    string(body),
+	}
+}
+
+// newNotFoundError reports a location a provider's geocoder couldn't resolve
+func newNotFoundError(provider, location string) *ProviderError {
+	return &ProviderError{
+		Provider: provider,
+		Kind:     ErrorNotFound,
+		Message:  fmt.Sprintf("location not found: %s", location),
+	}
+}
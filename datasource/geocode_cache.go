@@ -0,0 +1,102 @@
+package datasource
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// Bounds of the coordinates each provider remembers for geocoded locations
+const (
+	geocodeMaxEntries  = 1000
+	geocodeNotFoundTTL = 10 * time.Minute
+)
+
+// geocodeCache remembers geocoded coordinates, and for a while the locations the geocoder couldn't
+// find, for a bounded number of location queries. The least recently used entries are evicted first.
+type geocodeCache struct {
+	maxEntries  int
+	notFoundTTL time.Duration
+
+	entries map[string]*list.Element
+	order   *list.List // front is the most recently used
+	mutex   sync.Mutex
+}
+
+// geocodeEntry is a resolved location, or a failed lookup until it expires
+type geocodeEntry struct {
+	key     string
+	coords  [2]float64
+	err     error
+	expires time.Time // set for failed lookups only
+}
+
+// newGeocodeCache creates a cache holding at most maxEntries locations
+func newGeocodeCache(maxEntries int, notFoundTTL time.Duration) *geocodeCache {
+	return &geocodeCache{
+		maxEntries:  maxEntries,
+		notFoundTTL: notFoundTTL,
+		entries:     make(map[string]*list.Element),
+		order:       list.New(),
+	}
+}
+
+// get returns the cached coordinates of a location, or the error of a recent failed lookup;
+// found is false if the location has to be geocoded
+func (c *geocodeCache) get(location string) (coords [2]float64, found bool, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, exists := c.entries[models.NormalizeLocation(location)]
+	if !exists {
+		return [2]float64{}, false, nil
+	}
+	entry := element.Value.(*geocodeEntry)
+	if entry.err != nil && time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, entry.key)
+		return [2]float64{}, false, nil
+	}
+	c.order.MoveToFront(element)
+	return entry.coords, true, entry.err
+}
+
+// add remembers the coordinates of a location
+func (c *geocodeCache) add(location string, coords [2]float64) {
+	c.set(&geocodeEntry{key: models.NormalizeLocation(location), coords: coords})
+}
+
+// fail remembers a location the geocoder couldn't find; other errors, such as provider outages, aren't cached
+func (c *geocodeCache) fail(location string, err error) {
+	if ErrorKindOf(err) != ErrorNotFound {
+		return
+	}
+	c.set(&geocodeEntry{key: models.NormalizeLocation(location), err: err, expires: time.Now().Add(c.notFoundTTL)})
+}
+
+// set stores an entry, evicting the least recently used ones beyond the bound
+func (c *geocodeCache) set(entry *geocodeEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, exists := c.entries[entry.key]; exists {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*geocodeEntry).key)
+	}
+}
+
+// len returns the number of cached locations
+func (c *geocodeCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,88 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGeocodeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newGeocodeCache(2, time.Minute)
+	c.add("London,UK", [2]float64{51.5, -0.1})
+	c.add("Paris,FR", [2]float64{48.9, 2.4})
+
+	// Using London makes Paris the least recently used
+	if coords, found, err := c.get("  london, uk"); !found || err != nil || coords[0] != 51.5 {
+		t.Fatalf("get London = %v, %v, %v", coords, found, err)
+	}
+	c.add("Berlin,DE", [2]float64{52.5, 13.4})
+
+	if c.len() != 2 {
+		t.Errorf("len = %d, want 2", c.len())
+	}
+	if _, found, _ := c.get("Paris,FR"); found {
+		t.Error("Paris should have been evicted")
+	}
+	for _, location := range []string{"London,UK", "Berlin,DE"} {
+		if _, found, _ := c.get(location); !found {
+			t.Errorf("%s should still be cached", location)
+		}
+	}
+}
+
+func TestGeocodeCacheRemembersNotFoundUntilExpiry(t *testing.T) {
+	c := newGeocodeCache(10, time.Minute)
+	c.fail("Atlantis", newNotFoundError("Test", "Atlantis"))
+	c.fail("London,UK", errors.New("connection refused"))
+
+	_, found, err := c.get("Atlantis")
+	if !found || ErrorKindOf(err) != ErrorNotFound {
+		t.Fatalf("get Atlantis = %v, %v; want a cached not found error", found, err)
+	}
+	if _, found, _ := c.get("London,UK"); found {
+		t.Error("errors other than not found should not be cached")
+	}
+
+	// An expired failure is forgotten so the location is looked up again
+	c.entries["atlantis"].Value.(*geocodeEntry).expires = time.Now().Add(-time.Second)
+	if _, found, _ := c.get("Atlantis"); found {
+		t.Error("expired failure should not be found")
+	}
+	if c.len() != 0 {
+		t.Errorf("len = %d, want 0", c.len())
+	}
+}
+
+func TestOpenWeatherMapGeocodeIsCached(t *testing.T) {
+	var lookups int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if r.URL.Query().Get("q") == "Atlantis" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprint(w, `[{"lat": 51.5, "lon": -0.1}]`)
+	}))
+	defer server.Close()
+
+	provider := NewOpenWeatherMapProvider("key")
+	provider.geoURL = server.URL
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := provider.geocode(ctx, "London,UK"); err != nil {
+			t.Fatalf("geocode London: %v", err)
+		}
+		if _, _, err := provider.geocode(ctx, "Atlantis"); ErrorKindOf(err) != ErrorNotFound {
+			t.Fatalf("geocode Atlantis = %v, want not found", err)
+		}
+	}
+	if got := atomic.LoadInt32(&lookups); got != 2 {
+		t.Errorf("geocoder called %d times, want once per location", got)
+	}
+}
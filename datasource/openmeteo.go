@@ -0,0 +1,263 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"weather-service/models"
+)
+
+// OpenMeteoProvider implements HistorySource using the Open-Meteo historical archive
+type OpenMeteoProvider struct {
+	archiveURL string
+	geoURL     string
+	httpClient *http.Client
+
+	// Coordinates resolved through the geocoding API, and locations it couldn't find, by location query
+	coordinates *geocodeCache
+}
+
+// NewOpenMeteoProvider creates a new Open-Meteo provider; the service needs no API key
+func NewOpenMeteoProvider() *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		archiveURL: "https://archive-api.open-meteo.com/v1/archive",
+		geoURL:     "https://geocoding-api.open-meteo.com/v1/search",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+		coordinates: newGeocodeCache(geocodeMaxEntries, geocodeNotFoundTTL),
+	}
+}
+
+// Name returns the provider name
+func (p *OpenMeteoProvider) Name() string {
+	return "OpenMeteo"
+}
+
+// FetchHistory fetches hourly observations for the whole date range in a single archive request
+func (p *OpenMeteoProvider) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	from, to = historyDay(from), historyDay(to)
+	if to.Before(from) {
+		return models.HistoryData{}, fmt.Errorf("history range ends before it starts")
+	}
+
+	lat, lon, err := p.geocode(ctx, location)
+	if err != nil {
+		return models.HistoryData{}, err
+	}
+
+	// Build URL
+	params := url.Values{}
+	params.Add("latitude", fmt.Sprintf("%.4f", lat))
+	params.Add("longitude", fmt.Sprintf("%.4f", lon))
+	params.Add("start_date", from.Format("2006-01-02"))
+	params.Add("end_date", to.Format("2006-01-02"))
+	params.Add("hourly", "temperature_2m,relative_humidity_2m,precipitation,pressure_msl,wind_speed_10m,wind_direction_10m,weather_code")
+	params.Add("wind_speed_unit", "ms")
+	params.Add("timezone", "UTC")
+
+	body, err := p.get(ctx, p.archiveURL+"?"+params.Encode())
+	if err != nil {
+		return models.HistoryData{}, err
+	}
+
+	// The archive returns parallel arrays; values are null where no reanalysis data exists yet
+	var response struct {
+		Hourly struct {
+			Time          []string   `json:"time"`
+			Temperature   []*float64 `json:"temperature_2m"`
+			Humidity      []*float64 `json:"relative_humidity_2m"`
+			Precipitation []*float64 `json:"precipitation"`
+			Pressure      []*float64 `json:"pressure_msl"`
+			WindSpeed     []*float64 `json:"wind_speed_10m"`
+			WindDirection []*float64 `json:"wind_direction_10m"`
+			WeatherCode   []*int     `json:"weather_code"`
+		} `json:"hourly"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return models.HistoryData{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	history := models.HistoryData{
+		Provider: p.Name(),
+		Location: location,
+		From:     from,
+		To:       to,
+		Hours:    []models.WeatherData{},
+	}
+
+	hourly := response.Hourly
+	for i, stamp := range hourly.Time {
+		timestamp, err := time.Parse("2006-01-02T15:04", stamp)
+		if err != nil {
+			continue
+		}
+		// Skip hours the archive hasn't filled in yet
+		if valueAt(hourly.Temperature, i) == nil {
+			continue
+		}
+
+		code := -1
+		if i < len(hourly.WeatherCode) && hourly.WeatherCode[i] != nil {
+			code = *hourly.WeatherCode[i]
+		}
+
+		history.Hours = append(history.Hours, models.WeatherData{
+			Provider:      p.Name(),
+			Location:      location,
+			Latitude:      lat,
+			Longitude:     lon,
+			Temperature:   floatAt(hourly.Temperature, i),
+			Humidity:      floatAt(hourly.Humidity, i),
+			WindSpeed:     floatAt(hourly.WindSpeed, i),
+			WindDeg:       int(floatAt(hourly.WindDirection, i)),
+			Pressure:      floatAt(hourly.Pressure, i),
+			Precipitation: floatAt(hourly.Precipitation, i),
+			Description:   weatherCodeDescription(code),
+			Timestamp:     timestamp,
+			ObservedAt:    timestamp,
+		})
+	}
+
+	return history, nil
+}
+
+// geocode resolves a location query such as "London,UK" to coordinates, caching the result
+func (p *OpenMeteoProvider) geocode(ctx context.Context, location string) (float64, float64, error) {
+	if coords, found, err := p.coordinates.get(location); found {
+		return coords[0], coords[1], err
+	}
+
+	// The geocoder only matches place names, so search by name and use the country to pick a result
+	parts := strings.SplitN(location, ",", 2)
+	name := strings.TrimSpace(parts[0])
+	country := ""
+	if len(parts) > 1 {
+		country = strings.TrimSpace(parts[1])
+	}
+
+	params := url.Values{}
+	params.Add("name", name)
+	params.Add("count", "10")
+	params.Add("format", "json")
+
+	body, err := p.get(ctx, p.geoURL+"?"+params.Encode())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var response struct {
+		Results []struct {
+			Latitude    float64 `json:"latitude"`
+			Longitude   float64 `json:"longitude"`
+			Country     string  `json:"country"`
+			CountryCode string  `json:"country_code"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse geocoding response: %w", err)
+	}
+	if len(response.Results) == 0 {
+		err := newNotFoundError(p.Name(), location)
+		p.coordinates.fail(location, err)
+		return 0, 0, err
+	}
+
+	// Results are ordered by population; prefer the first one in the requested country
+	best := response.Results[0]
+	if country != "" {
+		for _, result := range response.Results {
+			if strings.EqualFold(result.CountryCode, country) || strings.EqualFold(result.Country, country) ||
+				(strings.EqualFold(country, "UK") && strings.EqualFold(result.CountryCode, "GB")) {
+				best = result
+				break
+			}
+		}
+	}
+
+	p.coordinates.add(location, [2]float64{best.Latitude, best.Longitude})
+
+	return best.Latitude, best.Longitude, nil
+}
+
+// get performs a GET request and returns the body of a successful response
+func (p *OpenMeteoProvider) get(ctx context.Context, requestURL string) ([]byte, error) {
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp.StatusCode, body)
+	}
+
+	return body, nil
+}
+
+// valueAt returns the i-th value of a nullable series, or nil if it is missing
+func valueAt(values []*float64, i int) *float64 {
+	if i >= len(values) {
+		return nil
+	}
+	return values[i]
+}
+
+// floatAt returns the i-th value of a nullable series, or zero if it is missing
+func floatAt(values []*float64, i int) float64 {
+	if v := valueAt(values, i); v != nil {
+		return *v
+	}
+	return 0
+}
+
+// weatherCodeDescription describes a WMO weather interpretation code
+func weatherCodeDescription(code int) string {
+	switch {
+	case code == 0:
+		return "Clear sky"
+	case code == 1:
+		return "Mainly clear"
+	case code == 2:
+		return "Partly cloudy"
+	case code == 3:
+		return "Overcast"
+	case code == 45 || code == 48:
+		return "Fog"
+	case code >= 51 && code <= 57:
+		return "Drizzle"
+	case code >= 61 && code <= 67:
+		return "Rain"
+	case code >= 71 && code <= 77:
+		return "Snow"
+	case code >= 80 && code <= 82:
+		return "Rain showers"
+	case code == 85 || code == 86:
+		return "Snow showers"
+	case code >= 95:
+		return "Thunderstorm"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,105 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// archiveServer answers geocoding searches with a London in Canada ahead of the one in the UK, and archive
+// requests for 51.5074,-0.1278 with three hours, the last not yet filled in
+func archiveServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "London" {
+			fmt.Fprint(w, `{}`)
+			return
+		}
+		fmt.Fprint(w, `{"results": [
+			{"latitude": 42.9834, "longitude": -81.2330, "country": "Canada", "country_code": "CA"},
+			{"latitude": 51.5074, "longitude": -0.1278, "country": "United Kingdom", "country_code": "GB"}
+		]}`)
+	})
+	mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("latitude") != "51.5074" || query.Get("longitude") != "-0.1278" ||
+			query.Get("start_date") != "2024-03-03" || query.Get("end_date") != "2024-03-04" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error": true, "reason": "unexpected query %s"}`, r.URL.RawQuery)
+			return
+		}
+		fmt.Fprint(w, `{"hourly": {
+			"time": ["2024-03-03T00:00", "2024-03-03T01:00", "2024-03-03T02:00"],
+			"temperature_2m": [6.1, 5.8, null],
+			"relative_humidity_2m": [88, 90, null],
+			"precipitation": [0.4, null, null],
+			"pressure_msl": [1004.2, 1004.6, null],
+			"wind_speed_10m": [4.2, 3.9, null],
+			"wind_direction_10m": [225, 230, null],
+			"weather_code": [61, null, null]
+		}}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenMeteoHistory(t *testing.T) {
+	server := archiveServer(t)
+	provider := NewOpenMeteoProvider()
+	provider.geoURL = server.URL + "/search"
+	provider.archiveURL = server.URL + "/archive"
+
+	from := time.Date(2024, 3, 3, 15, 0, 0, 0, time.UTC)
+	history, err := provider.FetchHistory(context.Background(), "London,UK", from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("FetchHistory: %v", err)
+	}
+
+	if want := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC); !history.From.Equal(want) {
+		t.Errorf("From = %v, want %v", history.From, want)
+	}
+	if len(history.Hours) != 2 {
+		t.Fatalf("got %d hours, want the 2 filled in", len(history.Hours))
+	}
+
+	first := history.Hours[0]
+	if first.Temperature != 6.1 || first.Humidity != 88 || first.Precipitation != 0.4 || first.Pressure != 1004.2 ||
+		first.WindSpeed != 4.2 || first.WindDeg != 225 || first.Latitude != 51.5074 {
+		t.Errorf("first hour = %+v", first)
+	}
+	if want := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC); !first.Timestamp.Equal(want) || !first.ObservedAt.Equal(want) {
+		t.Errorf("first hour at %v, observed %v, want %v", first.Timestamp, first.ObservedAt, want)
+	}
+	if first.Description != weatherCodeDescription(61) {
+		t.Errorf("first hour description = %q, want %q", first.Description, weatherCodeDescription(61))
+	}
+
+	// Missing values in a filled-in hour are zero rather than skipping the hour
+	if second := history.Hours[1]; second.Precipitation != 0 || second.Description != weatherCodeDescription(-1) {
+		t.Errorf("second hour = %+v", second)
+	}
+}
+
+func TestOpenMeteoHistoryErrors(t *testing.T) {
+	server := archiveServer(t)
+	provider := NewOpenMeteoProvider()
+	provider.geoURL = server.URL + "/search"
+	provider.archiveURL = server.URL + "/archive"
+	ctx := context.Background()
+	day := time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+
+	if _, err := provider.FetchHistory(ctx, "London,UK", day, day.AddDate(0, 0, -1)); err == nil {
+		t.Error("FetchHistory accepted a range ending before it starts")
+	}
+	if _, err := provider.FetchHistory(ctx, "Atlantis", day, day); ErrorKindOf(err) != ErrorNotFound {
+		t.Errorf("FetchHistory Atlantis = %v, want not found", err)
+	}
+	if _, err := provider.FetchHistory(ctx, "London,UK", day, day.AddDate(0, 0, 7)); err == nil {
+		t.Error("FetchHistory succeeded on an error response")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"time"
 
+	"weather-service/airquality"
+	"weather-service/astronomy"
 	"weather-service/models"
 )
 
@@ -16,7 +18,11 @@ import (
 type OpenWeatherMapProvider struct {
 	apiKey     string
 	baseURL    string
+	geoURL     string
 	httpClient *http.Client
+
+	// Coordinates resolved through the geocoding API, and locations it couldn't find, by location query
+	coordinates *geocodeCache
 }
 
 // NewOpenWeatherMapProvider creates a new OpenWeatherMap provider
@@ -24,9 +30,11 @@ func NewOpenWeatherMapProvider(apiKey string) *OpenWeatherMapProvider {
 	return &OpenWeatherMapProvider{
 		apiKey:  apiKey,
 		baseURL: "https://api.openweathermap.org/data/2.5",
+		geoURL:  "https://api.openweathermap.org/geo/1.0",
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		coordinates: newGeocodeCache(geocodeMaxEntries, geocodeNotFoundTTL),
 	}
 }
 
@@ -65,7 +73,7 @@ func (p *OpenWeatherMapProvider) GetWeather(ctx context.Context, location string
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return models.WeatherData{}, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return models.WeatherData{}, newHTTPError(p.Name(), resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -79,14 +87,24 @@ func (p *OpenWeatherMapProvider) GetWeather(ctx context.Context, location string
 			Speed float64 `json:"speed"`
 			Deg   int     `json:"deg"`
 		} `json:"wind"`
+		Rain struct {
+			OneHour float64 `json:"1h"`
+		} `json:"rain"`
 		Weather []struct {
 			Description string `json:"description"`
 			Icon        string `json:"icon"`
 		} `json:"weather"`
+		Coord struct {
+			Lat float64 `json:"lat"`
+			Lon float64 `json:"lon"`
+		} `json:"coord"`
 		Name string `json:"name"`
 		Sys  struct {
 			Country string `json:"country"`
+			Sunrise int64  `json:"sunrise"`
+			Sunset  int64  `json:"sunset"`
 		} `json:"sys"`
+		Dt int64 `json:"dt"` // time of the observation
 	}
 
 	if err := json.Unmarshal(body, &response); err != nil {
@@ -107,19 +125,47 @@ func (p *OpenWeatherMapProvider) GetWeather(ctx context.Context, location string
 		formattedLocation = fmt.Sprintf("%s,%s", response.Name, response.Sys.Country)
 	}
 
+	// Older stations may omit the observation time; fall back to the fetch time
+	fetchedAt := time.Now()
+	observedAt := fetchedAt
+	if response.Dt > 0 {
+		observedAt = time.Unix(response.Dt, 0)
+	}
+
 	// Create weather data
-	return models.WeatherData{
-		Provider:    p.Name(),
-		Location:    formattedLocation,
-		Temperature: response.Main.Temp,
-		Humidity:    float64(response.Main.Humidity),
-		WindSpeed:   response.Wind.Speed,
-		WindDeg:     response.Wind.Deg,
-		Pressure:    float64(response.Main.Pressure),
-		Description: description,
-		Icon:        icon,
-		Timestamp:   time.Now(),
-	}, nil
+	data := models.WeatherData{
+		Provider:      p.Name(),
+		Location:      formattedLocation,
+		Latitude:      response.Coord.Lat,
+		Longitude:     response.Coord.Lon,
+		Temperature:   response.Main.Temp,
+		Humidity:      float64(response.Main.Humidity),
+		WindSpeed:     response.Wind.Speed,
+		WindDeg:       response.Wind.Deg,
+		Pressure:      float64(response.Main.Pressure),
+		Precipitation: response.Rain.OneHour,
+		Description:   description,
+		Icon:          icon,
+		Timestamp:     fetchedAt,
+		ObservedAt:    observedAt,
+		FetchedAt:     fetchedAt,
+	}
+
+	// Use the sun times OpenWeatherMap reports, computing any it leaves out locally
+	if response.Sys.Sunrise > 0 {
+		data.Sunrise = time.Unix(response.Sys.Sunrise, 0)
+	}
+	if response.Sys.Sunset > 0 {
+		data.Sunset = time.Unix(response.Sys.Sunset, 0)
+	}
+	astronomy.FillSunTimes(&data)
+
+	return data, nil
+}
+
+// MaxForecastDays returns the length of the 5-day forecast endpoint, which always returns every step
+func (p *OpenWeatherMapProvider) MaxForecastDays() int {
+	return 5
 }
 
 // FetchForecast fetches forecast for a location for the specified number of days
@@ -152,7 +198,7 @@ func (p *OpenWeatherMapProvider) FetchForecast(ctx context.Context, location str
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return models.ForecastData{}, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return models.ForecastData{}, newHTTPError(p.Name(), resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -171,6 +217,10 @@ func (p *OpenWeatherMapProvider) FetchForecast(ctx context.Context, location str
 				Speed float64 `json:"speed"`
 				Deg   int     `json:"deg"`
 			} `json:"wind"`
+			Rain struct {
+				ThreeHours float64 `json:"3h"`
+			} `json:"rain"`
+			Pop     float64 `json:"pop"` // Probability of precipitation (0-1)
 			Weather []struct {
 				Description string `json:"description"`
 				Icon        string `json:"icon"`
@@ -214,16 +264,148 @@ func (p *OpenWeatherMapProvider) FetchForecast(ctx context.Context, location str
 		timestamp := time.Unix(item.Dt, 0)
 
 		forecast.Forecasts = append(forecast.Forecasts, models.Forecast{
-			Temperature: item.Main.Temp,
-			Humidity:    float64(item.Main.Humidity),
-			WindSpeed:   item.Wind.Speed,
-			WindDeg:     item.Wind.Deg,
-			Pressure:    float64(item.Main.Pressure),
-			Description: description,
-			Icon:        icon,
-			Timestamp:   timestamp,
+			Temperature:   item.Main.Temp,
+			Humidity:      float64(item.Main.Humidity),
+			WindSpeed:     item.Wind.Speed,
+			WindDeg:       item.Wind.Deg,
+			Pressure:      float64(item.Main.Pressure),
+			Precipitation: item.Rain.ThreeHours,
+			PrecipChance:  item.Pop * 100,
+			Description:   description,
+			Icon:          icon,
+			Timestamp:     timestamp,
 		})
 	}
 
 	return forecast, nil
 }
+
+// FetchAirQuality fetches current air quality for a location.
+// The air pollution API only accepts coordinates, so the location is geocoded first.
+func (p *OpenWeatherMapProvider) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	lat, lon, err := p.geocode(ctx, location)
+	if err != nil {
+		return models.AirQuality{}, err
+	}
+
+	// Build URL
+	endpoint := fmt.Sprintf("%s/air_pollution", p.baseURL)
+	params := url.Values{}
+	params.Add("lat", fmt.Sprintf("%.4f", lat))
+	params.Add("lon", fmt.Sprintf("%.4f", lon))
+	params.Add("appid", p.apiKey)
+
+	body, err := p.get(ctx, endpoint+"?"+params.Encode())
+	if err != nil {
+		return models.AirQuality{}, err
+	}
+
+	// Parse response
+	var response struct {
+		List []struct {
+			Dt   int64 `json:"dt"`
+			Main struct {
+				AQI int `json:"aqi"`
+			} `json:"main"`
+			Components struct {
+				CO   float64 `json:"co"`
+				NO2  float64 `json:"no2"`
+				O3   float64 `json:"o3"`
+				SO2  float64 `json:"so2"`
+				PM25 float64 `json:"pm2_5"`
+				PM10 float64 `json:"pm10"`
+			} `json:"components"`
+		} `json:"list"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return models.AirQuality{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response.List) == 0 {
+		return models.AirQuality{}, fmt.Errorf("response contains no air quality data")
+	}
+
+	item := response.List[0]
+	data := models.AirQuality{
+		Provider:           p.Name(),
+		Location:           location,
+		PM25:               item.Components.PM25,
+		PM10:               item.Components.PM10,
+		O3:                 item.Components.O3,
+		NO2:                item.Components.NO2,
+		SO2:                item.Components.SO2,
+		CO:                 item.Components.CO,
+		ProviderIndex:      item.Main.AQI,
+		ProviderIndexScale: "OpenWeatherMap 1-5",
+		Timestamp:          time.Unix(item.Dt, 0),
+		FetchedAt:          time.Now(),
+	}
+	airquality.Annotate(&data)
+
+	return data, nil
+}
+
+// geocode resolves a location name to coordinates, remembering earlier lookups
+func (p *OpenWeatherMapProvider) geocode(ctx context.Context, location string) (float64, float64, error) {
+	if coords, found, err := p.coordinates.get(location); found {
+		return coords[0], coords[1], err
+	}
+
+	// Build URL
+	endpoint := fmt.Sprintf("%s/direct", p.geoURL)
+	params := url.Values{}
+	params.Add("q", location)
+	params.Add("limit", "1")
+	params.Add("appid", p.apiKey)
+
+	body, err := p.get(ctx, endpoint+"?"+params.Encode())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var results []struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse geocoding response: %w", err)
+	}
+	if len(results) == 0 {
+		err := newNotFoundError(p.Name(), location)
+		p.coordinates.fail(location, err)
+		return 0, 0, err
+	}
+
+	p.coordinates.add(location, [2]float64{results[0].Lat, results[0].Lon})
+
+	return results[0].Lat, results[0].Lon, nil
+}
+
+// get performs a GET request and returns the body of a successful response
+func (p *OpenWeatherMapProvider) get(ctx context.Context, requestURL string) ([]byte, error) {
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(p.Name(), resp.StatusCode, body)
+	}
+
+	return body, nil
+}
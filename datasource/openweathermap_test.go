@@ -0,0 +1,45 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOpenWeatherMapSunTimes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sys := `"country": "GB", "sunrise": 1718941380, "sunset": 1719001260`
+		if r.URL.Query().Get("q") == "Greenwich,UK" {
+			sys = `"country": "GB"`
+		}
+		w.Write([]byte(`{
+			"main": {"temp": 18.5, "humidity": 60, "pressure": 1015},
+			"coord": {"lat": 51.4769, "lon": 0},
+			"name": "London", "dt": 1718982000,
+			"sys": {` + sys + `}
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenWeatherMapProvider("key")
+	provider.baseURL = server.URL
+
+	data, err := provider.GetWeather(context.Background(), "London,UK")
+	if err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	if !data.Sunrise.Equal(time.Unix(1718941380, 0)) || !data.Sunset.Equal(time.Unix(1719001260, 0)) {
+		t.Errorf("sunrise %v, sunset %v; want the provider's times", data.Sunrise, data.Sunset)
+	}
+
+	// Without them the times are computed from the coordinates
+	data, err = provider.GetWeather(context.Background(), "Greenwich,UK")
+	if err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	if data.Sunrise.IsZero() || data.Sunset.IsZero() || !data.Sunrise.Before(data.Sunset) {
+		t.Errorf("sunrise %v, sunset %v; want computed times", data.Sunrise, data.Sunset)
+	}
+}
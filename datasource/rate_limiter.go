@@ -3,6 +3,7 @@ package datasource
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"weather-service/models"
 
@@ -72,23 +73,66 @@ func (r *RateLimitedForecastSource) FetchForecast(ctx context.Context, location
 	return r.source.FetchForecast(ctx, location, days)
 }
 
+// MaxForecastDays returns how many days ahead the underlying source forecasts
+func (r *RateLimitedForecastSource) MaxForecastDays() int {
+	return MaxForecastDays(r.source)
+}
+
 // Name returns the source name
 func (r *RateLimitedForecastSource) Name() string {
 	return r.name
 }
 
+// RateLimitedHistorySource wraps a HistorySource with rate limiting
+type RateLimitedHistorySource struct {
+	source  HistorySource
+	limiter *rate.Limiter
+	name    string
+}
+
+// NewRateLimitedHistorySource creates a new rate limited history source
+// rps is the maximum requests per second allowed
+// burst is the maximum burst size allowed
+func NewRateLimitedHistorySource(source HistorySource, rps float64, burst int) *RateLimitedHistorySource {
+	return &RateLimitedHistorySource{
+		source:  source,
+		limiter: rate.NewLimiter(rate.Limit(rps), burst),
+		name:    fmt.Sprintf("%s [Rate Limited]", source.Name()),
+	}
+}
+
+// FetchHistory fetches historical data, respecting rate limits
+func (r *RateLimitedHistorySource) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	// Wait for rate limiter permission or context cancellation
+	if err := r.limiter.Wait(ctx); err != nil {
+		return models.HistoryData{}, fmt.Errorf("rate limit wait canceled: %w", err)
+	}
+
+	// Forward to the underlying source
+	return r.source.FetchHistory(ctx, location, from, to)
+}
+
+// Name returns the source name
+func (r *RateLimitedHistorySource) Name() string {
+	return r.name
+}
+
 // RateLimitedProvider combines both interfaces for providers that implement both
 type RateLimitedProvider struct {
-	provider        WeatherProvider
-	forecastSrc     ForecastSource
-	weatherLimiter  *rate.Limiter
-	forecastLimiter *rate.Limiter
-	name            string
+	provider      WeatherProvider
+	forecastSrc   ForecastSource
+	airQualitySrc AirQualitySource // nil if the provider has no air quality data
+	alertSrc      AlertSource      // nil if the provider has no alerts
+	historySrc    HistorySource    // nil if the provider has no historical data
+	marineSrc     MarineSource     // nil if the provider has no marine data
+	limiter       *rate.Limiter    // one quota shared by every capability
+	name          string
 }
 
-// NewRateLimitedProvider creates a provider that implements both interfaces with rate limiting
-// weatherRPS and forecastRPS are the maximum requests per second for weather and forecast APIs
-func NewRateLimitedProvider(provider interface{}, weatherRPS, forecastRPS float64, burst int) *RateLimitedProvider {
+// NewRateLimitedProvider creates a provider that implements both interfaces with rate limiting.
+// rps is the maximum requests per second for the provider's whole API key, so every capability
+// (weather, forecast, air quality, alerts, history, marine) draws from the same limiter.
+func NewRateLimitedProvider(provider interface{}, rps float64, burst int) *RateLimitedProvider {
 	name := "Unknown"
 
 	// Type assertions to get the name
@@ -98,18 +142,33 @@ func NewRateLimitedProvider(provider interface{}, weatherRPS, forecastRPS float6
 		name = fs.Name()
 	}
 
-	return &RateLimitedProvider{
-		provider:        provider.(WeatherProvider),
-		forecastSrc:     provider.(ForecastSource),
-		weatherLimiter:  rate.NewLimiter(rate.Limit(weatherRPS), burst),
-		forecastLimiter: rate.NewLimiter(rate.Limit(forecastRPS), burst),
-		name:            fmt.Sprintf("%s [Rate Limited]", name),
+	limited := &RateLimitedProvider{
+		provider:    provider.(WeatherProvider),
+		forecastSrc: provider.(ForecastSource),
+		limiter:     rate.NewLimiter(rate.Limit(rps), burst),
+		name:        fmt.Sprintf("%s [Rate Limited]", name),
+	}
+
+	// Optional capabilities are only forwarded when the provider supports them
+	if aq, ok := provider.(AirQualitySource); ok {
+		limited.airQualitySrc = aq
+	}
+	if as, ok := provider.(AlertSource); ok {
+		limited.alertSrc = as
 	}
+	if hs, ok := provider.(HistorySource); ok {
+		limited.historySrc = hs
+	}
+	if ms, ok := provider.(MarineSource); ok {
+		limited.marineSrc = ms
+	}
+
+	return limited
 }
 
 // GetWeather implements WeatherProvider interface with rate limiting
 func (r *RateLimitedProvider) GetWeather(ctx context.Context, location string) (models.WeatherData, error) {
-	if err := r.weatherLimiter.Wait(ctx); err != nil {
+	if err := r.limiter.Wait(ctx); err != nil {
 		return models.WeatherData{}, fmt.Errorf("rate limit wait canceled: %w", err)
 	}
 	return r.provider.GetWeather(ctx, location)
@@ -117,12 +176,81 @@ func (r *RateLimitedProvider) GetWeather(ctx context.Context, location string) (
 
 // FetchForecast implements ForecastSource interface with rate limiting
 func (r *RateLimitedProvider) FetchForecast(ctx context.Context, location string, days int) (models.ForecastData, error) {
-	if err := r.forecastLimiter.Wait(ctx); err != nil {
+	if err := r.limiter.Wait(ctx); err != nil {
 		return models.ForecastData{}, fmt.Errorf("rate limit wait canceled: %w", err)
 	}
 	return r.forecastSrc.FetchForecast(ctx, location, days)
 }
 
+// MaxForecastDays returns how many days ahead the wrapped provider forecasts
+func (r *RateLimitedProvider) MaxForecastDays() int {
+	return MaxForecastDays(r.forecastSrc)
+}
+
+// FetchAirQuality implements AirQualitySource interface with rate limiting
+func (r *RateLimitedProvider) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	if r.airQualitySrc == nil {
+		return models.AirQuality{}, fmt.Errorf("%s does not provide air quality data", r.name)
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		return models.AirQuality{}, fmt.Errorf("rate limit wait canceled: %w", err)
+	}
+	return r.airQualitySrc.FetchAirQuality(ctx, location)
+}
+
+// SupportsAirQuality reports whether the wrapped provider has air quality data
+func (r *RateLimitedProvider) SupportsAirQuality() bool {
+	return r.airQualitySrc != nil
+}
+
+// FetchAlerts implements AlertSource interface with rate limiting
+func (r *RateLimitedProvider) FetchAlerts(ctx context.Context, location string) ([]models.Alert, error) {
+	if r.alertSrc == nil {
+		return nil, fmt.Errorf("%s does not provide alerts", r.name)
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		return nil, fmt.Errorf("rate limit wait canceled: %w", err)
+	}
+	return r.alertSrc.FetchAlerts(ctx, location)
+}
+
+// SupportsAlerts reports whether the wrapped provider has alerts
+func (r *RateLimitedProvider) SupportsAlerts() bool {
+	return r.alertSrc != nil
+}
+
+// FetchHistory implements HistorySource interface with rate limiting
+func (r *RateLimitedProvider) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	if r.historySrc == nil {
+		return models.HistoryData{}, fmt.Errorf("%s does not provide historical data", r.name)
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		return models.HistoryData{}, fmt.Errorf("rate limit wait canceled: %w", err)
+	}
+	return r.historySrc.FetchHistory(ctx, location, from, to)
+}
+
+// SupportsHistory reports whether the wrapped provider has historical data
+func (r *RateLimitedProvider) SupportsHistory() bool {
+	return r.historySrc != nil
+}
+
+// FetchMarine implements MarineSource interface with rate limiting
+func (r *RateLimitedProvider) FetchMarine(ctx context.Context, location string, days int) (models.MarineData, error) {
+	if r.marineSrc == nil {
+		return models.MarineData{}, fmt.Errorf("%s does not provide marine data", r.name)
+	}
+	if err := r.limiter.Wait(ctx); err != nil {
+		return models.MarineData{}, fmt.Errorf("rate limit wait canceled: %w", err)
+	}
+	return r.marineSrc.FetchMarine(ctx, location, days)
+}
+
+// SupportsMarine reports whether the wrapped provider has marine data
+func (r *RateLimitedProvider) SupportsMarine() bool {
+	return r.marineSrc != nil
+}
+
 // Name returns the provider name
 func (r *RateLimitedProvider) Name() string {
 	return r.name
@@ -130,8 +258,13 @@ func (r *RateLimitedProvider) Name() string {
 
 // Verify that our rate limited types implement the required interfaces
 var (
-	_ WeatherProvider = (*RateLimitedWeatherProvider)(nil)
-	_ ForecastSource  = (*RateLimitedForecastSource)(nil)
-	_ WeatherProvider = (*RateLimitedProvider)(nil)
-	_ ForecastSource  = (*RateLimitedProvider)(nil)
+	_ WeatherProvider  = (*RateLimitedWeatherProvider)(nil)
+	_ ForecastSource   = (*RateLimitedForecastSource)(nil)
+	_ WeatherProvider  = (*RateLimitedProvider)(nil)
+	_ ForecastSource   = (*RateLimitedProvider)(nil)
+	_ AirQualitySource = (*RateLimitedProvider)(nil)
+	_ AlertSource      = (*RateLimitedProvider)(nil)
+	_ HistorySource    = (*RateLimitedProvider)(nil)
+	_ MarineSource     = (*RateLimitedProvider)(nil)
+	_ HistorySource    = (*RateLimitedHistorySource)(nil)
 )
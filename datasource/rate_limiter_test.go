@@ -0,0 +1,68 @@
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+// countingProvider serves weather, forecasts and air quality, counting the calls that reach it
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Name() string { return "Counting" }
+
+func (p *countingProvider) GetWeather(ctx context.Context, location string) (models.WeatherData, error) {
+	p.calls++
+	return models.WeatherData{}, nil
+}
+
+func (p *countingProvider) FetchForecast(ctx context.Context, location string, days int) (models.ForecastData, error) {
+	p.calls++
+	return models.ForecastData{}, nil
+}
+
+func (p *countingProvider) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	p.calls++
+	return models.AirQuality{}, nil
+}
+
+func TestRateLimitedProviderSharesQuota(t *testing.T) {
+	provider := &countingProvider{}
+	limited := NewRateLimitedProvider(provider, 0.01, 3)
+
+	// The burst is spent by any mix of capabilities
+	ctx := context.Background()
+	if _, err := limited.GetWeather(ctx, "London"); err != nil {
+		t.Fatalf("GetWeather: %v", err)
+	}
+	if _, err := limited.FetchForecast(ctx, "London", 3); err != nil {
+		t.Fatalf("FetchForecast: %v", err)
+	}
+	if _, err := limited.FetchAirQuality(ctx, "London"); err != nil {
+		t.Fatalf("FetchAirQuality: %v", err)
+	}
+
+	// Every capability now waits on the same empty limiter
+	calls := []struct {
+		name  string
+		fetch func(context.Context) error
+	}{
+		{"weather", func(ctx context.Context) error { _, err := limited.GetWeather(ctx, "London"); return err }},
+		{"forecast", func(ctx context.Context) error { _, err := limited.FetchForecast(ctx, "London", 3); return err }},
+		{"air quality", func(ctx context.Context) error { _, err := limited.FetchAirQuality(ctx, "London"); return err }},
+	}
+	for _, call := range calls {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		if err := call.fetch(ctx); err == nil {
+			t.Errorf("%s call went through after the shared burst was spent", call.name)
+		}
+		cancel()
+	}
+	if provider.calls != 3 {
+		t.Errorf("provider got %d calls, want 3", provider.calls)
+	}
+}
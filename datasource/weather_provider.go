@@ -3,7 +3,10 @@ package datasource
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"weather-service/models"
 )
@@ -26,6 +29,56 @@ type ForecastSource interface {
 	Name() string
 }
 
+// ForecastHorizon is implemented by forecast sources that know how many days ahead they forecast
+type ForecastHorizon interface {
+	// MaxForecastDays returns the most days worth requesting in one call
+	MaxForecastDays() int
+}
+
+// MaxForecastDays returns how many days ahead a source forecasts, or 0 if it doesn't say
+func MaxForecastDays(source ForecastSource) int {
+	if horizon, ok := source.(ForecastHorizon); ok {
+		return horizon.MaxForecastDays()
+	}
+	return 0
+}
+
+// HistorySource is an interface for services that can fetch past hourly observations
+type HistorySource interface {
+	// FetchHistory fetches hourly observations for every day from the day of from through the day of to (UTC)
+	FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error)
+
+	// Name returns the source's name
+	Name() string
+}
+
+// MarineSource is an interface for services that can fetch marine forecasts with tides
+type MarineSource interface {
+	// FetchMarine fetches hourly sea conditions and tide extremes for the specified number of days
+	FetchMarine(ctx context.Context, location string, days int) (models.MarineData, error)
+
+	// Name returns the source's name
+	Name() string
+}
+
+// AirQualitySource is an interface for services that can fetch current air quality
+type AirQualitySource interface {
+	// FetchAirQuality fetches current pollutant concentrations for a location
+	FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error)
+
+	// Name returns the source's name
+	Name() string
+}
+
+// AlertSource is an interface for services that can fetch severe weather alerts
+type AlertSource interface {
+	// FetchAlerts fetches the alerts currently issued for a location
+	FetchAlerts(ctx context.Context, location string) ([]models.Alert, error)
+
+	// Name returns the source's name
+	Name() string
+}
+
 // Config represents the application configuration
 type Config struct {
 	// API provider configurations
@@ -39,8 +92,174 @@ type Config struct {
 		APIKey  string `json:"apiKey"`
 	} `json:"weatherAPI"`
 
+	// Open-Meteo needs no API key; it is only used for historical data
+	OpenMeteo struct {
+		Enabled bool `json:"enabled"`
+	} `json:"openMeteo"`
+
 	// List of locations to monitor
 	Locations []string `json:"locations"`
+
+	// Thresholds for flagging provider disagreement on current conditions (zero keeps the default)
+	Consensus struct {
+		Temperature     float64 `json:"temperature"`     // in Celsius
+		Humidity        float64 `json:"humidity"`        // in percentage points
+		WindSpeed       float64 `json:"windSpeed"`       // in m/s
+		WindDeg         float64 `json:"windDeg"`         // in degrees
+		Pressure        float64 `json:"pressure"`        // in hPa
+		RainThreshold   float64 `json:"rainThreshold"`   // in mm, readings at or above count as rain
+		RecencyHalfLife string  `json:"recencyHalfLife"` // e.g. "30m"
+	} `json:"consensus"`
+
+	// Settings for verifying issued forecasts against observations (empty keeps the default)
+	Verification struct {
+		Tolerance       string `json:"tolerance"`       // max distance between forecast point and observation, e.g. "30m"
+		IssueInterval   string `json:"issueInterval"`   // min time between recorded forecast issues, e.g. "1h"
+		Window          string `json:"window"`          // how long scores are kept, e.g. "720h"
+		LeadBucketHours int    `json:"leadBucketHours"` // width of lead time buckets in hours
+	} `json:"verification"`
+
+	// When served data is reported as stale and when it expires, with overrides per provider name
+	Freshness struct {
+		FreshnessConfig
+		Providers map[string]FreshnessConfig `json:"providers"`
+	} `json:"freshness"`
+
+	// API key authentication; every endpoint except /health and /discovery requires a key when enabled
+	Auth struct {
+		Enabled  bool           `json:"enabled"`
+		KeysFile string         `json:"keysFile"` // JSON file of {"keys": [...]} in the same form as keys, e.g. "keys.json"
+		Keys     []APIKeyConfig `json:"keys"`
+
+		// JWTs from an identity provider are accepted as bearer tokens alongside API keys
+		JWT struct {
+			Enabled  bool   `json:"enabled"`
+			Issuer   string `json:"issuer"`   // required iss claim, e.g. "https://idp.example.com/"
+			Audience string `json:"audience"` // required aud claim, e.g. "weather-service"
+			JWKSURL  string `json:"jwksUrl"`  // the provider's key set, e.g. "https://idp.example.com/.well-known/jwks.json"
+			JWKSFile string `json:"jwksFile"` // or a local key set, e.g. as printed by -jwks-from-pem
+			Refresh  string `json:"refresh"`  // how often the key set is read again to pick up rotated keys, e.g. "1h"
+			Leeway   string `json:"leeway"`   // clock skew allowed for exp and nbf, e.g. "1m"
+
+			// Claim whose values name scopes, e.g. "scope" or "scp", and scopes granted by claim and value,
+			// e.g. {"groups": {"weather-ops": ["admin"]}}
+			ScopeClaim  string                         `json:"scopeClaim"`
+			ClaimScopes map[string]map[string][]string `json:"claimScopes"`
+
+			Tier string `json:"tier"` // rate limit tier of token holders; empty uses the default tier
+		} `json:"jwt"`
+	} `json:"auth"`
+
+	// Per-client request rate limits; keys are limited by their tier, requests without a valid key by address
+	RateLimit struct {
+		Enabled bool `json:"enabled"`
+		Tiers   map[string]struct {
+			Rate  float64 `json:"rate"`  // requests per second
+			Burst int     `json:"burst"` // requests allowed at once
+		} `json:"tiers"` // added to, or replacing, the anonymous, standard and premium tiers
+		DefaultTier   string `json:"defaultTier"`   // tier of keys that don't name one, e.g. "standard"
+		AnonymousTier string `json:"anonymousTier"` // tier of requests without a valid key, e.g. "anonymous"
+		IdleAfter     string `json:"idleAfter"`     // time after which an unused client's bucket is dropped, e.g. "10m"
+		TrustProxy    bool   `json:"trustProxy"`    // take client addresses from X-Forwarded-For
+	} `json:"rateLimit"`
+
+	// Where weather and forecast data is kept
+	Storage struct {
+		Backend string `json:"backend"` // "memory" (default) or "bolt"
+		Path    string `json:"path"`    // database file for on-disk backends
+	} `json:"storage"`
+
+	// Where on-demand responses are cached
+	Cache struct {
+		Backend    string `json:"backend"`    // "memory" (default, an LRU per cache) or "redis" (shared)
+		MaxEntries int    `json:"maxEntries"` // entries per in-memory cache
+		MaxBytes   int64  `json:"maxBytes"`   // bytes of keys and values per in-memory cache
+
+		// How long past expiry a cached forecast is served while it is refreshed in the background,
+		// and when the provider fails, e.g. "10m" and "2h"; empty disables
+		StaleWhileRevalidate string `json:"staleWhileRevalidate"`
+		StaleIfError         string `json:"staleIfError"`
+
+		// How long provider errors are cached by kind (not_found, auth, rate_limited, upstream), e.g. "10m";
+		// "0" stops caching a kind
+		NegativeTTLs       map[string]string `json:"negativeTTLs"`
+		NegativeMaxEntries int               `json:"negativeMaxEntries"`
+
+		Redis struct {
+			Addr     string `json:"addr"` // e.g. "localhost:6379"
+			Password string `json:"password"`
+			DB       int    `json:"db"`
+		} `json:"redis"`
+	} `json:"cache"`
+
+	// Unconfigured locations requested often enough are refreshed in the background like configured ones
+	Popular struct {
+		Enabled      bool    `json:"enabled"`
+		Window       string  `json:"window"`       // period requests are counted over, e.g. "1h"
+		MinRequests  int     `json:"minRequests"`  // requests within the window that promote a location
+		IdleAfter    string  `json:"idleAfter"`    // time without requests before a location is demoted, e.g. "2h"
+		RefreshAfter string  `json:"refreshAfter"` // age at which a popular location is refreshed, e.g. "20m"
+		MaxLocations int     `json:"maxLocations"` // most popular locations refreshed
+		QuotaShare   float64 `json:"quotaShare"`   // most of the background provider calls they may use, e.g. 0.5
+	} `json:"popular"`
+
+	// In-memory stores are snapshotted to a file so a restart can serve immediately; an empty path disables it
+	Snapshot struct {
+		Path     string `json:"path"`     // snapshot file, e.g. "snapshot.json"
+		Interval string `json:"interval"` // how often a snapshot is taken while running, e.g. "10m"
+	} `json:"snapshot"`
+
+	// How long each tier of stored data is kept; "0" or empty daily keeps it forever
+	Retention struct {
+		Raw       string `json:"raw"`       // individual observations, e.g. "168h"
+		Hourly    string `json:"hourly"`    // hourly rollups, e.g. "2160h"
+		Daily     string `json:"daily"`     // daily rollups
+		Forecasts string `json:"forecasts"` // forecasts not updated within this time are removed, e.g. "48h"
+		Interval  string `json:"interval"`  // how often the compactor runs, e.g. "1h"
+	} `json:"retention"`
+
+	// Climate normals built from historical data
+	Climate struct {
+		Years           int    `json:"years"`           // length of the historical period
+		RefreshInterval string `json:"refreshInterval"` // how often normals are rebuilt, e.g. "720h"
+	} `json:"climate"`
+
+	// Severe weather alert settings
+	Alerts struct {
+		// CAP 1.2 feeds to poll, each read from a URL or a local file
+		Feeds []struct {
+			Name     string `json:"name"`
+			URL      string `json:"url"`
+			File     string `json:"file"`
+			Location string `json:"location"` // optional location the feed's alerts apply to
+		} `json:"feeds"`
+	} `json:"alerts"`
+}
+
+// StalenessConfig sets the age at which data becomes stale and expires, e.g. "30m" and "3h" (empty keeps the default)
+type StalenessConfig struct {
+	Stale   string `json:"stale"`
+	Expired string `json:"expired"`
+}
+
+// FreshnessConfig sets staleness for each kind of served data
+type FreshnessConfig struct {
+	Weather    StalenessConfig `json:"weather"`
+	Forecast   StalenessConfig `json:"forecast"`
+	AirQuality StalenessConfig `json:"airQuality"`
+}
+
+// APIKeyConfig is an issued API key, given either as the plain key or its hash
+type APIKeyConfig struct {
+	Name       string   `json:"name"`
+	Key        string   `json:"key"`        // plain key, hashed when loaded; prefer hash
+	Hash       string   `json:"hash"`       // e.g. "sha256:<hex>", as printed by -hash-key
+	Scopes     []string `json:"scopes"`     // weather:read, forecast:read and admin; empty grants both read scopes
+	Locations  []string `json:"locations"`  // locations the key may query; empty allows all
+	DailyQuota int      `json:"dailyQuota"` // requests per UTC day; 0 is unlimited
+	Tier       string   `json:"tier"`       // rate limit tier; empty uses the default tier
+	ExpiresAt  string   `json:"expiresAt"`  // RFC 3339, e.g. "2025-01-01T00:00:00Z"; empty never expires
+	Revoked    bool     `json:"revoked"`
 }
 
 // LoadConfig loads configuration from a JSON file and environment variables
@@ -65,6 +284,15 @@ func LoadConfig(filename string) (*Config, error) {
 	if apiKey := os.Getenv("WEATHERAPI_KEY"); apiKey != "" {
 		config.WeatherAPI.APIKey = apiKey
 	}
+	if password := os.Getenv("REDIS_PASSWORD"); password != "" {
+		config.Cache.Redis.Password = password
+	}
+	// API_KEYS adds comma-separated plain keys, e.g. for containers without a keys file
+	for i, key := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			config.Auth.Keys = append(config.Auth.Keys, APIKeyConfig{Name: fmt.Sprintf("env-%d", i+1), Key: key})
+		}
+	}
 
 	return &config, nil
 }
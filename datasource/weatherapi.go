@@ -5,10 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"weather-service/airquality"
+	"weather-service/astronomy"
 	"weather-service/models"
 )
 
@@ -64,14 +70,16 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, location string) (m
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return models.WeatherData{}, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return models.WeatherData{}, weatherAPIError(p.Name(), resp.StatusCode, body)
 	}
 
 	// Parse response
 	var response struct {
 		Location struct {
-			Name    string `json:"name"`
-			Country string `json:"country"`
+			Name    string  `json:"name"`
+			Country string  `json:"country"`
+			Lat     float64 `json:"lat"`
+			Lon     float64 `json:"lon"`
 		} `json:"location"`
 		Current struct {
 			TempC      float64 `json:"temp_c"`
@@ -79,11 +87,13 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, location string) (m
 			WindKph    float64 `json:"wind_kph"`
 			WindDegree int     `json:"wind_degree"`
 			PressureMb float64 `json:"pressure_mb"`
+			PrecipMm   float64 `json:"precip_mm"`
 			Condition  struct {
 				Text string `json:"text"`
 				Icon string `json:"icon"`
 			} `json:"condition"`
-			LastUpdated string `json:"last_updated"`
+			LastUpdated      string `json:"last_updated"`
+			LastUpdatedEpoch int64  `json:"last_updated_epoch"`
 		} `json:"current"`
 	}
 
@@ -91,18 +101,41 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, location string) (m
 		return models.WeatherData{}, fmt.Errorf("failed to parse response: %w", err)
 	}
 
+	// WeatherAPI reports when the conditions were last updated, which can be well before the fetch
+	fetchedAt := time.Now()
+	observedAt := fetchedAt
+	if response.Current.LastUpdatedEpoch > 0 {
+		observedAt = time.Unix(response.Current.LastUpdatedEpoch, 0)
+	}
+
 	// Create weather data
-	return models.WeatherData{
-		Provider:    p.Name(),
-		Location:    fmt.Sprintf("%s,%s", response.Location.Name, response.Location.Country),
-		Temperature: response.Current.TempC,
-		Humidity:    float64(response.Current.Humidity),
-		WindSpeed:   response.Current.WindKph / 3.6, // Convert to m/s
-		Pressure:    response.Current.PressureMb,
-		Description: response.Current.Condition.Text,
-		Icon:        response.Current.Condition.Icon,
-		Timestamp:   time.Now(),
-	}, nil
+	data := models.WeatherData{
+		Provider:      p.Name(),
+		Location:      fmt.Sprintf("%s,%s", response.Location.Name, response.Location.Country),
+		Latitude:      response.Location.Lat,
+		Longitude:     response.Location.Lon,
+		Temperature:   response.Current.TempC,
+		Humidity:      float64(response.Current.Humidity),
+		WindSpeed:     response.Current.WindKph / 3.6, // Convert to m/s
+		WindDeg:       response.Current.WindDegree,
+		Pressure:      response.Current.PressureMb,
+		Precipitation: response.Current.PrecipMm,
+		Description:   response.Current.Condition.Text,
+		Icon:          response.Current.Condition.Icon,
+		Timestamp:     fetchedAt,
+		ObservedAt:    observedAt,
+		FetchedAt:     fetchedAt,
+	}
+
+	// The current weather endpoint doesn't include sun times, so compute them locally
+	astronomy.FillSunTimes(&data)
+
+	return data, nil
+}
+
+// MaxForecastDays returns the most days the free plan forecasts; longer requests are cut to this
+func (p *WeatherAPIProvider) MaxForecastDays() int {
+	return 3
 }
 
 // FetchForecast fetches forecast for a location for the specified number of days
@@ -135,7 +168,7 @@ func (p *WeatherAPIProvider) FetchForecast(ctx context.Context, location string,
 
 	// Check for error status code
 	if resp.StatusCode != http.StatusOK {
-		return models.ForecastData{}, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return models.ForecastData{}, weatherAPIError(p.Name(), resp.StatusCode, body)
 	}
 
 	// Parse response
@@ -161,13 +194,15 @@ func (p *WeatherAPIProvider) FetchForecast(ctx context.Context, location string,
 					} `json:"condition"`
 				} `json:"day"`
 				Hour []struct {
-					TimeEpoch  int64   `json:"time_epoch"`
-					TempC      float64 `json:"temp_c"`
-					Humidity   int     `json:"humidity"`
-					WindKph    float64 `json:"wind_kph"`
-					WindDegree int     `json:"wind_degree"`
-					PressureMb float64 `json:"pressure_mb"`
-					Condition  struct {
+					TimeEpoch    int64   `json:"time_epoch"`
+					TempC        float64 `json:"temp_c"`
+					Humidity     int     `json:"humidity"`
+					WindKph      float64 `json:"wind_kph"`
+					WindDegree   int     `json:"wind_degree"`
+					PressureMb   float64 `json:"pressure_mb"`
+					PrecipMm     float64 `json:"precip_mm"`
+					ChanceOfRain int     `json:"chance_of_rain"`
+					Condition    struct {
 						Text string `json:"text"`
 						Icon string `json:"icon"`
 					} `json:"condition"`
@@ -195,17 +230,487 @@ func (p *WeatherAPIProvider) FetchForecast(ctx context.Context, location string,
 			timestamp := time.Unix(hour.TimeEpoch, 0)
 
 			forecast.Forecasts = append(forecast.Forecasts, models.Forecast{
-				Temperature: hour.TempC,
-				Humidity:    float64(hour.Humidity),
-				WindSpeed:   hour.WindKph / 3.6, // Convert to m/s
-				WindDeg:     hour.WindDegree,
-				Pressure:    hour.PressureMb,
-				Description: hour.Condition.Text,
-				Icon:        hour.Condition.Icon,
-				Timestamp:   timestamp,
+				Temperature:   hour.TempC,
+				Humidity:      float64(hour.Humidity),
+				WindSpeed:     hour.WindKph / 3.6, // Convert to m/s
+				WindDeg:       hour.WindDegree,
+				Pressure:      hour.PressureMb,
+				Precipitation: hour.PrecipMm,
+				PrecipChance:  float64(hour.ChanceOfRain),
+				Description:   hour.Condition.Text,
+				Icon:          hour.Condition.Icon,
+				Timestamp:     timestamp,
 			})
 		}
 	}
 
 	return forecast, nil
 }
+
+// FetchAirQuality fetches current air quality for a location
+func (p *WeatherAPIProvider) FetchAirQuality(ctx context.Context, location string) (models.AirQuality, error) {
+	// Build URL
+	endpoint := fmt.Sprintf("%s/current.json", p.baseURL)
+	params := url.Values{}
+	params.Add("q", location)
+	params.Add("key", p.apiKey)
+	params.Add("aqi", "yes")
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return models.AirQuality{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return models.AirQuality{}, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return models.AirQuality{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return models.AirQuality{}, weatherAPIError(p.Name(), resp.StatusCode, body)
+	}
+
+	// Parse response
+	var response struct {
+		Location struct {
+			Name    string `json:"name"`
+			Country string `json:"country"`
+		} `json:"location"`
+		Current struct {
+			LastUpdatedEpoch int64 `json:"last_updated_epoch"`
+			AirQuality       *struct {
+				CO           float64 `json:"co"`
+				NO2          float64 `json:"no2"`
+				O3           float64 `json:"o3"`
+				SO2          float64 `json:"so2"`
+				PM25         float64 `json:"pm2_5"`
+				PM10         float64 `json:"pm10"`
+				USEPAIndex   int     `json:"us-epa-index"`
+				GBDefraIndex int     `json:"gb-defra-index"`
+			} `json:"air_quality"`
+		} `json:"current"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return models.AirQuality{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	aq := response.Current.AirQuality
+	if aq == nil {
+		return models.AirQuality{}, fmt.Errorf("response contains no air quality data")
+	}
+
+	data := models.AirQuality{
+		Provider:           p.Name(),
+		Location:           fmt.Sprintf("%s,%s", response.Location.Name, response.Location.Country),
+		PM25:               aq.PM25,
+		PM10:               aq.PM10,
+		O3:                 aq.O3,
+		NO2:                aq.NO2,
+		SO2:                aq.SO2,
+		CO:                 aq.CO,
+		ProviderIndex:      aq.USEPAIndex,
+		ProviderIndexScale: "US EPA band 1-6",
+		Timestamp:          time.Unix(response.Current.LastUpdatedEpoch, 0),
+		FetchedAt:          time.Now(),
+	}
+	airquality.Annotate(&data)
+
+	return data, nil
+}
+
+// weatherAPIAlert is an alert as returned by WeatherAPI with alerts=yes
+type weatherAPIAlert struct {
+	Headline    string `json:"headline"`
+	MsgType     string `json:"msgtype"`
+	Severity    string `json:"severity"`
+	Urgency     string `json:"urgency"`
+	Areas       string `json:"areas"`
+	Certainty   string `json:"certainty"`
+	Event       string `json:"event"`
+	Effective   string `json:"effective"`
+	Expires     string `json:"expires"`
+	Desc        string `json:"desc"`
+	Instruction string `json:"instruction"`
+}
+
+// FetchAlerts fetches the severe weather alerts currently issued for a location
+func (p *WeatherAPIProvider) FetchAlerts(ctx context.Context, location string) ([]models.Alert, error) {
+	// Alerts are only returned by the forecast endpoint, one day is enough
+	endpoint := fmt.Sprintf("%s/forecast.json", p.baseURL)
+	params := url.Values{}
+	params.Add("q", location)
+	params.Add("key", p.apiKey)
+	params.Add("days", "1")
+	params.Add("aqi", "no")
+	params.Add("alerts", "yes")
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return nil, weatherAPIError(p.Name(), resp.StatusCode, body)
+	}
+
+	// Parse response
+	var response struct {
+		Alerts struct {
+			Alert []weatherAPIAlert `json:"alert"`
+		} `json:"alerts"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	alerts := make([]models.Alert, 0, len(response.Alerts.Alert))
+	for _, a := range response.Alerts.Alert {
+		if strings.EqualFold(a.MsgType, "Cancel") {
+			continue
+		}
+		alerts = append(alerts, convertWeatherAPIAlert(a, p.Name(), location))
+	}
+
+	return alerts, nil
+}
+
+// convertWeatherAPIAlert converts a WeatherAPI alert to our model
+func convertWeatherAPIAlert(a weatherAPIAlert, source, location string) models.Alert {
+	effective, _ := time.Parse(time.RFC3339, a.Effective)
+	expires, _ := time.Parse(time.RFC3339, a.Expires)
+
+	return models.Alert{
+		ID:          fmt.Sprintf("%s-%s-%d", source, strings.ReplaceAll(a.Event, " ", ""), effective.Unix()),
+		Sources:     []string{source},
+		Location:    location,
+		Event:       a.Event,
+		Headline:    a.Headline,
+		Severity:    a.Severity,
+		Urgency:     a.Urgency,
+		Certainty:   a.Certainty,
+		Area:        a.Areas,
+		Effective:   effective,
+		Expires:     expires,
+		Description: a.Desc,
+		Instruction: a.Instruction,
+	}
+}
+
+// FetchHistory fetches hourly observations for each UTC day in the range. WeatherAPI's history days are the
+// location's local days, which cover a UTC day only together with their neighbours, so the local days either
+// side of the range are fetched too and the hours are kept by their UTC time. The neighbouring days are best
+// effort: they may lie in the future or past the plan's history limit, leaving the edge days incomplete.
+func (p *WeatherAPIProvider) FetchHistory(ctx context.Context, location string, from, to time.Time) (models.HistoryData, error) {
+	from, to = historyDay(from), historyDay(to)
+	if to.Before(from) {
+		return models.HistoryData{}, fmt.Errorf("history range ends before it starts")
+	}
+
+	history := models.HistoryData{
+		Provider: p.Name(),
+		Location: location,
+		From:     from,
+		To:       to,
+		Hours:    []models.WeatherData{},
+	}
+
+	end := to.AddDate(0, 0, 1)
+	seen := make(map[int64]bool)
+	for day := from.AddDate(0, 0, -1); !day.After(end); day = day.AddDate(0, 0, 1) {
+		name, hours, err := p.fetchHistoryDay(ctx, location, day)
+		if err != nil {
+			if day.Before(from) || day.After(to) {
+				log.Printf("Skipping %s history for %s on %s next to the requested range: %v",
+					p.Name(), location, day.Format("2006-01-02"), err)
+				continue
+			}
+			return models.HistoryData{}, fmt.Errorf("history for %s: %w", day.Format("2006-01-02"), err)
+		}
+		history.Location = name
+		for _, hour := range hours {
+			if hour.Timestamp.Before(from) || !hour.Timestamp.Before(end) || seen[hour.Timestamp.Unix()] {
+				continue
+			}
+			seen[hour.Timestamp.Unix()] = true
+			history.Hours = append(history.Hours, hour)
+		}
+	}
+
+	sort.Slice(history.Hours, func(i, j int) bool {
+		return history.Hours[i].Timestamp.Before(history.Hours[j].Timestamp)
+	})
+	return history, nil
+}
+
+// fetchHistoryDay fetches the hourly observations for a single day
+func (p *WeatherAPIProvider) fetchHistoryDay(ctx context.Context, location string, day time.Time) (string, []models.WeatherData, error) {
+	// Build URL
+	endpoint := fmt.Sprintf("%s/history.json", p.baseURL)
+	params := url.Values{}
+	params.Add("q", location)
+	params.Add("key", p.apiKey)
+	params.Add("dt", day.Format("2006-01-02"))
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, weatherAPIError(p.Name(), resp.StatusCode, body)
+	}
+
+	// Parse response; history uses the same layout as the forecast endpoint
+	var response struct {
+		Location struct {
+			Name    string  `json:"name"`
+			Country string  `json:"country"`
+			Lat     float64 `json:"lat"`
+			Lon     float64 `json:"lon"`
+		} `json:"location"`
+		Forecast struct {
+			ForecastDay []struct {
+				Hour []struct {
+					TimeEpoch  int64   `json:"time_epoch"`
+					TempC      float64 `json:"temp_c"`
+					Humidity   int     `json:"humidity"`
+					WindKph    float64 `json:"wind_kph"`
+					WindDegree int     `json:"wind_degree"`
+					PressureMb float64 `json:"pressure_mb"`
+					PrecipMm   float64 `json:"precip_mm"`
+					Condition  struct {
+						Text string `json:"text"`
+						Icon string `json:"icon"`
+					} `json:"condition"`
+				} `json:"hour"`
+			} `json:"forecastday"`
+		} `json:"forecast"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	name := fmt.Sprintf("%s,%s", response.Location.Name, response.Location.Country)
+	var hours []models.WeatherData
+	for _, forecastDay := range response.Forecast.ForecastDay {
+		for _, hour := range forecastDay.Hour {
+			hours = append(hours, models.WeatherData{
+				Provider:      p.Name(),
+				Location:      name,
+				Latitude:      response.Location.Lat,
+				Longitude:     response.Location.Lon,
+				Temperature:   hour.TempC,
+				Humidity:      float64(hour.Humidity),
+				WindSpeed:     hour.WindKph / 3.6, // Convert to m/s
+				WindDeg:       hour.WindDegree,
+				Pressure:      hour.PressureMb,
+				Precipitation: hour.PrecipMm,
+				Description:   hour.Condition.Text,
+				Icon:          hour.Condition.Icon,
+				Timestamp:     time.Unix(hour.TimeEpoch, 0).UTC(),
+				ObservedAt:    time.Unix(hour.TimeEpoch, 0).UTC(),
+			})
+		}
+	}
+
+	return name, hours, nil
+}
+
+// weatherAPIError classifies an error response. WeatherAPI reports an unknown location as
+// 400 with code 1006 and an exhausted quota as 403 with code 2007.
+func weatherAPIError(provider string, statusCode int, body []byte) *ProviderError {
+	providerErr := newHTTPError(provider, statusCode, body)
+
+	var response struct {
+		Error struct {
+			Code int `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return providerErr
+	}
+
+	switch response.Error.Code {
+	case 1006:
+		providerErr.Kind = ErrorNotFound
+	case 1002, 2006, 2008, 2009:
+		providerErr.Kind = ErrorAuth
+	case 2007:
+		providerErr.Kind = ErrorRateLimited
+	}
+	return providerErr
+}
+
+// historyDay truncates a time to midnight UTC of its day
+func historyDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// FetchMarine fetches the marine forecast and tides for a coastal location
+func (p *WeatherAPIProvider) FetchMarine(ctx context.Context, location string, days int) (models.MarineData, error) {
+	// Build URL
+	endpoint := fmt.Sprintf("%s/marine.json", p.baseURL)
+	params := url.Values{}
+	params.Add("q", location)
+	params.Add("key", p.apiKey)
+	params.Add("days", fmt.Sprintf("%d", days))
+	params.Add("tides", "yes")
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return models.MarineData{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Execute request
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return models.MarineData{}, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return models.MarineData{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Check for error status code
+	if resp.StatusCode != http.StatusOK {
+		return models.MarineData{}, weatherAPIError(p.Name(), resp.StatusCode, body)
+	}
+
+	// Parse response
+	var response struct {
+		Location struct {
+			Name    string  `json:"name"`
+			Country string  `json:"country"`
+			Lat     float64 `json:"lat"`
+			Lon     float64 `json:"lon"`
+			TzID    string  `json:"tz_id"`
+		} `json:"location"`
+		Forecast struct {
+			ForecastDay []struct {
+				Tides []struct {
+					Tide []struct {
+						TideTime     string `json:"tide_time"`
+						TideHeightMt string `json:"tide_height_mt"`
+						TideType     string `json:"tide_type"`
+					} `json:"tide"`
+				} `json:"tides"`
+				Hour []struct {
+					TimeEpoch       int64   `json:"time_epoch"`
+					TempC           float64 `json:"temp_c"`
+					WindKph         float64 `json:"wind_kph"`
+					WindDegree      int     `json:"wind_degree"`
+					VisKm           float64 `json:"vis_km"`
+					SigHtMt         float64 `json:"sig_ht_mt"`
+					SwellHtMt       float64 `json:"swell_ht_mt"`
+					SwellDir        float64 `json:"swell_dir"`
+					SwellDir16Point string  `json:"swell_dir_16_point"`
+					SwellPeriodSecs float64 `json:"swell_period_secs"`
+					WaterTempC      float64 `json:"water_temp_c"`
+				} `json:"hour"`
+			} `json:"forecastday"`
+		} `json:"forecast"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return models.MarineData{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Tide times are local to the location
+	zone, err := time.LoadLocation(response.Location.TzID)
+	if err != nil {
+		zone = time.UTC
+	}
+
+	marine := models.MarineData{
+		Provider:  p.Name(),
+		Location:  fmt.Sprintf("%s,%s", response.Location.Name, response.Location.Country),
+		Latitude:  response.Location.Lat,
+		Longitude: response.Location.Lon,
+		Hours:     []models.MarineConditions{},
+		Tides:     []models.TideExtreme{},
+		Updated:   time.Now(),
+	}
+
+	for _, day := range response.Forecast.ForecastDay {
+		for _, hour := range day.Hour {
+			marine.Hours = append(marine.Hours, models.MarineConditions{
+				Timestamp:        time.Unix(hour.TimeEpoch, 0),
+				WaveHeight:       hour.SigHtMt,
+				SwellHeight:      hour.SwellHtMt,
+				SwellPeriod:      hour.SwellPeriodSecs,
+				SwellDirection:   int(hour.SwellDir),
+				SwellCompass:     hour.SwellDir16Point,
+				WaterTemperature: hour.WaterTempC,
+				AirTemperature:   hour.TempC,
+				WindSpeed:        hour.WindKph / 3.6, // Convert to m/s
+				WindDeg:          hour.WindDegree,
+				Visibility:       hour.VisKm,
+			})
+		}
+
+		for _, tides := range day.Tides {
+			for _, tide := range tides.Tide {
+				tideTime, err := time.ParseInLocation("2006-01-02 15:04", tide.TideTime, zone)
+				if err != nil {
+					continue
+				}
+				height, _ := strconv.ParseFloat(tide.TideHeightMt, 64)
+				marine.Tides = append(marine.Tides, models.TideExtreme{
+					Time:   tideTime,
+					Height: height,
+					Type:   strings.ToLower(tide.TideType),
+				})
+			}
+		}
+	}
+
+	return marine, nil
+}
@@ -0,0 +1,151 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// historyServer answers history.json like WeatherAPI for a location ten hours ahead of UTC: each requested
+// date is the location's local day. Dates after lastDay are refused, as future dates are.
+func historyServer(t *testing.T, lastDay time.Time) *httptest.Server {
+	t.Helper()
+	zone := time.FixedZone("AEST", 10*60*60)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		day, err := time.ParseInLocation("2006-01-02", r.URL.Query().Get("dt"), zone)
+		if err != nil || day.After(lastDay) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 1008, "message": "date out of range"}}`))
+			return
+		}
+
+		type hour struct {
+			TimeEpoch int64   `json:"time_epoch"`
+			TempC     float64 `json:"temp_c"`
+		}
+		var hours []hour
+		for h := 0; h < 24; h++ {
+			at := day.Add(time.Duration(h) * time.Hour)
+			hours = append(hours, hour{TimeEpoch: at.Unix(), TempC: float64(at.UTC().Hour())})
+		}
+		response := map[string]interface{}{
+			"location": map[string]interface{}{"name": "Brisbane", "country": "Australia"},
+			"forecast": map[string]interface{}{"forecastday": []interface{}{map[string]interface{}{"hour": hours}}},
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestWeatherAPIHistoryUsesUTCDays(t *testing.T) {
+	from := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	provider := NewWeatherAPIProvider("key")
+	provider.baseURL = historyServer(t, to.AddDate(0, 0, 5)).URL
+
+	history, err := provider.FetchHistory(context.Background(), "Brisbane", from, to)
+	if err != nil {
+		t.Fatalf("FetchHistory: %v", err)
+	}
+
+	if len(history.Hours) != 48 {
+		t.Fatalf("got %d hours, want 48 for two complete UTC days", len(history.Hours))
+	}
+	for i, hour := range history.Hours {
+		want := from.Add(time.Duration(i) * time.Hour)
+		if !hour.Timestamp.Equal(want) {
+			t.Fatalf("hour %d at %s, want %s in order without duplicates", i, hour.Timestamp, want)
+		}
+	}
+}
+
+func TestWeatherAPIHistoryToleratesMissingNeighbours(t *testing.T) {
+	// The local day after the range is in the future, so the last UTC day can't be completed
+	from := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	provider := NewWeatherAPIProvider("key")
+	provider.baseURL = historyServer(t, from).URL
+
+	history, err := provider.FetchHistory(context.Background(), "Brisbane", from, from)
+	if err != nil {
+		t.Fatalf("FetchHistory: %v", err)
+	}
+	// Local 10 March covers UTC 9 March 14:00 to 10 March 14:00, so UTC 10 March only gets 14 hours
+	if len(history.Hours) != 14 {
+		t.Errorf("got %d hours, want the 14 of the UTC day covered by fetched local days", len(history.Hours))
+	}
+
+	// A day inside the range that can't be fetched is still an error
+	if _, err := provider.FetchHistory(context.Background(), "Brisbane", from, from.AddDate(0, 0, 1)); err == nil {
+		t.Error("FetchHistory succeeded with a day of the range unavailable")
+	}
+}
+
+func TestWeatherAPIMarine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/marine.json" || r.URL.Query().Get("tides") != "yes" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if r.URL.Query().Get("q") == "Atlantis" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 1006, "message": "No matching location found."}}`))
+			return
+		}
+		w.Write([]byte(`{
+			"location": {"name": "Falmouth", "country": "UK", "lat": 50.15, "lon": -5.07, "tz_id": "Europe/London"},
+			"forecast": {"forecastday": [{
+				"tides": [{"tide": [
+					{"tide_time": "2024-07-01 04:12", "tide_height_mt": "4.81", "tide_type": "HIGH"},
+					{"tide_time": "2024-07-01 10:30", "tide_height_mt": "0.9", "tide_type": "LOW"},
+					{"tide_time": "not a time", "tide_height_mt": "1", "tide_type": "HIGH"}
+				]}],
+				"hour": [{
+					"time_epoch": 1719788400, "temp_c": 15.2, "wind_kph": 36, "wind_degree": 250, "vis_km": 10,
+					"sig_ht_mt": 1.4, "swell_ht_mt": 1.1, "swell_dir": 247.5, "swell_dir_16_point": "WSW",
+					"swell_period_secs": 9.2, "water_temp_c": 14.6
+				}]
+			}]}
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewWeatherAPIProvider("key")
+	provider.baseURL = server.URL
+
+	marine, err := provider.FetchMarine(context.Background(), "Falmouth,UK", 1)
+	if err != nil {
+		t.Fatalf("FetchMarine: %v", err)
+	}
+	if marine.Location != "Falmouth,UK" || marine.Latitude != 50.15 || marine.Provider != "WeatherAPI" {
+		t.Errorf("marine = %+v", marine)
+	}
+
+	if len(marine.Hours) != 1 {
+		t.Fatalf("got %d hours, want 1", len(marine.Hours))
+	}
+	hour := marine.Hours[0]
+	if !hour.Timestamp.Equal(time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC)) || hour.WaveHeight != 1.4 ||
+		hour.SwellHeight != 1.1 || hour.SwellPeriod != 9.2 || hour.SwellDirection != 247 || hour.SwellCompass != "WSW" ||
+		hour.WaterTemperature != 14.6 || hour.WindSpeed != 10 || hour.WindDeg != 250 {
+		t.Errorf("hour = %+v", hour)
+	}
+
+	// Tide times are local; in July, London is an hour ahead of UTC
+	if len(marine.Tides) != 2 {
+		t.Fatalf("got %d tides, want the 2 with valid times", len(marine.Tides))
+	}
+	high, low := marine.Tides[0], marine.Tides[1]
+	if !high.Time.Equal(time.Date(2024, 7, 1, 3, 12, 0, 0, time.UTC)) || high.Height != 4.81 || high.Type != "high" {
+		t.Errorf("high tide = %+v", high)
+	}
+	if !low.Time.Equal(time.Date(2024, 7, 1, 9, 30, 0, 0, time.UTC)) || low.Height != 0.9 || low.Type != "low" {
+		t.Errorf("low tide = %+v", low)
+	}
+
+	if _, err := provider.FetchMarine(context.Background(), "Atlantis", 1); ErrorKindOf(err) != ErrorNotFound {
+		t.Errorf("FetchMarine Atlantis = %v, want not found", err)
+	}
+}
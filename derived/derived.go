@@ -0,0 +1,162 @@
+package derived
+
+import (
+	"math"
+
+	"weather-service/models"
+)
+
+// Formula names reported alongside each derived value
+const (
+	FormulaDewPoint            = "Magnus (Alduchov & Eskridge 1996)"
+	FormulaHeatIndex           = "NWS heat index (Rothfusz regression with Steadman fallback)"
+	FormulaWindChill           = "NWS/Environment Canada wind chill (2001)"
+	FormulaHumidex             = "Canadian humidex (Masterton & Richardson 1979)"
+	FormulaApparentTemperature = "Australian apparent temperature (Steadman 1994, shade)"
+	FormulaWBGT                = "Australian BoM simplified WBGT estimate"
+)
+
+// Magnus coefficients for saturation vapour pressure over water
+const (
+	magnusA = 17.625
+	magnusB = 243.04
+)
+
+// Compute derives all comfort indices from temperature (Celsius), relative humidity (percent) and wind speed (m/s)
+func Compute(temperature, humidity, windSpeed float64) models.DerivedMetrics {
+	return models.DerivedMetrics{
+		DewPoint:            DewPoint(temperature, humidity),
+		HeatIndex:           HeatIndex(temperature, humidity),
+		WindChill:           WindChill(temperature, windSpeed),
+		Humidex:             Humidex(temperature, humidity),
+		ApparentTemperature: ApparentTemperature(temperature, humidity, windSpeed),
+		WBGT:                WBGT(temperature, humidity),
+	}
+}
+
+// DewPoint computes the dew point in Celsius with the Magnus formula
+func DewPoint(temperature, humidity float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaDewPoint}
+	if humidity <= 0 || humidity > 100 {
+		return value
+	}
+
+	gamma := math.Log(humidity/100) + magnusA*temperature/(magnusB+temperature)
+	value.Value = round1(magnusB * gamma / (magnusA - gamma))
+	value.Applicable = temperature >= -45 && temperature <= 60
+	return value
+}
+
+// HeatIndex computes the NWS heat index in Celsius.
+// Below about 27°C (80°F) the index equals the simple Steadman estimate and is flagged as not applicable.
+func HeatIndex(temperature, humidity float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaHeatIndex}
+
+	t := temperature*9/5 + 32
+	rh := humidity
+
+	// Steadman's simple formula, used by the NWS when the result is below 80°F
+	hi := 0.5 * (t + 61.0 + (t-68.0)*1.2 + rh*0.094)
+
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+			0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+			0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+
+		// NWS adjustments for very dry and very humid conditions
+		if rh < 13 && t >= 80 && t <= 112 {
+			hi -= ((13 - rh) / 4) * math.Sqrt((17-math.Abs(t-95))/17)
+		} else if rh > 85 && t >= 80 && t <= 87 {
+			hi += ((rh - 85) / 10) * ((87 - t) / 5)
+		}
+	}
+
+	value.Value = round1((hi - 32) * 5 / 9)
+	value.Applicable = t >= 80
+	return value
+}
+
+// WindChill computes the wind chill in Celsius; it is defined at or below 10°C with wind above 4.8 km/h
+func WindChill(temperature, windSpeed float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaWindChill, Value: round1(temperature)}
+
+	kmh := windSpeed * 3.6
+	if temperature > 10 || kmh <= 4.8 {
+		return value
+	}
+
+	v := math.Pow(kmh, 0.16)
+	value.Value = round1(13.12 + 0.6215*temperature - 11.37*v + 0.3965*temperature*v)
+	value.Applicable = true
+	return value
+}
+
+// Humidex computes the Canadian humidex from temperature and the dew point derived from humidity
+func Humidex(temperature, humidity float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaHumidex}
+
+	if humidity <= 0 || humidity > 100 {
+		return value
+	}
+	dewPoint := DewPoint(temperature, humidity)
+
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+dewPoint.Value)))
+	value.Value = round1(temperature + 0.5555*(e-10))
+	// Environment Canada only reports humidex when it is at least 25 and above the air temperature
+	value.Applicable = value.Value >= 25 && value.Value > temperature
+	return value
+}
+
+// ApparentTemperature computes the Australian apparent temperature (shade, no radiation term) in Celsius
+func ApparentTemperature(temperature, humidity, windSpeed float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaApparentTemperature}
+
+	e := vapourPressure(temperature, humidity)
+	value.Value = round1(temperature + 0.33*e - 0.70*windSpeed - 4.00)
+	value.Applicable = humidity > 0 && humidity <= 100
+	return value
+}
+
+// WBGT estimates the wet bulb globe temperature in Celsius from temperature and humidity.
+// This is the BoM approximation for moderate sun and light wind; it is not a substitute for a measured WBGT.
+func WBGT(temperature, humidity float64) models.DerivedValue {
+	value := models.DerivedValue{Unit: "°C", Formula: FormulaWBGT}
+
+	e := vapourPressure(temperature, humidity)
+	value.Value = round1(0.567*temperature + 0.393*e + 3.94)
+	value.Applicable = humidity > 0 && humidity <= 100 && temperature >= 15
+	return value
+}
+
+// vapourPressure returns the water vapour pressure in hPa
+func vapourPressure(temperature, humidity float64) float64 {
+	return humidity / 100 * 6.105 * math.Exp(17.27*temperature/(237.7+temperature))
+}
+
+// round1 rounds to one decimal place
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// AnnotateWeather returns a copy of the readings with derived metrics filled in
+func AnnotateWeather(data []models.WeatherData) []models.WeatherData {
+	annotated := make([]models.WeatherData, len(data))
+	for i, d := range data {
+		metrics := Compute(d.Temperature, d.Humidity, d.WindSpeed)
+		d.Derived = &metrics
+		annotated[i] = d
+	}
+	return annotated
+}
+
+// AnnotateForecast returns a copy of the forecast with derived metrics filled in for every point
+func AnnotateForecast(data models.ForecastData) models.ForecastData {
+	forecasts := make([]models.Forecast, len(data.Forecasts))
+	for i, f := range data.Forecasts {
+		metrics := Compute(f.Temperature, f.Humidity, f.WindSpeed)
+		f.Derived = &metrics
+		forecasts[i] = f
+	}
+	data.Forecasts = forecasts
+	return data
+}
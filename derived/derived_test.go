@@ -0,0 +1,72 @@
+package derived
+
+import (
+	"math"
+	"testing"
+
+	"weather-service/models"
+)
+
+// fahrenheitToCelsius converts a table value given in Fahrenheit
+func fahrenheitToCelsius(f float64) float64 {
+	return (f - 32) * 5 / 9
+}
+
+func TestIndices(t *testing.T) {
+	tests := []struct {
+		name       string
+		got        models.DerivedValue
+		want       float64
+		tolerance  float64 // published tables are rounded to whole degrees
+		applicable bool
+	}{
+		{"dew point 20°C 50%", DewPoint(20, 50), 9.3, 0.1, true},
+		{"dew point saturated", DewPoint(15, 100), 15, 0.05, true},
+		{"dew point without humidity", DewPoint(20, 0), 0, 0, false},
+
+		{"heat index 90°F 70%", HeatIndex(fahrenheitToCelsius(90), 70), fahrenheitToCelsius(106), 0.6, true},
+		{"heat index 100°F 40%", HeatIndex(fahrenheitToCelsius(100), 40), fahrenheitToCelsius(109), 0.6, true},
+		{"heat index dry adjustment 100°F 10%", HeatIndex(fahrenheitToCelsius(100), 10), fahrenheitToCelsius(94), 0.6, true},
+		{"heat index below 80°F", HeatIndex(20, 50), 19.4, 0.6, false},
+
+		{"wind chill -10°C 20 km/h", WindChill(-10, 20/3.6), -17.9, 0.1, true},
+		{"wind chill -20°C 40 km/h", WindChill(-20, 40/3.6), -34, 0.6, true},
+		{"wind chill too warm", WindChill(15, 10), 15, 0, false},
+		{"wind chill calm", WindChill(-5, 1), -5, 0, false},
+
+		{"humidex 30°C 70%", Humidex(30, 70), 41, 0.6, true},
+		{"humidex cool", Humidex(18, 50), 18.6, 0.6, false},
+
+		{"apparent temperature 25°C 50% calm", ApparentTemperature(25, 50, 0), 26.2, 0.1, true},
+		{"apparent temperature windy", ApparentTemperature(25, 50, 10), 19.2, 0.1, true},
+
+		{"WBGT 30°C 50%", WBGT(30, 50), 29.3, 0.1, true},
+		{"WBGT too cool", WBGT(10, 50), 12, 0.1, false},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got.Value-tt.want) > tt.tolerance || tt.got.Applicable != tt.applicable {
+			t.Errorf("%s = %v (applicable %v), want %v±%v (applicable %v)",
+				tt.name, tt.got.Value, tt.got.Applicable, tt.want, tt.tolerance, tt.applicable)
+		}
+		if tt.got.Unit != "°C" || tt.got.Formula == "" {
+			t.Errorf("%s: unit %q, formula %q", tt.name, tt.got.Unit, tt.got.Formula)
+		}
+	}
+}
+
+func TestAnnotateLeavesInputUnchanged(t *testing.T) {
+	readings := []models.WeatherData{{Temperature: 30, Humidity: 70, WindSpeed: 2}}
+	annotated := AnnotateWeather(readings)
+	if readings[0].Derived != nil {
+		t.Error("AnnotateWeather modified its input")
+	}
+	if annotated[0].Derived == nil || annotated[0].Derived.Humidex.Value != Humidex(30, 70).Value {
+		t.Errorf("annotated = %+v", annotated[0].Derived)
+	}
+
+	forecast := models.ForecastData{Forecasts: []models.Forecast{{Temperature: -10, WindSpeed: 20 / 3.6}}}
+	annotatedForecast := AnnotateForecast(forecast)
+	if forecast.Forecasts[0].Derived != nil || annotatedForecast.Forecasts[0].Derived.WindChill.Value != -17.9 {
+		t.Errorf("annotated forecast = %+v", annotatedForecast.Forecasts[0].Derived)
+	}
+}
@@ -0,0 +1,102 @@
+package freshness
+
+import (
+	"time"
+
+	"weather-service/models"
+)
+
+// Weather returns copies of the readings with freshness filled in. Expired readings are left out
+// unless includeExpired is set; the providers of expired readings are always returned so
+// responses can flag them.
+func (p Policies) Weather(data []models.WeatherData, now time.Time, includeExpired bool) ([]models.WeatherData, []string) {
+	served := make([]models.WeatherData, 0, len(data))
+	expired := []string{}
+	for _, d := range data {
+		observedAt, fetchedAt := d.ObservedAt, d.FetchedAt
+		if fetchedAt.IsZero() {
+			// Readings stored before fetch times were recorded only have the timestamp
+			fetchedAt = d.Timestamp
+		}
+		f := p.Evaluate(KindWeather, d.Provider, observedAt, fetchedAt, now)
+		d.Freshness = &f
+
+		if f.State == models.FreshnessExpired {
+			expired = append(expired, d.Provider)
+			if !includeExpired {
+				continue
+			}
+		}
+		served = append(served, d)
+	}
+	return served, expired
+}
+
+// Forecast returns a copy of the forecast with the freshness of its issue filled in
+func (p Policies) Forecast(forecast models.ForecastData, now time.Time) models.ForecastData {
+	f := p.Evaluate(KindForecast, forecast.Provider, forecast.Updated, forecast.Updated, now)
+	forecast.Freshness = &f
+	return forecast
+}
+
+// Forecasts returns copies of the forecasts with freshness filled in, leaving out expired ones
+// unless includeExpired is set, along with the providers of expired forecasts
+func (p Policies) Forecasts(forecasts []models.ForecastData, now time.Time, includeExpired bool) ([]models.ForecastData, []string) {
+	served := make([]models.ForecastData, 0, len(forecasts))
+	expired := []string{}
+	for _, forecast := range forecasts {
+		forecast = p.Forecast(forecast, now)
+		if forecast.Freshness.State == models.FreshnessExpired {
+			expired = append(expired, forecast.Provider)
+			if !includeExpired {
+				continue
+			}
+		}
+		served = append(served, forecast)
+	}
+	return served, expired
+}
+
+// AirQuality returns copies of the readings with freshness filled in, leaving out expired ones
+// unless includeExpired is set, along with the providers of expired readings
+func (p Policies) AirQuality(readings []models.AirQuality, now time.Time, includeExpired bool) ([]models.AirQuality, []string) {
+	served := make([]models.AirQuality, 0, len(readings))
+	expired := []string{}
+	for _, aq := range readings {
+		f := p.Evaluate(KindAirQuality, aq.Provider, aq.Timestamp, aq.FetchedAt, now)
+		aq.Freshness = &f
+
+		if f.State == models.FreshnessExpired {
+			expired = append(expired, aq.Provider)
+			if !includeExpired {
+				continue
+			}
+		}
+		served = append(served, aq)
+	}
+	return served, expired
+}
+
+// stateRank orders freshness states from most to least current
+var stateRank = map[models.FreshnessState]int{
+	models.FreshnessFresh:   0,
+	models.FreshnessStale:   1,
+	models.FreshnessExpired: 2,
+}
+
+// Stalest returns the freshness of the least current reading, used to describe values merged from them.
+// Readings are compared by state first since providers can have different policies, then by age.
+func Stalest(data []models.WeatherData) *models.Freshness {
+	var stalest *models.Freshness
+	for _, d := range data {
+		if d.Freshness == nil {
+			continue
+		}
+		if stalest == nil || stateRank[d.Freshness.State] > stateRank[stalest.State] ||
+			(d.Freshness.State == stalest.State && d.Freshness.AgeSeconds > stalest.AgeSeconds) {
+			f := *d.Freshness
+			stalest = &f
+		}
+	}
+	return stalest
+}
@@ -0,0 +1,142 @@
+package freshness
+
+import (
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+var now = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func TestPolicyState(t *testing.T) {
+	policy := Policy{StaleAfter: 30 * time.Minute, ExpireAfter: 3 * time.Hour}
+	tests := []struct {
+		age  time.Duration
+		want models.FreshnessState
+	}{
+		{0, models.FreshnessFresh},
+		{29 * time.Minute, models.FreshnessFresh},
+		{30 * time.Minute, models.FreshnessStale},
+		{3*time.Hour - time.Second, models.FreshnessStale},
+		{3 * time.Hour, models.FreshnessExpired},
+	}
+	for _, tt := range tests {
+		if got := policy.State(tt.age); got != tt.want {
+			t.Errorf("State(%v) = %s, want %s", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestProviderOverridesKeepUnsetDefaults(t *testing.T) {
+	policies := DefaultPolicies()
+	policies.Providers["Slow"] = map[Kind]Policy{KindWeather: {StaleAfter: 2 * time.Hour}}
+
+	if got := policies.For(KindWeather, "Slow"); got != (Policy{StaleAfter: 2 * time.Hour, ExpireAfter: 3 * time.Hour}) {
+		t.Errorf("For(weather, Slow) = %+v, want the stale override with the default expiry", got)
+	}
+	if got := policies.For(KindForecast, "Slow"); got != policies.Defaults[KindForecast] {
+		t.Errorf("For(forecast, Slow) = %+v, want the default", got)
+	}
+	if got := policies.For(KindWeather, "Other"); got != policies.Defaults[KindWeather] {
+		t.Errorf("For(weather, Other) = %+v, want the default", got)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policies := DefaultPolicies()
+	observed := now.Add(-45 * time.Minute)
+	fetched := now.Add(-10 * time.Minute)
+
+	f := policies.Evaluate(KindWeather, "A", observed, fetched, now)
+	if f.State != models.FreshnessStale || f.AgeSeconds != 45*60 || !f.ObservedAt.Equal(observed) || !f.FetchedAt.Equal(fetched) {
+		t.Errorf("Evaluate = %+v, want stale at 45 minutes old", f)
+	}
+	if f.StaleAfter != "30m0s" || !f.ExpiresAt.Equal(observed.Add(3*time.Hour)) {
+		t.Errorf("stale after %s, expires %v", f.StaleAfter, f.ExpiresAt)
+	}
+
+	// Without an observation time the age is measured from the fetch
+	if f := policies.Evaluate(KindWeather, "A", time.Time{}, fetched, now); f.State != models.FreshnessFresh || f.AgeSeconds != 10*60 {
+		t.Errorf("Evaluate without an observation time = %+v, want fresh at 10 minutes old", f)
+	}
+
+	// A provider clock ahead of ours doesn't give a negative age
+	if f := policies.Evaluate(KindWeather, "A", now.Add(time.Minute), now, now); f.AgeSeconds != 0 || f.State != models.FreshnessFresh {
+		t.Errorf("Evaluate of a reading from the future = %+v", f)
+	}
+}
+
+func TestWeatherLeavesOutExpiredReadings(t *testing.T) {
+	policies := DefaultPolicies()
+	data := []models.WeatherData{
+		{Provider: "Fresh", ObservedAt: now.Add(-5 * time.Minute), FetchedAt: now},
+		{Provider: "Expired", ObservedAt: now.Add(-4 * time.Hour), FetchedAt: now},
+		// Stored before fetch times were recorded, so only the timestamp dates it
+		{Provider: "Legacy", Timestamp: now.Add(-time.Hour)},
+	}
+
+	served, expired := policies.Weather(data, now, false)
+	if len(served) != 2 || served[0].Provider != "Fresh" || served[1].Provider != "Legacy" {
+		t.Fatalf("served = %+v, want Fresh and Legacy", served)
+	}
+	if len(expired) != 1 || expired[0] != "Expired" {
+		t.Errorf("expired = %v, want [Expired]", expired)
+	}
+	if served[1].Freshness.State != models.FreshnessStale || served[1].Freshness.AgeSeconds != 3600 {
+		t.Errorf("Legacy freshness = %+v, want stale an hour old", served[1].Freshness)
+	}
+	if data[0].Freshness != nil {
+		t.Error("Weather changed its argument")
+	}
+
+	served, expired = policies.Weather(data, now, true)
+	if len(served) != 3 || len(expired) != 1 || served[1].Freshness.State != models.FreshnessExpired {
+		t.Errorf("with expired included, served %d and flagged %v", len(served), expired)
+	}
+}
+
+func TestForecastsAndAirQuality(t *testing.T) {
+	policies := DefaultPolicies()
+
+	forecasts, expired := policies.Forecasts([]models.ForecastData{
+		{Provider: "A", Updated: now.Add(-7 * time.Hour)},
+		{Provider: "B", Updated: now.Add(-25 * time.Hour)},
+	}, now, false)
+	if len(forecasts) != 1 || forecasts[0].Freshness.State != models.FreshnessStale || len(expired) != 1 || expired[0] != "B" {
+		t.Errorf("Forecasts = %+v, expired %v; want A stale and B expired", forecasts, expired)
+	}
+
+	readings, expired := policies.AirQuality([]models.AirQuality{
+		{Provider: "A", Timestamp: now.Add(-time.Hour), FetchedAt: now},
+		{Provider: "B", FetchedAt: now.Add(-7 * time.Hour)},
+	}, now, true)
+	if len(readings) != 2 || readings[0].Freshness.State != models.FreshnessFresh || readings[1].Freshness.State != models.FreshnessExpired {
+		t.Errorf("AirQuality = %+v, want A fresh and B expired", readings)
+	}
+	if len(expired) != 1 || expired[0] != "B" {
+		t.Errorf("expired = %v, want [B]", expired)
+	}
+}
+
+func TestStalest(t *testing.T) {
+	freshOld := &models.Freshness{State: models.FreshnessFresh, AgeSeconds: 1700}
+	staleYoung := &models.Freshness{State: models.FreshnessStale, AgeSeconds: 1000}
+	staleOld := &models.Freshness{State: models.FreshnessStale, AgeSeconds: 2000}
+
+	got := Stalest([]models.WeatherData{{Freshness: freshOld}, {}, {Freshness: staleYoung}, {Freshness: staleOld}})
+	if got == nil || *got != *staleOld {
+		t.Errorf("Stalest = %+v, want the older stale reading", got)
+	}
+	if got == staleOld {
+		t.Error("Stalest returned a reading's own freshness rather than a copy")
+	}
+
+	// A provider with a longer policy can be older yet still fresh; state comes first
+	if got := Stalest([]models.WeatherData{{Freshness: staleYoung}, {Freshness: freshOld}}); *got != *staleYoung {
+		t.Errorf("Stalest = %+v, want the stale reading", got)
+	}
+	if got := Stalest([]models.WeatherData{{}}); got != nil {
+		t.Errorf("Stalest without freshness = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,92 @@
+package freshness
+
+import (
+	"time"
+
+	"weather-service/models"
+)
+
+// Kind is a kind of data with its own staleness policy
+type Kind string
+
+// Kinds of data served with freshness metadata
+const (
+	KindWeather    Kind = "weather"
+	KindForecast   Kind = "forecast"
+	KindAirQuality Kind = "airquality"
+)
+
+// Policy sets when data becomes stale and when it expires, measured from when it was observed
+type Policy struct {
+	StaleAfter  time.Duration // data older than this is stale but still served
+	ExpireAfter time.Duration // data older than this is expired and hidden unless asked for
+}
+
+// State classifies data of the given age
+func (p Policy) State(age time.Duration) models.FreshnessState {
+	switch {
+	case age >= p.ExpireAfter:
+		return models.FreshnessExpired
+	case age >= p.StaleAfter:
+		return models.FreshnessStale
+	default:
+		return models.FreshnessFresh
+	}
+}
+
+// Policies holds the default policy for each kind of data and per-provider overrides
+type Policies struct {
+	Defaults  map[Kind]Policy
+	Providers map[string]map[Kind]Policy // key is provider name; zero durations keep the default
+}
+
+// DefaultPolicies returns policies matching the providers' usual update cadence: current
+// conditions every few minutes, forecasts a few times a day and air quality hourly
+func DefaultPolicies() Policies {
+	return Policies{
+		Defaults: map[Kind]Policy{
+			KindWeather:    {StaleAfter: 30 * time.Minute, ExpireAfter: 3 * time.Hour},
+			KindForecast:   {StaleAfter: 6 * time.Hour, ExpireAfter: 24 * time.Hour},
+			KindAirQuality: {StaleAfter: 2 * time.Hour, ExpireAfter: 6 * time.Hour},
+		},
+		Providers: make(map[string]map[Kind]Policy),
+	}
+}
+
+// For returns the policy for a kind of data from a provider
+func (p Policies) For(kind Kind, provider string) Policy {
+	policy := p.Defaults[kind]
+	if override, ok := p.Providers[provider][kind]; ok {
+		if override.StaleAfter > 0 {
+			policy.StaleAfter = override.StaleAfter
+		}
+		if override.ExpireAfter > 0 {
+			policy.ExpireAfter = override.ExpireAfter
+		}
+	}
+	return policy
+}
+
+// Evaluate describes the freshness of data from a provider at now. The age is measured from
+// observedAt, or from fetchedAt when the provider doesn't report an observation time.
+func (p Policies) Evaluate(kind Kind, provider string, observedAt, fetchedAt, now time.Time) models.Freshness {
+	if observedAt.IsZero() {
+		observedAt = fetchedAt
+	}
+	policy := p.For(kind, provider)
+
+	// Provider clocks can run slightly ahead of ours
+	age := now.Sub(observedAt)
+	if age < 0 {
+		age = 0
+	}
+
+	return models.Freshness{
+		ObservedAt: observedAt,
+		FetchedAt:  fetchedAt,
+		AgeSeconds: int64(age / time.Second),
+		State:      policy.State(age),
+		StaleAfter: policy.StaleAfter.String(),
+		ExpiresAt:  observedAt.Add(policy.ExpireAfter),
+	}
+}
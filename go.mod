@@ -6,5 +6,8 @@ toolchain go1.23.4
 
 require (
 	github.com/joho/godotenv v1.5.1
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/time v0.3.0
 )
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -0,0 +1,41 @@
+package models
+
+// TemperatureScore summarises temperature forecast errors (forecast minus observed)
+type TemperatureScore struct {
+	MAE     float64 `json:"mae"`     // mean absolute error in Celsius
+	Bias    float64 `json:"bias"`    // mean error in Celsius, positive means forecasts ran warm
+	Samples int     `json:"samples"` // number of verified forecast points
+}
+
+// PrecipitationScore summarises how well rain was forecast. Rates are null when nothing they divide by happened.
+type PrecipitationScore struct {
+	HitRate           *float64 `json:"hitRate"`           // probability of detection: share of observed rain forecast as rain
+	FalseAlarmRatio   *float64 `json:"falseAlarmRatio"`   // share of rain forecasts when no rain was observed
+	ProportionCorrect float64  `json:"proportionCorrect"` // share of points where rain/no-rain matched, dry ones included
+	Hits              int      `json:"hits"`              // observed rain that was forecast
+	Misses            int      `json:"misses"`            // observed rain that wasn't forecast
+	FalseAlarms       int      `json:"falseAlarms"`       // rain forecast but not observed
+	Samples           int      `json:"samples"`           // number of verified forecast points
+}
+
+// AccuracyStats holds verification scores for one provider, location and lead time bucket
+type AccuracyStats struct {
+	Location      string             `json:"location"`      // location name
+	Provider      string             `json:"provider"`      // forecast provider name
+	LeadFromHours int                `json:"leadFromHours"` // start of the lead time bucket, inclusive
+	LeadToHours   int                `json:"leadToHours"`   // end of the lead time bucket, exclusive
+	Temperature   TemperatureScore   `json:"temperature"`   // temperature errors
+	Precipitation PrecipitationScore `json:"precipitation"` // rain/no-rain scores
+}
+
+// ProviderRank is a provider's position in the skill ranking for a location
+type ProviderRank struct {
+	Rank                 int     `json:"rank"`                 // 1 is best
+	Provider             string  `json:"provider"`             // forecast provider name
+	Score                float64 `json:"score"`                // lower is better, 0 when unscored
+	TemperatureMAE       float64 `json:"temperatureMae"`       // recent temperature MAE in Celsius, averaged over buckets
+	PrecipitationCorrect float64 `json:"precipitationCorrect"` // share of rain/no-rain calls that were right
+	Buckets              int     `json:"buckets"`              // lead time buckets within the horizon that were scored
+	Samples              int     `json:"samples"`              // number of verified points behind the score
+	Reason               string  `json:"reason"`               // why the provider holds this rank
+}
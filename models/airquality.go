@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// AirQuality represents air pollutant concentrations and indices from a provider
+type AirQuality struct {
+	Provider           string    `json:"provider"`           // air quality data provider name
+	Location           string    `json:"location"`           // location name
+	PM25               float64   `json:"pm25"`               // fine particulate matter in µg/m³
+	PM10               float64   `json:"pm10"`               // coarse particulate matter in µg/m³
+	O3                 float64   `json:"o3"`                 // ozone in µg/m³
+	NO2                float64   `json:"no2"`                // nitrogen dioxide in µg/m³
+	SO2                float64   `json:"so2"`                // sulphur dioxide in µg/m³
+	CO                 float64   `json:"co"`                 // carbon monoxide in µg/m³
+	USAQI              int       `json:"usAqi"`              // US EPA AQI, 0-500
+	USCategory         string    `json:"usCategory"`         // US EPA category, e.g. "Moderate"
+	EUAQI              int       `json:"euAqi"`              // European Air Quality Index level, 1 (good) to 6 (extremely poor)
+	EUCategory         string    `json:"euCategory"`         // European category, e.g. "Fair"
+	DominantPollutant  string    `json:"dominantPollutant"`  // pollutant driving the US AQI
+	HealthGuidance     string    `json:"healthGuidance"`     // advice text for the US category
+	ProviderIndex      int       `json:"providerIndex"`      // index as reported by the provider
+	ProviderIndexScale string    `json:"providerIndexScale"` // scale of the provider index
+	Timestamp          time.Time `json:"timestamp"`          // time of the measurement
+	FetchedAt          time.Time `json:"fetchedAt"`          // when the service fetched the measurement
+
+	Freshness *Freshness `json:"freshness,omitempty"` // age and staleness, filled in API responses
+}
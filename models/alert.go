@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Alert represents a severe weather alert from one or more sources
+type Alert struct {
+	ID          string    `json:"id"`                    // identifier assigned by the first source
+	Sources     []string  `json:"sources"`               // every source that reported this alert
+	Location    string    `json:"location,omitempty"`    // location the alert was fetched for, if any
+	Event       string    `json:"event"`                 // e.g. "Flood Warning"
+	Headline    string    `json:"headline,omitempty"`    // short summary
+	Severity    string    `json:"severity"`              // CAP severity: Extreme, Severe, Moderate, Minor, Unknown
+	Urgency     string    `json:"urgency"`               // CAP urgency: Immediate, Expected, Future, Past, Unknown
+	Certainty   string    `json:"certainty,omitempty"`   // CAP certainty: Observed, Likely, Possible, Unlikely, Unknown
+	Area        string    `json:"area"`                  // description of the affected area
+	Effective   time.Time `json:"effective"`             // when the alert takes effect
+	Expires     time.Time `json:"expires"`               // when the alert expires, zero if open-ended
+	Description string    `json:"description"`           // full description
+	Instruction string    `json:"instruction,omitempty"` // recommended action
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// SunTimes holds the sun events for one local day; events that don't occur (polar day or night) are nil
+type SunTimes struct {
+	Sunrise          *time.Time `json:"sunrise,omitempty"`
+	Sunset           *time.Time `json:"sunset,omitempty"`
+	SolarNoon        time.Time  `json:"solarNoon"`
+	CivilDawn        *time.Time `json:"civilDawn,omitempty"`        // sun 6° below the horizon, morning
+	CivilDusk        *time.Time `json:"civilDusk,omitempty"`        // sun 6° below the horizon, evening
+	NauticalDawn     *time.Time `json:"nauticalDawn,omitempty"`     // sun 12° below the horizon, morning
+	NauticalDusk     *time.Time `json:"nauticalDusk,omitempty"`     // sun 12° below the horizon, evening
+	AstronomicalDawn *time.Time `json:"astronomicalDawn,omitempty"` // sun 18° below the horizon, morning
+	AstronomicalDusk *time.Time `json:"astronomicalDusk,omitempty"` // sun 18° below the horizon, evening
+	PolarDay         bool       `json:"polarDay"`                   // sun never sets
+	PolarNight       bool       `json:"polarNight"`                 // sun never rises
+}
+
+// CelestialPosition is the apparent position of a body in the sky at a given time
+type CelestialPosition struct {
+	Time      time.Time `json:"time"`
+	Elevation float64   `json:"elevation"` // degrees above the horizon
+	Azimuth   float64   `json:"azimuth"`   // degrees clockwise from north
+}
+
+// MoonInfo holds the moon phase and rise/set times for one local day
+type MoonInfo struct {
+	Phase        float64    `json:"phase"`        // 0 new moon, 0.25 first quarter, 0.5 full moon, 0.75 last quarter
+	PhaseName    string     `json:"phaseName"`    // e.g. "waxing gibbous"
+	Illumination float64    `json:"illumination"` // illuminated fraction of the disc, 0-1
+	Moonrise     *time.Time `json:"moonrise,omitempty"`
+	Moonset      *time.Time `json:"moonset,omitempty"`
+	AlwaysUp     bool       `json:"alwaysUp"`   // moon stays above the horizon all day
+	AlwaysDown   bool       `json:"alwaysDown"` // moon stays below the horizon all day
+}
+
+// AstronomyData is the locally computed astronomy for a location and date
+type AstronomyData struct {
+	Location      string            `json:"location"`
+	Latitude      float64           `json:"latitude"`
+	Longitude     float64           `json:"longitude"`
+	Date          string            `json:"date"` // local calendar date, YYYY-MM-DD
+	Sun           SunTimes          `json:"sun"`
+	SolarPosition CelestialPosition `json:"solarPosition"`
+	Moon          MoonInfo          `json:"moon"`
+	MoonPosition  CelestialPosition `json:"moonPosition"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// NormalStats summarises the historical distribution of one variable
+type NormalStats struct {
+	Mean        float64   `json:"mean"`
+	Percentiles []float64 `json:"percentiles"` // values at 0, 5, 10, ... 100 percent
+}
+
+// ClimateNormal is the climatology for one day of the year and hour of the day (UTC)
+type ClimateNormal struct {
+	DayOfYear     int         `json:"dayOfYear"` // 1-365, February 29 shares February 28's normal
+	Hour          int         `json:"hour"`      // UTC hour
+	Samples       int         `json:"samples"`   // number of historical observations
+	Temperature   NormalStats `json:"temperature"`
+	Precipitation NormalStats `json:"precipitation"` // hourly amounts in mm
+	WetFraction   float64     `json:"wetFraction"`   // share of hours with measurable precipitation
+}
+
+// ClimateBaseline describes the historical period the normals for a location were built from
+type ClimateBaseline struct {
+	Location string    `json:"location"`
+	Provider string    `json:"provider"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Samples  int       `json:"samples"` // number of hourly observations used
+	Built    time.Time `json:"built"`
+}
+
+// Anomaly compares a value with its climate normal
+type Anomaly struct {
+	Variable    string  `json:"variable"`          // "temperature" or "precipitation"
+	Value       float64 `json:"value"`             // the observed or forecast value
+	Normal      float64 `json:"normal"`            // climatological mean
+	Difference  float64 `json:"difference"`        // value minus normal
+	Unit        string  `json:"unit"`              // unit of value, normal and difference
+	Percentile  float64 `json:"percentile"`        // where the value falls in the historical distribution
+	Description string  `json:"description"`       // e.g. "+6.2°C above normal, 97th percentile"
+	Callout     string  `json:"callout,omitempty"` // e.g. "unusually warm", set only for notable anomalies
+}
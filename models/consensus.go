@@ -0,0 +1,19 @@
+package models
+
+// Disagreement describes a variable on which providers differ beyond the configured threshold
+type Disagreement struct {
+	Variable  string             `json:"variable"`  // variable name, e.g. temperature
+	Spread    float64            `json:"spread"`    // difference between the most extreme readings
+	Threshold float64            `json:"threshold"` // threshold the spread was compared against
+	Values    map[string]float64 `json:"values"`    // reading per provider
+	Reason    string             `json:"reason"`    // human readable explanation
+}
+
+// WeatherEstimate is the merged best estimate of current conditions across providers
+type WeatherEstimate struct {
+	Data          WeatherData     `json:"data"`                    // merged conditions
+	Providers     []string        `json:"providers"`               // providers that contributed
+	Method        string          `json:"method"`                  // how the values were combined
+	Flags         map[string]bool `json:"flags"`                   // disagreement flag per variable
+	Disagreements []Disagreement  `json:"disagreements,omitempty"` // details for flagged variables
+}
@@ -0,0 +1,19 @@
+package models
+
+// DerivedValue is a comfort index computed from measured values, with the formula that produced it
+type DerivedValue struct {
+	Value      float64 `json:"value"`
+	Unit       string  `json:"unit"`
+	Formula    string  `json:"formula"`    // name of the formula used
+	Applicable bool    `json:"applicable"` // whether the inputs fall inside the formula's validity range
+}
+
+// DerivedMetrics holds comfort indices derived from temperature, humidity and wind
+type DerivedMetrics struct {
+	DewPoint            DerivedValue `json:"dewPoint"`
+	HeatIndex           DerivedValue `json:"heatIndex"`
+	WindChill           DerivedValue `json:"windChill"`
+	Humidex             DerivedValue `json:"humidex"`
+	ApparentTemperature DerivedValue `json:"apparentTemperature"`
+	WBGT                DerivedValue `json:"wbgt"` // wet bulb globe temperature estimate
+}
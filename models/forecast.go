@@ -6,20 +6,27 @@ import (
 
 // Forecast represents a single forecast point with weather conditions at a specific time
 type Forecast struct {
-	Temperature float64   `json:"temperature"` // in Celsius
-	Humidity    float64   `json:"humidity"`    // percentage
-	WindSpeed   float64   `json:"windSpeed"`   // in m/s
-	WindDeg     int       `json:"windDeg"`     // wind direction in degrees
-	Pressure    float64   `json:"pressure"`    // in hPa
-	Description string    `json:"description"` // short text description
-	Icon        string    `json:"icon"`        // icon code or URL
-	Timestamp   time.Time `json:"timestamp"`   // time this forecast is for
+	Temperature   float64   `json:"temperature"`   // in Celsius
+	Humidity      float64   `json:"humidity"`      // percentage
+	WindSpeed     float64   `json:"windSpeed"`     // in m/s
+	WindDeg       int       `json:"windDeg"`       // wind direction in degrees
+	Pressure      float64   `json:"pressure"`      // in hPa
+	Precipitation float64   `json:"precipitation"` // in mm over the forecast step
+	PrecipChance  float64   `json:"precipChance"`  // probability of precipitation, percentage
+	Description   string    `json:"description"`   // short text description
+	Icon          string    `json:"icon"`          // icon code or URL
+	Timestamp     time.Time `json:"timestamp"`     // time this forecast is for
+
+	Derived   *DerivedMetrics `json:"derived,omitempty"`   // comfort indices, filled in API responses
+	Anomalies []Anomaly       `json:"anomalies,omitempty"` // departures from climate normals, filled in API responses
 }
 
 // ForecastData represents weather forecast data from a provider
 type ForecastData struct {
-	Provider  string     `json:"provider"`  // weather data provider name
-	Location  string     `json:"location"`  // location name
-	Forecasts []Forecast `json:"forecasts"` // list of forecasts
-	Updated   time.Time  `json:"updated"`   // when this forecast was updated
+	Provider  string       `json:"provider"`            // weather data provider name
+	Location  string       `json:"location"`            // location name
+	Forecasts []Forecast   `json:"forecasts"`           // list of forecasts
+	Updated   time.Time    `json:"updated"`             // when this forecast was updated
+	Freshness *Freshness   `json:"freshness,omitempty"` // age and staleness of the forecast issue, filled in API responses
+	Stale     *StaleNotice `json:"stale,omitempty"`     // set when served from an expired cache entry
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// FreshnessState describes how current a record is under its provider's staleness policy
+type FreshnessState string
+
+// Freshness states, from most to least current
+const (
+	FreshnessFresh   FreshnessState = "fresh"   // within the provider's update cadence
+	FreshnessStale   FreshnessState = "stale"   // older than expected but still usable
+	FreshnessExpired FreshnessState = "expired" // too old to be served as current
+)
+
+// Freshness describes the age of a record at the time it is served
+type Freshness struct {
+	ObservedAt time.Time      `json:"observedAt"` // when the provider measured or issued the data
+	FetchedAt  time.Time      `json:"fetchedAt"`  // when the service fetched it from the provider
+	AgeSeconds int64          `json:"ageSeconds"` // seconds since ObservedAt
+	State      FreshnessState `json:"state"`      // fresh, stale or expired
+	StaleAfter string         `json:"staleAfter"` // age at which the data becomes stale, e.g. "30m0s"
+	ExpiresAt  time.Time      `json:"expiresAt"`  // when the data expires
+}
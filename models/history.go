@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// HistoryData represents hourly weather observations for a past date range from a provider
+type HistoryData struct {
+	Provider string        `json:"provider"` // weather data provider name
+	Location string        `json:"location"` // location name
+	From     time.Time     `json:"from"`     // first day of the range (UTC midnight)
+	To       time.Time     `json:"to"`       // last day of the range (UTC midnight), inclusive
+	Hours    []WeatherData `json:"hours"`    // hourly observations in time order
+}
@@ -0,0 +1,12 @@
+package models
+
+import "strings"
+
+// NormalizeLocation makes spellings of the same location compare equal, e.g. " London, UK" and "london,uk"
+func NormalizeLocation(location string) string {
+	parts := strings.Split(strings.ToLower(location), ",")
+	for i, part := range parts {
+		parts[i] = strings.Join(strings.Fields(part), " ")
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// MarineConditions represents sea and swell conditions at a specific time
+type MarineConditions struct {
+	Timestamp        time.Time `json:"timestamp"`
+	WaveHeight       float64   `json:"waveHeight"`       // significant wave height in metres
+	SwellHeight      float64   `json:"swellHeight"`      // in metres
+	SwellPeriod      float64   `json:"swellPeriod"`      // in seconds
+	SwellDirection   int       `json:"swellDirection"`   // direction the swell comes from, in degrees
+	SwellCompass     string    `json:"swellCompass"`     // 16-point compass direction, e.g. "WSW"
+	WaterTemperature float64   `json:"waterTemperature"` // in Celsius
+	AirTemperature   float64   `json:"airTemperature"`   // in Celsius
+	WindSpeed        float64   `json:"windSpeed"`        // in m/s
+	WindDeg          int       `json:"windDeg"`          // wind direction in degrees
+	Visibility       float64   `json:"visibility"`       // in km
+}
+
+// TideExtreme represents a high or low tide
+type TideExtreme struct {
+	Time   time.Time `json:"time"`
+	Height float64   `json:"height"` // in metres relative to chart datum
+	Type   string    `json:"type"`   // "high" or "low"
+}
+
+// MarineData represents a marine forecast with tides from a provider
+type MarineData struct {
+	Provider  string             `json:"provider"`
+	Location  string             `json:"location"`
+	Latitude  float64            `json:"latitude"`
+	Longitude float64            `json:"longitude"`
+	Hours     []MarineConditions `json:"hours"` // hourly conditions in time order
+	Tides     []TideExtreme      `json:"tides"` // tide extremes in time order
+	Updated   time.Time          `json:"updated"`
+}
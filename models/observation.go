@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Stat summarises one variable over an interval
+type Stat struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+	Avg float64 `json:"avg"`
+}
+
+// ObservationAggregate summarises a provider's readings over one interval of a time series
+type ObservationAggregate struct {
+	Provider      string    `json:"provider"`
+	Location      string    `json:"location"`
+	Start         time.Time `json:"start"` // inclusive
+	End           time.Time `json:"end"`   // exclusive
+	Count         int       `json:"count"` // number of readings in the interval
+	Temperature   Stat      `json:"temperature"`
+	Humidity      Stat      `json:"humidity"`
+	WindSpeed     Stat      `json:"windSpeed"`
+	Pressure      Stat      `json:"pressure"`
+	Precipitation Stat      `json:"precipitation"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// Reasons an expired cache entry is served
+const (
+	StaleRevalidating = "revalidating" // a background refresh is replacing the entry
+	StaleSourceError  = "error"        // the source failed, so the last good entry is served instead
+)
+
+// StaleNotice marks data served from a cache entry past its lifetime
+type StaleNotice struct {
+	Reason     string `json:"reason"`          // StaleRevalidating or StaleSourceError
+	AgeSeconds int64  `json:"ageSeconds"`      // seconds since the entry was cached
+	Error      string `json:"error,omitempty"` // the source error, for StaleSourceError
+}
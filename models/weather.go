@@ -6,16 +6,35 @@ import (
 
 // WeatherData represents the weather data from a provider
 type WeatherData struct {
-	Provider    string    `json:"provider"`
-	Location    string    `json:"location"`
-	Temperature float64   `json:"temperature"`
-	Humidity    float64   `json:"humidity"`
-	WindSpeed   float64   `json:"windSpeed"`
-	Pressure    float64   `json:"pressure"`
-	Description string    `json:"description"`
-	Icon        string    `json:"icon"`
-	WindDeg     int       `json:"windDeg"`
-	Timestamp   time.Time `json:"timestamp"`
-	Sunrise     time.Time `json:"sunrise"`
-	Sunset      time.Time `json:"sunset"`
+	Provider      string    `json:"provider"`
+	Location      string    `json:"location"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	Temperature   float64   `json:"temperature"`
+	Humidity      float64   `json:"humidity"`
+	WindSpeed     float64   `json:"windSpeed"`
+	Pressure      float64   `json:"pressure"`
+	Precipitation float64   `json:"precipitation"` // in mm over the last hour
+	Description   string    `json:"description"`
+	Icon          string    `json:"icon"`
+	WindDeg       int       `json:"windDeg"`
+	Timestamp     time.Time `json:"timestamp"`  // when the reading was fetched; the time series is keyed by it
+	ObservedAt    time.Time `json:"observedAt"` // when the provider measured the conditions
+	FetchedAt     time.Time `json:"fetchedAt"`  // when the service fetched the reading
+	Sunrise       time.Time `json:"sunrise"`
+	Sunset        time.Time `json:"sunset"`
+
+	Derived   *DerivedMetrics `json:"derived,omitempty"`   // comfort indices, filled in API responses
+	Anomalies []Anomaly       `json:"anomalies,omitempty"` // departures from climate normals, filled in API responses
+	Freshness *Freshness      `json:"freshness,omitempty"` // age and staleness, filled in API responses
+	Stale     *StaleNotice    `json:"stale,omitempty"`     // set when served from an expired cache entry
+}
+
+// ObservationTime returns when the conditions were observed, falling back to the fetch
+// timestamp for readings stored before observation times were recorded
+func (d WeatherData) ObservationTime() time.Time {
+	if d.ObservedAt.IsZero() {
+		return d.Timestamp
+	}
+	return d.ObservedAt
 }
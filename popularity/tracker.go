@@ -0,0 +1,297 @@
+package popularity
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// maxTracked bounds how many distinct requested locations are counted; new locations are ignored beyond it
+const maxTracked = 10000
+
+// Options controls when requested locations join and leave the dynamic refresh set
+type Options struct {
+	Window       time.Duration // requests are counted, and the quota share measured, over this period
+	MinRequests  int           // requests within the window that promote a location
+	IdleAfter    time.Duration // a location without requests for this long is demoted
+	RefreshAfter time.Duration // a promoted location is refreshed once its data is this old
+	MaxLocations int           // most locations in the dynamic set
+	QuotaShare   float64       // most of the background provider calls the dynamic set may use, from 0 to 1
+}
+
+// DefaultOptions returns options that promote locations requested 20 times in an hour, refresh them
+// every 20 minutes, ahead of weather going stale, and let them use at most half of the background calls
+func DefaultOptions() Options {
+	return Options{
+		Window:       time.Hour,
+		MinRequests:  20,
+		IdleAfter:    2 * time.Hour,
+		RefreshAfter: 20 * time.Minute,
+		MaxLocations: 50,
+		QuotaShare:   0.5,
+	}
+}
+
+// LocationStats describes the traffic and refresh state of one requested location
+type LocationStats struct {
+	Location      string    `json:"location"`
+	Requests      float64   `json:"requests"` // estimated requests within the last window
+	LastRequest   time.Time `json:"lastRequest"`
+	Promoted      bool      `json:"promoted"`
+	PromotedAt    time.Time `json:"promotedAt,omitempty"`
+	LastRefreshed time.Time `json:"lastRefreshed,omitempty"`
+}
+
+// Stats summarises the dynamic refresh set and the provider calls made within the last window
+type Stats struct {
+	Dynamic      []LocationStats `json:"dynamic"`    // promoted locations, most requested first
+	Candidates   []LocationStats `json:"candidates"` // the most requested locations not promoted
+	Tracked      int             `json:"tracked"`
+	MaxLocations int             `json:"maxLocations"`
+	StaticCalls  int             `json:"staticCalls"`  // background calls for configured locations
+	DynamicCalls int             `json:"dynamicCalls"` // background calls for the dynamic set
+	QuotaShare   float64         `json:"quotaShare"`
+	Promotions   int             `json:"promotions"`
+	Demotions    int             `json:"demotions"`
+}
+
+// location counts requests in fixed windows; the previous window is weighted by how much of it
+// still overlaps the sliding window ending now
+type location struct {
+	name          string // spelling of the most recent request, used when refreshing
+	count         int
+	prevCount     int
+	windowStart   time.Time
+	lastRequest   time.Time
+	promotedAt    time.Time // zero unless promoted
+	lastRefreshed time.Time
+}
+
+// cycle records the background calls planned in one update
+type cycle struct {
+	at      time.Time
+	static  int
+	dynamic int
+}
+
+// Tracker counts requests per location and keeps the set of unconfigured locations popular enough
+// to be refreshed in the background
+type Tracker struct {
+	mutex      sync.Mutex
+	options    Options
+	configured map[string]bool
+	locations  map[string]*location
+	cycles     []cycle
+	promotions int
+	demotions  int
+}
+
+// NewTracker creates a tracker; configured locations are refreshed anyway, so they are never promoted
+func NewTracker(options Options, configured []string) *Tracker {
+	t := &Tracker{
+		options:    options,
+		configured: make(map[string]bool, len(configured)),
+		locations:  make(map[string]*location),
+	}
+	for _, name := range configured {
+		t.configured[models.NormalizeLocation(name)] = true
+	}
+	return t
+}
+
+// Record counts a request for a location; a nil tracker ignores it
+func (t *Tracker) Record(name string, now time.Time) {
+	if t == nil {
+		return
+	}
+	key := models.NormalizeLocation(name)
+	if key == "" {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.configured[key] {
+		return
+	}
+	l, exists := t.locations[key]
+	if !exists {
+		if len(t.locations) >= maxTracked {
+			return
+		}
+		l = &location{windowStart: now}
+		t.locations[key] = l
+	}
+
+	l.roll(now, t.options.Window)
+	l.count++
+	l.name = strings.TrimSpace(name)
+	l.lastRequest = now
+}
+
+// Plan picks the promoted locations to refresh in this update: those not refreshed within RefreshAfter,
+// most requested first, as many as the quota share allows next to the staticCalls made for configured
+// locations. Each location costs callsPerLocation provider calls. A nil tracker plans nothing.
+func (t *Tracker) Plan(now time.Time, staticCalls, callsPerLocation int) []string {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.update(now)
+
+	// Only calls made within the window count toward the share
+	kept := t.cycles[:0]
+	static, dynamic := staticCalls, 0
+	for _, c := range t.cycles {
+		if now.Sub(c.at) < t.options.Window {
+			kept = append(kept, c)
+			static += c.static
+			dynamic += c.dynamic
+		}
+	}
+	t.cycles = kept
+
+	// dynamic / (static + dynamic) <= share, so dynamic <= static * share / (1 - share)
+	budget := math.MaxInt
+	if t.options.QuotaShare < 1 {
+		share := math.Max(t.options.QuotaShare, 0)
+		budget = int(float64(static)*share/(1-share)) - dynamic
+	}
+
+	var due []*location
+	for _, l := range t.locations {
+		if !l.promotedAt.IsZero() && now.Sub(l.lastRefreshed) >= t.options.RefreshAfter {
+			due = append(due, l)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].rate(now, t.options.Window) > due[j].rate(now, t.options.Window)
+	})
+
+	var planned []string
+	for _, l := range due {
+		if callsPerLocation > budget {
+			log.Printf("Quota share of %.0f%% reached; deferred refreshing %d popular locations", t.options.QuotaShare*100, len(due)-len(planned))
+			break
+		}
+		budget -= callsPerLocation
+		l.lastRefreshed = now
+		planned = append(planned, l.name)
+	}
+
+	t.cycles = append(t.cycles, cycle{at: now, static: staticCalls, dynamic: len(planned) * callsPerLocation})
+	return planned
+}
+
+// update demotes idle locations, forgets unpromoted ones, and promotes the busiest locations above the threshold
+func (t *Tracker) update(now time.Time) {
+	promoted := 0
+	for key, l := range t.locations {
+		if now.Sub(l.lastRequest) < t.options.IdleAfter {
+			if !l.promotedAt.IsZero() {
+				promoted++
+			}
+			continue
+		}
+		if !l.promotedAt.IsZero() {
+			t.demotions++
+			log.Printf("Demoted %s from the dynamic refresh set after %s without requests", l.name, now.Sub(l.lastRequest).Round(time.Minute))
+		}
+		delete(t.locations, key)
+	}
+
+	var candidates []*location
+	for _, l := range t.locations {
+		if l.promotedAt.IsZero() && l.rate(now, t.options.Window) >= float64(t.options.MinRequests) {
+			candidates = append(candidates, l)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].rate(now, t.options.Window) > candidates[j].rate(now, t.options.Window)
+	})
+
+	for _, l := range candidates {
+		if promoted >= t.options.MaxLocations {
+			break
+		}
+		l.promotedAt = now
+		promoted++
+		t.promotions++
+		log.Printf("Promoted %s to the dynamic refresh set (%.0f requests in the last %s)", l.name, l.rate(now, t.options.Window), t.options.Window)
+	}
+}
+
+// Stats reports the dynamic set, the ten busiest other locations, and the calls within the last window
+func (t *Tracker) Stats(now time.Time) Stats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := Stats{
+		Dynamic:      []LocationStats{},
+		Candidates:   []LocationStats{},
+		Tracked:      len(t.locations),
+		MaxLocations: t.options.MaxLocations,
+		QuotaShare:   t.options.QuotaShare,
+		Promotions:   t.promotions,
+		Demotions:    t.demotions,
+	}
+	for _, c := range t.cycles {
+		if now.Sub(c.at) < t.options.Window {
+			stats.StaticCalls += c.static
+			stats.DynamicCalls += c.dynamic
+		}
+	}
+
+	for _, l := range t.locations {
+		s := LocationStats{
+			Location:      l.name,
+			Requests:      l.rate(now, t.options.Window),
+			LastRequest:   l.lastRequest,
+			Promoted:      !l.promotedAt.IsZero(),
+			PromotedAt:    l.promotedAt,
+			LastRefreshed: l.lastRefreshed,
+		}
+		if s.Promoted {
+			stats.Dynamic = append(stats.Dynamic, s)
+		} else {
+			stats.Candidates = append(stats.Candidates, s)
+		}
+	}
+
+	for _, list := range [][]LocationStats{stats.Dynamic, stats.Candidates} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Requests > list[j].Requests })
+	}
+	if len(stats.Candidates) > 10 {
+		stats.Candidates = stats.Candidates[:10]
+	}
+	return stats
+}
+
+// roll moves the counts forward to the window containing now
+func (l *location) roll(now time.Time, window time.Duration) {
+	elapsed := now.Sub(l.windowStart)
+	switch {
+	case elapsed >= 2*window:
+		l.prevCount, l.count = 0, 0
+		l.windowStart = now
+	case elapsed >= window:
+		l.prevCount, l.count = l.count, 0
+		l.windowStart = l.windowStart.Add(window)
+	}
+}
+
+// rate estimates the requests within the window ending now
+func (l *location) rate(now time.Time, window time.Duration) float64 {
+	l.roll(now, window)
+	overlap := 1 - float64(now.Sub(l.windowStart))/float64(window)
+	return float64(l.prevCount)*overlap + float64(l.count)
+}
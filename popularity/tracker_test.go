@@ -0,0 +1,154 @@
+package popularity
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+// record counts n requests for a location at a time
+func record(tracker *Tracker, name string, n int, at time.Time) {
+	for i := 0; i < n; i++ {
+		tracker.Record(name, at)
+	}
+}
+
+func TestRateSlidesAcrossWindows(t *testing.T) {
+	l := &location{windowStart: start}
+	l.roll(start, time.Hour)
+	l.count = 10
+
+	tests := []struct {
+		at     time.Duration
+		record bool
+		want   float64
+	}{
+		{30 * time.Minute, false, 10},
+		// Half of the previous window still overlaps the last hour
+		{90 * time.Minute, true, 6},
+		{150 * time.Minute, false, 0.5},
+		// Two whole windows later nothing is left
+		{5 * time.Hour, false, 0},
+	}
+	for _, tt := range tests {
+		now := start.Add(tt.at)
+		if tt.record {
+			l.roll(now, time.Hour)
+			l.count++
+		}
+		if got := l.rate(now, time.Hour); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("rate at +%s = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestPromotesBusiestUnconfiguredLocations(t *testing.T) {
+	options := DefaultOptions()
+	options.MinRequests = 3
+	options.MaxLocations = 1
+	tracker := NewTracker(options, []string{"London,UK"})
+
+	record(tracker, "paris,fr", 4, start)
+	record(tracker, "Paris, FR", 1, start)
+	record(tracker, "berlin,de", 3, start)
+	record(tracker, "rome,it", 1, start)
+	record(tracker, "london, uk", 10, start)
+	tracker.Record("  ", start)
+
+	planned := tracker.Plan(start, 10, 2)
+	if len(planned) != 1 || planned[0] != "Paris, FR" {
+		t.Errorf("Plan = %v, want the busiest location in its latest spelling", planned)
+	}
+
+	stats := tracker.Stats(start)
+	if stats.Tracked != 3 || stats.Promotions != 1 {
+		t.Errorf("tracked %d with %d promotions, want 3 and 1", stats.Tracked, stats.Promotions)
+	}
+	if len(stats.Dynamic) != 1 || stats.Dynamic[0].Requests != 5 || !stats.Dynamic[0].LastRefreshed.Equal(start) {
+		t.Errorf("dynamic = %+v, want Paris refreshed now", stats.Dynamic)
+	}
+	if len(stats.Candidates) != 2 || stats.Candidates[0].Location != "berlin,de" || stats.Candidates[1].Location != "rome,it" {
+		t.Errorf("candidates = %+v, want berlin then rome; configured locations aren't tracked", stats.Candidates)
+	}
+	if stats.StaticCalls != 10 || stats.DynamicCalls != 2 {
+		t.Errorf("calls = %d static, %d dynamic; want 10 and 2", stats.StaticCalls, stats.DynamicCalls)
+	}
+}
+
+func TestPlanRefreshesWhenDue(t *testing.T) {
+	options := DefaultOptions()
+	options.MinRequests = 1
+	tracker := NewTracker(options, nil)
+	record(tracker, "paris,fr", 1, start)
+
+	for _, step := range []struct {
+		at   time.Duration
+		want int
+	}{{0, 1}, {5 * time.Minute, 0}, {options.RefreshAfter, 1}} {
+		now := start.Add(step.at)
+		tracker.Record("paris,fr", now)
+		if planned := tracker.Plan(now, 100, 1); len(planned) != step.want {
+			t.Errorf("Plan at +%s = %v, want %d locations", step.at, planned, step.want)
+		}
+	}
+}
+
+func TestPlanKeepsWithinQuotaShare(t *testing.T) {
+	options := DefaultOptions()
+	options.MinRequests = 1
+	options.QuotaShare = 0.25
+	tracker := NewTracker(options, nil)
+	record(tracker, "a", 3, start)
+	record(tracker, "b", 2, start)
+	record(tracker, "c", 1, start)
+
+	// 6 static calls allow 2 dynamic ones for a quarter share
+	planned := tracker.Plan(start, 6, 1)
+	if len(planned) != 2 || planned[0] != "a" || planned[1] != "b" {
+		t.Errorf("Plan = %v, want the two busiest", planned)
+	}
+
+	// Calls within the window count: 12 static allow 4 dynamic, 2 of which are spent
+	if planned := tracker.Plan(start.Add(time.Minute), 6, 1); len(planned) != 1 || planned[0] != "c" {
+		t.Errorf("second Plan = %v, want the deferred location", planned)
+	}
+
+	// Calls older than the window no longer count
+	if stats := tracker.Stats(start.Add(options.Window + 2*time.Minute)); stats.StaticCalls != 0 || stats.DynamicCalls != 0 {
+		t.Errorf("calls after the window = %d static, %d dynamic; want none", stats.StaticCalls, stats.DynamicCalls)
+	}
+
+	unlimited := DefaultOptions()
+	unlimited.MinRequests = 1
+	unlimited.QuotaShare = 1
+	tracker = NewTracker(unlimited, nil)
+	record(tracker, "a", 1, start)
+	if planned := tracker.Plan(start, 0, 5); len(planned) != 1 {
+		t.Errorf("Plan with a full share = %v, want no limit", planned)
+	}
+}
+
+func TestIdleLocationsAreDemoted(t *testing.T) {
+	options := DefaultOptions()
+	options.MinRequests = 1
+	tracker := NewTracker(options, nil)
+	record(tracker, "paris,fr", 1, start)
+	record(tracker, "rome,it", 0, start)
+	tracker.Plan(start, 100, 1)
+
+	tracker.Plan(start.Add(options.IdleAfter), 100, 1)
+	stats := tracker.Stats(start.Add(options.IdleAfter))
+	if stats.Tracked != 0 || stats.Demotions != 1 || len(stats.Dynamic) != 0 {
+		t.Errorf("stats = %+v, want the idle location demoted and forgotten", stats)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Record("paris,fr", start)
+	if planned := tracker.Plan(start, 10, 1); planned != nil {
+		t.Errorf("Plan on a nil tracker = %v", planned)
+	}
+}
@@ -59,6 +59,9 @@ type OpenWeatherMapForecastResponse struct {
 		Clouds struct {
 			All int `json:"all"` // Cloudiness percentage
 		} `json:"clouds"`
+		Rain struct {
+			ThreeHours float64 `json:"3h"`
+		} `json:"rain"`
 		Pop float64 `json:"pop"` // Probability of precipitation
 	} `json:"list"`
 }
@@ -129,14 +132,16 @@ func (o *OpenWeatherMapForecastSource) FetchForecast(ctx context.Context, locati
 
 		// Create a forecast entry
 		forecast := models.Forecast{
-			Temperature: item.Main.Temp,
-			Humidity:    item.Main.Humidity,
-			WindSpeed:   item.Wind.Speed,
-			WindDeg:     item.Wind.Deg,
-			Pressure:    item.Main.Pressure,
-			Description: description,
-			Icon:        "", // OpenWeatherMap doesn't provide icon in this response
-			Timestamp:   forecastTime,
+			Temperature:   item.Main.Temp,
+			Humidity:      item.Main.Humidity,
+			WindSpeed:     item.Wind.Speed,
+			WindDeg:       item.Wind.Deg,
+			Pressure:      item.Main.Pressure,
+			Precipitation: item.Rain.ThreeHours,
+			PrecipChance:  item.Pop * 100,
+			Description:   description,
+			Icon:          "", // OpenWeatherMap doesn't provide icon in this response
+			Timestamp:     forecastTime,
 		}
 
 		forecastData.Forecasts = append(forecastData.Forecasts, forecast)
@@ -48,10 +48,17 @@ type OpenWeatherMapResponse struct {
 		Speed float64 `json:"speed"`
 		Deg   int     `json:"deg"`
 	} `json:"wind"`
+	Rain struct {
+		OneHour float64 `json:"1h"`
+	} `json:"rain"`
 	Weather []struct {
 		Description string `json:"description"`
 		Icon        string `json:"icon"`
 	} `json:"weather"`
+	Coord struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	} `json:"coord"`
 	Name string `json:"name"`
 	Dt   int64  `json:"dt"`
 	Sys  struct {
@@ -96,18 +103,21 @@ func (o *OpenWeatherMapSource) FetchWeatherData(ctx context.Context, location st
 
 	// Build the WeatherData struct from the API response
 	data := models.WeatherData{
-		Provider:    o.Name(),
-		Location:    location,
-		Timestamp:   time.Unix(owmResp.Dt, 0),
-		Temperature: owmResp.Main.Temp,
-		Humidity:    owmResp.Main.Humidity,
-		WindSpeed:   owmResp.Wind.Speed,
-		Pressure:    owmResp.Main.Pressure,
-		Description: "",
-		Icon:        "",
-		WindDeg:     owmResp.Wind.Deg,
-		Sunrise:     time.Unix(owmResp.Sys.Sunrise, 0),
-		Sunset:      time.Unix(owmResp.Sys.Sunset, 0),
+		Provider:      o.Name(),
+		Location:      location,
+		Latitude:      owmResp.Coord.Lat,
+		Longitude:     owmResp.Coord.Lon,
+		Timestamp:     time.Unix(owmResp.Dt, 0),
+		Temperature:   owmResp.Main.Temp,
+		Humidity:      owmResp.Main.Humidity,
+		WindSpeed:     owmResp.Wind.Speed,
+		Pressure:      owmResp.Main.Pressure,
+		Precipitation: owmResp.Rain.OneHour,
+		Description:   "",
+		Icon:          "",
+		WindDeg:       owmResp.Wind.Deg,
+		Sunrise:       time.Unix(owmResp.Sys.Sunrise, 0),
+		Sunset:        time.Unix(owmResp.Sys.Sunset, 0),
 	}
 
 	// Add the weather description and icon if available
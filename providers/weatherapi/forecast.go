@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"weather-service/datasource"
@@ -19,8 +20,11 @@ type WeatherAPIForecastSource struct {
 	client *http.Client
 }
 
-// Ensure WeatherAPIForecastSource implements ForecastSource
-var _ datasource.ForecastSource = (*WeatherAPIForecastSource)(nil)
+// Ensure WeatherAPIForecastSource implements ForecastSource and AlertSource
+var (
+	_ datasource.ForecastSource = (*WeatherAPIForecastSource)(nil)
+	_ datasource.AlertSource    = (*WeatherAPIForecastSource)(nil)
+)
 
 // NewWeatherAPIForecastSource creates a new forecast source
 func NewWeatherAPIForecastSource(apiKey string) *WeatherAPIForecastSource {
@@ -69,11 +73,27 @@ type WeatherAPIForecastResponse struct {
 				WindKph      float64 `json:"wind_kph"`
 				WindDegree   int     `json:"wind_degree"`
 				PressureMb   float64 `json:"pressure_mb"`
+				PrecipMm     float64 `json:"precip_mm"`
 				Humidity     int     `json:"humidity"`
 				ChanceOfRain int     `json:"chance_of_rain"`
 			} `json:"hour"`
 		} `json:"forecastday"`
 	} `json:"forecast"`
+	Alerts struct {
+		Alert []struct {
+			Headline    string `json:"headline"`
+			MsgType     string `json:"msgtype"`
+			Severity    string `json:"severity"`
+			Urgency     string `json:"urgency"`
+			Areas       string `json:"areas"`
+			Certainty   string `json:"certainty"`
+			Event       string `json:"event"`
+			Effective   string `json:"effective"`
+			Expires     string `json:"expires"`
+			Desc        string `json:"desc"`
+			Instruction string `json:"instruction"`
+		} `json:"alert"`
+	} `json:"alerts"`
 }
 
 // FetchForecast gets forecast data from WeatherAPI.com
@@ -84,37 +104,9 @@ func (w *WeatherAPIForecastSource) FetchForecast(ctx context.Context, location s
 		days = 3 // Limit to what the free tier can provide
 	}
 
-	// Build the URL
-	apiURL := fmt.Sprintf("https://api.weatherapi.com/v1/forecast.json?key=%s&q=%s&days=%d&aqi=no&alerts=no",
-		w.apiKey, url.QueryEscape(location), days)
-
-	fmt.Printf("Making WeatherAPI forecast request to: %s\n", apiURL)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return models.ForecastData{}, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := w.client.Do(req)
-	if err != nil {
-		return models.ForecastData{}, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return models.ForecastData{}, fmt.Errorf("API returned non-200 status: %d", resp.StatusCode)
-	}
-
-	// Read the response body
-	rawData, err := io.ReadAll(resp.Body)
+	forecastResp, err := w.fetch(ctx, location, days)
 	if err != nil {
-		return models.ForecastData{}, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	// Parse the response
-	var forecastResp WeatherAPIForecastResponse
-	if err := json.Unmarshal(rawData, &forecastResp); err != nil {
-		return models.ForecastData{}, fmt.Errorf("failed to parse API response: %w", err)
+		return models.ForecastData{}, err
 	}
 
 	// Create the forecast data
@@ -136,12 +128,14 @@ func (w *WeatherAPIForecastSource) FetchForecast(ctx context.Context, location s
 				Temperature: hour.TempC,
 				Humidity:    float64(hour.Humidity),
 				// Convert from km/h to m/s
-				WindSpeed:   hour.WindKph / 3.6,
-				WindDeg:     hour.WindDegree,
-				Pressure:    hour.PressureMb,
-				Description: hour.Condition.Text,
-				Icon:        hour.Condition.Icon,
-				Timestamp:   forecastTime,
+				WindSpeed:     hour.WindKph / 3.6,
+				WindDeg:       hour.WindDegree,
+				Pressure:      hour.PressureMb,
+				Precipitation: hour.PrecipMm,
+				PrecipChance:  float64(hour.ChanceOfRain),
+				Description:   hour.Condition.Text,
+				Icon:          hour.Condition.Icon,
+				Timestamp:     forecastTime,
 			}
 
 			forecastData.Forecasts = append(forecastData.Forecasts, forecast)
@@ -150,3 +144,78 @@ func (w *WeatherAPIForecastSource) FetchForecast(ctx context.Context, location s
 
 	return forecastData, nil
 }
+
+// FetchAlerts gets the severe weather alerts currently issued for a location.
+// WeatherAPI.com only returns alerts from the forecast endpoint, so a one-day forecast is requested.
+func (w *WeatherAPIForecastSource) FetchAlerts(ctx context.Context, location string) ([]models.Alert, error) {
+	forecastResp, err := w.fetch(ctx, location, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := make([]models.Alert, 0, len(forecastResp.Alerts.Alert))
+	for _, a := range forecastResp.Alerts.Alert {
+		if strings.EqualFold(a.MsgType, "Cancel") {
+			continue
+		}
+
+		effective, _ := time.Parse(time.RFC3339, a.Effective)
+		expires, _ := time.Parse(time.RFC3339, a.Expires)
+
+		alerts = append(alerts, models.Alert{
+			ID:          fmt.Sprintf("%s-%s-%d", w.Name(), strings.ReplaceAll(a.Event, " ", ""), effective.Unix()),
+			Sources:     []string{w.Name()},
+			Location:    location,
+			Event:       a.Event,
+			Headline:    a.Headline,
+			Severity:    a.Severity,
+			Urgency:     a.Urgency,
+			Certainty:   a.Certainty,
+			Area:        a.Areas,
+			Effective:   effective,
+			Expires:     expires,
+			Description: a.Desc,
+			Instruction: a.Instruction,
+		})
+	}
+
+	return alerts, nil
+}
+
+// fetch requests the forecast endpoint, including alerts, and parses the response
+func (w *WeatherAPIForecastSource) fetch(ctx context.Context, location string, days int) (WeatherAPIForecastResponse, error) {
+	// Build the URL
+	apiURL := fmt.Sprintf("https://api.weatherapi.com/v1/forecast.json?key=%s&q=%s&days=%d&aqi=no&alerts=yes",
+		w.apiKey, url.QueryEscape(location), days)
+
+	fmt.Printf("Making WeatherAPI forecast request to: %s\n", apiURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return WeatherAPIForecastResponse{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return WeatherAPIForecastResponse{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return WeatherAPIForecastResponse{}, fmt.Errorf("API returned non-200 status: %d", resp.StatusCode)
+	}
+
+	// Read the response body
+	rawData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return WeatherAPIForecastResponse{}, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// Parse the response
+	var forecastResp WeatherAPIForecastResponse
+	if err := json.Unmarshal(rawData, &forecastResp); err != nil {
+		return WeatherAPIForecastResponse{}, fmt.Errorf("failed to parse API response: %w", err)
+	}
+
+	return forecastResp, nil
+}
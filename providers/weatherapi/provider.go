@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"time"
 
+	"weather-service/astronomy"
 	"weather-service/datasource"
 	"weather-service/models"
 )
@@ -40,8 +41,10 @@ func (w *WeatherAPISource) Name() string {
 // WeatherAPIResponse represents the API response structure
 type WeatherAPIResponse struct {
 	Location struct {
-		Name    string `json:"name"`
-		Country string `json:"country"`
+		Name    string  `json:"name"`
+		Country string  `json:"country"`
+		Lat     float64 `json:"lat"`
+		Lon     float64 `json:"lon"`
 	} `json:"location"`
 	Current struct {
 		TempC      float64 `json:"temp_c"`
@@ -49,6 +52,7 @@ type WeatherAPIResponse struct {
 		WindKph    float64 `json:"wind_kph"`
 		WindDegree int     `json:"wind_degree"`
 		PressureMb float64 `json:"pressure_mb"`
+		PrecipMm   float64 `json:"precip_mm"`
 		Condition  struct {
 			Text string `json:"text"`
 			Icon string `json:"icon"`
@@ -126,18 +130,26 @@ func (w *WeatherAPISource) FetchWeatherData(ctx context.Context, location string
 	}
 
 	// Build the WeatherData struct from the API response
-	return models.WeatherData{
-		Provider:    w.Name(),
-		Location:    formattedLocation,
-		Timestamp:   lastUpdated,
-		Temperature: wapiResp.Current.TempC,
-		Humidity:    float64(wapiResp.Current.Humidity),
-		WindSpeed:   wapiResp.Current.WindKph / 3.6, // Convert km/h to m/s
-		WindDeg:     wapiResp.Current.WindDegree,
-		Pressure:    wapiResp.Current.PressureMb,
-		Description: wapiResp.Current.Condition.Text,
-		Icon:        wapiResp.Current.Condition.Icon,
-		Sunrise:     sunrise,
-		Sunset:      sunset,
-	}, nil
+	data := models.WeatherData{
+		Provider:      w.Name(),
+		Location:      formattedLocation,
+		Latitude:      wapiResp.Location.Lat,
+		Longitude:     wapiResp.Location.Lon,
+		Timestamp:     lastUpdated,
+		Temperature:   wapiResp.Current.TempC,
+		Humidity:      float64(wapiResp.Current.Humidity),
+		WindSpeed:     wapiResp.Current.WindKph / 3.6, // Convert km/h to m/s
+		WindDeg:       wapiResp.Current.WindDegree,
+		Pressure:      wapiResp.Current.PressureMb,
+		Precipitation: wapiResp.Current.PrecipMm,
+		Description:   wapiResp.Current.Condition.Text,
+		Icon:          wapiResp.Current.Condition.Icon,
+		Sunrise:       sunrise,
+		Sunset:        sunset,
+	}
+
+	// current.json carries no astro block, so fill missing sun times locally
+	astronomy.FillSunTimes(&data)
+
+	return data, nil
 }
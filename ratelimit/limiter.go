@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Tier sets the sustained rate and burst allowed to a class of clients
+type Tier struct {
+	Rate  float64 `json:"rate"`  // requests per second; 0 or less is unlimited
+	Burst int     `json:"burst"` // requests allowed at once; at least 1 for a limited tier
+}
+
+// Options controls which tier each client gets and when idle buckets are dropped
+type Options struct {
+	Tiers         map[string]Tier
+	DefaultTier   string        // tier of API keys that don't name one
+	AnonymousTier string        // tier of clients without a valid key, limited per IP address
+	IdleAfter     time.Duration // buckets unused this long, and full again, are dropped
+	TrustProxy    bool          // take the client address from the last X-Forwarded-For entry, as set by a proxy
+}
+
+// DefaultOptions returns anonymous, standard and premium tiers, with keys on standard unless they name another
+func DefaultOptions() Options {
+	return Options{
+		Tiers: map[string]Tier{
+			"anonymous": {Rate: 2, Burst: 10},
+			"standard":  {Rate: 10, Burst: 50},
+			"premium":   {Rate: 50, Burst: 100},
+		},
+		DefaultTier:   "standard",
+		AnonymousTier: "anonymous",
+		IdleAfter:     10 * time.Minute,
+	}
+}
+
+// Decision is the outcome of a request against its client's bucket
+type Decision struct {
+	Allowed    bool
+	Limit      int           // the burst, i.e. requests allowed at once
+	Remaining  int           // requests still allowed at once
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, when refused
+}
+
+// bucket is one client's token bucket
+type bucket struct {
+	limiter  *rate.Limiter
+	tier     Tier
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per client and is safe for concurrent use
+type Limiter struct {
+	mutex   sync.Mutex
+	options Options
+	buckets map[string]*bucket
+}
+
+// NewLimiter creates a limiter with no buckets. A limited tier without a burst is rejected: its decisions
+// would carry a zero limit, which callers take to mean unlimited, so the tier would never be enforced.
+func NewLimiter(options Options) (*Limiter, error) {
+	names := make([]string, 0, len(options.Tiers))
+	for name := range options.Tiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if tier := options.Tiers[name]; tier.Rate > 0 && tier.Burst < 1 {
+			return nil, fmt.Errorf("rate limit tier %q has rate %g but burst %d; a limited tier needs a burst of at least 1",
+				name, tier.Rate, tier.Burst)
+		}
+	}
+	return &Limiter{
+		options: options,
+		buckets: make(map[string]*bucket),
+	}, nil
+}
+
+// HasTier reports whether a tier is configured
+func (l *Limiter) HasTier(name string) bool {
+	_, exists := l.options.Tiers[name]
+	return exists
+}
+
+// Tier returns a tier by name; an empty or unknown name gets the default tier
+func (l *Limiter) Tier(name string) Tier {
+	if tier, exists := l.options.Tiers[name]; exists {
+		return tier
+	}
+	return l.options.Tiers[l.options.DefaultTier]
+}
+
+// AnonymousTier returns the tier of clients without a valid key
+func (l *Limiter) AnonymousTier() Tier {
+	return l.options.Tiers[l.options.AnonymousTier]
+}
+
+// Allow takes a token from a client's bucket, creating the bucket on first use
+func (l *Limiter) Allow(client string, tier Tier, now time.Time) Decision {
+	if tier.Rate <= 0 {
+		return Decision{Allowed: true}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// A client moved to another tier starts with a fresh bucket
+	b, exists := l.buckets[client]
+	if !exists || b.tier != tier {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(tier.Rate), tier.Burst), tier: tier}
+		l.buckets[client] = b
+	}
+	b.lastSeen = now
+
+	decision := Decision{
+		Allowed: b.limiter.AllowN(now, 1),
+		Limit:   tier.Burst,
+	}
+	tokens := b.limiter.TokensAt(now)
+	decision.Remaining = max(int(math.Floor(tokens)), 0)
+	decision.Reset = seconds((float64(tier.Burst) - tokens) / tier.Rate)
+	if !decision.Allowed {
+		decision.RetryAfter = seconds((1 - tokens) / tier.Rate)
+	}
+	return decision
+}
+
+// seconds converts a number of seconds to a duration, never negative
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// Sweep drops buckets that have been idle for IdleAfter and have refilled, so dropping them changes nothing
+func (l *Limiter) Sweep(now time.Time) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	removed := 0
+	for client, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.options.IdleAfter && b.limiter.TokensAt(now) >= float64(b.tier.Burst) {
+			delete(l.buckets, client)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of client buckets
+func (l *Limiter) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.buckets)
+}
+
+// Run sweeps idle buckets every interval until stop is closed
+func (l *Limiter) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if removed := l.Sweep(time.Now()); removed > 0 {
+				log.Printf("Dropped %d idle rate limit buckets", removed)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ClientIP returns the address a request came from. Behind a trusted proxy it is the last X-Forwarded-For
+// entry, the one the proxy added; earlier entries are set by the client and can't be trusted.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	if l.options.TrustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			entries := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLimiterRejectsTierWithoutBurst(t *testing.T) {
+	options := DefaultOptions()
+	options.Tiers["broken"] = Tier{Rate: 5, Burst: 0}
+	if _, err := NewLimiter(options); err == nil || !strings.Contains(err.Error(), `"broken"`) {
+		t.Errorf("NewLimiter = %v, want an error naming the tier", err)
+	}
+
+	// Unlimited tiers need no burst
+	options.Tiers["broken"] = Tier{Rate: 0, Burst: 0}
+	if _, err := NewLimiter(options); err != nil {
+		t.Errorf("NewLimiter with an unlimited tier: %v", err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	limiter, err := NewLimiter(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tier := Tier{Rate: 2, Burst: 3}
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		d := limiter.Allow("client", tier, now)
+		if !d.Allowed || d.Limit != 3 || d.Remaining != 2-i {
+			t.Fatalf("request %d: %+v, want allowed with %d remaining", i, d, 2-i)
+		}
+	}
+	d := limiter.Allow("client", tier, now)
+	if d.Allowed || d.Remaining != 0 {
+		t.Fatalf("request over the burst: %+v, want refused", d)
+	}
+	if d.RetryAfter != 500*time.Millisecond || d.Reset != 1500*time.Millisecond {
+		t.Errorf("retry after %s and reset %s, want 500ms and 1.5s", d.RetryAfter, d.Reset)
+	}
+
+	// Other clients have their own buckets, and the bucket refills at the tier's rate
+	if d := limiter.Allow("other", tier, now); !d.Allowed {
+		t.Error("another client should not share the bucket")
+	}
+	if d := limiter.Allow("client", tier, now.Add(500*time.Millisecond)); !d.Allowed {
+		t.Error("a token should have been added after 500ms")
+	}
+
+	// Unlimited tiers are always allowed and report no limit
+	if d := limiter.Allow("client", Tier{}, now); !d.Allowed || d.Limit != 0 {
+		t.Errorf("unlimited tier: %+v", d)
+	}
+}
+
+func TestTierChangeStartsFreshBucket(t *testing.T) {
+	limiter, _ := NewLimiter(DefaultOptions())
+	now := time.Now()
+	limiter.Allow("client", Tier{Rate: 1, Burst: 1}, now)
+	if d := limiter.Allow("client", Tier{Rate: 1, Burst: 1}, now); d.Allowed {
+		t.Fatal("second request in a burst of 1 should be refused")
+	}
+	if d := limiter.Allow("client", Tier{Rate: 10, Burst: 5}, now); !d.Allowed || d.Remaining != 4 {
+		t.Errorf("after a tier change: %+v, want a fresh bucket", d)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	options := DefaultOptions()
+	options.IdleAfter = time.Minute
+	limiter, _ := NewLimiter(options)
+	tier := Tier{Rate: 1, Burst: 10}
+	now := time.Now()
+
+	limiter.Allow("idle", tier, now)
+	limiter.Allow("busy", tier, now.Add(50*time.Second))
+	if removed := limiter.Sweep(now.Add(61 * time.Second)); removed != 1 || limiter.Len() != 1 {
+		t.Errorf("Sweep removed %d, %d left; want 1 and 1", removed, limiter.Len())
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{false, "", "192.0.2.1"},
+		{false, "198.51.100.7", "192.0.2.1"},
+		{true, "203.0.113.9, 198.51.100.7", "198.51.100.7"},
+		{true, "", "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		options := DefaultOptions()
+		options.TrustProxy = tt.trustProxy
+		limiter, _ := NewLimiter(options)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if got := limiter.ClientIP(r); got != tt.want {
+			t.Errorf("ClientIP(trustProxy=%v, %q) = %s, want %s", tt.trustProxy, tt.forwarded, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"weather-service/storage"
+	"weather-service/timeseries"
+)
+
+// Policy says how long each tier of stored data is kept; zero keeps a tier forever
+type Policy struct {
+	Raw       time.Duration // individual observations
+	Hourly    time.Duration // hourly rollups
+	Daily     time.Duration // daily rollups
+	Forecasts time.Duration // latest forecasts, by the time they were updated
+}
+
+// DefaultPolicy keeps raw observations for a week, hourly rollups for 90 days, daily rollups forever
+// and forecasts for two days
+func DefaultPolicy() Policy {
+	return Policy{
+		Raw:       7 * 24 * time.Hour,
+		Hourly:    90 * 24 * time.Hour,
+		Daily:     0,
+		Forecasts: 48 * time.Hour,
+	}
+}
+
+// Report describes what one compaction run wrote and removed
+type Report struct {
+	Started          time.Time `json:"started"`
+	Duration         string    `json:"duration"`
+	HourlyRollups    int       `json:"hourlyRollups"`    // hourly rollups written or refreshed
+	DailyRollups     int       `json:"dailyRollups"`     // daily rollups written or refreshed
+	RawRemoved       int       `json:"rawRemoved"`       // observations past the raw retention
+	HourlyRemoved    int       `json:"hourlyRemoved"`    // hourly rollups past their retention
+	DailyRemoved     int       `json:"dailyRemoved"`     // daily rollups past their retention
+	ForecastsRemoved int       `json:"forecastsRemoved"` // forecasts not updated within their retention
+	Errors           []string  `json:"errors,omitempty"`
+}
+
+// Compactor rolls observations up into hourly and daily aggregates and enforces the retention policy
+type Compactor struct {
+	backend storage.Backend
+	policy  Policy
+
+	lastReport *Report
+	mutex      sync.RWMutex
+}
+
+// NewCompactor creates a compactor for a storage backend
+func NewCompactor(backend storage.Backend, policy Policy) *Compactor {
+	return &Compactor{
+		backend: backend,
+		policy:  policy,
+	}
+}
+
+// Policy returns the retention policy being enforced
+func (c *Compactor) Policy() Policy {
+	return c.policy
+}
+
+// LastReport returns the report of the most recent run, or nil if none has completed
+func (c *Compactor) LastReport() *Report {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.lastReport
+}
+
+// Run performs one compaction. Each tier is rolled up over the whole period the next tier keeps,
+// so data is summarised before it is trimmed, and tiers are only trimmed on whole hours or days so a
+// rebuilt rollup never covers a partial interval.
+func (c *Compactor) Run(now time.Time) Report {
+	started := time.Now()
+	report := Report{Started: now}
+	fail := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		report.Errors = append(report.Errors, message)
+		log.Printf("Compaction error: %s", message)
+	}
+
+	locations, err := c.backend.WeatherLocations()
+	if err != nil {
+		fail("listing locations: %v", err)
+	}
+
+	// Tiers kept forever only need their recent intervals rolled up; older ones were done by earlier runs
+	hourlyWindow := rollupWindow(now, c.policy.Hourly, 90*24*time.Hour)
+	dailyWindow := rollupWindow(now, c.policy.Daily, 365*24*time.Hour)
+
+	// Raw readings older than the hourly tier still feed the daily tier through short-lived hourly rollups
+	hourlyFrom := floor(earliest(rollupWindow(now, c.policy.Raw, 7*24*time.Hour), dailyWindow), time.Hour)
+	hourlyTo := floor(now, time.Hour)
+	dailyFrom := floor(earliest(hourlyWindow, dailyWindow), 24*time.Hour)
+	dailyTo := floor(now, 24*time.Hour)
+
+	for _, location := range locations {
+		// Hourly rollups from raw observations in complete hours
+		observations, err := c.backend.Observations(location, "", hourlyFrom, hourlyTo)
+		if err != nil {
+			fail("reading observations for %s: %v", location, err)
+		} else if hourly := timeseries.Aggregate(observations, time.Hour); len(hourly) > 0 {
+			if err := c.backend.PutRollups(storage.ResolutionHourly, hourly); err != nil {
+				fail("writing hourly rollups for %s: %v", location, err)
+			} else {
+				report.HourlyRollups += len(hourly)
+			}
+		}
+
+		// Daily rollups from hourly rollups in complete days
+		hourly, err := c.backend.Rollups(storage.ResolutionHourly, location, "", dailyFrom, dailyTo)
+		if err != nil {
+			fail("reading hourly rollups for %s: %v", location, err)
+		} else if daily := timeseries.Rollup(hourly, 24*time.Hour); len(daily) > 0 {
+			if err := c.backend.PutRollups(storage.ResolutionDaily, daily); err != nil {
+				fail("writing daily rollups for %s: %v", location, err)
+			} else {
+				report.DailyRollups += len(daily)
+			}
+		}
+	}
+
+	// Enforce retention once the rollups are safe
+	if c.policy.Raw > 0 {
+		if report.RawRemoved, err = c.backend.DeleteObservationsBefore(floor(now.Add(-c.policy.Raw), time.Hour)); err != nil {
+			fail("removing observations: %v", err)
+		}
+	}
+	if c.policy.Hourly > 0 {
+		if report.HourlyRemoved, err = c.backend.DeleteRollupsBefore(storage.ResolutionHourly, floor(now.Add(-c.policy.Hourly), 24*time.Hour)); err != nil {
+			fail("removing hourly rollups: %v", err)
+		}
+	}
+	if c.policy.Daily > 0 {
+		if report.DailyRemoved, err = c.backend.DeleteRollupsBefore(storage.ResolutionDaily, floor(now.Add(-c.policy.Daily), 24*time.Hour)); err != nil {
+			fail("removing daily rollups: %v", err)
+		}
+	}
+	if c.policy.Forecasts > 0 {
+		report.ForecastsRemoved = c.pruneForecasts(now.Add(-c.policy.Forecasts), fail)
+	}
+
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+	log.Printf("Compaction finished: %d hourly and %d daily rollups written; removed %d observations, %d hourly rollups, %d daily rollups, %d forecasts",
+		report.HourlyRollups, report.DailyRollups, report.RawRemoved, report.HourlyRemoved, report.DailyRemoved, report.ForecastsRemoved)
+
+	c.mutex.Lock()
+	c.lastReport = &report
+	c.mutex.Unlock()
+
+	return report
+}
+
+// pruneForecasts removes forecasts that haven't been updated since cutoff
+func (c *Compactor) pruneForecasts(cutoff time.Time, fail func(string, ...interface{})) int {
+	locations, err := c.backend.ForecastLocations()
+	if err != nil {
+		fail("listing forecast locations: %v", err)
+		return 0
+	}
+
+	removed := 0
+	for _, location := range locations {
+		forecasts, err := c.backend.GetForecasts(location)
+		if err != nil {
+			fail("reading forecasts for %s: %v", location, err)
+			continue
+		}
+		for _, forecast := range forecasts {
+			if !forecast.Updated.Before(cutoff) {
+				continue
+			}
+			if err := c.backend.DeleteForecast(location, forecast.Provider); err != nil {
+				fail("removing forecast for %s from %s: %v", location, forecast.Provider, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// rollupWindow returns how far back a tier reaches: its retention, or a fixed lookback for tiers kept forever
+func rollupWindow(now time.Time, retention, lookback time.Duration) time.Time {
+	if retention <= 0 {
+		return now.Add(-lookback)
+	}
+	return now.Add(-retention)
+}
+
+// earliest returns the earlier of two times
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// floor truncates a time to a multiple of d in UTC
+func floor(t time.Time, d time.Duration) time.Time {
+	return t.UTC().Truncate(d)
+}
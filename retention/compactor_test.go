@@ -0,0 +1,122 @@
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"weather-service/models"
+	"weather-service/storage"
+)
+
+var (
+	firstDay = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	now      = time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC)
+)
+
+// seeded returns a memory backend with a reading every half hour from the start of firstDay until now,
+// a forecast updated an hour ago and one updated three days ago
+func seeded(t *testing.T) storage.Backend {
+	t.Helper()
+	backend := storage.NewMemoryBackend()
+	backend.PutWeather(models.WeatherData{Location: "london,uk", Provider: "A", Timestamp: now})
+	for at := firstDay; at.Before(now); at = at.Add(30 * time.Minute) {
+		err := backend.AppendObservation(models.WeatherData{
+			Location:    "london,uk",
+			Provider:    "A",
+			Timestamp:   at,
+			Temperature: float64(at.Day()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "A", Updated: now.Add(-time.Hour)})
+	backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "B", Updated: now.Add(-72 * time.Hour)})
+	return backend
+}
+
+func policy() Policy {
+	return Policy{Raw: 2 * 24 * time.Hour, Hourly: 5 * 24 * time.Hour, Forecasts: 48 * time.Hour}
+}
+
+func TestCompactorRollsUpBeforeTrimming(t *testing.T) {
+	backend := seeded(t)
+	compactor := NewCompactor(backend, policy())
+	if compactor.LastReport() != nil {
+		t.Error("LastReport before any run is not nil")
+	}
+
+	report := compactor.Run(now)
+	if len(report.Errors) != 0 {
+		t.Fatalf("errors: %v", report.Errors)
+	}
+
+	// Every complete hour up to 10:00 today, and every complete day before today
+	if report.HourlyRollups != 9*24+10 || report.DailyRollups != 9 {
+		t.Errorf("wrote %d hourly and %d daily rollups, want 226 and 9", report.HourlyRollups, report.DailyRollups)
+	}
+	// Raw readings before 08 March 10:00, hourly rollups before 05 March
+	if report.RawRemoved != 7*48+20 || report.HourlyRemoved != 4*24 || report.DailyRemoved != 0 {
+		t.Errorf("removed %d observations, %d hourly and %d daily rollups; want 356, 96 and 0",
+			report.RawRemoved, report.HourlyRemoved, report.DailyRemoved)
+	}
+	if report.ForecastsRemoved != 1 {
+		t.Errorf("removed %d forecasts, want the one not updated for three days", report.ForecastsRemoved)
+	}
+	if last := compactor.LastReport(); last == nil || last.RawRemoved != report.RawRemoved {
+		t.Errorf("LastReport = %+v, want the run's report", last)
+	}
+
+	observations, _ := backend.Observations("london,uk", "", firstDay, now)
+	if len(observations) != 2*48+1 || !observations[0].Timestamp.Equal(time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("%d observations left, want 97 from 08 March 10:00", len(observations))
+	}
+	hourly, _ := backend.Rollups(storage.ResolutionHourly, "london,uk", "", firstDay, now)
+	if len(hourly) != 5*24+10 || !hourly[0].Start.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("%d hourly rollups left, want 130 from 05 March", len(hourly))
+	}
+	if forecasts, _ := backend.GetForecasts("london,uk"); len(forecasts) != 1 || forecasts[0].Provider != "A" {
+		t.Errorf("forecasts left = %+v, want A's", forecasts)
+	}
+}
+
+func TestCompactorRerunKeepsCompleteRollups(t *testing.T) {
+	backend := seeded(t)
+	compactor := NewCompactor(backend, policy())
+	compactor.Run(now)
+
+	// A second run rebuilds from what is left and must not replace rollups with partial ones
+	report := compactor.Run(now)
+	if len(report.Errors) != 0 {
+		t.Fatalf("errors: %v", report.Errors)
+	}
+	if report.HourlyRollups != 48 || report.DailyRollups != 5 {
+		t.Errorf("rewrote %d hourly and %d daily rollups, want 48 and 5", report.HourlyRollups, report.DailyRollups)
+	}
+	if report.RawRemoved != 0 || report.HourlyRemoved != 0 || report.ForecastsRemoved != 0 {
+		t.Errorf("second run removed data: %+v", report)
+	}
+
+	daily, _ := backend.Rollups(storage.ResolutionDaily, "london,uk", "", firstDay, now)
+	if len(daily) != 9 {
+		t.Fatalf("got %d daily rollups, want one for each day from 01 to 09 March", len(daily))
+	}
+	for _, d := range daily {
+		if d.Count != 48 || d.Temperature.Avg != float64(d.Start.Day()) {
+			t.Errorf("daily rollup for %s has %d readings averaging %v, want 48 averaging %d",
+				d.Start.Format("2006-01-02"), d.Count, d.Temperature.Avg, d.Start.Day())
+		}
+	}
+}
+
+func TestCompactorKeepsTiersWithoutRetention(t *testing.T) {
+	backend := seeded(t)
+	report := NewCompactor(backend, Policy{}).Run(now)
+
+	if report.RawRemoved != 0 || report.HourlyRemoved != 0 || report.DailyRemoved != 0 || report.ForecastsRemoved != 0 {
+		t.Errorf("removed data with no retention limits: %+v", report)
+	}
+	if observations, _ := backend.Observations("london,uk", "", firstDay, now); len(observations) != 9*48+21 {
+		t.Errorf("%d observations left, want all 453", len(observations))
+	}
+}
@@ -0,0 +1,157 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// formatVersion is the version of the snapshot file layout
+const formatVersion = 1
+
+// Source is anything whose contents can be saved to and restored from a snapshot.
+// Entries keep their original timestamps, so restored data reports its real age.
+type Source interface {
+	// Snapshot returns the contents encoded as JSON
+	Snapshot() (json.RawMessage, error)
+
+	// Restore replaces the contents with a previous snapshot
+	Restore(data json.RawMessage) error
+}
+
+// file is the on-disk snapshot layout
+type file struct {
+	Version  int                        `json:"version"`
+	Taken    time.Time                  `json:"taken"`
+	Sections map[string]json.RawMessage `json:"sections"`
+}
+
+// Manager saves registered sources to a single file and restores them from it
+type Manager struct {
+	path    string
+	sources map[string]Source
+	mutex   sync.Mutex
+}
+
+// NewManager creates a manager that keeps its snapshot at path
+func NewManager(path string) *Manager {
+	return &Manager{
+		path:    path,
+		sources: make(map[string]Source),
+	}
+}
+
+// Register adds a source under a name that must be stable across restarts
+func (m *Manager) Register(name string, source Source) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sources[name] = source
+}
+
+// Save writes every source to the snapshot file. The file is replaced atomically,
+// so a crash during a save leaves the previous snapshot intact.
+func (m *Manager) Save() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := file{
+		Version:  formatVersion,
+		Taken:    time.Now(),
+		Sections: make(map[string]json.RawMessage, len(m.sources)),
+	}
+	for name, source := range m.sources {
+		data, err := source.Snapshot()
+		if err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", name, err)
+		}
+		snapshot.Sections[name] = data
+	}
+
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), filepath.Base(m.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), m.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Load restores every registered source found in the snapshot file and returns when the snapshot was taken.
+// A missing file is not an error; the zero time is returned. Sections that fail to restore are logged and skipped.
+func (m *Manager) Load() (time.Time, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	encoded, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot file
+	if err := json.Unmarshal(encoded, &snapshot); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if snapshot.Version != formatVersion {
+		return time.Time{}, fmt.Errorf("unsupported snapshot version %d", snapshot.Version)
+	}
+
+	names := make([]string, 0, len(m.sources))
+	for name := range m.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, found := snapshot.Sections[name]
+		if !found {
+			continue
+		}
+		if err := m.sources[name].Restore(data); err != nil {
+			log.Printf("Warning: failed to restore %s from snapshot: %v", name, err)
+		}
+	}
+
+	return snapshot.Taken, nil
+}
+
+// Run saves a snapshot every interval until stop is closed; the caller saves the final one on shutdown
+func (m *Manager) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.Save(); err != nil {
+				log.Printf("Error saving snapshot: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// counter is a source holding a single number
+type counter struct {
+	value    int
+	failSave bool
+	restored int
+}
+
+func (c *counter) Snapshot() (json.RawMessage, error) {
+	if c.failSave {
+		return nil, errors.New("not now")
+	}
+	return json.Marshal(c.value)
+}
+
+func (c *counter) Restore(data json.RawMessage) error {
+	c.restored++
+	return json.Unmarshal(data, &c.value)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	saved := NewManager(path)
+	saved.Register("weather", &counter{value: 3})
+	saved.Register("forecast", &counter{value: 5})
+	before := time.Now()
+	if err := saved.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	weather, forecast, unknown := &counter{}, &counter{}, &counter{value: 9}
+	loaded := NewManager(path)
+	loaded.Register("weather", weather)
+	loaded.Register("forecast", forecast)
+	loaded.Register("alerts", unknown)
+
+	taken, err := loaded.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if taken.Before(before) || taken.After(time.Now()) {
+		t.Errorf("taken = %v, want the time of the save", taken)
+	}
+	if weather.value != 3 || forecast.value != 5 {
+		t.Errorf("restored weather %d and forecast %d, want 3 and 5", weather.value, forecast.value)
+	}
+	// A source missing from the snapshot is left as it is
+	if unknown.restored != 0 || unknown.value != 9 {
+		t.Errorf("source missing from the snapshot was restored to %d", unknown.value)
+	}
+
+	// No temporary files are left beside the snapshot
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the snapshot", len(entries))
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	manager := NewManager(filepath.Join(t.TempDir(), "snapshot.json"))
+	source := &counter{}
+	manager.Register("weather", source)
+
+	taken, err := manager.Load()
+	if err != nil || !taken.IsZero() {
+		t.Errorf("Load = %v, %v; want the zero time and no error", taken, err)
+	}
+	if source.restored != 0 {
+		t.Error("source restored without a snapshot")
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"malformed": `{"version": 1, "sections": `,
+		"future":    `{"version": 2, "sections": {"weather": 3}}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		manager := NewManager(path)
+		source := &counter{}
+		manager.Register("weather", source)
+		if _, err := manager.Load(); err == nil {
+			t.Errorf("Load of a %s snapshot succeeded", name)
+		}
+		if source.restored != 0 {
+			t.Errorf("source restored from a %s snapshot", name)
+		}
+	}
+}
+
+func TestLoadSkipsSectionsThatFail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	content := `{"version": 1, "taken": "2024-03-10T12:00:00Z", "sections": {"forecast": "five", "weather": 3}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	weather, forecast := &counter{}, &counter{}
+	manager := NewManager(path)
+	manager.Register("weather", weather)
+	manager.Register("forecast", forecast)
+
+	taken, err := manager.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !taken.Equal(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("taken = %v", taken)
+	}
+	if forecast.restored != 1 || weather.value != 3 {
+		t.Errorf("weather = %d after a failed forecast section, want 3", weather.value)
+	}
+}
+
+func TestFailedSaveKeepsPreviousSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	source := &counter{value: 3}
+	manager := NewManager(path)
+	manager.Register("weather", source)
+	if err := manager.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	source.value, source.failSave = 4, true
+	if err := manager.Save(); err == nil {
+		t.Fatal("Save succeeded with a failing source")
+	}
+
+	restored := &counter{}
+	loader := NewManager(path)
+	loader.Register("weather", restored)
+	if _, err := loader.Load(); err != nil || restored.value != 3 {
+		t.Errorf("Load = %d, %v; want the earlier snapshot's 3", restored.value, err)
+	}
+
+	if err := NewManager(filepath.Join(t.TempDir(), "missing", "snapshot.json")).Save(); err == nil {
+		t.Error("Save into a missing directory succeeded")
+	}
+}
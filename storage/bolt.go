@@ -0,0 +1,458 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"weather-service/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// SchemaVersion is the layout version written by this code. Bump it and add a migration
+// to boltMigrations whenever the bucket layout or stored encoding changes.
+const SchemaVersion = 3
+
+// Bucket names; weather and forecast buckets hold one nested bucket per location, keyed by provider.
+// The observations bucket nests location, then provider, keyed by big-endian Unix nanoseconds.
+// The rollups bucket nests resolution, then location and provider, keyed by interval start the same way.
+var (
+	metaBucket        = []byte("meta")
+	weatherBucket     = []byte("weather")
+	forecastBucket    = []byte("forecasts")
+	observationBucket = []byte("observations")
+	rollupBucket      = []byte("rollups")
+	schemaVersionKey  = []byte("schema_version")
+)
+
+// boltMigrations upgrade the database one version at a time; entry i migrates from version i to i+1.
+// Version 0 is an empty database.
+var boltMigrations = []func(tx *bolt.Tx) error{
+	// 0 -> 1: create the initial buckets
+	func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{weatherBucket, forecastBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+
+	// 1 -> 2: add the observation time series
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(observationBucket)
+		return err
+	},
+
+	// 2 -> 3: add rollups of the time series
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(rollupBucket)
+		return err
+	},
+}
+
+// BoltBackend stores data in an embedded bbolt database file
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+// OpenBoltBackend opens or creates the database at path and migrates it to the current schema
+func OpenBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+
+	backend := &BoltBackend{db: db}
+	if err := backend.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+// SchemaVersion returns the schema version recorded in the database
+func (b *BoltBackend) SchemaVersion() (int, error) {
+	version := 0
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = readSchemaVersion(tx)
+		return err
+	})
+	return version, err
+}
+
+// migrate applies any migrations the database hasn't seen, refusing databases written by newer code
+func (b *BoltBackend) migrate() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		version, err := readSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if version > SchemaVersion {
+			return fmt.Errorf("database schema version %d is newer than supported version %d", version, SchemaVersion)
+		}
+
+		for ; version < SchemaVersion; version++ {
+			if err := boltMigrations[version](tx); err != nil {
+				return fmt.Errorf("failed to migrate database from schema version %d: %w", version, err)
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, []byte(strconv.Itoa(SchemaVersion)))
+	})
+}
+
+// readSchemaVersion returns the recorded schema version, or 0 for a new database
+func readSchemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket(metaBucket)
+	if meta == nil {
+		return 0, nil
+	}
+	value := meta.Get(schemaVersionKey)
+	if value == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", value, err)
+	}
+	return version, nil
+}
+
+// put stores a JSON value under bucket/location/provider
+func (b *BoltBackend) put(bucket []byte, location, provider string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s for %s: %w", bucket, location, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		locationBucket, err := tx.Bucket(bucket).CreateBucketIfNotExists([]byte(location))
+		if err != nil {
+			return err
+		}
+		return locationBucket.Put([]byte(provider), encoded)
+	})
+}
+
+// each calls fn with the JSON value of every provider stored for a location, in provider order
+func (b *BoltBackend) each(bucket []byte, location string, fn func(value []byte) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		locationBucket := tx.Bucket(bucket).Bucket([]byte(location))
+		if locationBucket == nil {
+			return nil
+		}
+		return locationBucket.ForEach(func(_, value []byte) error {
+			return fn(value)
+		})
+	})
+}
+
+// locations returns the nested bucket names of a top-level bucket, in order
+func (b *BoltBackend) locations(bucket []byte) ([]string, error) {
+	locations := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(key, value []byte) error {
+			// Nested buckets have a nil value
+			if value == nil {
+				locations = append(locations, string(key))
+			}
+			return nil
+		})
+	})
+	return locations, err
+}
+
+// PutWeather stores a reading, replacing any earlier one from the same provider
+func (b *BoltBackend) PutWeather(data models.WeatherData) error {
+	return b.put(weatherBucket, data.Location, data.Provider, data)
+}
+
+// GetWeather returns the latest reading from every provider for a location, ordered by provider
+func (b *BoltBackend) GetWeather(location string) ([]models.WeatherData, error) {
+	data := []models.WeatherData{}
+	err := b.each(weatherBucket, location, func(value []byte) error {
+		var d models.WeatherData
+		if err := json.Unmarshal(value, &d); err != nil {
+			return fmt.Errorf("failed to decode weather for %s: %w", location, err)
+		}
+		data = append(data, d)
+		return nil
+	})
+	return data, err
+}
+
+// WeatherLocations returns every location with weather data
+func (b *BoltBackend) WeatherLocations() ([]string, error) {
+	return b.locations(weatherBucket)
+}
+
+// AppendObservation adds a reading to the time series, replacing one with the same provider and timestamp
+func (b *BoltBackend) AppendObservation(data models.WeatherData) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode observation for %s: %w", data.Location, err)
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		locationBucket, err := tx.Bucket(observationBucket).CreateBucketIfNotExists([]byte(data.Location))
+		if err != nil {
+			return err
+		}
+		providerBucket, err := locationBucket.CreateBucketIfNotExists([]byte(data.Provider))
+		if err != nil {
+			return err
+		}
+		return providerBucket.Put(timeKey(data.Timestamp), encoded)
+	})
+}
+
+// Observations returns the readings for a location in a time range, ordered by time and provider
+func (b *BoltBackend) Observations(location, provider string, from, to time.Time) ([]models.WeatherData, error) {
+	observations := []models.WeatherData{}
+	start, end := timeKey(from), timeKey(to)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		locationBucket := tx.Bucket(observationBucket).Bucket([]byte(location))
+		if locationBucket == nil {
+			return nil
+		}
+
+		return locationBucket.ForEach(func(name, value []byte) error {
+			if value != nil || (provider != "" && string(name) != provider) {
+				return nil
+			}
+
+			cursor := locationBucket.Bucket(name).Cursor()
+			for key, encoded := cursor.Seek(start); key != nil && bytes.Compare(key, end) < 0; key, encoded = cursor.Next() {
+				var data models.WeatherData
+				if err := json.Unmarshal(encoded, &data); err != nil {
+					return fmt.Errorf("failed to decode observation for %s: %w", location, err)
+				}
+				observations = append(observations, data)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortObservations(observations)
+	return observations, nil
+}
+
+// DeleteObservationsBefore removes readings older than cutoff from every time series
+func (b *BoltBackend) DeleteObservationsBefore(cutoff time.Time) (int, error) {
+	removed := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		removed, err = deleteSeriesBefore(tx.Bucket(observationBucket), timeKey(cutoff))
+		return err
+	})
+	return removed, err
+}
+
+// PutRollups stores aggregates at a resolution, replacing existing ones with the same key
+func (b *BoltBackend) PutRollups(resolution string, aggregates []models.ObservationAggregate) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		resolutionBucket, err := tx.Bucket(rollupBucket).CreateBucketIfNotExists([]byte(resolution))
+		if err != nil {
+			return err
+		}
+
+		for _, a := range aggregates {
+			encoded, err := json.Marshal(a)
+			if err != nil {
+				return fmt.Errorf("failed to encode rollup for %s: %w", a.Location, err)
+			}
+			locationBucket, err := resolutionBucket.CreateBucketIfNotExists([]byte(a.Location))
+			if err != nil {
+				return err
+			}
+			providerBucket, err := locationBucket.CreateBucketIfNotExists([]byte(a.Provider))
+			if err != nil {
+				return err
+			}
+			if err := providerBucket.Put(timeKey(a.Start), encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Rollups returns the aggregates at a resolution for a location in a time range
+func (b *BoltBackend) Rollups(resolution, location, provider string, from, to time.Time) ([]models.ObservationAggregate, error) {
+	aggregates := []models.ObservationAggregate{}
+	start, end := timeKey(from), timeKey(to)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		resolutionBucket := tx.Bucket(rollupBucket).Bucket([]byte(resolution))
+		if resolutionBucket == nil {
+			return nil
+		}
+		locationBucket := resolutionBucket.Bucket([]byte(location))
+		if locationBucket == nil {
+			return nil
+		}
+
+		return locationBucket.ForEach(func(name, value []byte) error {
+			if value != nil || (provider != "" && string(name) != provider) {
+				return nil
+			}
+
+			cursor := locationBucket.Bucket(name).Cursor()
+			for key, encoded := cursor.Seek(start); key != nil && bytes.Compare(key, end) < 0; key, encoded = cursor.Next() {
+				var a models.ObservationAggregate
+				if err := json.Unmarshal(encoded, &a); err != nil {
+					return fmt.Errorf("failed to decode rollup for %s: %w", location, err)
+				}
+				aggregates = append(aggregates, a)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortAggregates(aggregates)
+	return aggregates, nil
+}
+
+// DeleteRollupsBefore removes aggregates at a resolution that start before cutoff
+func (b *BoltBackend) DeleteRollupsBefore(resolution string, cutoff time.Time) (int, error) {
+	removed := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		resolutionBucket := tx.Bucket(rollupBucket).Bucket([]byte(resolution))
+		if resolutionBucket == nil {
+			return nil
+		}
+		var err error
+		removed, err = deleteSeriesBefore(resolutionBucket, timeKey(cutoff))
+		return err
+	})
+	return removed, err
+}
+
+// deleteSeriesBefore removes keys before cutoff from every location/provider series under parent
+func deleteSeriesBefore(parent *bolt.Bucket, cutoff []byte) (int, error) {
+	removed := 0
+	err := parent.ForEach(func(location, value []byte) error {
+		if value != nil {
+			return nil
+		}
+		locationBucket := parent.Bucket(location)
+
+		return locationBucket.ForEach(func(provider, value []byte) error {
+			if value != nil {
+				return nil
+			}
+			series := locationBucket.Bucket(provider)
+
+			// Collect keys first; deleting while iterating a cursor skips entries
+			var expired [][]byte
+			cursor := series.Cursor()
+			for key, _ := cursor.First(); key != nil && bytes.Compare(key, cutoff) < 0; key, _ = cursor.Next() {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+			for _, key := range expired {
+				if err := series.Delete(key); err != nil {
+					return err
+				}
+			}
+			removed += len(expired)
+			return nil
+		})
+	})
+	return removed, err
+}
+
+// timeKey encodes a time so that keys sort chronologically
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// PutForecast stores a forecast, replacing any earlier one from the same provider
+func (b *BoltBackend) PutForecast(data models.ForecastData) error {
+	return b.put(forecastBucket, data.Location, data.Provider, data)
+}
+
+// GetForecasts returns the forecast from every provider for a location, ordered by provider
+func (b *BoltBackend) GetForecasts(location string) ([]models.ForecastData, error) {
+	forecasts := []models.ForecastData{}
+	err := b.each(forecastBucket, location, func(value []byte) error {
+		var f models.ForecastData
+		if err := json.Unmarshal(value, &f); err != nil {
+			return fmt.Errorf("failed to decode forecast for %s: %w", location, err)
+		}
+		forecasts = append(forecasts, f)
+		return nil
+	})
+	return forecasts, err
+}
+
+// GetForecast returns the forecast from one provider for a location
+func (b *BoltBackend) GetForecast(location, provider string) (models.ForecastData, bool, error) {
+	var forecast models.ForecastData
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		locationBucket := tx.Bucket(forecastBucket).Bucket([]byte(location))
+		if locationBucket == nil {
+			return nil
+		}
+		value := locationBucket.Get([]byte(provider))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &forecast)
+	})
+	if err != nil {
+		return models.ForecastData{}, false, fmt.Errorf("failed to read forecast for %s from %s: %w", location, provider, err)
+	}
+	return forecast, found, nil
+}
+
+// ForecastLocations returns every location with forecast data
+func (b *BoltBackend) ForecastLocations() ([]string, error) {
+	return b.locations(forecastBucket)
+}
+
+// DeleteForecast removes the forecast from one provider, and the location once it has none left
+func (b *BoltBackend) DeleteForecast(location, provider string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		forecasts := tx.Bucket(forecastBucket)
+		locationBucket := forecasts.Bucket([]byte(location))
+		if locationBucket == nil {
+			return nil
+		}
+		if err := locationBucket.Delete([]byte(provider)); err != nil {
+			return err
+		}
+		if key, _ := locationBucket.Cursor().First(); key == nil {
+			return forecasts.DeleteBucket([]byte(location))
+		}
+		return nil
+	})
+}
+
+// Close closes the database file
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+// Ensure BoltBackend implements Backend
+var _ Backend = (*BoltBackend)(nil)
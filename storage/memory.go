@@ -0,0 +1,345 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// MemoryBackend keeps all data in maps; everything is lost on restart
+type MemoryBackend struct {
+	weather   map[string]map[string]models.WeatherData          // key is location, then provider
+	forecasts map[string]map[string]models.ForecastData         // key is location, then provider
+	series    map[string]map[string][]models.WeatherData        // key is location, then provider; ordered by time
+	rollups   map[string]map[string]models.ObservationAggregate // key is resolution, then location|provider|start
+	mutex     sync.RWMutex
+}
+
+// NewMemoryBackend creates a new in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		weather:   make(map[string]map[string]models.WeatherData),
+		forecasts: make(map[string]map[string]models.ForecastData),
+		series:    make(map[string]map[string][]models.WeatherData),
+		rollups:   make(map[string]map[string]models.ObservationAggregate),
+	}
+}
+
+// PutWeather stores a reading, replacing any earlier one from the same provider
+func (m *MemoryBackend) PutWeather(data models.WeatherData) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.weather[data.Location]; !exists {
+		m.weather[data.Location] = make(map[string]models.WeatherData)
+	}
+	m.weather[data.Location][data.Provider] = data
+	return nil
+}
+
+// GetWeather returns the latest reading from every provider for a location, ordered by provider
+func (m *MemoryBackend) GetWeather(location string) ([]models.WeatherData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	providers := m.weather[location]
+	data := make([]models.WeatherData, 0, len(providers))
+	for _, d := range providers {
+		data = append(data, d)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Provider < data[j].Provider
+	})
+	return data, nil
+}
+
+// WeatherLocations returns every location with weather data
+func (m *MemoryBackend) WeatherLocations() ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	locations := make([]string, 0, len(m.weather))
+	for location := range m.weather {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	return locations, nil
+}
+
+// AppendObservation adds a reading to the time series, keeping it ordered by time
+func (m *MemoryBackend) AppendObservation(data models.WeatherData) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.series[data.Location]; !exists {
+		m.series[data.Location] = make(map[string][]models.WeatherData)
+	}
+	series := m.series[data.Location][data.Provider]
+
+	// Readings almost always arrive in order, so search from the end
+	i := len(series)
+	for i > 0 && series[i-1].Timestamp.After(data.Timestamp) {
+		i--
+	}
+	if i > 0 && series[i-1].Timestamp.Equal(data.Timestamp) {
+		series[i-1] = data
+		return nil
+	}
+
+	series = append(series, models.WeatherData{})
+	copy(series[i+1:], series[i:])
+	series[i] = data
+	m.series[data.Location][data.Provider] = series
+	return nil
+}
+
+// Observations returns the readings for a location in a time range, ordered by time and provider
+func (m *MemoryBackend) Observations(location, provider string, from, to time.Time) ([]models.WeatherData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	observations := []models.WeatherData{}
+	for name, series := range m.series[location] {
+		if provider != "" && name != provider {
+			continue
+		}
+		start := sort.Search(len(series), func(i int) bool {
+			return !series[i].Timestamp.Before(from)
+		})
+		for _, data := range series[start:] {
+			if !data.Timestamp.Before(to) {
+				break
+			}
+			observations = append(observations, data)
+		}
+	}
+
+	sortObservations(observations)
+	return observations, nil
+}
+
+// DeleteObservationsBefore removes readings older than cutoff from every time series
+func (m *MemoryBackend) DeleteObservationsBefore(cutoff time.Time) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := 0
+	for location, providers := range m.series {
+		for provider, series := range providers {
+			keep := sort.Search(len(series), func(i int) bool {
+				return !series[i].Timestamp.Before(cutoff)
+			})
+			if keep == 0 {
+				continue
+			}
+			removed += keep
+
+			// Copy the remainder so the removed readings can be garbage collected
+			if keep == len(series) {
+				delete(providers, provider)
+			} else {
+				providers[provider] = append([]models.WeatherData(nil), series[keep:]...)
+			}
+		}
+		if len(providers) == 0 {
+			delete(m.series, location)
+		}
+	}
+	return removed, nil
+}
+
+// PutRollups stores aggregates at a resolution, replacing existing ones with the same key
+func (m *MemoryBackend) PutRollups(resolution string, aggregates []models.ObservationAggregate) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.rollups[resolution]; !exists {
+		m.rollups[resolution] = make(map[string]models.ObservationAggregate)
+	}
+	for _, a := range aggregates {
+		m.rollups[resolution][rollupKey(a)] = a
+	}
+	return nil
+}
+
+// Rollups returns the aggregates at a resolution for a location in a time range
+func (m *MemoryBackend) Rollups(resolution, location, provider string, from, to time.Time) ([]models.ObservationAggregate, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	aggregates := []models.ObservationAggregate{}
+	for _, a := range m.rollups[resolution] {
+		if a.Location != location || (provider != "" && a.Provider != provider) {
+			continue
+		}
+		if a.Start.Before(from) || !a.Start.Before(to) {
+			continue
+		}
+		aggregates = append(aggregates, a)
+	}
+
+	sortAggregates(aggregates)
+	return aggregates, nil
+}
+
+// DeleteRollupsBefore removes aggregates at a resolution that start before cutoff
+func (m *MemoryBackend) DeleteRollupsBefore(resolution string, cutoff time.Time) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := 0
+	for key, a := range m.rollups[resolution] {
+		if a.Start.Before(cutoff) {
+			delete(m.rollups[resolution], key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// rollupKey identifies an aggregate within a resolution
+func rollupKey(a models.ObservationAggregate) string {
+	return a.Location + "|" + a.Provider + "|" + a.Start.UTC().Format(time.RFC3339)
+}
+
+// PutForecast stores a forecast, replacing any earlier one from the same provider
+func (m *MemoryBackend) PutForecast(data models.ForecastData) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.forecasts[data.Location]; !exists {
+		m.forecasts[data.Location] = make(map[string]models.ForecastData)
+	}
+	m.forecasts[data.Location][data.Provider] = data
+	return nil
+}
+
+// GetForecasts returns the forecast from every provider for a location, ordered by provider
+func (m *MemoryBackend) GetForecasts(location string) ([]models.ForecastData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	providers := m.forecasts[location]
+	forecasts := make([]models.ForecastData, 0, len(providers))
+	for _, f := range providers {
+		forecasts = append(forecasts, f)
+	}
+	sort.Slice(forecasts, func(i, j int) bool {
+		return forecasts[i].Provider < forecasts[j].Provider
+	})
+	return forecasts, nil
+}
+
+// GetForecast returns the forecast from one provider for a location
+func (m *MemoryBackend) GetForecast(location, provider string) (models.ForecastData, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	forecast, exists := m.forecasts[location][provider]
+	return forecast, exists, nil
+}
+
+// ForecastLocations returns every location with forecast data
+func (m *MemoryBackend) ForecastLocations() ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	locations := make([]string, 0, len(m.forecasts))
+	for location := range m.forecasts {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	return locations, nil
+}
+
+// DeleteForecast removes the forecast from one provider, and the location once it has none left
+func (m *MemoryBackend) DeleteForecast(location, provider string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.forecasts[location], provider)
+	if len(m.forecasts[location]) == 0 {
+		delete(m.forecasts, location)
+	}
+	return nil
+}
+
+// Close does nothing for the memory backend
+func (m *MemoryBackend) Close() error {
+	return nil
+}
+
+// Ensure MemoryBackend implements Backend
+var _ Backend = (*MemoryBackend)(nil)
+
+// memorySnapshot is the serialised form of a MemoryBackend
+type memorySnapshot struct {
+	Weather      []models.WeatherData                     `json:"weather"`
+	Forecasts    []models.ForecastData                    `json:"forecasts"`
+	Observations []models.WeatherData                     `json:"observations"`
+	Rollups      map[string][]models.ObservationAggregate `json:"rollups"`
+}
+
+// Snapshot encodes the backend's contents as JSON
+func (m *MemoryBackend) Snapshot() (json.RawMessage, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	snapshot := memorySnapshot{
+		Rollups: make(map[string][]models.ObservationAggregate),
+	}
+	for _, providers := range m.weather {
+		for _, data := range providers {
+			snapshot.Weather = append(snapshot.Weather, data)
+		}
+	}
+	for _, providers := range m.forecasts {
+		for _, forecast := range providers {
+			snapshot.Forecasts = append(snapshot.Forecasts, forecast)
+		}
+	}
+	for _, providers := range m.series {
+		for _, series := range providers {
+			snapshot.Observations = append(snapshot.Observations, series...)
+		}
+	}
+	for resolution, aggregates := range m.rollups {
+		for _, a := range aggregates {
+			snapshot.Rollups[resolution] = append(snapshot.Rollups[resolution], a)
+		}
+	}
+
+	return json.Marshal(snapshot)
+}
+
+// Restore replaces the backend's contents with a snapshot
+func (m *MemoryBackend) Restore(data json.RawMessage) error {
+	var snapshot memorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse storage snapshot: %w", err)
+	}
+
+	restored := NewMemoryBackend()
+	for _, d := range snapshot.Weather {
+		restored.PutWeather(d)
+	}
+	for _, f := range snapshot.Forecasts {
+		restored.PutForecast(f)
+	}
+	for _, d := range snapshot.Observations {
+		restored.AppendObservation(d)
+	}
+	for resolution, aggregates := range snapshot.Rollups {
+		restored.PutRollups(resolution, aggregates)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.weather = restored.weather
+	m.forecasts = restored.forecasts
+	m.series = restored.series
+	m.rollups = restored.rollups
+	return nil
+}
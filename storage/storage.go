@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"weather-service/models"
+)
+
+// WeatherBackend persists the latest weather reading for each location and provider
+type WeatherBackend interface {
+	// PutWeather stores a reading, replacing any earlier one from the same provider for the location
+	PutWeather(data models.WeatherData) error
+
+	// GetWeather returns the latest reading from every provider for a location, ordered by provider
+	GetWeather(location string) ([]models.WeatherData, error)
+
+	// WeatherLocations returns every location with weather data
+	WeatherLocations() ([]string, error)
+
+	// AppendObservation adds a reading to the location's time series; a reading with the same
+	// provider and timestamp as an existing one replaces it
+	AppendObservation(data models.WeatherData) error
+
+	// Observations returns the readings for a location with from <= timestamp < to, ordered by
+	// time and then provider; an empty provider matches every provider
+	Observations(location, provider string, from, to time.Time) ([]models.WeatherData, error)
+
+	// DeleteObservationsBefore removes readings older than cutoff from every time series and returns how many were removed
+	DeleteObservationsBefore(cutoff time.Time) (int, error)
+
+	// PutRollups stores aggregates at a resolution, replacing any with the same location, provider and start
+	PutRollups(resolution string, aggregates []models.ObservationAggregate) error
+
+	// Rollups returns the aggregates at a resolution for a location that start in [from, to),
+	// ordered by start and then provider; an empty provider matches every provider
+	Rollups(resolution, location, provider string, from, to time.Time) ([]models.ObservationAggregate, error)
+
+	// DeleteRollupsBefore removes aggregates at a resolution that start before cutoff and returns how many were removed
+	DeleteRollupsBefore(resolution string, cutoff time.Time) (int, error)
+}
+
+// Resolutions of stored rollups
+const (
+	ResolutionHourly = "hourly"
+	ResolutionDaily  = "daily"
+)
+
+// ForecastBackend persists the latest forecast for each location and provider
+type ForecastBackend interface {
+	// PutForecast stores a forecast, replacing any earlier one from the same provider for the location
+	PutForecast(data models.ForecastData) error
+
+	// GetForecasts returns the forecast from every provider for a location, ordered by provider
+	GetForecasts(location string) ([]models.ForecastData, error)
+
+	// GetForecast returns the forecast from one provider for a location
+	GetForecast(location, provider string) (models.ForecastData, bool, error)
+
+	// ForecastLocations returns every location with forecast data
+	ForecastLocations() ([]string, error)
+
+	// DeleteForecast removes the forecast from one provider for a location
+	DeleteForecast(location, provider string) error
+}
+
+// Backend stores weather and forecast data
+type Backend interface {
+	WeatherBackend
+	ForecastBackend
+
+	// Close releases any resources held by the backend
+	Close() error
+}
+
+// Backend names accepted by Open
+const (
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+// Open creates the named backend; path is the database file for on-disk backends
+func Open(backend, path string) (Backend, error) {
+	switch backend {
+	case "", BackendMemory:
+		return NewMemoryBackend(), nil
+	case BackendBolt:
+		if path == "" {
+			return nil, fmt.Errorf("storage backend %q requires a path", backend)
+		}
+		return OpenBoltBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// sortObservations orders readings by time and then provider
+func sortObservations(observations []models.WeatherData) {
+	sort.Slice(observations, func(i, j int) bool {
+		a, b := observations[i], observations[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.Provider < b.Provider
+	})
+}
+
+// sortAggregates orders aggregates by start and then provider
+func sortAggregates(aggregates []models.ObservationAggregate) {
+	sort.Slice(aggregates, func(i, j int) bool {
+		a, b := aggregates[i], aggregates[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		return a.Provider < b.Provider
+	})
+}
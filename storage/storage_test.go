@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"weather-service/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var start = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// backends returns a fresh memory backend and a fresh bolt backend in a temporary directory
+func backends(t *testing.T) map[string]Backend {
+	t.Helper()
+	onDisk, err := OpenBoltBackend(filepath.Join(t.TempDir(), "weather.db"))
+	if err != nil {
+		t.Fatalf("OpenBoltBackend: %v", err)
+	}
+	t.Cleanup(func() { onDisk.Close() })
+	return map[string]Backend{BackendMemory: NewMemoryBackend(), BackendBolt: onDisk}
+}
+
+// reading returns a reading from a provider hours after start
+func reading(location, provider string, hours int, temperature float64) models.WeatherData {
+	return models.WeatherData{
+		Location:    location,
+		Provider:    provider,
+		Timestamp:   start.Add(time.Duration(hours) * time.Hour),
+		Temperature: temperature,
+	}
+}
+
+// aggregate returns an hourly aggregate from a provider hours after start
+func aggregate(location, provider string, hours, count int) models.ObservationAggregate {
+	at := start.Add(time.Duration(hours) * time.Hour)
+	return models.ObservationAggregate{Location: location, Provider: provider, Start: at, End: at.Add(time.Hour), Count: count}
+}
+
+// describe lists the provider and hour of each reading
+func describe(observations []models.WeatherData) []string {
+	var described []string
+	for _, o := range observations {
+		described = append(described, o.Provider+"@"+o.Timestamp.Sub(start).String())
+	}
+	return described
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLatestWeather(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			backend.PutWeather(reading("paris,fr", "B", 0, 8))
+			backend.PutWeather(reading("london,uk", "B", 0, 9))
+			backend.PutWeather(reading("london,uk", "A", 0, 10))
+			backend.PutWeather(reading("london,uk", "A", 1, 11))
+
+			data, err := backend.GetWeather("london,uk")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != 2 || data[0].Provider != "A" || data[0].Temperature != 11 || data[1].Provider != "B" {
+				t.Errorf("GetWeather = %+v, want the latest from A then B", data)
+			}
+
+			if data, err := backend.GetWeather("berlin,de"); err != nil || len(data) != 0 {
+				t.Errorf("GetWeather of an unknown location = %+v, %v", data, err)
+			}
+
+			locations, err := backend.WeatherLocations()
+			if err != nil || !equal(locations, []string{"london,uk", "paris,fr"}) {
+				t.Errorf("WeatherLocations = %v, %v", locations, err)
+			}
+		})
+	}
+}
+
+func TestForecasts(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "B"})
+			backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "A", Forecasts: []models.Forecast{{Temperature: 1}}})
+			backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "A", Forecasts: []models.Forecast{{Temperature: 2}}})
+
+			forecasts, err := backend.GetForecasts("london,uk")
+			if err != nil || len(forecasts) != 2 || forecasts[0].Provider != "A" || forecasts[1].Provider != "B" {
+				t.Fatalf("GetForecasts = %+v, %v", forecasts, err)
+			}
+			if forecasts[0].Forecasts[0].Temperature != 2 {
+				t.Errorf("A's forecast = %+v, want the replacement", forecasts[0])
+			}
+
+			if forecast, found, err := backend.GetForecast("london,uk", "B"); err != nil || !found || forecast.Provider != "B" {
+				t.Errorf("GetForecast B = %+v, %v, %v", forecast, found, err)
+			}
+			if _, found, err := backend.GetForecast("london,uk", "C"); err != nil || found {
+				t.Errorf("GetForecast C = %v, %v; want not found", found, err)
+			}
+
+			// The location is dropped with its last forecast
+			backend.DeleteForecast("london,uk", "A")
+			if locations, _ := backend.ForecastLocations(); !equal(locations, []string{"london,uk"}) {
+				t.Errorf("ForecastLocations after deleting A = %v", locations)
+			}
+			backend.DeleteForecast("london,uk", "B")
+			backend.DeleteForecast("paris,fr", "B")
+			if locations, _ := backend.ForecastLocations(); len(locations) != 0 {
+				t.Errorf("ForecastLocations after deleting every forecast = %v", locations)
+			}
+		})
+	}
+}
+
+func TestObservations(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			// Out of order, with a repeated reading that replaces the first
+			for _, r := range []models.WeatherData{
+				reading("london,uk", "A", 2, 12),
+				reading("london,uk", "A", 0, 10),
+				reading("london,uk", "B", 1, 21),
+				reading("london,uk", "A", 1, 11),
+				reading("london,uk", "B", 2, 22),
+				reading("london,uk", "A", 1, 15),
+				reading("paris,fr", "A", 1, 30),
+			} {
+				if err := backend.AppendObservation(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			all, err := backend.Observations("london,uk", "", start, start.Add(3*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"A@0s", "A@1h0m0s", "B@1h0m0s", "A@2h0m0s", "B@2h0m0s"}; !equal(describe(all), want) {
+				t.Errorf("Observations = %v, want %v", describe(all), want)
+			}
+			if all[1].Temperature != 15 {
+				t.Errorf("A at 1h = %v, want the replacement 15", all[1].Temperature)
+			}
+
+			// from is inclusive, to exclusive
+			window, _ := backend.Observations("london,uk", "A", start.Add(time.Hour), start.Add(2*time.Hour))
+			if want := []string{"A@1h0m0s"}; !equal(describe(window), want) {
+				t.Errorf("Observations of A in [1h, 2h) = %v, want %v", describe(window), want)
+			}
+
+			removed, err := backend.DeleteObservationsBefore(start.Add(2 * time.Hour))
+			if err != nil || removed != 4 {
+				t.Errorf("DeleteObservationsBefore = %d, %v; want 4", removed, err)
+			}
+			left, _ := backend.Observations("london,uk", "", start, start.Add(3*time.Hour))
+			if want := []string{"A@2h0m0s", "B@2h0m0s"}; !equal(describe(left), want) {
+				t.Errorf("Observations after deleting = %v, want %v", describe(left), want)
+			}
+			if paris, _ := backend.Observations("paris,fr", "", start, start.Add(3*time.Hour)); len(paris) != 0 {
+				t.Errorf("paris observations = %v, want none", describe(paris))
+			}
+		})
+	}
+}
+
+func TestRollups(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			err := backend.PutRollups(ResolutionHourly, []models.ObservationAggregate{
+				aggregate("london,uk", "B", 0, 1),
+				aggregate("london,uk", "A", 1, 1),
+				aggregate("london,uk", "A", 0, 1),
+				aggregate("paris,fr", "A", 0, 1),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Rebuilding an interval replaces it
+			backend.PutRollups(ResolutionHourly, []models.ObservationAggregate{aggregate("london,uk", "A", 0, 5)})
+			backend.PutRollups(ResolutionDaily, []models.ObservationAggregate{aggregate("london,uk", "A", 0, 24)})
+
+			rollups, err := backend.Rollups(ResolutionHourly, "london,uk", "", start, start.Add(2*time.Hour))
+			if err != nil || len(rollups) != 3 {
+				t.Fatalf("Rollups = %+v, %v", rollups, err)
+			}
+			if rollups[0].Provider != "A" || rollups[0].Count != 5 || rollups[1].Provider != "B" || !rollups[2].Start.Equal(start.Add(time.Hour)) {
+				t.Errorf("Rollups = %+v, want A and B at 0h then A at 1h", rollups)
+			}
+			if b, _ := backend.Rollups(ResolutionHourly, "london,uk", "B", start, start.Add(time.Hour)); len(b) != 1 {
+				t.Errorf("Rollups of B = %+v", b)
+			}
+			if none, _ := backend.Rollups("weekly", "london,uk", "", start, start.Add(time.Hour)); len(none) != 0 {
+				t.Errorf("Rollups at an unknown resolution = %+v", none)
+			}
+
+			removed, err := backend.DeleteRollupsBefore(ResolutionHourly, start.Add(time.Hour))
+			if err != nil || removed != 3 {
+				t.Errorf("DeleteRollupsBefore = %d, %v; want 3", removed, err)
+			}
+			if daily, _ := backend.Rollups(ResolutionDaily, "london,uk", "", start, start.Add(time.Hour)); len(daily) != 1 {
+				t.Errorf("daily rollups = %+v, want them left alone", daily)
+			}
+			if removed, err := backend.DeleteRollupsBefore("weekly", start.Add(time.Hour)); err != nil || removed != 0 {
+				t.Errorf("DeleteRollupsBefore at an unknown resolution = %d, %v", removed, err)
+			}
+		})
+	}
+}
+
+func TestBoltBackendPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weather.db")
+	backend, err := OpenBoltBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.PutWeather(reading("london,uk", "A", 0, 10))
+	backend.AppendObservation(reading("london,uk", "A", 0, 10))
+	backend.Close()
+
+	backend, err = OpenBoltBackend(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	if data, _ := backend.GetWeather("london,uk"); len(data) != 1 || data[0].Temperature != 10 {
+		t.Errorf("GetWeather after reopening = %+v", data)
+	}
+	if observations, _ := backend.Observations("london,uk", "", start, start.Add(time.Hour)); len(observations) != 1 {
+		t.Errorf("Observations after reopening = %+v", observations)
+	}
+}
+
+// writeSchema creates a database at path as written by code at the given schema version
+func writeSchema(t *testing.T, path, version string, setup func(tx *bolt.Tx) error) {
+	t.Helper()
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		if err := setup(tx); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(schemaVersionKey, []byte(version))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBoltBackendMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weather.db")
+	writeSchema(t, path, "1", func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucket(forecastBucket); err != nil {
+			return err
+		}
+		weather, err := tx.CreateBucket(weatherBucket)
+		if err != nil {
+			return err
+		}
+		london, err := weather.CreateBucket([]byte("london,uk"))
+		if err != nil {
+			return err
+		}
+		encoded, _ := json.Marshal(reading("london,uk", "A", 0, 10))
+		return london.Put([]byte("A"), encoded)
+	})
+
+	backend, err := OpenBoltBackend(path)
+	if err != nil {
+		t.Fatalf("OpenBoltBackend: %v", err)
+	}
+	defer backend.Close()
+
+	if version, err := backend.SchemaVersion(); err != nil || version != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, %v; want %d", version, err, SchemaVersion)
+	}
+	if data, _ := backend.GetWeather("london,uk"); len(data) != 1 || data[0].Temperature != 10 {
+		t.Errorf("GetWeather after migrating = %+v, want the version 1 reading", data)
+	}
+	if err := backend.AppendObservation(reading("london,uk", "A", 0, 10)); err != nil {
+		t.Errorf("AppendObservation after migrating: %v", err)
+	}
+	if err := backend.PutRollups(ResolutionHourly, []models.ObservationAggregate{aggregate("london,uk", "A", 0, 1)}); err != nil {
+		t.Errorf("PutRollups after migrating: %v", err)
+	}
+}
+
+func TestBoltBackendRefusesUnknownSchemas(t *testing.T) {
+	noBuckets := func(tx *bolt.Tx) error { return nil }
+
+	newer := filepath.Join(t.TempDir(), "newer.db")
+	writeSchema(t, newer, "4", noBuckets)
+	if backend, err := OpenBoltBackend(newer); err == nil {
+		backend.Close()
+		t.Error("OpenBoltBackend accepted a database from newer code")
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.db")
+	writeSchema(t, invalid, "three", noBuckets)
+	if backend, err := OpenBoltBackend(invalid); err == nil {
+		backend.Close()
+		t.Error("OpenBoltBackend accepted an invalid schema version")
+	}
+
+	// The database is closed after a refusal, so it can be opened again at once
+	db, err := bolt.Open(newer, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("database still locked after a refused open: %v", err)
+	}
+	db.Close()
+}
+
+func TestOpen(t *testing.T) {
+	if backend, err := Open("", ""); err != nil {
+		t.Errorf("Open default = %v", err)
+	} else if _, ok := backend.(*MemoryBackend); !ok {
+		t.Errorf("Open default = %T, want the memory backend", backend)
+	}
+
+	if _, err := Open(BackendBolt, ""); err == nil {
+		t.Error("Open bolt without a path succeeded")
+	}
+	if _, err := Open("sqlite", "weather.db"); err == nil {
+		t.Error("Open of an unknown backend succeeded")
+	}
+
+	backend, err := Open(BackendBolt, filepath.Join(t.TempDir(), "weather.db"))
+	if err != nil {
+		t.Fatalf("Open bolt = %v", err)
+	}
+	defer backend.Close()
+	if _, ok := backend.(*BoltBackend); !ok {
+		t.Errorf("Open bolt = %T", backend)
+	}
+}
+
+func TestMemoryBackendSnapshot(t *testing.T) {
+	backend := NewMemoryBackend()
+	backend.PutWeather(reading("london,uk", "A", 0, 10))
+	backend.PutForecast(models.ForecastData{Location: "london,uk", Provider: "A"})
+	backend.AppendObservation(reading("london,uk", "A", 1, 11))
+	backend.AppendObservation(reading("london,uk", "A", 0, 10))
+	backend.PutRollups(ResolutionDaily, []models.ObservationAggregate{aggregate("london,uk", "A", 0, 24)})
+
+	snapshot, err := backend.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewMemoryBackend()
+	restored.PutWeather(reading("paris,fr", "A", 0, 5))
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if locations, _ := restored.WeatherLocations(); !equal(locations, []string{"london,uk"}) {
+		t.Errorf("WeatherLocations = %v, want the snapshot's only", locations)
+	}
+	if _, found, _ := restored.GetForecast("london,uk", "A"); !found {
+		t.Error("forecast not restored")
+	}
+	observations, _ := restored.Observations("london,uk", "A", start, start.Add(2*time.Hour))
+	if want := []string{"A@0s", "A@1h0m0s"}; !equal(describe(observations), want) {
+		t.Errorf("Observations = %v, want %v", describe(observations), want)
+	}
+	if rollups, _ := restored.Rollups(ResolutionDaily, "london,uk", "", start, start.Add(time.Hour)); len(rollups) != 1 {
+		t.Errorf("Rollups = %+v", rollups)
+	}
+
+	if err := restored.Restore(json.RawMessage(`[`)); err == nil {
+		t.Error("Restore accepted malformed JSON")
+	}
+}
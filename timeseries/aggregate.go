@@ -0,0 +1,152 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"weather-service/models"
+)
+
+// accumulator collects min, max and sum for one variable
+type accumulator struct {
+	min, max, sum float64
+	count         int
+}
+
+// add includes a value
+func (a *accumulator) add(v float64) {
+	if a.count == 0 || v < a.min {
+		a.min = v
+	}
+	if a.count == 0 || v > a.max {
+		a.max = v
+	}
+	a.sum += v
+	a.count++
+}
+
+// merge includes a stat that summarised count readings
+func (a *accumulator) merge(s models.Stat, count int) {
+	if count == 0 {
+		return
+	}
+	if a.count == 0 || s.Min < a.min {
+		a.min = s.Min
+	}
+	if a.count == 0 || s.Max > a.max {
+		a.max = s.Max
+	}
+	a.sum += s.Avg * float64(count)
+	a.count += count
+}
+
+// stat returns the summary, rounded to two decimal places
+func (a *accumulator) stat() models.Stat {
+	if a.count == 0 {
+		return models.Stat{}
+	}
+	return models.Stat{
+		Min: round2(a.min),
+		Max: round2(a.max),
+		Avg: round2(a.sum / float64(a.count)),
+	}
+}
+
+// bucket accumulates every variable for one provider and interval
+type bucket struct {
+	aggregate                                                 models.ObservationAggregate
+	temperature, humidity, windSpeed, pressure, precipitation accumulator
+}
+
+// result finalises the aggregate
+func (b *bucket) result() models.ObservationAggregate {
+	aggregate := b.aggregate
+	aggregate.Temperature = b.temperature.stat()
+	aggregate.Humidity = b.humidity.stat()
+	aggregate.WindSpeed = b.windSpeed.stat()
+	aggregate.Pressure = b.pressure.stat()
+	aggregate.Precipitation = b.precipitation.stat()
+	return aggregate
+}
+
+// Aggregate groups readings into intervals aligned to UTC (e.g. whole hours or days) and summarises
+// each provider's readings per interval. The result is ordered by interval start and then provider.
+func Aggregate(observations []models.WeatherData, interval time.Duration) []models.ObservationAggregate {
+	buckets := make(map[string]*bucket)
+	for _, data := range observations {
+		start := data.Timestamp.UTC().Truncate(interval)
+		key := data.Provider + "|" + start.Format(time.RFC3339)
+
+		b, exists := buckets[key]
+		if !exists {
+			b = &bucket{aggregate: models.ObservationAggregate{
+				Provider: data.Provider,
+				Location: data.Location,
+				Start:    start,
+				End:      start.Add(interval),
+			}}
+			buckets[key] = b
+		}
+
+		b.aggregate.Count++
+		b.temperature.add(data.Temperature)
+		b.humidity.add(data.Humidity)
+		b.windSpeed.add(data.WindSpeed)
+		b.pressure.add(data.Pressure)
+		b.precipitation.add(data.Precipitation)
+	}
+
+	return results(buckets)
+}
+
+// Rollup combines aggregates into coarser intervals aligned to UTC, weighting averages by reading count.
+// The interval should be a multiple of the aggregates' own interval.
+func Rollup(aggregates []models.ObservationAggregate, interval time.Duration) []models.ObservationAggregate {
+	buckets := make(map[string]*bucket)
+	for _, a := range aggregates {
+		start := a.Start.UTC().Truncate(interval)
+		key := a.Provider + "|" + start.Format(time.RFC3339)
+
+		b, exists := buckets[key]
+		if !exists {
+			b = &bucket{aggregate: models.ObservationAggregate{
+				Provider: a.Provider,
+				Location: a.Location,
+				Start:    start,
+				End:      start.Add(interval),
+			}}
+			buckets[key] = b
+		}
+
+		b.aggregate.Count += a.Count
+		b.temperature.merge(a.Temperature, a.Count)
+		b.humidity.merge(a.Humidity, a.Count)
+		b.windSpeed.merge(a.WindSpeed, a.Count)
+		b.pressure.merge(a.Pressure, a.Count)
+		b.precipitation.merge(a.Precipitation, a.Count)
+	}
+
+	return results(buckets)
+}
+
+// results returns the finished aggregates in order
+func results(buckets map[string]*bucket) []models.ObservationAggregate {
+	aggregates := make([]models.ObservationAggregate, 0, len(buckets))
+	for _, b := range buckets {
+		aggregates = append(aggregates, b.result())
+	}
+	sort.Slice(aggregates, func(i, j int) bool {
+		a, b := aggregates[i], aggregates[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		return a.Provider < b.Provider
+	})
+	return aggregates
+}
+
+// round2 rounds to two decimal places
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,109 @@
+package timeseries
+
+import (
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+var start = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// reading returns a reading from a provider minutes after start
+func reading(provider string, minutes int, temperature, precipitation float64) models.WeatherData {
+	return models.WeatherData{
+		Provider:      provider,
+		Location:      "london,uk",
+		Timestamp:     start.Add(time.Duration(minutes) * time.Minute),
+		Temperature:   temperature,
+		Humidity:      80,
+		Pressure:      1010,
+		Precipitation: precipitation,
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	zone := time.FixedZone("CET", 60*60)
+	late := reading("A", 75, 4, 0)
+	late.Timestamp = late.Timestamp.In(zone)
+
+	aggregates := Aggregate([]models.WeatherData{
+		reading("B", 10, 7, 0),
+		reading("A", 0, -2, 0.4),
+		reading("A", 30, 3, 0),
+		reading("A", 50, 1.5, 0.2),
+		late,
+	}, time.Hour)
+
+	if len(aggregates) != 3 {
+		t.Fatalf("got %d aggregates, want A and B at 00:00 and A at 01:00: %+v", len(aggregates), aggregates)
+	}
+
+	first := aggregates[0]
+	if first.Provider != "A" || !first.Start.Equal(start) || !first.End.Equal(start.Add(time.Hour)) || first.Count != 3 {
+		t.Errorf("first aggregate = %s %v-%v with %d readings", first.Provider, first.Start, first.End, first.Count)
+	}
+	if want := (models.Stat{Min: -2, Max: 3, Avg: 0.83}); first.Temperature != want {
+		t.Errorf("temperature = %+v, want %+v", first.Temperature, want)
+	}
+	if want := (models.Stat{Min: 0, Max: 0.4, Avg: 0.2}); first.Precipitation != want {
+		t.Errorf("precipitation = %+v, want %+v", first.Precipitation, want)
+	}
+	if want := (models.Stat{Min: 80, Max: 80, Avg: 80}); first.Humidity != want {
+		t.Errorf("humidity = %+v, want %+v", first.Humidity, want)
+	}
+
+	if b := aggregates[1]; b.Provider != "B" || !b.Start.Equal(start) || b.Count != 1 || b.Temperature.Avg != 7 {
+		t.Errorf("second aggregate = %+v, want B's reading", b)
+	}
+
+	// Intervals are aligned to UTC whatever the reading's zone
+	if last := aggregates[2]; !last.Start.Equal(start.Add(time.Hour)) || last.Start.Location() != time.UTC || last.Temperature.Max != 4 {
+		t.Errorf("last aggregate = %+v, want A's reading at 01:15 UTC", last)
+	}
+
+	if empty := Aggregate(nil, time.Hour); empty == nil || len(empty) != 0 {
+		t.Errorf("Aggregate(nil) = %#v, want an empty slice", empty)
+	}
+}
+
+func TestRollupWeightsByCount(t *testing.T) {
+	var readings []models.WeatherData
+	// Three readings in the first hour, one in the second, and one the next day
+	for _, r := range []struct {
+		minutes     int
+		temperature float64
+	}{{0, 2}, {20, 4}, {40, 6}, {60, 12}, {24 * 60, 20}} {
+		readings = append(readings, reading("A", r.minutes, r.temperature, 0))
+	}
+
+	hourly := Aggregate(readings, time.Hour)
+	daily := Rollup(hourly, 24*time.Hour)
+	direct := Aggregate(readings, 24*time.Hour)
+
+	if len(daily) != 2 || len(direct) != 2 {
+		t.Fatalf("got %d rolled up and %d direct daily aggregates, want 2", len(daily), len(direct))
+	}
+	for i := range daily {
+		if daily[i] != direct[i] {
+			t.Errorf("day %d rolled up = %+v, aggregated directly = %+v", i, daily[i], direct[i])
+		}
+	}
+	if first := daily[0]; first.Count != 4 || first.Temperature != (models.Stat{Min: 2, Max: 12, Avg: 6}) {
+		t.Errorf("first day = %+v, want 4 readings averaging 6", first)
+	}
+	if !daily[1].Start.Equal(start.AddDate(0, 0, 1)) || !daily[1].End.Equal(start.AddDate(0, 0, 2)) {
+		t.Errorf("second day = %v-%v", daily[1].Start, daily[1].End)
+	}
+}
+
+func TestRollupSkipsEmptyAggregates(t *testing.T) {
+	empty := models.ObservationAggregate{Provider: "A", Start: start}
+	full := models.ObservationAggregate{Provider: "A", Start: start.Add(time.Hour), Count: 2,
+		Temperature: models.Stat{Min: 5, Max: 9, Avg: 7}}
+
+	rolled := Rollup([]models.ObservationAggregate{empty, full}, 24*time.Hour)
+	if len(rolled) != 1 || rolled[0].Count != 2 || rolled[0].Temperature != full.Temperature {
+		t.Errorf("Rollup = %+v, want the empty aggregate's zero stats ignored", rolled)
+	}
+}
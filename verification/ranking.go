@@ -0,0 +1,99 @@
+package verification
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"weather-service/models"
+)
+
+// precipitationWeight converts the share of wrong rain/no-rain calls into the same scale as temperature MAE,
+// so that missing every call costs as much as a 3°C average temperature error.
+const precipitationWeight = 3.0
+
+// bucketScore is one provider's score within a single lead time bucket
+type bucketScore struct {
+	score, mae, correct float64
+	samples             int
+}
+
+// Rank orders providers for a location by recent forecast skill, best first, for a forecast reaching horizon
+// ahead. Each lead time bucket within the horizon is scored from temperature MAE and the share of wrong
+// rain/no-rain calls, and the buckets count equally, as each covers an equal part of the forecast, so plentiful
+// long-lead samples don't decide the short range. Providers without samples are ranked last, alphabetically,
+// so the result never depends on map order. Any spelling of a location finds its samples.
+func (v *Verifier) Rank(location string, providers []string, horizon time.Duration) []models.ProviderRank {
+	byProvider := make(map[string][]bucketScore)
+	for _, stats := range v.stats(location, "", -1, time.Now().Add(-v.options.Window)) {
+		if time.Duration(stats.LeadFromHours)*time.Hour > horizon {
+			continue
+		}
+		correct := stats.Precipitation.ProportionCorrect
+		byProvider[stats.Provider] = append(byProvider[stats.Provider], bucketScore{
+			score:   stats.Temperature.MAE + precipitationWeight*(1-correct),
+			mae:     stats.Temperature.MAE,
+			correct: correct,
+			samples: stats.Temperature.Samples,
+		})
+	}
+
+	ranks := make([]models.ProviderRank, 0, len(providers))
+	for _, provider := range providers {
+		buckets := byProvider[provider]
+
+		rank := models.ProviderRank{
+			Provider: provider,
+			Buckets:  len(buckets),
+			Score:    math.Inf(1),
+		}
+
+		if len(buckets) > 0 {
+			var score, mae, correct float64
+			for _, b := range buckets {
+				score += b.score
+				mae += b.mae
+				correct += b.correct
+				rank.Samples += b.samples
+			}
+			n := float64(len(buckets))
+			rank.Score = score / n
+			rank.TemperatureMAE = mae / n
+			rank.PrecipitationCorrect = correct / n
+		}
+
+		ranks = append(ranks, rank)
+	}
+
+	sort.SliceStable(ranks, func(i, j int) bool {
+		a, b := ranks[i], ranks[j]
+		if (a.Samples > 0) != (b.Samples > 0) {
+			return a.Samples > 0
+		}
+		if a.Samples > 0 && a.Score != b.Score {
+			return a.Score < b.Score
+		}
+		return a.Provider < b.Provider
+	})
+
+	for i := range ranks {
+		ranks[i].Rank = i + 1
+		ranks[i].Reason = rankReason(ranks[i])
+		if math.IsInf(ranks[i].Score, 1) {
+			// JSON can't encode infinity
+			ranks[i].Score = 0
+		}
+	}
+
+	return ranks
+}
+
+// rankReason explains in plain words why a provider holds its rank
+func rankReason(rank models.ProviderRank) string {
+	if rank.Samples == 0 {
+		return "no verified forecasts yet, ranked alphabetically after scored providers"
+	}
+	return fmt.Sprintf("score %.2f from temperature MAE %.2f°C and %.0f%% of rain/no-rain calls right, averaged over %d lead time buckets with %d verified points",
+		rank.Score, rank.TemperatureMAE, rank.PrecipitationCorrect*100, rank.Buckets, rank.Samples)
+}
@@ -0,0 +1,111 @@
+package verification
+
+import (
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+func TestRank(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	issue := time.Now().Add(-6 * time.Hour).Truncate(time.Hour)
+	for provider, temperature := range map[string]float64{"Good": 10, "Poor": 14} {
+		v.RecordForecast(models.ForecastData{
+			Provider: provider,
+			Location: "London,UK",
+			Updated:  issue,
+			Forecasts: []models.Forecast{
+				{Temperature: temperature, Timestamp: issue.Add(time.Hour)},
+			},
+		})
+	}
+	v.RecordObservation(models.WeatherData{Location: "London,UK", Temperature: 10, Timestamp: issue.Add(time.Hour)})
+	v.RecordObservation(models.WeatherData{Location: "London,UK", Temperature: 10, Timestamp: issue.Add(2 * time.Hour)})
+
+	// Any spelling of the location finds the samples; providers without any come last, alphabetically
+	ranks := v.Rank(" london, UK", []string{"Unscored", "Poor", "Good", "Another"}, 72*time.Hour)
+	want := []string{"Good", "Poor", "Another", "Unscored"}
+	if len(ranks) != len(want) {
+		t.Fatalf("got %d ranks, want %d", len(ranks), len(want))
+	}
+	for i, rank := range ranks {
+		if rank.Provider != want[i] || rank.Rank != i+1 {
+			t.Errorf("rank %d = %s (%d), want %s", i+1, rank.Provider, rank.Rank, want[i])
+		}
+	}
+
+	good, poor := ranks[0], ranks[1]
+	if good.Samples != 1 || good.TemperatureMAE != 0 || good.PrecipitationCorrect != 1 || good.Buckets != 1 || good.Score != 0 {
+		t.Errorf("Good = %+v", good)
+	}
+	if poor.TemperatureMAE != 4 || poor.Score != 4 {
+		t.Errorf("Poor = %+v, want MAE and score 4", poor)
+	}
+	if ranks[2].Samples != 0 || ranks[2].Score != 0 || ranks[2].Reason == "" {
+		t.Errorf("unscored provider = %+v, want a zero score and a reason", ranks[2])
+	}
+}
+
+func TestRankWeighsPrecipitationMisses(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	issue := time.Now().Add(-6 * time.Hour).Truncate(time.Hour)
+	v.RecordForecast(models.ForecastData{
+		Provider:  "Dry",
+		Location:  "London,UK",
+		Updated:   issue,
+		Forecasts: []models.Forecast{{Temperature: 10, Timestamp: issue.Add(time.Hour)}},
+	})
+	v.RecordForecast(models.ForecastData{
+		Provider:  "Warm",
+		Location:  "London,UK",
+		Updated:   issue,
+		Forecasts: []models.Forecast{{Temperature: 12, Precipitation: 1, Timestamp: issue.Add(time.Hour)}},
+	})
+	v.RecordObservation(models.WeatherData{Location: "London,UK", Temperature: 10, Precipitation: 1, Timestamp: issue.Add(time.Hour)})
+	v.RecordObservation(models.WeatherData{Location: "London,UK", Temperature: 10, Timestamp: issue.Add(2 * time.Hour)})
+
+	// Missing the rain costs 3, more than Warm's 2°C error
+	ranks := v.Rank("London,UK", []string{"Dry", "Warm"}, 72*time.Hour)
+	if ranks[0].Provider != "Warm" || ranks[0].Score != 2 || ranks[1].Score != precipitationWeight {
+		t.Errorf("ranks = %+v, want Warm (2) ahead of Dry (3)", ranks)
+	}
+}
+
+func TestRankWeighsLeadTimeBucketsEqually(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	issue := time.Now().Add(-70 * time.Hour).Truncate(time.Hour)
+	leads := []time.Duration{1, 60, 61, 62, 63}
+	for provider, temperatures := range map[string][]float64{
+		"Sharp":  {10, 14, 14, 14, 14},
+		"Steady": {12.5, 12.5, 12.5, 12.5, 12.5},
+	} {
+		forecast := models.ForecastData{Provider: provider, Location: "London,UK", Updated: issue}
+		for i, lead := range leads {
+			forecast.Forecasts = append(forecast.Forecasts, models.Forecast{Temperature: temperatures[i], Timestamp: issue.Add(lead * time.Hour)})
+		}
+		v.RecordForecast(forecast)
+	}
+	for _, lead := range append(leads, 65) {
+		v.RecordObservation(models.WeatherData{Location: "London,UK", Temperature: 10, Timestamp: issue.Add(lead * time.Hour)})
+	}
+
+	// Pooled, Sharp's four long-lead errors would outweigh its perfect short range (MAE 3.2 against 2.5)
+	tests := []struct {
+		horizon time.Duration
+		best    string
+		score   float64
+	}{
+		{72 * time.Hour, "Sharp", 2},
+		{6 * time.Hour, "Sharp", 0},
+	}
+	for _, tt := range tests {
+		ranks := v.Rank("London,UK", []string{"Steady", "Sharp"}, tt.horizon)
+		if ranks[0].Provider != tt.best || ranks[0].Score != tt.score || ranks[1].Score != 2.5 {
+			t.Errorf("ranks up to %s = %+v, want %s scoring %v ahead of Steady's 2.5", tt.horizon, ranks, tt.best, tt.score)
+		}
+	}
+	if ranks := v.Rank("London,UK", []string{"Sharp"}, 6*time.Hour); ranks[0].Buckets != 1 || ranks[0].Samples != 1 {
+		t.Errorf("Sharp up to 6h = %+v, want only the first bucket", ranks[0])
+	}
+}
@@ -0,0 +1,412 @@
+package verification
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"weather-service/models"
+)
+
+// Options configures how forecasts are kept and matched against observations
+type Options struct {
+	Tolerance     time.Duration // maximum distance between a forecast point and the observation verifying it
+	IssueInterval time.Duration // minimum time between recorded issues per location and provider
+	Window        time.Duration // how long verification samples are kept
+	LeadBucket    time.Duration // width of the lead time buckets scores are grouped by
+	RainThreshold float64       // precipitation in mm at or above which a point counts as rain
+	RainChance    float64       // probability of precipitation (percentage) at or above which a forecast counts as rain
+}
+
+// DefaultOptions returns the options used when none are configured
+func DefaultOptions() Options {
+	return Options{
+		Tolerance:     30 * time.Minute,
+		IssueInterval: time.Hour,
+		Window:        30 * 24 * time.Hour,
+		LeadBucket:    6 * time.Hour,
+		RainThreshold: 0.1,
+		RainChance:    50,
+	}
+}
+
+// issuedForecast is a forecast as it was issued, with the closest observation seen so far for each point
+// and the points that have been verified
+type issuedForecast struct {
+	provider string
+	issuedAt time.Time
+	points   []models.Forecast
+	closest  []*models.WeatherData
+	verified []bool
+}
+
+// Sample is the outcome of verifying a single forecast point against an observation
+type Sample struct {
+	Location     string        `json:"location"`
+	Provider     string        `json:"provider"`
+	Lead         time.Duration `json:"lead"`
+	VerifiedAt   time.Time     `json:"verifiedAt"`
+	TempError    float64       `json:"tempError"` // forecast minus observed, in Celsius
+	RainForecast bool          `json:"rainForecast"`
+	RainObserved bool          `json:"rainObserved"`
+}
+
+// Verifier keeps issued forecasts and scores them once observations for their target times arrive
+type Verifier struct {
+	options Options
+	issued  map[string][]*issuedForecast // key is the normalized location
+	samples []Sample
+	mutex   sync.RWMutex
+}
+
+// NewVerifier creates a new forecast verifier
+func NewVerifier(options Options) *Verifier {
+	return &Verifier{
+		options: options,
+		issued:  make(map[string][]*issuedForecast),
+	}
+}
+
+// RecordForecast keeps an issued forecast so it can be verified later.
+// Issues arriving sooner than IssueInterval after the previous one from the same provider are skipped.
+func (v *Verifier) RecordForecast(data models.ForecastData) {
+	if len(data.Forecasts) == 0 {
+		return
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	location := models.NormalizeLocation(data.Location)
+	for _, existing := range v.issued[location] {
+		if existing.provider == data.Provider && data.Updated.Sub(existing.issuedAt) < v.options.IssueInterval {
+			return
+		}
+	}
+
+	points := make([]models.Forecast, len(data.Forecasts))
+	copy(points, data.Forecasts)
+
+	// Points in the past at issue time aren't forecasts
+	verified := make([]bool, len(points))
+	for i, point := range points {
+		verified[i] = point.Timestamp.Before(data.Updated)
+	}
+
+	v.issued[location] = append(v.issued[location], &issuedForecast{
+		provider: data.Provider,
+		issuedAt: data.Updated,
+		points:   points,
+		closest:  make([]*models.WeatherData, len(points)),
+		verified: verified,
+	})
+}
+
+// RecordObservation offers an observation, from any provider, to every pending forecast point within the
+// tolerance of its time at the observation's location. Each point keeps the closest observation offered, and is
+// scored against it once the observation's fetch time is past the point's tolerance window, so every forecast
+// of a location is verified against the same observations whichever provider issued it.
+func (v *Verifier) RecordObservation(obs models.WeatherData) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	location := models.NormalizeLocation(obs.Location)
+	observed := obs.ObservationTime()
+	for _, issue := range v.issued[location] {
+		for i, point := range issue.points {
+			if issue.verified[i] {
+				continue
+			}
+			distance := absDuration(point.Timestamp.Sub(observed))
+			if distance > v.options.Tolerance {
+				continue
+			}
+			if closest := issue.closest[i]; closest == nil || distance < absDuration(point.Timestamp.Sub(closest.ObservationTime())) {
+				candidate := obs
+				issue.closest[i] = &candidate
+			}
+		}
+	}
+
+	v.settle(obs.Timestamp)
+	v.prune(obs.Timestamp)
+}
+
+// settle scores every point whose tolerance window closed before now against the closest observation it got
+func (v *Verifier) settle(now time.Time) {
+	settled := len(v.samples)
+	for location, issues := range v.issued {
+		for _, issue := range issues {
+			for i, point := range issue.points {
+				if issue.verified[i] || now.Sub(point.Timestamp) <= v.options.Tolerance {
+					continue
+				}
+				issue.verified[i] = true
+
+				obs := issue.closest[i]
+				if obs == nil {
+					continue
+				}
+				issue.closest[i] = nil
+				v.samples = append(v.samples, Sample{
+					Location:     location,
+					Provider:     issue.provider,
+					Lead:         point.Timestamp.Sub(issue.issuedAt),
+					VerifiedAt:   obs.ObservationTime(),
+					TempError:    point.Temperature - obs.Temperature,
+					RainForecast: point.Precipitation >= v.options.RainThreshold || point.PrecipChance >= v.options.RainChance,
+					RainObserved: obs.Precipitation >= v.options.RainThreshold,
+				})
+			}
+		}
+	}
+
+	// Points from different issues close in no particular order; prune relies on samples sorted by time
+	if len(v.samples) > settled {
+		sort.SliceStable(v.samples, func(i, j int) bool {
+			return v.samples[i].VerifiedAt.Before(v.samples[j].VerifiedAt)
+		})
+	}
+}
+
+// absDuration returns the magnitude of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// prune drops forecasts whose points have all been settled and samples outside the window
+func (v *Verifier) prune(now time.Time) {
+	for location, issues := range v.issued {
+		kept := issues[:0]
+		for _, issue := range issues {
+			last := issue.points[len(issue.points)-1].Timestamp
+			if now.Sub(last) <= v.options.Tolerance {
+				kept = append(kept, issue)
+			}
+		}
+		if len(kept) == 0 {
+			delete(v.issued, location)
+		} else {
+			v.issued[location] = kept
+		}
+	}
+
+	cutoff := now.Add(-v.options.Window)
+	first := 0
+	for first < len(v.samples) && v.samples[first].VerifiedAt.Before(cutoff) {
+		first++
+	}
+	if first > 0 {
+		v.samples = append([]Sample(nil), v.samples[first:]...)
+	}
+}
+
+// Samples returns verification samples matching the filters; empty strings match everything, and locations
+// match in any spelling
+func (v *Verifier) Samples(location, provider string, since time.Time) []Sample {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	location = models.NormalizeLocation(location)
+	var matched []Sample
+	for _, s := range v.samples {
+		if location != "" && s.Location != location {
+			continue
+		}
+		if provider != "" && s.Provider != provider {
+			continue
+		}
+		if s.VerifiedAt.Before(since) {
+			continue
+		}
+		matched = append(matched, s)
+	}
+	return matched
+}
+
+// Stats aggregates verification scores by location, provider and lead time bucket.
+// Empty location or provider match everything; a negative lead matches every bucket.
+func (v *Verifier) Stats(location, provider string, lead time.Duration) []models.AccuracyStats {
+	return v.stats(location, provider, lead, time.Time{})
+}
+
+// stats aggregates the scores of samples verified since a time
+func (v *Verifier) stats(location, provider string, lead time.Duration, since time.Time) []models.AccuracyStats {
+	bucketWidth := v.options.LeadBucket
+	if bucketWidth <= 0 {
+		bucketWidth = DefaultOptions().LeadBucket
+	}
+
+	type groupKey struct {
+		location string
+		provider string
+		bucket   int
+	}
+	type totals struct {
+		absError    float64
+		error       float64
+		hits        int
+		misses      int
+		falseAlarms int
+		correct     int
+		count       int
+	}
+
+	groups := make(map[groupKey]*totals)
+	for _, s := range v.Samples(location, provider, since) {
+		bucket := int(s.Lead / bucketWidth)
+		if lead >= 0 && bucket != int(lead/bucketWidth) {
+			continue
+		}
+
+		key := groupKey{location: s.Location, provider: s.Provider, bucket: bucket}
+		t, exists := groups[key]
+		if !exists {
+			t = &totals{}
+			groups[key] = t
+		}
+		t.absError += math.Abs(s.TempError)
+		t.error += s.TempError
+		t.count++
+		switch {
+		case s.RainForecast && s.RainObserved:
+			t.hits++
+		case s.RainObserved:
+			t.misses++
+		case s.RainForecast:
+			t.falseAlarms++
+		}
+		if s.RainForecast == s.RainObserved {
+			t.correct++
+		}
+	}
+
+	bucketHours := int(bucketWidth / time.Hour)
+	stats := make([]models.AccuracyStats, 0, len(groups))
+	for key, t := range groups {
+		stats = append(stats, models.AccuracyStats{
+			Location:      key.location,
+			Provider:      key.provider,
+			LeadFromHours: key.bucket * bucketHours,
+			LeadToHours:   (key.bucket + 1) * bucketHours,
+			Temperature: models.TemperatureScore{
+				MAE:     t.absError / float64(t.count),
+				Bias:    t.error / float64(t.count),
+				Samples: t.count,
+			},
+			Precipitation: models.PrecipitationScore{
+				HitRate:           ratio(t.hits, t.hits+t.misses),
+				FalseAlarmRatio:   ratio(t.falseAlarms, t.hits+t.falseAlarms),
+				ProportionCorrect: float64(t.correct) / float64(t.count),
+				Hits:              t.hits,
+				Misses:            t.misses,
+				FalseAlarms:       t.falseAlarms,
+				Samples:           t.count,
+			},
+		})
+	}
+
+	// Sort for a stable response order
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Location != b.Location {
+			return a.Location < b.Location
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.LeadFromHours < b.LeadFromHours
+	})
+
+	return stats
+}
+
+// issueSnapshot is the saved form of an issued forecast
+type issueSnapshot struct {
+	Location string                `json:"location"`
+	Provider string                `json:"provider"`
+	IssuedAt time.Time             `json:"issuedAt"`
+	Points   []models.Forecast     `json:"points"`
+	Closest  []*models.WeatherData `json:"closest"`
+	Verified []bool                `json:"verified"`
+}
+
+// verifierSnapshot is the saved form of a verifier
+type verifierSnapshot struct {
+	Issued  []issueSnapshot `json:"issued"`
+	Samples []Sample        `json:"samples"`
+}
+
+// ratio returns n/d, or nil if d is zero
+func ratio(n, d int) *float64 {
+	if d == 0 {
+		return nil
+	}
+	r := float64(n) / float64(d)
+	return &r
+}
+
+// Snapshot encodes the pending forecasts and the verification samples as JSON
+func (v *Verifier) Snapshot() (json.RawMessage, error) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	snapshot := verifierSnapshot{Issued: []issueSnapshot{}, Samples: v.samples}
+	if snapshot.Samples == nil {
+		snapshot.Samples = []Sample{}
+	}
+	for location, issues := range v.issued {
+		for _, issue := range issues {
+			snapshot.Issued = append(snapshot.Issued, issueSnapshot{
+				Location: location,
+				Provider: issue.provider,
+				IssuedAt: issue.issuedAt,
+				Points:   issue.points,
+				Closest:  issue.closest,
+				Verified: issue.verified,
+			})
+		}
+	}
+	return json.Marshal(snapshot)
+}
+
+// Restore replaces the pending forecasts and the verification samples with a snapshot
+func (v *Verifier) Restore(data json.RawMessage) error {
+	var snapshot verifierSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse verification snapshot: %w", err)
+	}
+
+	issued := make(map[string][]*issuedForecast)
+	for _, issue := range snapshot.Issued {
+		if len(issue.Points) == 0 || len(issue.Closest) != len(issue.Points) || len(issue.Verified) != len(issue.Points) {
+			return fmt.Errorf("invalid verification snapshot: forecast from %s for %s has mismatched points", issue.Provider, issue.Location)
+		}
+		location := models.NormalizeLocation(issue.Location)
+		issued[location] = append(issued[location], &issuedForecast{
+			provider: issue.Provider,
+			issuedAt: issue.IssuedAt,
+			points:   issue.Points,
+			closest:  issue.Closest,
+			verified: issue.Verified,
+		})
+	}
+
+	// prune relies on samples sorted by time
+	samples := snapshot.Samples
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].VerifiedAt.Before(samples[j].VerifiedAt)
+	})
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.issued = issued
+	v.samples = samples
+	return nil
+}
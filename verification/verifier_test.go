@@ -0,0 +1,209 @@
+package verification
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"weather-service/models"
+)
+
+var issued = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+// forecast returns a forecast issued at issued with one point per temperature, hourly from hour 1
+func forecast(provider, location string, temperatures ...float64) models.ForecastData {
+	data := models.ForecastData{Provider: provider, Location: location, Updated: issued}
+	for i, temperature := range temperatures {
+		data.Forecasts = append(data.Forecasts, models.Forecast{
+			Temperature: temperature,
+			Timestamp:   issued.Add(time.Duration(i+1) * time.Hour),
+		})
+	}
+	return data
+}
+
+// observe offers an observation made at a time
+func observe(v *Verifier, location string, at time.Time, temperature, precipitation float64) {
+	v.RecordObservation(models.WeatherData{
+		Provider:      "Observer",
+		Location:      location,
+		Temperature:   temperature,
+		Precipitation: precipitation,
+		Timestamp:     at,
+	})
+}
+
+func TestVerifierUsesClosestObservation(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	v.RecordForecast(forecast("A", "London,UK", 10))
+	target := issued.Add(time.Hour)
+
+	observe(v, "London,UK", target.Add(-20*time.Minute), 5, 0)
+	observe(v, "London,UK", target.Add(5*time.Minute), 8, 0)
+	if samples := v.Samples("", "", time.Time{}); len(samples) != 0 {
+		t.Fatalf("point verified before its tolerance window closed: %+v", samples)
+	}
+
+	// An observation after the window closes settles the point against the closest one
+	observe(v, "London,UK", target.Add(40*time.Minute), 0, 0)
+	samples := v.Samples("", "", time.Time{})
+	if len(samples) != 1 {
+		t.Fatalf("got %d samples, want 1", len(samples))
+	}
+	s := samples[0]
+	if s.TempError != 2 || s.Lead != time.Hour || s.Provider != "A" || s.Location != "london,uk" {
+		t.Errorf("sample = %+v, want error 2 at 1h lead for A in london,uk", s)
+	}
+	if !s.VerifiedAt.Equal(target.Add(5 * time.Minute)) {
+		t.Errorf("verified at %s, want the closest observation's time", s.VerifiedAt)
+	}
+}
+
+func TestVerifierMatchesLocationSpellings(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	v.RecordForecast(forecast("A", "London,UK", 10))
+	v.RecordForecast(forecast("B", "london, uk", 12))
+	target := issued.Add(time.Hour)
+
+	observe(v, " LONDON,UK ", target, 11, 0)
+	observe(v, "Paris,FR", target, 30, 0)
+	observe(v, "London,UK", target.Add(time.Hour), 11, 0)
+
+	samples := v.Samples("London, UK", "", time.Time{})
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want one per provider", len(samples))
+	}
+	for _, s := range samples {
+		if math.Abs(s.TempError) != 1 {
+			t.Errorf("%s error = %v, want ±1 against the London observation", s.Provider, s.TempError)
+		}
+	}
+}
+
+func TestVerifierSkipsFrequentIssuesAndPastPoints(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	v.RecordForecast(forecast("A", "London,UK", 10))
+	again := forecast("A", "London,UK", 20)
+	again.Updated = issued.Add(10 * time.Minute)
+	v.RecordForecast(again)
+
+	// A point already past when the forecast was issued isn't a forecast
+	past := forecast("B", "London,UK", 10)
+	past.Forecasts[0].Timestamp = issued.Add(-time.Hour)
+	v.RecordForecast(past)
+
+	observe(v, "London,UK", issued.Add(time.Hour), 10, 0)
+	observe(v, "London,UK", issued.Add(-time.Hour), 10, 0)
+	observe(v, "London,UK", issued.Add(3*time.Hour), 10, 0)
+
+	samples := v.Samples("", "", time.Time{})
+	if len(samples) != 1 || samples[0].Provider != "A" || samples[0].TempError != 0 {
+		t.Errorf("samples = %+v, want only the first issue from A", samples)
+	}
+}
+
+func TestVerifierDropsSamplesOutsideWindow(t *testing.T) {
+	options := DefaultOptions()
+	options.Window = 24 * time.Hour
+	v := NewVerifier(options)
+	v.RecordForecast(forecast("A", "London,UK", 10))
+	observe(v, "London,UK", issued.Add(time.Hour), 10, 0)
+	observe(v, "London,UK", issued.Add(2*time.Hour), 10, 0)
+	if len(v.Samples("", "", time.Time{})) != 1 {
+		t.Fatal("expected a sample")
+	}
+
+	observe(v, "London,UK", issued.Add(26*time.Hour), 10, 0)
+	if samples := v.Samples("", "", time.Time{}); len(samples) != 0 {
+		t.Errorf("samples older than the window should be dropped, got %+v", samples)
+	}
+}
+
+func TestStats(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	rainy := forecast("A", "London,UK", 10, 10, 10)
+	rainy.Forecasts[0].PrecipChance = 80
+	rainy.Forecasts[1].Precipitation = 2
+	v.RecordForecast(rainy)
+
+	// Errors of +2, -1 and 0; rain forecast and observed, forecast but not observed, then neither
+	observe(v, "London,UK", issued.Add(time.Hour), 8, 1)
+	observe(v, "London,UK", issued.Add(2*time.Hour), 11, 0)
+	observe(v, "London,UK", issued.Add(3*time.Hour), 10, 0)
+	observe(v, "London,UK", issued.Add(4*time.Hour), 10, 0)
+
+	stats := v.Stats("London,UK", "A", -1)
+	if len(stats) != 1 {
+		t.Fatalf("got %d groups, want 1", len(stats))
+	}
+	s := stats[0]
+	if s.LeadFromHours != 0 || s.LeadToHours != 6 || s.Temperature.Samples != 3 {
+		t.Errorf("group = %+v", s)
+	}
+	if math.Abs(s.Temperature.MAE-1) > 1e-9 || math.Abs(s.Temperature.Bias-1.0/3) > 1e-9 {
+		t.Errorf("MAE %v and bias %v, want 1 and 1/3", s.Temperature.MAE, s.Temperature.Bias)
+	}
+	p := s.Precipitation
+	if p.Hits != 1 || p.Misses != 0 || p.FalseAlarms != 1 || math.Abs(p.ProportionCorrect-2.0/3) > 1e-9 {
+		t.Errorf("precipitation = %+v, want a hit, a false alarm and 2 of 3 correct", p)
+	}
+	if p.HitRate == nil || *p.HitRate != 1 || p.FalseAlarmRatio == nil || *p.FalseAlarmRatio != 0.5 {
+		t.Errorf("hit rate %v and false alarm ratio %v, want 1 and 0.5", p.HitRate, p.FalseAlarmRatio)
+	}
+
+	// Dry points that were forecast dry are correct but aren't hits
+	dryVerifier := NewVerifier(DefaultOptions())
+	dryVerifier.RecordForecast(forecast("A", "Paris,FR", 20, 20))
+	for hour := 1; hour <= 3; hour++ {
+		observe(dryVerifier, "Paris,FR", issued.Add(time.Duration(hour)*time.Hour), 20, 0)
+	}
+	dry := dryVerifier.Stats("Paris,FR", "A", -1)
+	if len(dry) != 1 || dry[0].Precipitation.HitRate != nil || dry[0].Precipitation.FalseAlarmRatio != nil ||
+		dry[0].Precipitation.ProportionCorrect != 1 {
+		t.Errorf("dry stats = %+v, want no rates and every point correct", dry)
+	}
+
+	if other := v.Stats("London,UK", "A", 12*time.Hour); len(other) != 0 {
+		t.Errorf("12h lead bucket should be empty, got %+v", other)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	v := NewVerifier(DefaultOptions())
+	v.RecordForecast(forecast("A", "London,UK", 10, 20))
+	observe(v, "London,UK", issued.Add(time.Hour), 8, 0)
+	observe(v, "London,UK", issued.Add(2*time.Hour), 18, 0)
+
+	data, err := v.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewVerifier(DefaultOptions())
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// The settled first point is kept as a sample, and a forecast issued again within the issue interval of
+	// the restored one is still skipped
+	if samples := restored.Samples("", "", time.Time{}); len(samples) != 1 || samples[0].TempError != 2 || samples[0].Lead != time.Hour {
+		t.Fatalf("restored samples = %+v, want the first point's", samples)
+	}
+	restored.RecordForecast(forecast("A", "London,UK", 10))
+	if issues := restored.issued["london,uk"]; len(issues) != 1 {
+		t.Errorf("restored %d issues, want the pending one with the repeat skipped", len(issues))
+	}
+
+	// The pending second point still has its observation
+	observe(restored, "London,UK", issued.Add(3*time.Hour), 0, 0)
+	samples := restored.Samples("", "", time.Time{})
+	if len(samples) != 2 || samples[1].TempError != 2 || samples[1].Lead != 2*time.Hour {
+		t.Errorf("samples after restoring = %+v, want the second point verified against its earlier observation", samples)
+	}
+
+	if err := restored.Restore([]byte(`{"issued":[{"location":"x","points":[{}],"closest":[],"verified":[]}]}`)); err == nil {
+		t.Error("Restore accepted a forecast with mismatched points")
+	}
+	if err := restored.Restore([]byte(`{`)); err == nil {
+		t.Error("Restore accepted malformed JSON")
+	}
+}